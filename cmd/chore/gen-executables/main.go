@@ -5,12 +5,16 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 
+	"golang.org/x/crypto/ssh"
+
 	"github.com/caos/orbos/internal/executables"
+	"github.com/caos/orbos/internal/operator/common"
 )
 
 func main() {
@@ -24,6 +28,7 @@ func main() {
 	dev := flag.Bool("dev", false, "Compile executables with debugging features enabled")
 	containeronly := flag.Bool("containeronly", false, "Compile orbctl binaries only for in-container usage")
 	hostBinsOnly := flag.Bool("host-bins-only", false, "Build only daemon binaries running on host machines")
+	releaseSigningKey := flag.String("releasesigningkey", "", "Path to the private key which signs the node agent for self-updates")
 
 	flag.Parse()
 
@@ -49,19 +54,33 @@ func main() {
 		executables.Buildable{OutDir: filepath.Join(*orbctldir, "health"), MainDir: path("health"), Env: map[string]string{"GOOS": "linux", "GOARCH": "amd64", "CGO_ENABLED": "0"}},
 	)
 
+	var hostBins []executables.BuiltTuple
+	for bin := range builtExecutables {
+		hostBins = append(hostBins, bin)
+	}
+
 	if *hostBinsOnly {
-		for range builtExecutables {
-		}
 		return
 	}
 
-	packableExecutables := executables.PackableBuilds(builtExecutables)
-
-	packableFiles := executables.PackableFiles(toChan([]string{
+	files := []string{
 		filepath.Join(cmdPath, "../internal/operator/orbiter/kinds/clusters/kubernetes/networks/calico.yaml"),
 		filepath.Join(cmdPath, "../internal/operator/orbiter/kinds/clusters/kubernetes/networks/cilium.yaml"),
 		filepath.Join(cmdPath, "../internal/operator/orbiter/kinds/providers/gce/kubernetes_gce.yaml"),
-	}))
+	}
+
+	// Without a release signing key, ORBITER can't self-update node agents
+	if *releaseSigningKey != "" {
+		signatureFiles, err := signNodeAgent(*orbctldir, *releaseSigningKey, *version, *commit)
+		if err != nil {
+			panic(err)
+		}
+		files = append(files, signatureFiles...)
+	}
+
+	packableExecutables := executables.PackableBuilds(toBuiltChan(hostBins))
+
+	packableFiles := executables.PackableFiles(toChan(files))
 
 	if err := executables.PreBuild(deriveJoinPackables(packableExecutables, packableFiles)); err != nil {
 		panic(err)
@@ -99,6 +118,42 @@ func main() {
 	}
 }
 
+// signNodeAgent writes the node agents release signature and the public release signing key to the orbctl directory
+func signNodeAgent(orbctldir, releaseSigningKeyPath, version, commit string) ([]string, error) {
+
+	key, err := ioutil.ReadFile(releaseSigningKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading release signing key failed: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("parsing release signing key failed: %w", err)
+	}
+
+	nodeagent, err := ioutil.ReadFile(filepath.Join(orbctldir, "nodeagent"))
+	if err != nil {
+		return nil, fmt.Errorf("reading node agent binary failed: %w", err)
+	}
+
+	signature, err := common.SignNodeAgent(version, commit, nodeagent, key)
+	if err != nil {
+		return nil, err
+	}
+
+	signaturePath := filepath.Join(orbctldir, common.NodeAgentSignature)
+	if err := ioutil.WriteFile(signaturePath, []byte(signature), 0644); err != nil {
+		return nil, err
+	}
+
+	publicKeyPath := filepath.Join(orbctldir, common.ReleaseSigningKey)
+	if err := ioutil.WriteFile(publicKeyPath, ssh.MarshalAuthorizedKey(signer.PublicKey()), 0644); err != nil {
+		return nil, err
+	}
+
+	return []string{signaturePath, publicKeyPath}, nil
+}
+
 func orbctlBin(mainPath, outPath, goos, goarch string) executables.Buildable {
 
 	arch := "x86_64"
@@ -142,3 +197,14 @@ func toChan(args []string) <-chan string {
 	}()
 	return ch
 }
+
+func toBuiltChan(bins []executables.BuiltTuple) <-chan executables.BuiltTuple {
+	ch := make(chan executables.BuiltTuple)
+	go func() {
+		for _, bin := range bins {
+			ch <- bin
+		}
+		close(ch)
+	}()
+	return ch
+}
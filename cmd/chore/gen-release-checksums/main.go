@@ -0,0 +1,99 @@
+// gen-release-checksums pins the sha256 checksums of the releases the node agents install on immutable operating systems.
+// Review the generated file like any other code change, as the node agents only install downloads matching it.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes"
+)
+
+func main() {
+
+	out := flag.String("out", "package-manager-checksums.go", "File to write the pinned checksums to")
+	arch := flag.String("arch", "amd64", "Architecture of the node agents")
+	flag.Parse()
+
+	if err := generate(*out, *arch); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func generate(out, arch string) error {
+
+	client := &http.Client{Timeout: time.Minute}
+
+	checksums := make(map[string]string)
+	for pkg, versions := range kubernetes.ReleasedVersions() {
+		urls, err := dep.ReleaseChecksumURLs(pkg, versions, arch)
+		if err != nil {
+			return err
+		}
+		for url, checksumURL := range urls {
+			checksum, err := download(client, checksumURL)
+			if err != nil {
+				return err
+			}
+			checksums[url] = checksum
+		}
+	}
+
+	urls := make([]string, 0, len(checksums))
+	for url := range checksums {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	src := new(bytes.Buffer)
+	src.WriteString(`// Code generated by cmd/chore/gen-release-checksums. DO NOT EDIT.
+
+package dep
+
+// releaseChecksums maps the download urls of releases to their sha256 checksums
+var releaseChecksums = map[string]string{
+`)
+	for _, url := range urls {
+		fmt.Fprintf(src, "%q: %q,\n", url, checksums[url])
+	}
+	src.WriteString("}\n")
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, formatted, 0644)
+}
+
+// download returns the hex encoded sha256 sum of a checksum file, which is either the sum only or a sha256sum output
+func download(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("downloading %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading %s failed with status %s", url, resp.Status)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading %s failed: %w", url, err)
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum %s is empty", url)
+	}
+	return strings.ToLower(fields[0]), nil
+}
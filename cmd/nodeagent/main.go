@@ -22,9 +22,14 @@ import (
 	_ "net/http/pprof"
 
 	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/custom"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep/conv"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/envoy"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/haproxy"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/nginx"
 	"github.com/caos/orbos/internal/operator/nodeagent/firewall"
+	"github.com/caos/orbos/internal/operator/nodeagent/metrics"
 )
 
 var (
@@ -49,7 +54,11 @@ func main() {
 	ignorePorts := flag.String("ignore-ports", "", "Comma separated list of firewall ports that are ignored")
 	nodeAgentID := flag.String("id", "", "The managed machines ID")
 	pprof := flag.Bool("pprof", false, "start pprof as port 6060")
+	metricsPort := flag.Int("metrics-port", metrics.Port, "Port where Prometheus metrics are served, 0 disables them")
 	sentryEnvironment := flag.String("environment", "", "Sentry environment")
+	printStatus := flag.Bool("status", false, "Print the status of the running node agent")
+	rollback := flag.Bool("rollback", false, "Restore the previous binary if the last self-update was not confirmed")
+	repositoryDir := flag.String("repository-dir", "", "Directory where the repository is kept between iterations and restarts, empty keeps it in memory")
 
 	flag.Parse()
 
@@ -58,6 +67,24 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *printStatus {
+		status, err := nodeagent.QueryStatus()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(string(status))
+		os.Exit(0)
+	}
+
+	if *rollback {
+		if err := nodeagent.Rollback(monitor); err != nil {
+			monitor.Error(fmt.Errorf("rolling back failed: %w", err))
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if *sentryEnvironment != "" {
 		if err := mntr.Ingest(monitor, "orbos", version, *sentryEnvironment, "node-agent"); err != nil {
 			panic(err)
@@ -105,6 +132,23 @@ func main() {
 		}()
 	}
 
+	if *metricsPort > 0 {
+		nginxCollector, err := nginx.Collector(ctx, monitor)
+		if err != nil {
+			panic(err)
+		}
+		go func() {
+			monitor.Error(metrics.Serve(*metricsPort, nginxCollector, haproxy.Collector(monitor), envoy.Collector(monitor)))
+		}()
+	}
+
+	status := nodeagent.NewStatusRecorder(*nodeAgentID, gitCommit)
+	go func() {
+		if err := nodeagent.ServeStatus(ctx, monitor, status); err != nil {
+			monitor.Error(fmt.Errorf("serving status failed: %w", err))
+		}
+	}()
+
 	runningOnOS, err := dep.GetOperatingSystem()
 	if err != nil {
 		panic(err)
@@ -120,6 +164,9 @@ func main() {
 	conv := conv.New(ctx, monitor, runningOnOS, fmt.Sprintf("%x", hashed[:]))
 
 	gitClient := git.New(ctx, monitor, fmt.Sprintf("Node Agent %s", *nodeAgentID), "node-agent@caos.ch")
+	if *repositoryDir != "" {
+		gitClient.StoreIn(*repositoryDir)
+	}
 
 	var portsSlice []string
 	if len(*ignorePorts) > 0 {
@@ -129,12 +176,15 @@ func main() {
 	itFunc := nodeagent.Iterator(
 		monitor,
 		gitClient,
+		version,
 		gitCommit,
 		*nodeAgentID,
-		firewall.Ensurer(monitor, runningOnOS.OperatingSystem, portsSlice),
-		networking.Ensurer(monitor, runningOnOS.OperatingSystem),
+		firewall.Ensurer(monitor, runningOnOS, portsSlice),
+		networking.Ensurer(monitor, runningOnOS),
+		custom.Ensurer(monitor, dep.NewSystemD(monitor)),
 		conv,
-		conv.Init())
+		conv.Init(),
+		status)
 
 	type updateType struct{}
 	go func() {
@@ -2,11 +2,14 @@ package cmds
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/caos/orbos/pkg/cfg"
 	"github.com/caos/orbos/pkg/kubernetes/cli"
 
 	orbcfg "github.com/caos/orbos/pkg/orb"
@@ -30,6 +33,7 @@ func Takeoff(
 	gitCommit string,
 	kubeconfig string,
 	gitOps bool,
+	plan bool,
 	operators []string,
 ) error {
 
@@ -55,12 +59,19 @@ func Takeoff(
 				OrbConfigPath: orbConfig.Path,
 				GitCommit:     gitCommit,
 			}
+			if plan {
+				return PrintOrbiterPlan(monitor, orbiterConfig, gitClient)
+			}
 			if err := ctrlgitops.Orbiter(ctx, monitor, orbiterConfig, gitClient); err != nil {
 				return err
 			}
 		}
 	}
 
+	if plan {
+		return mntr.ToUserError(errors.New("planning is only supported for ORBITER in gitops mode"))
+	}
+
 	if !deploy {
 		monitor.Info("Skipping operator deployments")
 		return nil
@@ -84,13 +95,7 @@ func Takeoff(
 	}
 
 	if gitOps {
-
-		orbConfigBytes, err := yaml.Marshal(orbConfig)
-		if err != nil {
-			return err
-		}
-
-		if err := kubernetes.EnsureOrbconfigSecret(monitor, k8sClient, orbConfigBytes); err != nil {
+		if err := cfg.ApplyOrbconfigSecret(orbConfig, gitClient, k8sClient, monitor); err != nil {
 			return fmt.Errorf("failed to apply configuration resources into k8s-cluster: %w", err)
 		}
 	}
@@ -106,6 +111,24 @@ func Takeoff(
 	return nil
 }
 
+// PrintOrbiterPlan writes what ORBITER would change to stdout
+func PrintOrbiterPlan(monitor mntr.Monitor, conf *ctrlgitops.OrbiterConfig, gitClient *git.Client) error {
+	plan, err := ctrlgitops.OrbiterPlan(monitor, conf, gitClient)
+	if err != nil {
+		return err
+	}
+
+	if plan.Empty() {
+		fmt.Println("ORBITER would not change anything")
+		return nil
+	}
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(plan)
+}
+
 func deployOperator(arguments []string, operator string) bool {
 	if len(arguments) == 0 {
 		return true
@@ -75,6 +75,7 @@ func ConfigCommand(getRv GetRootValues) *cobra.Command {
 		if !uninitialized {
 			if err := cfg.ApplyOrbconfigSecret(
 				rv.OrbConfig,
+				rv.GitClient,
 				k8sClient,
 				rv.Monitor,
 			); err != nil {
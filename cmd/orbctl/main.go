@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
 )
 
 var (
@@ -25,6 +26,8 @@ func main() {
 
 	defer func() { monitor.RecoverPanic(recover()) }()
 
+	secret.LocalRefs = true
+
 	rootCmd, getRootValues := RootCommand()
 	rootCmd.Version = fmt.Sprintf("%s %s\n", version, gitCommit)
 
@@ -47,9 +50,26 @@ func main() {
 		ReplaceCommand(getRootValues),
 		RebootCommand(getRootValues),
 		ExecCommand(getRootValues),
+		StatusCommand(getRootValues),
 		ListCommand(getRootValues),
 	)
 
+	restore := RestoreCommand()
+	restore.AddCommand(
+		RestoreEtcdCommand(getRootValues),
+	)
+
+	rotate := RotateCommand()
+	rotate.AddCommand(
+		RotateMasterkeyCommand(getRootValues),
+		RotateRecipientsCommand(getRootValues),
+	)
+
+	secrets := SecretsCommand()
+	secrets.AddCommand(
+		SecretsReportCommand(getRootValues),
+	)
+
 	rootCmd.AddCommand(
 		ReadSecretCommand(getRootValues),
 		WriteSecretCommand(getRootValues),
@@ -60,6 +80,9 @@ func main() {
 		file,
 		start,
 		nodes,
+		restore,
+		rotate,
+		secrets,
 	)
 
 	if err := rootCmd.Execute(); err != nil {
@@ -19,7 +19,7 @@ func NodeCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:     "node [id] command",
 		Short:   "Work with an orbs node",
-		Example: `orbctl node <exec|reboot|replace> `,
+		Example: `orbctl node <exec|reboot|replace|status> `,
 		Aliases: []string{"nodes", "machine", "machines"},
 		Args:    cobra.MinimumNArgs(1),
 	}
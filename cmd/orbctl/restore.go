@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/caos/orbos/cmd/orbctl/cmds"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/etcd"
+	orbadapter "github.com/caos/orbos/internal/operator/orbiter/kinds/orb"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+func RestoreCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "restore",
+		Short:   "Restore backups",
+		Example: `orbctl restore etcd --snapshot <id>`,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func RestoreEtcdCommand(getRv GetRootValues) *cobra.Command {
+	var (
+		snapshot string
+		cmd      = &cobra.Command{
+			Use:   "etcd",
+			Short: "Rebuild the control plane from an etcd snapshot",
+			Long:  "All control plane machines are reset. Then ORBITER initializes the control plane from the snapshot and joins the remaining control plane machines. Omit the --snapshot flag for selecting a snapshot interactively",
+			Args:  cobra.NoArgs,
+		}
+	)
+
+	flags := cmd.Flags()
+	flags.StringVar(&snapshot, "snapshot", "", "ID of the etcd snapshot to restore")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+
+		rv := getRv("restore", "etcd", map[string]interface{}{"snapshot": snapshot})
+		defer rv.ErrFunc(err)
+
+		if !rv.Gitops {
+			return mntr.ToUserError(errors.New("restore command is only supported with the --gitops flag and a committed orbiter.yml"))
+		}
+
+		var restore bool
+		if err := machines(monitor, rv.GitClient, rv.OrbConfig, func(_ []string, machines map[string]infra.Machine, desired *tree.Tree) error {
+
+			clusterID, cluster, err := etcdBackupCluster(desired)
+			if err != nil {
+				return err
+			}
+
+			store, err := etcd.NewStore(cluster.Spec.EtcdBackup, rv.GitClient, clusterID)
+			if err != nil {
+				return err
+			}
+
+			snapshots, err := store.List()
+			if err != nil {
+				return err
+			}
+
+			if len(snapshots) == 0 {
+				return mntr.ToUserError(fmt.Errorf("no etcd snapshots found for cluster %s", clusterID))
+			}
+
+			if snapshot == "" {
+				options := make([]string, len(snapshots))
+				for idx := range snapshots {
+					options[idx] = snapshots[len(snapshots)-1-idx]
+				}
+				if err := survey.AskOne(&survey.Select{
+					Message: "Select a snapshot:",
+					Options: options,
+				}, &snapshot, survey.WithValidator(survey.Required)); err != nil {
+					return err
+				}
+			}
+
+			if !contains(snapshots, snapshot) {
+				return mntr.ToUserError(fmt.Errorf("etcd snapshot %s not found for cluster %s", snapshot, clusterID))
+			}
+
+			fmt.Printf("Are you absolutely sure you want to reset all control plane machines of cluster %s and restore etcd snapshot %s? [y/N]\n", clusterID, snapshot)
+			var response string
+			fmt.Scanln(&response)
+
+			if !contains([]string{"y", "yes"}, strings.ToLower(response)) {
+				monitor.Info("Not touching cluster")
+				return nil
+			}
+
+			controlplanePrefix := fmt.Sprintf("%s.%s.", cluster.Spec.ControlPlane.Provider, cluster.Spec.ControlPlane.Pool)
+			for id, machine := range machines {
+				if !strings.HasPrefix(id, controlplanePrefix) {
+					continue
+				}
+				machineMonitor := monitor.WithField("machine", id)
+				if _, err := machine.Execute(nil, "sudo kubeadm reset -f && sudo rm -rf /var/lib/etcd"); err != nil {
+					machineMonitor.Info(fmt.Sprintf("Resetting control plane machine failed, it is probably not reachable: %s", err.Error()))
+					continue
+				}
+				machineMonitor.Info("Control plane machine reset")
+			}
+
+			cluster.Spec.EtcdBackup.Restore = snapshot
+			cluster.Spec.Kubeconfig = nil
+			restore = true
+			return rv.GitClient.PushDesiredFunc(git.OrbiterFile, desired)(monitor)
+		}); err != nil || !restore {
+			return err
+		}
+
+		return cmds.Takeoff(
+			monitor,
+			rv.Ctx,
+			rv.OrbConfig,
+			rv.GitClient,
+			false,
+			true,
+			false,
+			version,
+			gitCommit,
+			rv.Kubeconfig,
+			rv.Gitops,
+			false,
+			[]string{"orbiter"},
+		)
+	}
+	return cmd
+}
+
+func etcdBackupCluster(desired *tree.Tree) (string, *kubernetes.DesiredV0, error) {
+
+	orbDesired, ok := desired.Parsed.(*orbadapter.DesiredV0)
+	if !ok {
+		return "", nil, errors.New("parsing orbiter.yml failed")
+	}
+
+	var (
+		clusterID string
+		cluster   *kubernetes.DesiredV0
+	)
+	for id, clusterTree := range orbDesired.Clusters {
+		k8sDesired, ok := clusterTree.Parsed.(*kubernetes.DesiredV0)
+		if !ok || k8sDesired.Spec.EtcdBackup == nil {
+			continue
+		}
+		if cluster != nil {
+			return "", nil, mntr.ToUserError(errors.New("restoring etcd is only supported for orbs with a single cluster with etcdbackup configured"))
+		}
+		clusterID, cluster = id, k8sDesired
+	}
+
+	if cluster == nil {
+		return "", nil, mntr.ToUserError(errors.New("no cluster with etcdbackup configured found"))
+	}
+	return clusterID, cluster, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/cfg"
+	"github.com/caos/orbos/pkg/git"
+	"github.com/caos/orbos/pkg/kubernetes"
+	"github.com/caos/orbos/pkg/kubernetes/cli"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+func RotateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "rotate",
+		Short:   "Rotate keys",
+		Example: `orbctl --gitops rotate masterkey`,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func RotateMasterkeyCommand(getRv GetRootValues) *cobra.Command {
+	var (
+		newMasterkey string
+		cmd          = &cobra.Command{
+			Use:   "masterkey",
+			Short: "Reencrypt all secrets with a new masterkey",
+			Long:  "All secrets in the repository are reencrypted with the new masterkey in a single commit. Then, the orbconfig file and the orbconfig kubernetes secret are updated. Omit the --masterkey flag for generating a random masterkey",
+			Args:  cobra.NoArgs,
+		}
+	)
+
+	flags := cmd.Flags()
+	flags.StringVar(&newMasterkey, "masterkey", "", "New masterkey")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+
+		rv := getRv("rotate", "masterkey", map[string]interface{}{"masterkey": newMasterkey != ""})
+		defer rv.ErrFunc(err)
+
+		if !rv.Gitops {
+			return mntr.ToUserError(errors.New("rotate command is only supported with the --gitops flag"))
+		}
+
+		if newMasterkey == "" {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return err
+			}
+			newMasterkey = base64.RawURLEncoding.EncodeToString(key)
+		}
+
+		if newMasterkey == rv.OrbConfig.Masterkey {
+			return mntr.ToUserError(errors.New("new masterkey must differ from the current masterkey"))
+		}
+
+		k8sClient, err := initRotation(rv)
+		if err != nil {
+			return err
+		}
+
+		if rv.OrbConfig.Keys.Configured() {
+			return mntr.ToUserError(errors.New("secrets are encrypted for recipients, use orbctl rotate recipients instead"))
+		}
+
+		reencrypt, err := cfg.ReencryptSecrets(monitor, rv.OrbConfig, rv.GitClient, k8sClient, "Rotated masterkey")
+		if err != nil {
+			return err
+		}
+
+		if err := rv.OrbConfig.RotateMasterkey(newMasterkey, func() error {
+			return secret.Rewrite(newMasterkey, reencrypt)
+		}); err != nil {
+			return err
+		}
+		monitor.Info("Secrets reencrypted and orbconfig written")
+
+		return cfg.ApplyOrbconfigSecret(rv.OrbConfig, rv.GitClient, k8sClient, monitor)
+	}
+	return cmd
+}
+
+func RotateRecipientsCommand(getRv GetRootValues) *cobra.Command {
+	var (
+		add, remove []string
+		cmd         = &cobra.Command{
+			Use:     "recipients",
+			Short:   "Change the recipients and reencrypt all secrets for them",
+			Long:    "All secrets in the repository are decrypted with the identity in the orbconfig and reencrypted for its recipients and vault transit key in a single commit. Recipients passed with --add and --remove are changed in the orbconfig and the orbconfig kubernetes secret. If the orbconfig has no recipients yet, the recipient of its identity is added",
+			Example: `orbctl --gitops rotate recipients --add age1... --remove age1...`,
+			Args:    cobra.NoArgs,
+		}
+	)
+
+	flags := cmd.Flags()
+	flags.StringSliceVar(&add, "add", nil, "Recipients to encrypt the secrets for")
+	flags.StringSliceVar(&remove, "remove", nil, "Recipients to not encrypt the secrets for anymore")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+
+		rv := getRv("rotate", "recipients", map[string]interface{}{"add": len(add), "remove": len(remove)})
+		defer rv.ErrFunc(err)
+
+		if !rv.Gitops {
+			return mntr.ToUserError(errors.New("rotate command is only supported with the --gitops flag"))
+		}
+
+		k8sClient, err := initRotation(rv)
+		if err != nil {
+			return err
+		}
+
+		return cfg.RotateRecipients(monitor, rv.OrbConfig, rv.GitClient, k8sClient, add, remove)
+	}
+	return cmd
+}
+
+func initRotation(rv *RootValues) (kubernetes.ClientInt, error) {
+	k8sClient, err := cli.Init(monitor, rv.OrbConfig, rv.GitClient, rv.Kubeconfig, rv.Gitops, rv.Gitops, rv.Gitops)
+	if err != nil && !errors.Is(err, cli.ErrNotInitialized) {
+		return nil, err
+	}
+
+	for _, operatorFile := range []git.DesiredFile{git.DatabaseFile, git.ZitadelFile} {
+		if rv.GitClient.Exists(operatorFile) {
+			return nil, mntr.ToUserError(fmt.Errorf("found %s in git repository. Please use zitadelctl for reencrypting its secrets", operatorFile))
+		}
+	}
+	return k8sClient, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kataras/tablewriter"
+	"github.com/landoop/tableprinter"
+	"github.com/spf13/cobra"
+
+	"github.com/caos/orbos/internal/secret/operators"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/kubernetes/cli"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+func SecretsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "secrets",
+		Short:   "Audit secrets",
+		Example: `orbctl --gitops secrets report`,
+		Args:    cobra.MinimumNArgs(1),
+	}
+}
+
+func SecretsReportCommand(getRv GetRootValues) *cobra.Command {
+	var (
+		output string
+		depth  int
+		cmd    = &cobra.Command{
+			Use:   "report",
+			Short: "List all secrets with their states",
+			Long:  "List all secrets of all operators, whether they are set, how they are stored, whether referenced kubernetes secrets exist and match, when their values last changed and whether the current spec uses them",
+			Args:  cobra.NoArgs,
+		}
+	)
+
+	flags := cmd.Flags()
+	flags.StringVarP(&output, "output", "o", "table", "Output format, either table or json")
+	flags.IntVar(&depth, "depth", 100, "Number of commits to inspect for finding when the values last changed")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+
+		rv := getRv("secrets", "report", map[string]interface{}{"output": output, "depth": depth})
+		defer rv.ErrFunc(err)
+
+		if output != "table" && output != "json" {
+			return mntr.ToUserError(fmt.Errorf("unknown output format %s, use table or json", output))
+		}
+
+		if depth < 1 {
+			return mntr.ToUserError(errors.New("depth must be at least 1"))
+		}
+
+		k8sClient, err := cli.Init(monitor, rv.OrbConfig, rv.GitClient, rv.Kubeconfig, rv.Gitops, rv.Gitops, rv.Gitops)
+		if err != nil && (!rv.Gitops || !errors.Is(err, cli.ErrNotInitialized)) {
+			return err
+		}
+
+		var historyFunc secret.HistoryFunc
+		if rv.Gitops {
+			historyFunc = operators.HistoryFunc(monitor, rv.GitClient, rv.OrbConfig, depth)
+		}
+
+		items, err := secret.Report(
+			k8sClient,
+			operators.GetAllSecretsFunc(monitor, false, rv.Gitops, rv.GitClient, k8sClient, rv.OrbConfig),
+			historyFunc,
+			operators.Unused,
+		)
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(items)
+		}
+
+		rows := make([][]string, len(items))
+		for i, item := range items {
+			lastChanged := ""
+			if item.LastChanged != nil {
+				lastChanged = item.LastChanged.Format(time.RFC3339)
+			}
+			kind := item.Kind
+			if item.Reference != "" {
+				kind = item.Kind + " " + item.Reference
+			}
+			rows[i] = []string{item.Path, strconv.FormatBool(item.Set), kind, item.Kubernetes, lastChanged, strconv.FormatBool(item.Unused)}
+		}
+
+		printer := tableprinter.New(os.Stdout)
+		printer.BorderTop, printer.BorderBottom = true, true
+		printer.HeaderFgColor = tablewriter.FgYellowColor
+		printer.Render([]string{"path", "set", "kind", "kubernetes", "last changed", "unused"}, rows, nil, false)
+		return nil
+	}
+	return cmd
+}
@@ -4,10 +4,12 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/caos/orbos/cmd/orbctl/cmds"
 	"github.com/caos/orbos/internal/ctrlcrd"
 	"github.com/caos/orbos/internal/ctrlgitops"
 	"github.com/caos/orbos/pkg/kubernetes"
 	orbcfg "github.com/caos/orbos/pkg/orb"
+	"github.com/caos/orbos/pkg/secret"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +19,8 @@ func StartCommand() *cobra.Command {
 		Short: "Start an operator",
 		Long:  `Pass exactly one of orbiter, boom or networking"`,
 		Args:  cobra.MinimumNArgs(1),
+		// Desired states pushed to the repository must not make the operators read their local files and environment variables
+		PersistentPreRun: func(*cobra.Command, []string) { secret.LocalRefs = false },
 	}
 }
 
@@ -26,6 +30,7 @@ func StartOrbiter(getRv GetRootValues) *cobra.Command {
 		recur   bool
 		deploy  bool
 		pprof   bool
+		plan    bool
 		cmd     = &cobra.Command{
 			Use:   "orbiter",
 			Short: "Launch an orbiter",
@@ -37,10 +42,11 @@ func StartOrbiter(getRv GetRootValues) *cobra.Command {
 	flags.BoolVar(&recur, "recur", true, "Ensure the desired state continously")
 	flags.BoolVar(&deploy, "deploy", true, "Ensure Orbiter deployment continously")
 	flags.BoolVar(&pprof, "pprof", false, "Start pprof to analyse memory usage")
+	flags.BoolVar(&plan, "plan", false, "Print what ORBITER would change without changing anything")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
 
-		rv := getRv("start", "orbiter", map[string]interface{}{"recur": recur, "depoy": deploy, "pprof": pprof})
+		rv := getRv("start", "orbiter", map[string]interface{}{"recur": recur, "depoy": deploy, "pprof": pprof, "plan": plan})
 		defer rv.ErrFunc(err)
 
 		if err := orbcfg.IsComplete(rv.OrbConfig); err != nil {
@@ -60,6 +66,10 @@ func StartOrbiter(getRv GetRootValues) *cobra.Command {
 			GitCommit:     gitCommit,
 		}
 
+		if plan {
+			return cmds.PrintOrbiterPlan(monitor, orbiterConfig, rv.GitClient)
+		}
+
 		if pprof {
 			go func() {
 				log.Println(http.ListenAndServe("localhost:6060", nil))
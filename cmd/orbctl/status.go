@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+func StatusCommand(getRv GetRootValues) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status [<provider>.<pool>.<machine>]",
+		Short: "Print the node agents status",
+		Long:  "Queries the node agents local API over SSH, so the status is printed even if the node agent can't push its current state",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+			machineID := ""
+			if len(args) > 0 {
+				machineID = args[0]
+			}
+
+			rv := getRv("status", "", map[string]interface{}{"machine": machineID})
+			defer rv.ErrFunc(err)
+
+			if !rv.Gitops {
+				return mntr.ToUserError(errors.New("status command is only supported with the --gitops flag and a committed orbiter.yml"))
+			}
+
+			return machines(monitor, rv.GitClient, rv.OrbConfig, func(machineIDs []string, machines map[string]infra.Machine, _ *tree.Tree) error {
+
+				if machineID == "" {
+					if err := survey.AskOne(&survey.Select{
+						Message: "Select a machine:",
+						Options: machineIDs,
+					}, &machineID, survey.WithValidator(survey.Required)); err != nil {
+						return err
+					}
+				}
+
+				machine, found := machines[machineID]
+				if !found {
+					return mntr.ToUserError(fmt.Errorf("machine with ID %s unknown", machineID))
+				}
+
+				status, err := core.NodeAgentStatus(machine)
+				if err != nil {
+					return mntr.ToUserError(err)
+				}
+				fmt.Print(string(common.MarshalYAML(status)))
+				return nil
+			})
+		},
+	}
+}
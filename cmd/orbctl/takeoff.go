@@ -13,21 +13,26 @@ func TakeoffCommand(getRv GetRootValues) *cobra.Command {
 		verbose bool
 		recur   bool
 		deploy  bool
+		plan    bool
 		cmd     = &cobra.Command{
 			Use:   "takeoff",
 			Short: "Launch an operator",
-			Long:  `For launching specific operators only, pass one or many of "orbiter", "boom" or "networking"`,
-			Args:  cobra.MaximumNArgs(3),
+			Long: `For launching specific operators only, pass one or many of "orbiter", "boom" or "networking"
+
+With --plan, ORBITER only queries the current state and prints what ensuring would change, including the load balancing node agent configs.
+It neither changes the machines, the clusters or the providers nor pushes anything.`,
+			Args: cobra.MaximumNArgs(3),
 		}
 	)
 
 	flags := cmd.Flags()
 	flags.BoolVar(&recur, "recur", false, "Ensure the desired state continously")
 	flags.BoolVar(&deploy, "deploy", true, "Ensure Orbiter and Boom deployments continously")
+	flags.BoolVar(&plan, "plan", false, "Print what ORBITER would change without changing anything")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
 
-		rv := getRv("takeoff", "", map[string]interface{}{"recur": recur, "deploy": deploy, "args": args})
+		rv := getRv("takeoff", "", map[string]interface{}{"recur": recur, "deploy": deploy, "plan": plan, "args": args})
 		defer rv.ErrFunc(err)
 
 		return cmds.Takeoff(
@@ -42,6 +47,7 @@ func TakeoffCommand(getRv GetRootValues) *cobra.Command {
 			gitCommit,
 			rv.Kubeconfig,
 			rv.Gitops,
+			plan,
 			args,
 		)
 	}
@@ -20,16 +20,18 @@ func WriteSecretCommand(getRv GetRootValues) *cobra.Command {
 		value string
 		file  string
 		stdin bool
+		ref   string
 		cmd   = &cobra.Command{
 			Use:   "writesecret [path]",
 			Short: "Encrypt a secret and push it to the repository",
-			Long:  "Encrypt a secret and push it to the repository.\nIf no path is provided, a secret can interactively be chosen from a list of all possible secrets",
+			Long:  "Encrypt a secret and push it to the repository.\nIf no path is provided, a secret can interactively be chosen from a list of all possible secrets.\nIf the secret references an external store, the value is written to the store instead",
 			Args:  cobra.MaximumNArgs(1),
 			Example: `orbctl writesecret --file ~/.ssh/my-orb-bootstrap
 orbctl writesecret --value $(cat ~/.ssh/my-orb-bootstrap)
 orbctl writesecret mystaticprovider.bootstrapkey.encrypted --file ~/.ssh/my-orb-bootstrap
 orbctl writesecret mystaticprovider.bootstrapkey_pub.encrypted --file ~/.ssh/my-orb-bootstrap.pub
-orbctl writesecret mygceprovider.google_application_credentials_value.encrypted --value "$(cat $GOOGLE_APPLICATION_CREDENTIALS)" `,
+orbctl writesecret mygceprovider.google_application_credentials_value.encrypted --value "$(cat $GOOGLE_APPLICATION_CREDENTIALS)"
+orbctl writesecret mygceprovider.google_application_credentials_value.encrypted --ref vault://secret/data/orbos/gce#jsonkey`,
 		}
 	)
 
@@ -37,6 +39,7 @@ orbctl writesecret mygceprovider.google_application_credentials_value.encrypted
 	flags.StringVar(&value, "value", "", "Secret value to encrypt")
 	flags.StringVarP(&file, "file", "s", "", "File containing the value to encrypt")
 	flags.BoolVar(&stdin, "stdin", false, "Value to encrypt is read from standard input")
+	flags.StringVar(&ref, "ref", "", "Reference the value in an external store like vault://<path>#<key>, file:///<path> or env://<variable> instead of encrypting it")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
 
@@ -45,21 +48,37 @@ orbctl writesecret mygceprovider.google_application_credentials_value.encrypted
 			path = args[0]
 		}
 
-		rv := getRv("writesecret", "", map[string]interface{}{"path": path, "value": value != "", "file": file, "stdin": stdin})
+		rv := getRv("writesecret", "", map[string]interface{}{"path": path, "value": value != "", "file": file, "stdin": stdin, "ref": ref != ""})
 		defer rv.ErrFunc(err)
 
-		s, err := content(value, file, stdin)
-		if err != nil {
-			return err
+		if ref != "" && (value != "" || file != "" || stdin) {
+			return mntr.ToUserError(errors.New("content must not be provided together with a reference"))
 		}
 
-		defer rv.ErrFunc(err)
+		var s string
+		if ref == "" {
+			if s, err = content(value, file, stdin); err != nil {
+				return err
+			}
+		}
 
 		k8sClient, err := cli.Init(monitor, rv.OrbConfig, rv.GitClient, rv.Kubeconfig, rv.Gitops, rv.Gitops, rv.Gitops)
 		if err != nil && (!rv.Gitops || !errors.Is(err, cli.ErrNotInitialized)) {
 			return err
 		}
 
+		if ref != "" {
+			if !rv.Gitops {
+				return mntr.ToUserError(errors.New("references are only supported with the --gitops flag"))
+			}
+			return secret.WriteRef(
+				monitor,
+				path,
+				ref,
+				operators.GetAllSecretsFunc(monitor, true, rv.Gitops, rv.GitClient, k8sClient, rv.OrbConfig),
+				operators.PushFunc(monitor, rv.Gitops, rv.GitClient, k8sClient))
+		}
+
 		return secret.Write(
 			monitor,
 			k8sClient,
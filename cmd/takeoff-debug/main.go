@@ -52,6 +52,7 @@ func main() {
 		gitCommit,
 		*kubeconfig,
 		*gitops,
+		false,
 		[]string{"orbiter"},
 	); err != nil {
 		monitor.Error(err)
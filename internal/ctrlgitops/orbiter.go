@@ -11,9 +11,11 @@ import (
 
 	"github.com/caos/orbos/internal/executables"
 	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/autoscaler"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/orb"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/git"
+	"github.com/caos/orbos/pkg/kubernetes"
 )
 
 type OrbiterConfig struct {
@@ -35,6 +37,7 @@ func Orbiter(ctx context.Context, monitor mntr.Monitor, conf *OrbiterConfig, orb
 
 	if conf.Recur {
 		go orbiter.Instrument(monitor, healthyChan)
+		go serveAutoscaler(ctx, monitor, conf)
 	} else {
 		go func() {
 			for range healthyChan {
@@ -64,6 +67,62 @@ loop:
 	return nil
 }
 
+// serveAutoscaler serves the cluster-autoscaler cloud provider with its own git client, as it writes orbiter.yml concurrently to the iterations
+func serveAutoscaler(ctx context.Context, monitor mntr.Monitor, conf *OrbiterConfig) {
+	defer func() { monitor.RecoverPanic(recover()) }()
+
+	autoscalerMonitor := monitor.WithField("task", "autoscaler")
+
+	orbFile, err := orbcfg.ParseOrbConfig(conf.OrbConfigPath)
+	if err != nil {
+		autoscalerMonitor.Error(err)
+		return
+	}
+
+	gitClient := git.New(ctx, autoscalerMonitor, "orbos", "orbos@caos.ch")
+	if err := gitClient.Configure(orbFile.URL, []byte(orbFile.Repokey)); err != nil {
+		autoscalerMonitor.Error(err)
+		return
+	}
+
+	if err := autoscaler.Serve(ctx, autoscalerMonitor, gitClient, ":9001", kubernetes.AutoscalerTLSPath); err != nil {
+		autoscalerMonitor.Error(err)
+	}
+}
+
+// OrbiterPlan adapts and queries the orbs desired state once and returns what ORBITER would change, without changing anything
+func OrbiterPlan(monitor mntr.Monitor, conf *OrbiterConfig, gitClient *git.Client) (*orbiter.Plan, error) {
+
+	orbFile, err := orbcfg.ParseOrbConfig(conf.OrbConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gitClient.Configure(orbFile.URL, []byte(orbFile.Repokey)); err != nil {
+		return nil, err
+	}
+
+	if err := gitClient.Clone(); err != nil {
+		return nil, err
+	}
+
+	return orbiter.Dryrun(monitor, &orbiter.Config{
+		OrbiterCommit: conf.GitCommit,
+		GitClient:     gitClient,
+		Adapt: orb.AdaptFunc(
+			labels.MustForOperator("ORBOS", "orbiter.caos.ch", conf.Version),
+			orbFile,
+			conf.GitCommit,
+			!conf.Recur,
+			// Planning never deploys ORBITER
+			false,
+			gitClient,
+		),
+		FinishedChan: make(chan struct{}),
+		OrbConfig:    *orbFile,
+	})
+}
+
 func iterate(conf *OrbiterConfig, gitClient *git.Client, firstIteration bool, ctx context.Context, monitor mntr.Monitor, finishedChan chan struct{}, healthyChan chan bool, done func(iterated bool)) {
 
 	var err error
@@ -31,6 +31,11 @@ func PreBuilt(name string) []byte {
 	return executable
 }
 
+// PreBuiltOptional returns nil if the file was not prebuilt
+func PreBuiltOptional(name string) []byte {
+	return executables[name]
+}
+
 func PreBuild(packables <-chan PackableTuple) (err error) {
 	sp := selfPath()
 	tmpFile := filepath.Join(sp, "prebuilt.tmp")
@@ -0,0 +1,162 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// File is written by the node agent
+type File struct {
+	// Path must be absolute
+	Path    string
+	Content string
+	// Permissions are octal like 0600
+	//@default: 0644
+	Permissions string `yaml:",omitempty"`
+	// Restart lists the systemd units which are restarted when the file changes
+	Restart []string `yaml:",omitempty"`
+}
+
+// Unit is written to /etc/systemd/system by the node agent
+type Unit struct {
+	// Name is the unit file name like vector.service
+	Name    string
+	Content string
+	// Enabled units are enabled and kept active. Other units are only written, for example services triggered by timers.
+	Enabled bool `yaml:",omitempty"`
+}
+
+// CustomCurrent reports the sha256 hashes of the custom files and units and which of them drifted from the desired state
+type CustomCurrent struct {
+	Files   map[string]string `yaml:",omitempty"`
+	Units   map[string]string `yaml:",omitempty"`
+	Drifted []string          `yaml:",omitempty"`
+}
+
+var unitSuffixes = []string{".service", ".socket", ".timer", ".path", ".mount", ".target"}
+
+var (
+	// reservedUnits are managed by the node agent, regardless of their suffix
+	reservedUnits = []string{"kubelet", "containerd", "docker", "keepalived", "nginx", "haproxy", "envoy", "bird", "sshd"}
+	// reservedUnitPrefixes are prefixes of unit names managed by the node agent
+	reservedUnitPrefixes = []string{"node-agent", "orbos.", "orbos-"}
+	// reservedPaths are files and directories managed by the node agent
+	reservedPaths = []string{
+		"/etc/systemd/system",
+		"/lib/systemd/system",
+		"/usr/lib/systemd/system",
+		"/etc/kubernetes",
+		"/etc/kubeadm",
+		"/var/lib/kubelet",
+		"/etc/containerd",
+		"/etc/docker",
+		"/etc/crictl.yaml",
+		"/opt/cni/bin",
+		"/etc/keepalived",
+		"/etc/nginx",
+		"/etc/haproxy",
+		"/etc/envoy",
+		"/etc/bird",
+		"/etc/bird.conf",
+		"/etc/orbos",
+		"/etc/ssh/sshd_config",
+		"/etc/hosts",
+		"/etc/fstab",
+		"/etc/sysctl.d/90-orbiter.conf",
+		"/etc/modules-load.d/containerd.conf",
+		"/usr/local/bin/node-agent",
+		"/usr/local/bin/health",
+		"/usr/local/bin/envoy",
+		"/usr/local/bin/orbos-bgp-announce",
+		"/var/orbiter",
+		"/var/lib/orbiter",
+		"/var/lib/orbos",
+	}
+)
+
+func (f *File) Mode() (uint32, error) {
+	if f.Permissions == "" {
+		return 0644, nil
+	}
+	mode, err := strconv.ParseUint(f.Permissions, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("permissions %s of file %s are not octal", f.Permissions, f.Path)
+	}
+	return uint32(mode), nil
+}
+
+// ValidateCustom ensures that paths and unit names are valid, unique and not managed by the node agent already
+func ValidateCustom(files []*File, units []*Unit) error {
+	paths := make(map[string]bool)
+	for _, file := range files {
+		if file == nil {
+			return errors.New("files must not be empty")
+		}
+		if !filepath.IsAbs(file.Path) || filepath.Clean(file.Path) != file.Path {
+			return fmt.Errorf("file path %s must be absolute and clean", file.Path)
+		}
+		if reservedPath(file.Path) {
+			return fmt.Errorf("file path %s is managed by the node agent", file.Path)
+		}
+		if paths[file.Path] {
+			return fmt.Errorf("file %s is defined more than once", file.Path)
+		}
+		paths[file.Path] = true
+		if _, err := file.Mode(); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, unit := range units {
+		if unit == nil {
+			return errors.New("units must not be empty")
+		}
+		if strings.ContainsRune(unit.Name, '/') || !hasUnitSuffix(unit.Name) {
+			return fmt.Errorf("unit name %s must be a file name ending with one of %s", unit.Name, strings.Join(unitSuffixes, ", "))
+		}
+		if reservedUnit(unit.Name) {
+			return fmt.Errorf("unit name %s is reserved", unit.Name)
+		}
+		if names[unit.Name] {
+			return fmt.Errorf("unit %s is defined more than once", unit.Name)
+		}
+		names[unit.Name] = true
+	}
+	return nil
+}
+
+func hasUnitSuffix(name string) bool {
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func reservedUnit(name string) bool {
+	for _, prefix := range reservedUnitPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, unit := range reservedUnits {
+		if strings.HasPrefix(name, unit+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func reservedPath(path string) bool {
+	for _, reserved := range reservedPaths {
+		if path == reserved || strings.HasPrefix(path, reserved+"/") {
+			return true
+		}
+	}
+	return false
+}
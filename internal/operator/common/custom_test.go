@@ -0,0 +1,42 @@
+package common
+
+import "testing"
+
+func TestValidateCustom(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []*File
+		units   []*Unit
+		wantErr bool
+	}{{
+		name:  "It should accept custom files and units",
+		files: []*File{{Path: "/etc/vector/vector.toml"}},
+		units: []*Unit{{Name: "vector.service"}},
+	}, {
+		name:    "It should reject units the node agent manages",
+		units:   []*Unit{{Name: "kubelet.service"}},
+		wantErr: true,
+	}, {
+		name:    "It should reject units with reserved prefixes",
+		units:   []*Unit{{Name: "node-agent.service"}},
+		wantErr: true,
+	}, {
+		name:    "It should reject files in directories the node agent manages",
+		files:   []*File{{Path: "/etc/kubernetes/pki/ca.crt"}},
+		wantErr: true,
+	}, {
+		name:    "It should reject the node agent binary",
+		files:   []*File{{Path: "/usr/local/bin/node-agent"}},
+		wantErr: true,
+	}, {
+		name:  "It should accept files which only share a prefix with managed paths",
+		files: []*File{{Path: "/etc/kubernetes-audit/policy.yaml"}},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateCustom(tt.files, tt.units); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCustom() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -17,6 +17,34 @@ type NodeAgentSpec struct {
 	Networking     *Networking
 	Firewall       *Firewall
 	RebootRequired time.Time
+	// Update lets the node agent replace its own binary instead of waiting for ORBITER to reinstall it over SSH
+	Update *NodeAgentUpdate `yaml:",omitempty"`
+	// RebootSentinel is either a file which exists or a shell command which exits with 0 if the machine needs a reboot
+	RebootSentinel string `yaml:",omitempty"`
+	// SecurityUpdates lets the node agent report and apply security updates of the operating system packages
+	SecurityUpdates *SecurityUpdatesSpec `yaml:",omitempty"`
+	// Files and Units are custom files and systemd units the node agent ensures
+	Files []*File `yaml:",omitempty"`
+	Units []*Unit `yaml:",omitempty"`
+}
+
+// SecurityUpdatesSpec lets ORBITER decide when a node agent applies security updates
+type SecurityUpdatesSpec struct {
+	// Generation requires the node agent to apply the available security updates if the generation it applied last is lower.
+	// Unlike timestamps, generations don't depend on the clocks of ORBITER and the machine being in sync.
+	Generation int64 `yaml:",omitempty"`
+}
+
+// NodeAgentUpdate describes the node agent binary ORBITER desires
+type NodeAgentUpdate struct {
+	Version string
+	Commit  string
+	URL     string
+	SHA256  string
+	// Signature is the base64 encoded SSH signature of the version, the commit and the checksum, made with the release signing key
+	Signature string
+	// Rollback allows updating to a lower version than the running one
+	Rollback bool `yaml:",omitempty"`
 }
 
 type NodeAgentCurrent struct {
@@ -26,6 +54,37 @@ type NodeAgentCurrent struct {
 	Networking  NetworkingCurrent
 	Commit      string
 	Booted      time.Time
+	// RebootRequired is true if the reboot sentinel or the applied security updates require a reboot
+	RebootRequired  bool                    `yaml:",omitempty"`
+	SecurityUpdates *SecurityUpdatesCurrent `yaml:",omitempty"`
+	Custom          *CustomCurrent          `yaml:",omitempty"`
+}
+
+type SecurityUpdatesCurrent struct {
+	Available []*SecurityUpdate `yaml:",omitempty"`
+	Checked   time.Time
+	Applied   time.Time
+	// Generation is the generation of the security updates the node agent applied last
+	Generation int64 `yaml:",omitempty"`
+}
+
+// SecurityUpdate is an available update of an operating system package which fixes vulnerabilities
+type SecurityUpdate struct {
+	Package  string
+	Version  string
+	Advisory string `yaml:",omitempty"`
+	Severity string `yaml:",omitempty"`
+}
+
+// NodeAgentStatus is served by the node agents local API
+type NodeAgentStatus struct {
+	ID            string
+	Commit        string
+	Desired       *NodeAgentSpec    `yaml:",omitempty"`
+	Current       *NodeAgentCurrent `yaml:",omitempty"`
+	RebootPending bool
+	LastIteration time.Time
+	LastError     string `yaml:",omitempty"`
 }
 
 var prune = regexp.MustCompile("[^a-zA-Z0-9]+")
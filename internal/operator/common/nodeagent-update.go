@@ -0,0 +1,101 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	// NodeAgentSignature is the prebuilt file which contains the release signature of the node agent binary
+	NodeAgentSignature = "nodeagent.sig"
+	// ReleaseSigningKey is the prebuilt file which contains the public release signing key in the authorized keys format
+	ReleaseSigningKey = "release-signing-key.pub"
+	// ReleaseSigningKeyPath is where ORBITER pins the public release signing key when it installs a node agent
+	ReleaseSigningKeyPath = "/var/orbiter/release-signing-key"
+)
+
+// SignNodeAgent signs the version, the commit and the checksum of the node agent binary with the private release signing key.
+// Only the release pipeline knows the private key, so neither ORBITER nor anybody with write access to the orbs repository can sign binaries.
+func SignNodeAgent(version, commit string, binary, releaseSigningKey []byte) (string, error) {
+
+	signer, err := ssh.ParsePrivateKey(releaseSigningKey)
+	if err != nil {
+		return "", fmt.Errorf("parsing release signing key failed: %w", err)
+	}
+
+	sum := sha256.Sum256(binary)
+	signature, err := signer.Sign(rand.Reader, signedRelease(version, commit, hex.EncodeToString(sum[:])))
+	if err != nil {
+		return "", fmt.Errorf("signing node agent release failed: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(ssh.Marshal(signature)), nil
+}
+
+// signedRelease binds the checksum to the release, so a signature can't be reused for desiring the binary as another version
+func signedRelease(version, commit, sha256 string) []byte {
+	return []byte(fmt.Sprintf("orbos node agent\nversion %s\ncommit %s\nsha256 %s\n", version, commit, sha256))
+}
+
+// NewNodeAgentUpdate checksums the node agent binary and attaches the signature made at release time
+func NewNodeAgentUpdate(version, commit, url string, binary []byte, signature string) *NodeAgentUpdate {
+	sum := sha256.Sum256(binary)
+	return &NodeAgentUpdate{
+		Version:   version,
+		Commit:    commit,
+		URL:       url,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Signature: strings.TrimSpace(signature),
+	}
+}
+
+// Verify fails if the binary doesn't match the checksum or if the version, the commit and the checksum are not signed with the release signing key.
+// The public key is pinned on the machine when ORBITER installs the node agent, so the desired state can't replace it.
+func (u *NodeAgentUpdate) Verify(binary, releaseKey []byte) error {
+
+	sum := sha256.Sum256(binary)
+	if hex.EncodeToString(sum[:]) != u.SHA256 {
+		return fmt.Errorf("checksum %x does not match the desired checksum %s", sum, u.SHA256)
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(releaseKey)
+	if err != nil {
+		return fmt.Errorf("parsing release signing key failed: %w", err)
+	}
+
+	marshalled, err := base64.StdEncoding.DecodeString(u.Signature)
+	if err != nil {
+		return fmt.Errorf("decoding signature failed: %w", err)
+	}
+
+	signature := &ssh.Signature{}
+	if err := ssh.Unmarshal(marshalled, signature); err != nil {
+		return fmt.Errorf("parsing signature failed: %w", err)
+	}
+
+	if err := publicKey.Verify(signedRelease(u.Version, u.Commit, u.SHA256), signature); err != nil {
+		return errors.New("release is not signed with the release signing key")
+	}
+	return nil
+}
+
+// Downgrades is true if the desired version is lower than the running version.
+// Development builds without a semantic version can't be compared, so they are never downgraded.
+func (u *NodeAgentUpdate) Downgrades(runningVersion string) (bool, error) {
+	desired, err := version.ParseSemantic(u.Version)
+	if err != nil {
+		return false, fmt.Errorf("parsing desired node agent version failed: %w", err)
+	}
+	running, err := version.ParseSemantic(runningVersion)
+	if err != nil {
+		return false, nil
+	}
+	return desired.LessThan(running), nil
+}
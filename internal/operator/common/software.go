@@ -8,6 +8,9 @@ type Software struct {
 	Containerruntime Package `yaml:",omitempty"`
 	KeepaliveD       Package `yaml:",omitempty"`
 	Nginx            Package `yaml:",omitempty"`
+	HAProxy          Package `yaml:",omitempty"`
+	Envoy            Package `yaml:",omitempty"`
+	Bird             Package `yaml:",omitempty"`
 	SSHD             Package `yaml:",omitempty"`
 	Hostname         Package `yaml:",omitempty"`
 	Sysctl           Package `yaml:",omitempty"`
@@ -31,6 +34,18 @@ func (s *Software) Merge(sw Software, forceKernel bool) {
 		s.Nginx = sw.Nginx
 	}
 
+	if !sw.HAProxy.Equals(zeroPkg) {
+		s.HAProxy = sw.HAProxy
+	}
+
+	if !sw.Envoy.Equals(zeroPkg) {
+		s.Envoy = sw.Envoy
+	}
+
+	if !sw.Bird.Equals(zeroPkg) {
+		s.Bird = sw.Bird
+	}
+
 	if !sw.Kubeadm.Equals(zeroPkg) {
 		s.Kubeadm = sw.Kubeadm
 	}
@@ -0,0 +1,296 @@
+package custom
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+	"github.com/caos/orbos/mntr"
+)
+
+// The paths are variables, so tests can replace them
+var (
+	unitsDir = "/etc/systemd/system"
+	// managedPath lists the files and units the node agent wrote, so it removes them when they are not desired anymore
+	managedPath = "/var/orbiter/custom-managed.yml"
+)
+
+// systemD is implemented by *dep.SystemD
+type systemD interface {
+	Enable(unit string) error
+	Start(unit string) error
+	Disable(unit string) error
+	Active(unit string) bool
+	DaemonReload() error
+}
+
+type managed struct {
+	Files []string
+	Units []string
+	// Preexisting lists the files and units which existed before the node agent first wrote them, so they are never removed
+	Preexisting []string `yaml:",omitempty"`
+}
+
+// preexisting is true if the file or unit existed before the node agent first wrote it
+func (m managed) preexisting(item string, managedItems []string, exists bool) bool {
+	return contains(m.Preexisting, item) || exists && !contains(managedItems, item)
+}
+
+func Ensurer(monitor mntr.Monitor, systemd *dep.SystemD) nodeagent.CustomEnsurer {
+	return nodeagent.CustomEnsurerFunc(func(files []*common.File, units []*common.Unit) (*common.CustomCurrent, func() error, error) {
+		return query(monitor, systemd, files, units)
+	})
+}
+
+func query(monitor mntr.Monitor, systemd systemD, files []*common.File, units []*common.Unit) (*common.CustomCurrent, func() error, error) {
+
+	previous, err := readManaged()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(files) == 0 && len(units) == 0 && len(previous.Files) == 0 && len(previous.Units) == 0 {
+		return nil, nil, nil
+	}
+
+	current := &common.CustomCurrent{
+		Files: make(map[string]string),
+		Units: make(map[string]string),
+	}
+	next := managed{}
+
+	var writeFiles []*common.File
+	restart := make(map[string]bool)
+	for _, file := range files {
+		next.Files = append(next.Files, file.Path)
+		mode, err := file.Mode()
+		if err != nil {
+			return nil, nil, err
+		}
+		hash, currentMode, err := hashFile(file.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		current.Files[file.Path] = hash
+		if previous.preexisting(file.Path, previous.Files, hash != "") {
+			next.Preexisting = append(next.Preexisting, file.Path)
+		}
+		if hash != hashContent(file.Content) || currentMode != os.FileMode(mode) {
+			writeFiles = append(writeFiles, file)
+			current.Drifted = append(current.Drifted, "file "+file.Path)
+			for _, unit := range file.Restart {
+				restart[unit] = true
+			}
+		}
+	}
+
+	var writeUnits []*common.Unit
+	for _, unit := range units {
+		next.Units = append(next.Units, unit.Name)
+		hash, _, err := hashFile(filepath.Join(unitsDir, unit.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+		current.Units[unit.Name] = hash
+		if previous.preexisting(unit.Name, previous.Units, hash != "") {
+			next.Preexisting = append(next.Preexisting, unit.Name)
+		}
+		if hash != hashContent(unit.Content) {
+			writeUnits = append(writeUnits, unit)
+			current.Drifted = append(current.Drifted, "unit "+unit.Name)
+			if unit.Enabled {
+				restart[unit.Name] = true
+			}
+			continue
+		}
+		if unit.Enabled && !systemd.Active(unit.Name) {
+			restart[unit.Name] = true
+			current.Drifted = append(current.Drifted, "unit "+unit.Name+" is inactive")
+		}
+	}
+
+	removeFiles := notContained(previous.Files, next.Files)
+	for _, path := range removeFiles {
+		current.Drifted = append(current.Drifted, "file "+path+" is not desired anymore")
+	}
+	removeUnits := notContained(previous.Units, next.Units)
+	for _, unit := range removeUnits {
+		current.Drifted = append(current.Drifted, "unit "+unit+" is not desired anymore")
+	}
+
+	if len(current.Drifted) == 0 {
+		if reflect.DeepEqual(previous, next) {
+			return current, nil, nil
+		}
+		return current, func() error { return writeManaged(next) }, nil
+	}
+	sort.Strings(current.Drifted)
+
+	return current, func() error {
+		monitor.WithField("drifted", current.Drifted).Info("Ensuring custom files and units")
+
+		for _, unit := range removeUnits {
+			if contains(previous.Preexisting, unit) {
+				monitor.WithField("unit", unit).Info("Keeping unit as it existed before the node agent wrote it")
+				continue
+			}
+			if err := systemd.Disable(unit); err != nil {
+				return err
+			}
+			if err := os.Remove(filepath.Join(unitsDir, unit)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("removing unit %s failed: %w", unit, err)
+			}
+		}
+
+		for _, path := range removeFiles {
+			if contains(previous.Preexisting, path) {
+				monitor.WithField("file", path).Info("Keeping file as it existed before the node agent wrote it")
+				continue
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("removing file %s failed: %w", path, err)
+			}
+		}
+
+		for _, file := range writeFiles {
+			mode, _ := file.Mode()
+			if err := writeFile(file.Path, file.Content, os.FileMode(mode)); err != nil {
+				return err
+			}
+		}
+
+		for _, unit := range writeUnits {
+			if err := writeFile(filepath.Join(unitsDir, unit.Name), unit.Content, 0644); err != nil {
+				return err
+			}
+		}
+
+		if len(writeUnits) > 0 || len(removeUnits) > 0 {
+			if err := systemd.DaemonReload(); err != nil {
+				return err
+			}
+		}
+
+		// Enabled custom units are started if they are inactive and restarted if they or their files changed
+		for _, unit := range units {
+			if !unit.Enabled {
+				continue
+			}
+			if restart[unit.Name] {
+				if err := systemd.Enable(unit.Name); err != nil {
+					return err
+				}
+				delete(restart, unit.Name)
+				if err := systemd.Start(unit.Name); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Units which are not custom, like kubelet, are only restarted if they are running
+		restartOthers := make([]string, 0, len(restart))
+		for unit := range restart {
+			restartOthers = append(restartOthers, unit)
+		}
+		sort.Strings(restartOthers)
+		for _, unit := range restartOthers {
+			if systemd.Active(unit) {
+				if err := systemd.Start(unit); err != nil {
+					return err
+				}
+			}
+		}
+
+		return writeManaged(next)
+	}, nil
+}
+
+func hashContent(content string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+}
+
+// hashFile returns an empty hash if the file doesn't exist
+func hashFile(path string) (string, os.FileMode, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("reading file info of %s failed: %w", path, err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("reading file %s failed: %w", path, err)
+	}
+	return hashContent(string(content)), info.Mode().Perm(), nil
+}
+
+func writeFile(path, content string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating directory for file %s failed: %w", path, err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), mode); err != nil {
+		return fmt.Errorf("writing file %s failed: %w", path, err)
+	}
+	// WriteFile doesn't change the permissions of existing files
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("changing permissions of file %s failed: %w", path, err)
+	}
+	return nil
+}
+
+func readManaged() (managed, error) {
+	m := managed{}
+	content, err := ioutil.ReadFile(managedPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("reading managed custom files and units failed: %w", err)
+	}
+	if err := yaml.Unmarshal(content, &m); err != nil {
+		return m, fmt.Errorf("parsing managed custom files and units failed: %w", err)
+	}
+	return m, nil
+}
+
+func writeManaged(m managed) error {
+	content, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(managedPath, content, 0600); err != nil {
+		return fmt.Errorf("writing managed custom files and units failed: %w", err)
+	}
+	return nil
+}
+
+func notContained(all, desired []string) []string {
+	var diff []string
+	for _, item := range all {
+		if !contains(desired, item) {
+			diff = append(diff, item)
+		}
+	}
+	return diff
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
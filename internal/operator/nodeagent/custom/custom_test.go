@@ -0,0 +1,268 @@
+package custom
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+type fakeSystemD struct {
+	active map[string]bool
+	calls  []string
+}
+
+func (f *fakeSystemD) Enable(unit string) error {
+	f.calls = append(f.calls, "enable "+unit)
+	return nil
+}
+
+func (f *fakeSystemD) Start(unit string) error {
+	f.calls = append(f.calls, "restart "+unit)
+	f.active[unit] = true
+	return nil
+}
+
+func (f *fakeSystemD) Disable(unit string) error {
+	f.calls = append(f.calls, "disable "+unit)
+	f.active[unit] = false
+	return nil
+}
+
+func (f *fakeSystemD) Active(unit string) bool {
+	return f.active[unit]
+}
+
+func (f *fakeSystemD) DaemonReload() error {
+	f.calls = append(f.calls, "daemon-reload")
+	return nil
+}
+
+const unitContent = "[Service]\nExecStart=/usr/local/bin/vector\n"
+
+func TestQuery(t *testing.T) {
+
+	tests := []struct {
+		name string
+		// existing files are written before querying, relative paths are joined to a temporary directory
+		existing    map[string]string
+		managed     *managed
+		active      map[string]bool
+		files       func(dir string) []*common.File
+		units       []*common.Unit
+		wantDrifted []string
+		wantCalls   []string
+		wantAbsent  []string
+	}{{
+		name:     "It should report no drift when files and units are ensured",
+		existing: map[string]string{"vector.toml": "config", "units/vector.service": unitContent},
+		managed:  &managed{Files: []string{"vector.toml"}, Units: []string{"vector.service"}},
+		active:   map[string]bool{"vector.service": true},
+		files: func(dir string) []*common.File {
+			return []*common.File{{Path: filepath.Join(dir, "vector.toml"), Content: "config", Restart: []string{"vector.service"}}}
+		},
+		units: []*common.Unit{{Name: "vector.service", Content: unitContent, Enabled: true}},
+	}, {
+		name:     "It should write changed files and restart their units",
+		existing: map[string]string{"vector.toml": "old", "units/vector.service": unitContent},
+		managed:  &managed{Files: []string{"vector.toml"}, Units: []string{"vector.service"}},
+		active:   map[string]bool{"vector.service": true},
+		files: func(dir string) []*common.File {
+			return []*common.File{{Path: filepath.Join(dir, "vector.toml"), Content: "config", Restart: []string{"vector.service"}}}
+		},
+		units:       []*common.Unit{{Name: "vector.service", Content: unitContent, Enabled: true}},
+		wantDrifted: []string{"file vector.toml"},
+		wantCalls:   []string{"enable vector.service", "restart vector.service"},
+	}, {
+		name:     "It should only restart units which are not custom if they are running",
+		existing: map[string]string{"ca.crt": "old"},
+		active:   map[string]bool{"kubelet.service": true},
+		files: func(dir string) []*common.File {
+			return []*common.File{{Path: filepath.Join(dir, "ca.crt"), Content: "new", Restart: []string{"kubelet.service", "containerd.service"}}}
+		},
+		wantDrifted: []string{"file ca.crt"},
+		wantCalls:   []string{"restart kubelet.service"},
+	}, {
+		name:        "It should write changed units, reload systemd and restart them",
+		existing:    map[string]string{"units/vector.service": "[Service]\n"},
+		active:      map[string]bool{"vector.service": true},
+		units:       []*common.Unit{{Name: "vector.service", Content: unitContent, Enabled: true}},
+		wantDrifted: []string{"unit vector.service"},
+		wantCalls:   []string{"daemon-reload", "enable vector.service", "restart vector.service"},
+	}, {
+		name:        "It should enable and start enabled units which are inactive",
+		existing:    map[string]string{"units/vector.service": unitContent},
+		active:      map[string]bool{},
+		units:       []*common.Unit{{Name: "vector.service", Content: unitContent, Enabled: true}},
+		wantDrifted: []string{"unit vector.service is inactive"},
+		wantCalls:   []string{"enable vector.service", "restart vector.service"},
+	}, {
+		name:        "It should only write units which are not enabled",
+		active:      map[string]bool{},
+		units:       []*common.Unit{{Name: "backup.service", Content: unitContent}},
+		wantDrifted: []string{"unit backup.service"},
+		wantCalls:   []string{"daemon-reload"},
+	}, {
+		name:        "It should disable and remove units and files which are not desired anymore",
+		existing:    map[string]string{"vector.toml": "config", "units/vector.service": unitContent},
+		managed:     &managed{Files: []string{"vector.toml"}, Units: []string{"vector.service"}},
+		active:      map[string]bool{"vector.service": true},
+		wantDrifted: []string{"file vector.toml is not desired anymore", "unit vector.service is not desired anymore"},
+		wantCalls:   []string{"disable vector.service", "daemon-reload"},
+		wantAbsent:  []string{"vector.toml", "units/vector.service"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			unitsDir = filepath.Join(dir, "units")
+			managedPath = filepath.Join(dir, "managed.yml")
+			abs := func(path string) string { return filepath.Join(dir, path) }
+
+			for path, content := range tt.existing {
+				if err := writeFile(abs(path), content, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.managed != nil {
+				prev := managed{Units: tt.managed.Units}
+				for _, file := range tt.managed.Files {
+					prev.Files = append(prev.Files, abs(file))
+				}
+				if err := writeManaged(prev); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var files []*common.File
+			if tt.files != nil {
+				files = tt.files(dir)
+			}
+
+			systemd := &fakeSystemD{active: tt.active}
+			current, ensure, err := query(mntr.Monitor{}, systemd, files, tt.units)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var drifted []string
+			for _, d := range current.Drifted {
+				drifted = append(drifted, strings.ReplaceAll(d, dir+"/", ""))
+			}
+			if !reflect.DeepEqual(drifted, tt.wantDrifted) {
+				t.Errorf("Drifted = %v, want %v", drifted, tt.wantDrifted)
+			}
+			if len(tt.wantDrifted) == 0 {
+				if ensure != nil {
+					t.Error("expected nothing to ensure")
+				}
+				return
+			}
+
+			if err := ensure(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(systemd.calls, tt.wantCalls) {
+				t.Errorf("systemd calls = %v, want %v", systemd.calls, tt.wantCalls)
+			}
+			for _, path := range tt.wantAbsent {
+				if _, err := os.Stat(abs(path)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed", path)
+				}
+			}
+
+			// Ensuring converges
+			current, _, err = query(mntr.Monitor{}, systemd, files, tt.units)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current != nil && len(current.Drifted) > 0 {
+				t.Errorf("expected no drift after ensuring, but got %v", current.Drifted)
+			}
+		})
+	}
+}
+
+func TestQuery_permissions(t *testing.T) {
+	dir := t.TempDir()
+	unitsDir = filepath.Join(dir, "units")
+	managedPath = filepath.Join(dir, "managed.yml")
+	path := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(path, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*common.File{{Path: path, Content: "secret", Permissions: "0600"}}
+	current, ensure, err := query(mntr.Monitor{}, &fakeSystemD{active: map[string]bool{}}, files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current.Drifted) != 1 {
+		t.Fatalf("expected the permissions to drift, but got %v", current.Drifted)
+	}
+	if err := ensure(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("permissions = %o, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestQuery_preexisting(t *testing.T) {
+	dir := t.TempDir()
+	unitsDir = filepath.Join(dir, "units")
+	managedPath = filepath.Join(dir, "managed.yml")
+	path := filepath.Join(dir, "vector.toml")
+	for file, content := range map[string]string{path: "old", filepath.Join(unitsDir, "vector.service"): "old"} {
+		if err := writeFile(file, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	systemd := &fakeSystemD{active: map[string]bool{}}
+
+	files := []*common.File{{Path: path, Content: "config"}}
+	units := []*common.Unit{{Name: "vector.service", Content: unitContent, Enabled: true}}
+	for i := 0; i < 2; i++ {
+		// The second iteration must still know that the files existed before
+		_, ensure, err := query(mntr.Monitor{}, systemd, files, units)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ensure != nil {
+			if err := ensure(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	systemd.calls = nil
+	current, ensure, err := query(mntr.Monitor{}, systemd, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current.Drifted) != 2 {
+		t.Fatalf("expected the file and the unit to drift, but got %v", current.Drifted)
+	}
+	if err := ensure(); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{path, filepath.Join(unitsDir, "vector.service")} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("expected %s to be kept, but got %v", file, err)
+		}
+	}
+	if !reflect.DeepEqual(systemd.calls, []string{"daemon-reload"}) {
+		t.Errorf("expected the unit not to be disabled, but got systemd calls %v", systemd.calls)
+	}
+
+	if _, ensure, err = query(mntr.Monitor{}, systemd, nil, nil); err != nil || ensure != nil {
+		t.Errorf("expected the kept files and units not to be managed anymore, but got %v", err)
+	}
+}
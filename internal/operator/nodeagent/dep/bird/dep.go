@@ -0,0 +1,208 @@
+package bird
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/middleware"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	announcePath = "/usr/local/bin/orbos-bgp-announce"
+	announceUnit = "orbos.bgp-announce.service"
+	unitPath     = "/etc/systemd/system/" + announceUnit
+	unit         = `[Unit]
+Description=Announces the healthy VIPs using BIRD
+After=network.target bird.service
+Requires=bird.service
+
+[Service]
+Type=simple
+User=root
+ExecStart=/usr/local/bin/orbos-bgp-announce
+ExecStopPost=/sbin/ip address flush dev lo label lo:orbos
+Restart=always
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+type Installer interface {
+	isBird()
+	nodeagent.Installer
+}
+
+type birdDep struct {
+	monitor mntr.Monitor
+	manager *dep.PackageManager
+	systemd *dep.SystemD
+	os      dep.OperatingSystem
+}
+
+// New returns a dependency which announces VIPs using the BIRD 2 routing daemon from the operating systems repositories.
+// As the available versions differ between operating systems, the version is not managed.
+func New(monitor mntr.Monitor, manager *dep.PackageManager, systemd *dep.SystemD, os dep.OperatingSystem) Installer {
+	return &birdDep{monitor, manager, systemd, os}
+}
+
+func (birdDep) isBird() {}
+
+func (birdDep) Is(other nodeagent.Installer) bool {
+	_, ok := middleware.Unwrap(other).(Installer)
+	return ok
+}
+
+func (birdDep) String() string { return "BIRD" }
+
+func (*birdDep) Equals(other nodeagent.Installer) bool {
+	_, ok := other.(*birdDep)
+	return ok
+}
+
+func (s *birdDep) InstalledFilter() []string {
+	return []string{s.packageName()}
+}
+
+func (s *birdDep) Current() (pkg common.Package, err error) {
+	if !s.systemd.Active("bird") {
+		return pkg, nil
+	}
+
+	config, err := ioutil.ReadFile(s.configPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkg, nil
+		}
+		return pkg, err
+	}
+
+	pkg.Config = map[string]string{
+		"bird.conf": string(config),
+	}
+
+	if !s.systemd.Active(announceUnit) {
+		return pkg, nil
+	}
+
+	announce, err := ioutil.ReadFile(announcePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkg, nil
+		}
+		return pkg, err
+	}
+	pkg.Config["announce.sh"] = string(announce)
+	return pkg, nil
+}
+
+func (s *birdDep) Ensure(remove common.Package, ensure common.Package, _ bool) error {
+
+	ensureCfg, ok := ensure.Config["bird.conf"]
+	if !ok {
+		// Withdraw the announcements before stopping the daemon
+		if err := s.systemd.Disable(announceUnit); err != nil {
+			return err
+		}
+		if err := s.systemd.Disable("bird"); err != nil {
+			return err
+		}
+		for _, path := range []string{announcePath, s.configPath()} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, installed := remove.Config["bird.conf"]; !installed {
+		if err := s.install(); err != nil {
+			return err
+		}
+	}
+
+	tmpPath := s.configPath() + ".orbos"
+	if err := ioutil.WriteFile(tmpPath, []byte(ensureCfg), 0640); err != nil {
+		return err
+	}
+
+	if out, err := exec.Command("bird", "-p", "-c", tmpPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("validating bird config failed: %s: %w", string(out), err)
+	}
+
+	if err := os.Rename(tmpPath, s.configPath()); err != nil {
+		return err
+	}
+
+	if err := s.systemd.Enable("bird"); err != nil {
+		return err
+	}
+
+	if err := s.systemd.Reload("bird"); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(announcePath, []byte(ensure.Config["announce.sh"]), 0755); err != nil {
+		return err
+	}
+
+	currentUnit, err := ioutil.ReadFile(unitPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if string(currentUnit) != unit {
+		if err := ioutil.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+			return err
+		}
+		if err := s.systemd.DaemonReload(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.systemd.Enable(announceUnit); err != nil {
+		return err
+	}
+
+	// The script runs in an endless loop, so changes only apply after a restart
+	return s.systemd.Start(announceUnit)
+}
+
+func (s *birdDep) install() error {
+	if s.os == dep.CentOS {
+		return errors.New("BIRD 2 is not available for CentOS 7")
+	}
+
+	if s.os.RedHatBased() {
+		if err := s.manager.Install(&dep.Software{Package: "epel-release"}); err != nil {
+			return fmt.Errorf("installing epel-release failed: %w", err)
+		}
+	}
+
+	if err := s.manager.Install(&dep.Software{Package: s.packageName()}); err != nil {
+		return fmt.Errorf("installing software failed: %w", err)
+	}
+	return nil
+}
+
+func (s *birdDep) packageName() string {
+	if s.os.Packages == dep.DebianBased {
+		return "bird2"
+	}
+	return "bird"
+}
+
+func (s *birdDep) configPath() string {
+	if s.os.Packages == dep.DebianBased {
+		return "/etc/bird/bird.conf"
+	}
+	return "/etc/bird.conf"
+}
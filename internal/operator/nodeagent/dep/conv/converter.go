@@ -11,7 +11,10 @@ import (
 	"github.com/caos/orbos/internal/operator/common"
 	"github.com/caos/orbos/internal/operator/nodeagent"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/bird"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep/cri"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/envoy"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/haproxy"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep/hostname"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep/k8s/kubeadm"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep/k8s/kubectl"
@@ -47,7 +50,7 @@ func New(ctx context.Context, monitor mntr.Monitor, os dep.OperatingSystemMajor,
 func (d *dependencies) Init() func() error {
 
 	d.sysd = dep.NewSystemD(d.monitor)
-	d.pm = dep.NewPackageManager(d.monitor, d.os.OperatingSystem, d.sysd)
+	d.pm = dep.NewPackageManager(d.monitor, d.os, d.sysd)
 
 	return func() error {
 		if err := d.pm.RefreshInstalled(append(d.InstalledFilter(),
@@ -55,6 +58,9 @@ func (d *dependencies) Init() func() error {
 			"yum-utils",
 			"yum-plugin-versionlock",
 			"firewalld",
+			"dnf-plugins-core",
+			"python3-dnf-plugin-versionlock",
+			"nftables",
 		)); err != nil {
 			return err
 		}
@@ -73,6 +79,31 @@ func (d *dependencies) Update() error {
 	return d.pm.Update()
 }
 
+func (d *dependencies) SecurityUpdates() ([]*common.SecurityUpdate, error) {
+	updates, err := d.pm.SecurityUpdates()
+	if err != nil {
+		return nil, err
+	}
+	sw := make([]*common.SecurityUpdate, len(updates))
+	for idx, update := range updates {
+		sw[idx] = &common.SecurityUpdate{
+			Package:  update.Package,
+			Version:  update.Version,
+			Advisory: update.Advisory,
+			Severity: update.Severity,
+		}
+	}
+	return sw, nil
+}
+
+func (d *dependencies) ApplySecurityUpdates() error {
+	return d.pm.ApplySecurityUpdates()
+}
+
+func (d *dependencies) RebootRequired() bool {
+	return d.pm.RebootRequired()
+}
+
 func (d *dependencies) InstalledFilter() []string {
 	var query []string
 	for _, dep := range d.ToDependencies(common.Software{}) {
@@ -107,6 +138,15 @@ func (d *dependencies) ToDependencies(sw common.Software) []*nodeagent.Dependenc
 	}, {
 		Desired:   sw.Nginx,
 		Installer: nginx.New(d.monitor, d.pm, d.sysd, d.os.OperatingSystem),
+	}, {
+		Desired:   sw.HAProxy,
+		Installer: haproxy.New(d.monitor, d.pm, d.sysd, d.os.OperatingSystem),
+	}, {
+		Desired:   sw.Envoy,
+		Installer: envoy.New(d.monitor, d.sysd),
+	}, {
+		Desired:   sw.Bird,
+		Installer: bird.New(d.monitor, d.pm, d.sysd, d.os.OperatingSystem),
 	}, {
 		Desired:   sw.Containerruntime,
 		Installer: cri.New(d.monitor, d.os, d.pm, d.sysd),
@@ -155,6 +195,12 @@ func (d *dependencies) ToSoftware(dependencies []*nodeagent.Dependency, pkg func
 			sw.KeepaliveD = pkg(*dependency)
 		case nginx.Installer:
 			sw.Nginx = pkg(*dependency)
+		case haproxy.Installer:
+			sw.HAProxy = pkg(*dependency)
+		case envoy.Installer:
+			sw.Envoy = pkg(*dependency)
+		case bird.Installer:
+			sw.Bird = pkg(*dependency)
 		case sshd.Installer:
 			sw.SSHD = pkg(*dependency)
 		default:
@@ -0,0 +1,195 @@
+package cri
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+)
+
+const (
+	containerdConfigPath   = "/etc/containerd/config.toml"
+	containerdSocket       = "unix:///run/containerd/containerd.sock"
+	defaultSandboxImage    = "k8s.gcr.io/pause:3.2"
+	kubeletFlagsPath       = "/var/lib/kubelet/kubeadm-flags.env"
+	kubeletRemoteRuntime   = "--container-runtime=remote --container-runtime-endpoint=" + containerdSocket
+	containerdModulesPath  = "/etc/modules-load.d/containerd.conf"
+	containerdModules      = "overlay\nbr_netfilter\n"
+	crictlConfigPath       = "/etc/crictl.yaml"
+	crictlConfigContainerd = "runtime-endpoint: " + containerdSocket + "\nimage-endpoint: " + containerdSocket + "\n"
+)
+
+var (
+	sandboxImageRegexp = regexp.MustCompile(`sandbox_image = "([^"]*)"`)
+	containerdConfig   = template.Must(template.New("").Parse(`version = 2
+
+[plugins."io.containerd.grpc.v1.cri"]
+  sandbox_image = "{{ . }}"
+
+  [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc]
+    runtime_type = "io.containerd.runc.v2"
+
+    [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runc.options]
+      SystemdCgroup = true
+`))
+)
+
+func renderContainerdConfig(sandboxImage string) ([]byte, error) {
+	if sandboxImage == "" {
+		sandboxImage = defaultSandboxImage
+	}
+	buf := new(bytes.Buffer)
+	if err := containerdConfig.Execute(buf, sandboxImage); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// currentContainerd reports the sandbox image only if it is not the default.
+// If the config file was not rendered by the node agent, its whole content is reported, so it is overwritten.
+func (c *criDep) currentContainerd() (pkg common.Package, err error) {
+
+	for _, installedPkg := range c.manager.CurrentVersions("containerd.io") {
+		pkg.Version = "containerd.io v" + c.dockerVersionPrunerRegexp.FindString(installedPkg.Version)
+	}
+
+	config, err := ioutil.ReadFile(containerdConfigPath)
+	if err != nil && !os.IsNotExist(err) {
+		return pkg, err
+	}
+
+	var sandboxImage string
+	if match := sandboxImageRegexp.FindSubmatch(config); len(match) == 2 {
+		sandboxImage = string(match[1])
+	}
+
+	rendered, err := renderContainerdConfig(sandboxImage)
+	if err != nil {
+		return pkg, err
+	}
+
+	if !bytes.Equal(config, rendered) {
+		pkg.Config = map[string]string{"config.toml": string(config)}
+		return pkg, nil
+	}
+
+	if sandboxImage != defaultSandboxImage {
+		pkg.Config = map[string]string{"sandboximage": sandboxImage}
+	}
+	return pkg, nil
+}
+
+// runContainerd replaces docker if it is installed and switches the kubelet to containerd
+func (c *criDep) runContainerd(version, sandboxImage string) error {
+
+	if err := ioutil.WriteFile(containerdModulesPath, []byte(containerdModules), 0644); err != nil {
+		return err
+	}
+
+	for _, module := range strings.Fields(containerdModules) {
+		if err := c.command("modprobe", module); err != nil {
+			return err
+		}
+	}
+
+	if c.systemd.Active("docker") {
+		c.monitor.Info("Replacing docker by containerd")
+		if err := c.systemd.Disable("docker.socket"); err != nil {
+			return err
+		}
+		if err := c.systemd.Disable("docker"); err != nil {
+			return err
+		}
+		// Only installed packages are removed, as apt fails for packages it doesn't know
+		var docker []*dep.Software
+		for _, installed := range c.manager.CurrentVersions("docker-ce", "docker-ce-cli") {
+			docker = append(docker, &dep.Software{Package: installed.Package})
+		}
+		if err := c.manager.Remove(docker...); err != nil {
+			return fmt.Errorf("removing docker failed: %w", err)
+		}
+	}
+
+	if err := c.manager.Install(&dep.Software{
+		Package: "containerd.io",
+		Version: version,
+	}); err != nil {
+		return err
+	}
+
+	config, err := renderContainerdConfig(sandboxImage)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll("/etc/containerd", 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(containerdConfigPath, config, 0600); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(crictlConfigPath, []byte(crictlConfigContainerd), 0644); err != nil {
+		return err
+	}
+
+	if err := c.systemd.Enable("containerd"); err != nil {
+		return err
+	}
+
+	if err := c.systemd.Start("containerd"); err != nil {
+		return err
+	}
+
+	return c.kubeletUseContainerd()
+}
+
+// kubeletUseContainerd configures kubelets which were joined with docker to use containerd instead.
+// Kubelets which are not joined yet are configured by kubeadm.
+func (c *criDep) kubeletUseContainerd() error {
+
+	flags, err := ioutil.ReadFile(kubeletFlagsPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if bytes.Contains(flags, []byte(kubeletRemoteRuntime)) {
+		return nil
+	}
+
+	replaced := strings.Replace(strings.TrimSpace(string(flags)), `KUBELET_KUBEADM_ARGS="`, `KUBELET_KUBEADM_ARGS="`+kubeletRemoteRuntime+" ", 1)
+	if err := ioutil.WriteFile(kubeletFlagsPath, []byte(replaced+"\n"), 0644); err != nil {
+		return err
+	}
+
+	c.monitor.Info("Switched kubelet to containerd")
+	return c.systemd.Start("kubelet")
+}
+
+func (c *criDep) command(binary string, args ...string) error {
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+
+	cmd := exec.Command(binary, args...)
+	cmd.Stderr = errBuf
+	if c.monitor.IsVerbose() {
+		fmt.Println(strings.Join(cmd.Args, " "))
+		cmd.Stdout = os.Stdout
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s failed with stderr %s: %w", strings.Join(cmd.Args, " "), errBuf.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package cri
+
+import "testing"
+
+func TestRenderContainerdConfig(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		sandboxImage string
+		want         string
+	}{
+		{name: "default sandbox image", sandboxImage: "", want: defaultSandboxImage},
+		{name: "custom sandbox image", sandboxImage: "registry.example.com/pause:3.2", want: "registry.example.com/pause:3.2"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := renderContainerdConfig(tt.sandboxImage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			match := sandboxImageRegexp.FindSubmatch(config)
+			if len(match) != 2 || string(match[1]) != tt.want {
+				t.Errorf("expected sandbox image %s in config, but got\n%s", tt.want, string(config))
+			}
+		})
+	}
+}
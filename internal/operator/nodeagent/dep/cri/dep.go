@@ -23,7 +23,6 @@ type Installer interface {
 	nodeagent.Installer
 }
 
-// TODO: Add support for containerd, cri-o, ...
 type criDep struct {
 	monitor                   mntr.Monitor
 	os                        dep.OperatingSystemMajor
@@ -57,6 +56,9 @@ func (c *criDep) InstalledFilter() []string {
 
 func (c *criDep) Current() (pkg common.Package, err error) {
 	if !c.systemd.Active("docker") {
+		if c.systemd.Active("containerd") {
+			return c.currentContainerd()
+		}
 		return pkg, err
 	}
 
@@ -107,34 +109,42 @@ func (c *criDep) Current() (pkg common.Package, err error) {
 
 func (c *criDep) Ensure(_ common.Package, install common.Package, leaveOSRepositories bool) error {
 
-	if install.Config == nil {
-		return errors.New("Docker config is nil")
-	}
-
-	if err := os.MkdirAll("/etc/docker", 600); err != nil {
-		return err
-	}
-
-	if err := ioutil.WriteFile("/etc/docker/daemon.json", []byte(install.Config["daemon.json"]), 600); err != nil {
-		return err
-	}
-
 	fields := strings.Fields(install.Version)
 	if len(fields) != 2 {
 		return fmt.Errorf("container runtime must have the form [runtime] [version], but got %s", install)
 	}
 
-	if fields[0] != "docker-ce" {
-		return errors.New("Only docker-ce is supported yet")
+	switch fields[0] {
+	case "docker-ce":
+		if install.Config == nil {
+			return errors.New("Docker config is nil")
+		}
+
+		if err := os.MkdirAll("/etc/docker", 600); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile("/etc/docker/daemon.json", []byte(install.Config["daemon.json"]), 600); err != nil {
+			return err
+		}
+	case "containerd.io":
+	default:
+		return fmt.Errorf("container runtime %s is not supported", fields[0])
 	}
 
 	version := strings.TrimLeft(fields[1], "v")
 
-	switch c.os.OperatingSystem {
-	case dep.Ubuntu:
-		return c.ensureUbuntu(fields[0], version, leaveOSRepositories)
-	case dep.CentOS:
-		return c.ensureCentOS(fields[0], version, leaveOSRepositories)
+	switch c.os.OperatingSystem.Packages {
+	case dep.DebianBased:
+		return c.ensureUbuntu(fields[0], version, install.Config, leaveOSRepositories)
+	case dep.REMBased, dep.DNFBased:
+		return c.ensureCentOS(fields[0], version, install.Config, leaveOSRepositories)
+	case dep.Immutable:
+		// Immutable operating systems have no package repositories, so containerd is installed from its release archive
+		if fields[0] != "containerd.io" {
+			return fmt.Errorf("container runtime %s is not supported on %s, choose containerd", fields[0], c.os)
+		}
+		return c.runContainerd(version, install.Config["sandboximage"])
 	}
 	return fmt.Errorf("operating system %s is not supported", c.os)
 }
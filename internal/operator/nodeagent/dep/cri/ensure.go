@@ -9,7 +9,7 @@ import (
 	"github.com/caos/orbos/internal/operator/nodeagent/dep"
 )
 
-func (c *criDep) ensureCentOS(runtime string, version string, leaveOSRepositories bool) error {
+func (c *criDep) ensureCentOS(runtime string, version string, config map[string]string, leaveOSRepositories bool) error {
 
 	if err := c.manager.Remove(
 		&dep.Software{Package: "docker"},
@@ -31,6 +31,7 @@ func (c *criDep) ensureCentOS(runtime string, version string, leaveOSRepositorie
 	return c.run(
 		runtime,
 		version,
+		config,
 		"https://download.docker.com/linux/centos/docker-ce.repo",
 		"",
 		"",
@@ -38,7 +39,13 @@ func (c *criDep) ensureCentOS(runtime string, version string, leaveOSRepositorie
 	)
 }
 
-func (c *criDep) ensureUbuntu(runtime string, version string, leaveOSRepositories bool) error {
+// ensureUbuntu also ensures docker on debian, which is distributed the same way
+func (c *criDep) ensureUbuntu(runtime string, version string, config map[string]string, leaveOSRepositories bool) error {
+
+	distribution := "ubuntu"
+	if c.os.OperatingSystem == dep.Debian {
+		distribution = "debian"
+	}
 
 	errBuf := new(bytes.Buffer)
 	defer errBuf.Reset()
@@ -77,16 +84,17 @@ func (c *criDep) ensureUbuntu(runtime string, version string, leaveOSRepositorie
 	return c.run(
 		runtime,
 		strings.TrimSpace(strings.Split(versionLine, "|")[1]),
-		fmt.Sprintf("deb [arch=amd64] https://download.docker.com/linux/ubuntu %s stable", c.os.Version),
-		"https://download.docker.com/linux/ubuntu/gpg",
+		config,
+		fmt.Sprintf("deb [arch=amd64] https://download.docker.com/linux/%s %s stable", distribution, c.os.Version),
+		fmt.Sprintf("https://download.docker.com/linux/%s/gpg", distribution),
 		"0EBFCD88",
 		leaveOSRepositories,
 	)
 }
 
-func (c *criDep) run(runtime, version, repoURL, keyURL, keyFingerprint string, leaveOSRepositories bool) error {
+func (c *criDep) run(runtime, version string, config map[string]string, repoURL, keyURL, keyFingerprint string, leaveOSRepositories bool) error {
 
-	swmonitor := c.monitor.WithField("software", "docker")
+	swmonitor := c.monitor.WithField("software", runtime)
 
 	if !leaveOSRepositories {
 		if err := c.manager.Add(&dep.Repository{
@@ -100,6 +108,10 @@ func (c *criDep) run(runtime, version, repoURL, keyURL, keyFingerprint string, l
 		swmonitor.WithField("url", repoURL).Info("repo added")
 	}
 
+	if runtime == "containerd.io" {
+		return c.runContainerd(version, config["sandboximage"])
+	}
+
 	// Obviously, docker doesn't care about the exact containerd version, so neighter should ORBITER
 	// https://docs.docker.com/engine/install/centos/
 	// https://docs.docker.com/engine/install/ubuntu/
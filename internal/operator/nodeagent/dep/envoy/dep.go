@@ -0,0 +1,192 @@
+package envoy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/middleware"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	// readyListener is the listener keepalived checks
+	readyListener = "ready"
+	binaryPath    = "/usr/local/bin/envoy"
+	configPath    = "/etc/envoy/envoy.yaml"
+	unitPath      = "/etc/systemd/system/envoy.service"
+	downloadURL   = "https://github.com/envoyproxy/envoy/releases/download/v%s/envoy-%s-linux-x86_64"
+	unit          = `[Unit]
+Description=Envoy Proxy
+After=network.target
+
+[Service]
+Type=simple
+User=root
+ExecStart=/usr/local/bin/envoy --config-path /etc/envoy/envoy.yaml --log-level warn
+Restart=always
+RestartSec=10
+LimitNOFILE=8192
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+type Installer interface {
+	isEnvoy()
+	nodeagent.Installer
+}
+
+type envoyDep struct {
+	monitor    mntr.Monitor
+	systemd    *dep.SystemD
+	normalizer *regexp.Regexp
+}
+
+// New returns a dependency which runs the official envoy release binary, as most operating systems don't package envoy
+func New(monitor mntr.Monitor, systemd *dep.SystemD) Installer {
+	return &envoyDep{monitor, systemd, regexp.MustCompile(`\d+\.\d+\.\d+`)}
+}
+
+func (envoyDep) isEnvoy() {}
+
+func (envoyDep) Is(other nodeagent.Installer) bool {
+	_, ok := middleware.Unwrap(other).(Installer)
+	return ok
+}
+
+func (envoyDep) String() string { return "Envoy" }
+
+func (*envoyDep) Equals(other nodeagent.Installer) bool {
+	_, ok := other.(*envoyDep)
+	return ok
+}
+
+func (*envoyDep) InstalledFilter() []string { return nil }
+
+func (s *envoyDep) Current() (pkg common.Package, err error) {
+	if !s.systemd.Active("envoy") {
+		return pkg, nil
+	}
+
+	pkg.Version = s.version()
+
+	config, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkg, nil
+		}
+		return pkg, err
+	}
+
+	pkg.Config = map[string]string{
+		"envoy.yaml": string(config),
+	}
+	return pkg, nil
+}
+
+func (s *envoyDep) Ensure(_ common.Package, ensure common.Package, _ bool) error {
+
+	ensureCfg, ok := ensure.Config["envoy.yaml"]
+	if !ok {
+		if err := s.systemd.Disable("envoy"); err != nil {
+			return err
+		}
+		if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if s.version() != ensure.Version {
+		if err := download(strings.TrimLeft(ensure.Version, "v")); err != nil {
+			return err
+		}
+		s.monitor.WithField("version", ensure.Version).Info("Envoy downloaded")
+	}
+
+	if err := os.MkdirAll("/etc/envoy", 0700); err != nil {
+		return err
+	}
+
+	tmpPath := configPath + ".orbos"
+	if err := ioutil.WriteFile(tmpPath, []byte(ensureCfg), 0600); err != nil {
+		return err
+	}
+
+	if out, err := exec.Command(binaryPath, "--mode", "validate", "--config-path", tmpPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("validating envoy config failed: %s: %w", string(out), err)
+	}
+
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		return err
+	}
+
+	currentUnit, err := ioutil.ReadFile(unitPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if string(currentUnit) != unit {
+		if err := ioutil.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+			return err
+		}
+		if err := s.systemd.DaemonReload(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.systemd.Enable("envoy"); err != nil {
+		return err
+	}
+
+	return s.systemd.Start("envoy")
+}
+
+func (s *envoyDep) version() string {
+	out, err := exec.Command(binaryPath, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	version := s.normalizer.FindString(string(out))
+	if version == "" {
+		return ""
+	}
+	return "v" + version
+}
+
+func download(version string) error {
+
+	url := fmt.Sprintf(downloadURL, version, version)
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("downloading envoy from %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading envoy from %s failed with status %s", url, resp.Status)
+	}
+
+	binary := new(bytes.Buffer)
+	defer binary.Reset()
+	if _, err := io.Copy(binary, resp.Body); err != nil {
+		return err
+	}
+
+	tmpPath := binaryPath + ".orbos"
+	if err := ioutil.WriteFile(tmpPath, binary.Bytes(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, binaryPath)
+}
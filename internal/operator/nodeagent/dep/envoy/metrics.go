@@ -0,0 +1,87 @@
+package envoy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/caos/orbos/internal/operator/nodeagent/metrics"
+	"github.com/caos/orbos/mntr"
+)
+
+// AdminAddress is where the rendered configurations must let envoy serve its admin API
+const AdminAddress = "127.0.0.1:29996"
+
+type collector struct {
+	monitor mntr.Monitor
+	client  *http.Client
+}
+
+// Collector reports the connections envoy rejected, as long as it serves its admin API
+func Collector(monitor mntr.Monitor) prometheus.Collector {
+	return &collector{monitor, &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.RejectedConnections
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	resp, err := c.client.Get(fmt.Sprintf("http://%s/stats", AdminAddress))
+	if err != nil {
+		// envoy is not running
+		return
+	}
+	defer resp.Body.Close()
+
+	rejected, err := rejectedConnections(resp.Body)
+	if err != nil {
+		c.monitor.Error(err)
+		return
+	}
+	metrics.Rejected(ch, "envoy", rejected)
+}
+
+// rejectedConnections sums the connections that matched no whitelisted filter chain,
+// were denied by the rbac filter or exceeded the connection limit per listener stat prefix
+func rejectedConnections(stats io.Reader) (map[string]float64, error) {
+	rejected := make(map[string]float64)
+	scanner := bufio.NewScanner(stats)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := parts[0]
+
+		var listener string
+		switch {
+		case strings.HasPrefix(name, "listener.") && strings.HasSuffix(name, ".no_filter_chain_match"):
+			listener = strings.TrimSuffix(strings.TrimPrefix(name, "listener."), ".no_filter_chain_match")
+		case strings.HasSuffix(name, ".rbac.denied"):
+			listener = strings.TrimSuffix(name, ".rbac.denied")
+		case strings.HasPrefix(name, "connection_limit.") && strings.HasSuffix(name, ".limited_connections"):
+			listener = strings.TrimSuffix(strings.TrimPrefix(name, "connection_limit."), ".limited_connections")
+		default:
+			continue
+		}
+
+		// Listeners without a stat prefix are named by their address
+		if listener == readyListener || listener == "admin" || strings.ContainsAny(listener, ".:") {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing stat %s failed: %w", name, err)
+		}
+		rejected[listener] += value
+	}
+	return rejected, scanner.Err()
+}
@@ -0,0 +1,26 @@
+package envoy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRejectedConnections(t *testing.T) {
+	stats := `cluster.kubeapi.upstream_cx_total: 12
+connection_limit.kubeapi.limited_connections: 3
+kubeapi.rbac.allowed: 9
+kubeapi.rbac.denied: 2
+listener.0.0.0.0_29997.no_filter_chain_match: 0
+listener.admin.downstream_cx_total: 1
+listener.kubeapi.no_filter_chain_match: 5
+listener.ready.no_filter_chain_match: 1
+`
+	rejected, err := rejectedConnections(strings.NewReader(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := map[string]float64{"kubeapi": 10}; !reflect.DeepEqual(rejected, expect) {
+		t.Errorf("expected rejected connections %v, but got %v", expect, rejected)
+	}
+}
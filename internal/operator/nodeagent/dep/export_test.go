@@ -0,0 +1,4 @@
+package dep
+
+// ReleaseChecksums exposes the pinned checksums to the black box tests
+var ReleaseChecksums = releaseChecksums
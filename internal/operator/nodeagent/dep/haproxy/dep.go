@@ -0,0 +1,163 @@
+package haproxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/middleware"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/selinux"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	configPath = "/etc/haproxy/haproxy.cfg"
+	// SocketPath is where the rendered configurations must let HAProxy serve its runtime API
+	SocketPath = "/var/lib/haproxy/admin.sock"
+)
+
+type Installer interface {
+	isHAProxy()
+	nodeagent.Installer
+}
+
+type haproxyDep struct {
+	manager *dep.PackageManager
+	systemd *dep.SystemD
+	monitor mntr.Monitor
+	os      dep.OperatingSystem
+	version *regexp.Regexp
+}
+
+// New returns a dependency which installs HAProxy from the operating systems repositories.
+// As the available versions differ between operating systems, the version is not managed.
+func New(monitor mntr.Monitor, manager *dep.PackageManager, systemd *dep.SystemD, os dep.OperatingSystem) Installer {
+	return &haproxyDep{manager, systemd, monitor, os, regexp.MustCompile(`version (\d+)\.(\d+)`)}
+}
+
+func (haproxyDep) isHAProxy() {}
+
+func (haproxyDep) Is(other nodeagent.Installer) bool {
+	_, ok := middleware.Unwrap(other).(Installer)
+	return ok
+}
+
+func (haproxyDep) String() string { return "HAProxy" }
+
+func (*haproxyDep) Equals(other nodeagent.Installer) bool {
+	_, ok := other.(*haproxyDep)
+	return ok
+}
+
+func (*haproxyDep) InstalledFilter() []string {
+	return []string{"haproxy"}
+}
+
+func (s *haproxyDep) Current() (pkg common.Package, err error) {
+	if !s.systemd.Active("haproxy") {
+		return pkg, nil
+	}
+
+	config, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkg, nil
+		}
+		return pkg, err
+	}
+
+	pkg.Config = map[string]string{
+		"haproxy.cfg": string(config),
+	}
+	return pkg, nil
+}
+
+func (s *haproxyDep) Ensure(remove common.Package, ensure common.Package, _ bool) error {
+
+	if err := selinux.EnsurePermissive(s.monitor, s.os, remove); err != nil {
+		return err
+	}
+
+	ensureCfg, ok := ensure.Config["haproxy.cfg"]
+	if !ok {
+		if err := s.systemd.Disable("haproxy"); err != nil {
+			return err
+		}
+		if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	removeCfg, installed := remove.Config["haproxy.cfg"]
+	if !installed {
+		if err := s.manager.Install(&dep.Software{Package: "haproxy"}); err != nil {
+			return fmt.Errorf("installing software failed: %w", err)
+		}
+		if err := s.checkVersion(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll("/etc/haproxy", 0700); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll("/var/lib/haproxy", 0700); err != nil {
+		return err
+	}
+
+	if installed {
+		removed := removedServers(servers(removeCfg), servers(ensureCfg))
+		if len(removed) > 0 {
+			if err := drain(s.monitor, SocketPath, removed); err != nil {
+				s.monitor.Info(fmt.Sprintf("Draining removed servers failed: %s", err.Error()))
+			}
+		}
+	}
+
+	tmpPath := configPath + ".orbos"
+	if err := ioutil.WriteFile(tmpPath, []byte(ensureCfg), 0600); err != nil {
+		return err
+	}
+
+	if out, err := exec.Command("haproxy", "-c", "-f", tmpPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("validating haproxy config failed: %s: %w", string(out), err)
+	}
+
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		return err
+	}
+
+	if err := s.systemd.Enable("haproxy"); err != nil {
+		return err
+	}
+
+	return s.systemd.Reload("haproxy")
+}
+
+// checkVersion ensures the runtime API supports draining servers
+func (s *haproxyDep) checkVersion() error {
+	out, err := exec.Command("haproxy", "-v").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("getting haproxy version failed: %s: %w", string(out), err)
+	}
+
+	match := s.version.FindStringSubmatch(string(out))
+	if len(match) != 3 {
+		return fmt.Errorf("parsing haproxy version from %s failed", string(out))
+	}
+
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	if major < 1 || major == 1 && minor < 8 {
+		return fmt.Errorf("haproxy %s.%s from the repositories of %s is too old, at least 1.8 is needed", match[1], match[2], s.os)
+	}
+	return nil
+}
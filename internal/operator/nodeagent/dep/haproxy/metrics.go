@@ -0,0 +1,42 @@
+package haproxy
+
+import (
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/caos/orbos/internal/operator/nodeagent/metrics"
+	"github.com/caos/orbos/mntr"
+)
+
+type collector struct {
+	monitor mntr.Monitor
+}
+
+// Collector reports the connections HAProxy rejected, as long as it serves its runtime API
+func Collector(monitor mntr.Monitor) prometheus.Collector {
+	return &collector{monitor}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.RejectedConnections
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	if _, err := os.Stat(SocketPath); err != nil {
+		return
+	}
+
+	stats, err := runtimeAPI(SocketPath, "show stat")
+	if err != nil {
+		c.monitor.Error(err)
+		return
+	}
+
+	rejected, err := rejectedConnections(stats)
+	if err != nil {
+		c.monitor.Error(err)
+		return
+	}
+	metrics.Rejected(ch, "haproxy", rejected)
+}
@@ -0,0 +1,198 @@
+package haproxy
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	drainTimeout = 30 * time.Second
+	// readyProxy is the name of the listen section keepalived checks
+	readyProxy = "ready"
+)
+
+// servers returns the servers of all backends in the form backend/server
+func servers(config string) map[string]struct{} {
+	srvs := make(map[string]struct{})
+	var backend string
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "backend", "listen":
+			backend = fields[1]
+		case "frontend", "global", "defaults":
+			backend = ""
+		case "server":
+			if backend != "" {
+				srvs[backend+"/"+fields[1]] = struct{}{}
+			}
+		}
+	}
+	return srvs
+}
+
+func removedServers(before, after map[string]struct{}) []string {
+	var removed []string
+	for srv := range before {
+		if _, ok := after[srv]; !ok {
+			removed = append(removed, srv)
+		}
+	}
+	return removed
+}
+
+// drain stops HAProxy from sending new connections to the servers and waits for their current sessions to finish
+func drain(monitor mntr.Monitor, socket string, srvs []string) error {
+
+	for _, srv := range srvs {
+		if _, err := runtimeAPI(socket, fmt.Sprintf("set server %s state drain", srv)); err != nil {
+			return err
+		}
+	}
+	monitor.WithField("servers", srvs).Info("Draining servers")
+
+	timeout := time.After(drainTimeout)
+	for {
+		stats, err := runtimeAPI(socket, "show stat")
+		if err != nil {
+			return err
+		}
+
+		sessions, err := currentSessions(stats)
+		if err != nil {
+			return err
+		}
+
+		var open int
+		for _, srv := range srvs {
+			open += sessions[srv]
+		}
+
+		if open == 0 {
+			return nil
+		}
+
+		select {
+		case <-timeout:
+			return fmt.Errorf("%d sessions are still open after %s", open, drainTimeout)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func runtimeAPI(socket, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		return "", err
+	}
+
+	resp, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	return string(resp), nil
+}
+
+// parseStats parses the CSV output of show stat into one map per proxy row
+func parseStats(stats string) ([]map[string]string, error) {
+
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(stats, "# ")))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("no stats returned")
+	}
+
+	header := records[0]
+	for _, required := range []string{"pxname", "svname"} {
+		var found bool
+		for _, col := range header {
+			found = found || col == required
+		}
+		if !found {
+			return nil, fmt.Errorf("unexpected stats header %s", strings.Join(header, ","))
+		}
+	}
+
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for idx, col := range header {
+			if idx < len(record) {
+				row[col] = record[idx]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// currentSessions returns the current sessions in the form backend/server
+func currentSessions(stats string) (map[string]int, error) {
+
+	rows, err := parseStats(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]int)
+	for _, row := range rows {
+		if row["scur"] == "" {
+			continue
+		}
+		current, err := strconv.Atoi(row["scur"])
+		if err != nil {
+			return nil, fmt.Errorf("parsing current sessions of %s/%s failed: %w", row["pxname"], row["svname"], err)
+		}
+		sessions[row["pxname"]+"/"+row["svname"]] = current
+	}
+	return sessions, nil
+}
+
+// rejectedConnections returns the connections each frontend denied by tcp-request connection rules
+func rejectedConnections(stats string) (map[string]float64, error) {
+
+	rows, err := parseStats(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected := make(map[string]float64)
+	for _, row := range rows {
+		if row["svname"] != "FRONTEND" || row["pxname"] == readyProxy || row["dcon"] == "" {
+			continue
+		}
+		denied, err := strconv.ParseFloat(row["dcon"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing denied connections of %s failed: %w", row["pxname"], err)
+		}
+		rejected[row["pxname"]] = denied
+	}
+	return rejected, nil
+}
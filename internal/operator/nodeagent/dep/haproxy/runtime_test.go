@@ -0,0 +1,72 @@
+package haproxy
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRemovedServers(t *testing.T) {
+	before := `global
+	maxconn 8192
+
+frontend kubeapi
+	bind 10.0.0.1:6443
+	default_backend kubeapi
+
+backend kubeapi
+	server cp.1 10.0.0.11:6666 check
+	server cp.2 10.0.0.12:6666 check
+	server cp.3 10.0.0.13:6666 check
+`
+	after := `global
+	maxconn 8192
+
+frontend kubeapi
+	bind 10.0.0.1:6443
+	default_backend kubeapi
+
+backend kubeapi
+	server cp.1 10.0.0.11:6666 check
+	server cp.4 10.0.0.14:6666 check
+`
+	removed := removedServers(servers(before), servers(after))
+	sort.Strings(removed)
+	if expect := []string{"kubeapi/cp.2", "kubeapi/cp.3"}; !reflect.DeepEqual(removed, expect) {
+		t.Errorf("expected removed servers %v, but got %v", expect, removed)
+	}
+}
+
+func TestCurrentSessions(t *testing.T) {
+	stats := `# pxname,svname,qcur,qmax,scur,smax,slim,stot,
+kubeapi,FRONTEND,,,4,10,8192,120,
+kubeapi,cp.1,0,0,3,5,,60,
+kubeapi,cp.2,0,0,0,5,,60,
+kubeapi,BACKEND,0,0,3,10,820,120,
+
+`
+	sessions, err := currentSessions(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sessions["kubeapi/cp.1"] != 3 || sessions["kubeapi/cp.2"] != 0 {
+		t.Errorf("unexpected sessions %v", sessions)
+	}
+}
+
+func TestRejectedConnections(t *testing.T) {
+	stats := `# pxname,svname,scur,dcon,
+ready,FRONTEND,0,0,
+kubeapi,FRONTEND,4,17,
+kubeapi,cp.1,3,,
+kubeapi,BACKEND,3,,
+
+`
+	rejected, err := rejectedConnections(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := map[string]float64{"kubeapi": 17}; !reflect.DeepEqual(rejected, expect) {
+		t.Errorf("expected rejected connections %v, but got %v", expect, rejected)
+	}
+}
@@ -1,7 +1,6 @@
 package k8s
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"regexp"
@@ -39,9 +38,14 @@ func (c *Common) Ensure(remove common.Package, install common.Package, leaveOSRe
 		}
 	}()
 
+	// Immutable operating systems get the release binaries, which are versioned without package revisions
+	if c.os.Packages == dep.Immutable {
+		return c.manager.Install(&dep.Software{Package: c.pkg, Version: strings.TrimLeft(install.Version, "v")})
+	}
+
 	if !leaveOSRepositories {
-		switch c.os {
-		case dep.Ubuntu:
+		switch c.os.Packages {
+		case dep.DebianBased:
 			if err := c.manager.Add(&dep.Repository{
 				KeyURL:         "https://packages.cloud.google.com/apt/doc/apt-key.gpg",
 				KeyFingerprint: "",
@@ -49,7 +53,7 @@ func (c *Common) Ensure(remove common.Package, install common.Package, leaveOSRe
 			}); err != nil {
 				return err
 			}
-		case dep.CentOS:
+		case dep.REMBased, dep.DNFBased:
 			if err := ioutil.WriteFile("/etc/yum.repos.d/kubernetes.repo", []byte(`[kubernetes]
 name=Kubernetes
 baseurl=https://packages.cloud.google.com/yum/repos/kubernetes-el7-x86_64
@@ -60,12 +64,12 @@ gpgkey=https://packages.cloud.google.com/yum/doc/yum-key.gpg https://packages.cl
 				return err
 			}
 		default:
-			return errors.New("unknown OS")
+			return fmt.Errorf("adding the kubernetes repository on %s is not supported", c.os)
 		}
 	}
 
 	pkgVersion := strings.TrimLeft(install.Version, "v") + "-0"
-	if c.os == dep.Ubuntu {
+	if c.os.Packages == dep.DebianBased {
 		pkgVersion += "0"
 	}
 	return c.manager.Install(&dep.Software{Package: c.pkg, Version: pkgVersion})
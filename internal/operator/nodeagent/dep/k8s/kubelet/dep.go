@@ -66,7 +66,7 @@ func (k *kubeletDep) Ensure(remove common.Package, install common.Package, leave
 		return err
 	}
 
-	if k.os != dep.CentOS {
+	if !k.os.RedHatBased() {
 		return k.ensurePackage(remove, install, leaveOSRepositories)
 	}
 
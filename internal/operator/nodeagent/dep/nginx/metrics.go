@@ -0,0 +1,81 @@
+package nginx
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/caos/orbos/internal/operator/nodeagent/metrics"
+	"github.com/caos/orbos/mntr"
+)
+
+// RejectedSyslog is where the rendered configurations must let NGINX log rejected connections.
+// As NGINX doesn't count them itself, the node agent receives the log messages and counts them.
+const RejectedSyslog = "127.0.0.1:29995"
+
+type collector struct {
+	mux      sync.Mutex
+	rejected map[string]float64
+}
+
+// Collector reports the connections NGINX logged as rejected until the context is done
+func Collector(ctx context.Context, monitor mntr.Monitor) (prometheus.Collector, error) {
+
+	conn, err := net.ListenPacket("udp", RejectedSyslog)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &collector{rejected: make(map[string]float64)}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if ctx.Err() == nil {
+					monitor.Error(err)
+				}
+				return
+			}
+			if listener := rejectedListener(string(buf[:n])); listener != "" {
+				c.mux.Lock()
+				c.rejected[listener]++
+				c.mux.Unlock()
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metrics.RejectedConnections
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	metrics.Rejected(ch, "nginx", c.rejected)
+}
+
+// rejectedListener parses syslog messages like <190>Oct 18 10:00:00 orbos: kubeapi 403
+func rejectedListener(message string) string {
+	idx := strings.Index(message, "orbos: ")
+	if idx < 0 {
+		return ""
+	}
+	fields := strings.Fields(message[idx+len("orbos: "):])
+	if len(fields) != 2 {
+		return ""
+	}
+	return fields[0]
+}
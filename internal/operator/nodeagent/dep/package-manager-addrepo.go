@@ -3,9 +3,12 @@ package dep
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -133,3 +136,162 @@ func (p *PackageManager) debbasedAdd(repo *Repository) error {
 	p.monitor.Debug("Updated index")
 	return nil
 }
+
+func (p *PackageManager) dnfbasedAdd(repo *Repository) error {
+
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+
+	cmd := exec.Command("dnf", "config-manager", "--add-repo", repo.Repository)
+	cmd.Stderr = errBuf
+	if p.monitor.IsVerbose() {
+		fmt.Println(strings.Join(cmd.Args, " "))
+		cmd.Stdout = os.Stdout
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("adding dnf repository %s failed with stderr %s: %w", repo.Repository, errBuf.String(), err)
+	}
+	return nil
+}
+
+// debbasedAddSigned adds the repository to its own sources list file and trusts its key only for this repository
+func (p *PackageManager) debbasedAddSigned(repo *Repository) error {
+
+	name, err := debRepositoryName(repo.Repository)
+	if err != nil {
+		return err
+	}
+
+	line := repo.Repository
+	if repo.KeyURL != "" {
+		keyring := filepath.Join("/etc/apt/keyrings", name+".gpg")
+		if err := p.debbasedAddKeyring(repo, keyring); err != nil {
+			return err
+		}
+		line = debSignedBy(repo.Repository, keyring)
+	}
+
+	sourcesList := filepath.Join("/etc/apt/sources.list.d", name+".list")
+	if err := ioutil.WriteFile(sourcesList, []byte(line+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing %s failed: %w", sourcesList, err)
+	}
+	p.monitor.WithFields(map[string]interface{}{
+		"repository": repo.Repository,
+		"file":       sourcesList,
+	}).Debug("Added repository")
+
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+	cmd := exec.Command("apt-get", strings.Fields("--assume-yes --allow-downgrades update")...)
+	cmd.Stderr = errBuf
+	if p.monitor.IsVerbose() {
+		fmt.Println(strings.Join(cmd.Args, " "))
+		cmd.Stdout = os.Stdout
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("updating indices failed with stderr %s: %w", errBuf.String(), err)
+	}
+	p.monitor.Debug("Updated index")
+	return nil
+}
+
+func (p *PackageManager) debbasedAddKeyring(repo *Repository, keyring string) error {
+
+	resp, err := http.Get(repo.KeyURL)
+	if err != nil {
+		return fmt.Errorf("getting key from url %s failed: %w", repo.KeyURL, err)
+	}
+	defer resp.Body.Close()
+
+	key, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading key from url %s failed: %w", repo.KeyURL, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyring), 0755); err != nil {
+		return err
+	}
+
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+
+	// apt only reads binary keyrings
+	if bytes.Contains(key, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		cmd := exec.Command("gpg", "--batch", "--yes", "--dearmor", "--output", keyring)
+		cmd.Stdin = bytes.NewReader(key)
+		cmd.Stderr = errBuf
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("dearmoring key from url %s failed with stderr %s: %w", repo.KeyURL, errBuf.String(), err)
+		}
+	} else if err := ioutil.WriteFile(keyring, key, 0644); err != nil {
+		return fmt.Errorf("writing keyring %s failed: %w", keyring, err)
+	}
+	p.monitor.WithFields(map[string]interface{}{
+		"url":     repo.KeyURL,
+		"keyring": keyring,
+	}).Debug("Added repository key from url")
+
+	if repo.KeyFingerprint == "" {
+		return nil
+	}
+
+	errBuf.Reset()
+	cmd := exec.Command("gpg", "--show-keys", "--with-colons", keyring)
+	cmd.Stderr = errBuf
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("verifying fingerprint %s failed with stderr %s: %w", repo.KeyFingerprint, errBuf.String(), err)
+	}
+
+	if !debKeyringHasFingerprint(string(out), repo.KeyFingerprint) {
+		os.Remove(keyring)
+		return fmt.Errorf("no key with fingerprint %s found", repo.KeyFingerprint)
+	}
+	p.monitor.WithFields(map[string]interface{}{
+		"url":         repo.KeyURL,
+		"fingerprint": repo.KeyFingerprint,
+	}).Debug("Checked fingerprint")
+	return nil
+}
+
+// debRepositoryName derives a file name from the repositories host
+func debRepositoryName(repository string) (string, error) {
+	for _, field := range strings.Fields(repository) {
+		if !strings.HasPrefix(field, "http://") && !strings.HasPrefix(field, "https://") {
+			continue
+		}
+		repoURL, err := url.Parse(field)
+		if err != nil {
+			return "", fmt.Errorf("parsing repository url %s failed: %w", field, err)
+		}
+		return repoURL.Hostname(), nil
+	}
+	return "", fmt.Errorf("no url found in repository %s", repository)
+}
+
+// debSignedBy adds the signed-by option to a one line style repository
+func debSignedBy(repository, keyring string) string {
+	fields := strings.Fields(repository)
+	if len(fields) < 2 {
+		return repository
+	}
+	signedBy := "signed-by=" + keyring
+	if strings.HasPrefix(fields[1], "[") {
+		fields[1] = "[" + signedBy + " " + strings.TrimPrefix(fields[1], "[")
+	} else {
+		fields = append(fields[:1], append([]string{"[" + signedBy + "]"}, fields[1:]...)...)
+	}
+	return strings.Join(fields, " ")
+}
+
+func debKeyringHasFingerprint(gpgColons, fingerprint string) bool {
+	fingerprint = strings.ToUpper(strings.ReplaceAll(fingerprint, " ", ""))
+	for _, line := range strings.Split(gpgColons, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) > 9 && fields[0] == "fpr" && strings.HasSuffix(fields[9], fingerprint) {
+			return true
+		}
+	}
+	return false
+}
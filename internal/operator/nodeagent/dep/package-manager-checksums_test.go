@@ -0,0 +1,22 @@
+package dep_test
+
+import (
+	"testing"
+
+	"github.com/caos/orbos/internal/operator/nodeagent/dep"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes"
+)
+
+func TestReleaseChecksumsArePinned(t *testing.T) {
+	for pkg, versions := range kubernetes.ReleasedVersions() {
+		urls, err := dep.ReleaseChecksumURLs(pkg, versions, "amd64")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for url := range urls {
+			if _, ok := dep.ReleaseChecksums[url]; !ok {
+				t.Errorf("no sha256 checksum is pinned for %s, run go generate ./internal/operator/nodeagent/dep", url)
+			}
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package dep
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ImmutableBinDir is where the node agent installs binaries on immutable operating systems, as /usr is read-only there
+const ImmutableBinDir = "/opt/bin"
+
+//go:generate go run ../../../../cmd/chore/gen-release-checksums -out package-manager-checksums.go
+
+// release is software the node agent installs from official release downloads on immutable operating systems.
+// Downloads are only installed if their sha256 checksum is pinned in releaseChecksums.
+type release struct {
+	// url returns the download url for a version and an architecture
+	url func(version, arch string) string
+	// checksumSuffix is appended to the download url for getting the sha256 checksum when the checksums are generated
+	checksumSuffix string
+	// archive maps directories in the downloaded tar.gz archive to directories on the host.
+	// If it is nil, the download is the binary itself.
+	archive map[string]string
+	// binary prints the installed version when it is called with the versionArgs
+	binary      string
+	versionArgs []string
+	units       map[string]string
+}
+
+var (
+	releaseVersion = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+	// releaseClient times out, so a stalled download doesn't block the node agents iteration
+	releaseClient = &http.Client{Timeout: 10 * time.Minute}
+
+	kubernetesRelease = func(binary string, versionArgs []string, units map[string]string) *release {
+		return &release{
+			url: func(version, arch string) string {
+				return fmt.Sprintf("https://dl.k8s.io/release/v%s/bin/linux/%s/%s", version, arch, binary)
+			},
+			checksumSuffix: ".sha256",
+			binary:         filepath.Join(ImmutableBinDir, binary),
+			versionArgs:    versionArgs,
+			units:          units,
+		}
+	}
+
+	releases = map[string]*release{
+		"kubelet": kubernetesRelease("kubelet", []string{"--version"}, map[string]string{
+			"/etc/systemd/system/kubelet.service": `[Unit]
+Description=kubelet: The Kubernetes Node Agent
+Documentation=https://kubernetes.io/docs/home/
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+ExecStart=/opt/bin/kubelet
+Restart=always
+StartLimitInterval=0
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+`,
+			"/etc/systemd/system/kubelet.service.d/10-kubeadm.conf": `[Service]
+Environment="KUBELET_KUBECONFIG_ARGS=--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubelet.conf --kubeconfig=/etc/kubernetes/kubelet.conf"
+Environment="KUBELET_CONFIG_ARGS=--config=/var/lib/kubelet/config.yaml"
+EnvironmentFile=-/var/lib/kubelet/kubeadm-flags.env
+EnvironmentFile=-/etc/default/kubelet
+ExecStart=
+ExecStart=/opt/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_KUBEADM_ARGS $KUBELET_EXTRA_ARGS
+`,
+		}),
+		"kubeadm": kubernetesRelease("kubeadm", []string{"version", "--output", "short"}, nil),
+		"kubectl": kubernetesRelease("kubectl", []string{"version", "--client"}, nil),
+		// The cri-containerd-cni archive bundles containerd with runc, crictl and the CNI plugins, which the kubernetes packages depend on
+		"containerd.io": {
+			url: func(version, arch string) string {
+				return fmt.Sprintf("https://github.com/containerd/containerd/releases/download/v%s/cri-containerd-cni-%s-linux-%s.tar.gz", version, version, arch)
+			},
+			checksumSuffix: ".sha256sum",
+			archive: map[string]string{
+				"usr/local/bin":  ImmutableBinDir,
+				"usr/local/sbin": ImmutableBinDir,
+				"opt/cni/bin":    "/opt/cni/bin",
+			},
+			binary:      filepath.Join(ImmutableBinDir, "containerd"),
+			versionArgs: []string{"--version"},
+			units: map[string]string{
+				"/etc/systemd/system/containerd.service": `[Unit]
+Description=containerd container runtime
+Documentation=https://containerd.io
+After=network.target local-fs.target
+
+[Service]
+Environment="PATH=/opt/bin:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+ExecStartPre=-/sbin/modprobe overlay
+ExecStart=/opt/bin/containerd
+Type=notify
+Delegate=yes
+KillMode=process
+Restart=always
+RestartSec=5
+LimitNPROC=infinity
+LimitCORE=infinity
+LimitNOFILE=infinity
+TasksMax=infinity
+OOMScoreAdjust=-999
+
+[Install]
+WantedBy=multi-user.target
+`,
+			},
+		},
+	}
+)
+
+// immutableInstalled reports the versions of the released software the node agent installed
+func (p *PackageManager) immutableInstalled(filter []string) error {
+	p.installed = make(map[string][]string)
+	for _, pkg := range filter {
+		r, ok := releases[pkg]
+		if !ok {
+			continue
+		}
+		out, err := exec.Command(r.binary, r.versionArgs...).Output()
+		if err != nil {
+			continue
+		}
+		if version := releaseVersion.FindString(string(out)); version != "" {
+			p.installed[pkg] = []string{version}
+		}
+	}
+	return nil
+}
+
+func (p *PackageManager) immutableInstall(install ...*Software) error {
+
+	var imageOnly []*Software
+	for _, sw := range install {
+		r, ok := releases[sw.Package]
+		if !ok {
+			imageOnly = append(imageOnly, sw)
+			continue
+		}
+
+		if sw.Version == "" {
+			return fmt.Errorf("installing %s on %s needs a version", sw.Package, p.os)
+		}
+
+		if installed, ok := p.installed[sw.Package]; ok && installed[0] == sw.Version {
+			continue
+		}
+
+		if err := r.install(sw.Version, runtime.GOARCH); err != nil {
+			return fmt.Errorf("installing %s failed: %w", sw, err)
+		}
+
+		if err := p.ensureUnits(r.units); err != nil {
+			return err
+		}
+
+		p.installed[sw.Package] = []string{sw.Version}
+		p.monitor.WithFields(map[string]interface{}{
+			"package": sw.Package,
+			"version": sw.Version,
+		}).Info("Installed release")
+	}
+	return p.immutable("installing", imageOnly)
+}
+
+func (p *PackageManager) immutableRemove(remove ...*Software) error {
+
+	var imageOnly []*Software
+	for _, sw := range remove {
+		r, ok := releases[sw.Package]
+		if !ok {
+			imageOnly = append(imageOnly, sw)
+			continue
+		}
+
+		for _, path := range append([]string{r.binary}, unitPaths(r.units)...) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("removing %s failed: %w", sw.Package, err)
+			}
+		}
+		delete(p.installed, sw.Package)
+	}
+	return p.immutable("removing", imageOnly)
+}
+
+// immutable fails, as software that is not released as binaries has to be shipped with the image of immutable operating systems
+func (p *PackageManager) immutable(action string, software []*Software) error {
+	if len(software) == 0 {
+		return nil
+	}
+	pkgs := make([]string, len(software))
+	for idx, sw := range software {
+		pkgs[idx] = sw.String()
+	}
+	return fmt.Errorf("%s packages [%s] is not possible on %s, they have to be part of the image", action, strings.Join(pkgs, ", "), p.os)
+}
+
+func (p *PackageManager) ensureUnits(units map[string]string) error {
+
+	var changed bool
+	for path, unit := range units {
+		current, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if string(current) == unit {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(unit), 0644); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return p.systemd.DaemonReload()
+}
+
+func unitPaths(units map[string]string) []string {
+	paths := make([]string, 0, len(units))
+	for path := range units {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func (r *release) install(version, arch string) error {
+
+	url := r.url(version, arch)
+	checksum, ok := releaseChecksums[url]
+	if !ok {
+		return fmt.Errorf("no sha256 checksum is pinned for %s", url)
+	}
+
+	download, err := get(url)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyChecksum(download, []byte(checksum)); err != nil {
+		return fmt.Errorf("verifying %s failed: %w", url, err)
+	}
+
+	if r.archive == nil {
+		return writeExecutable(r.binary, bytes.NewReader(download))
+	}
+	return extract(bytes.NewReader(download), r.archive)
+}
+
+func get(url string) ([]byte, error) {
+	resp, err := releaseClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s failed with status %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ReleaseChecksumURLs maps the download urls of a released package to the urls of their published sha256 checksums
+func ReleaseChecksumURLs(pkg string, versions []string, arch string) (map[string]string, error) {
+	r, ok := releases[pkg]
+	if !ok {
+		return nil, fmt.Errorf("package %s is not installed from release downloads", pkg)
+	}
+	urls := make(map[string]string, len(versions))
+	for _, version := range versions {
+		url := r.url(version, arch)
+		urls[url] = url + r.checksumSuffix
+	}
+	return urls, nil
+}
+
+// verifyChecksum accepts checksum files which contain only the hex encoded sha256 sum as well as sha256sum outputs
+func verifyChecksum(download, checksum []byte) error {
+	fields := strings.Fields(string(checksum))
+	if len(fields) == 0 {
+		return errors.New("checksum is empty")
+	}
+	sum := sha256.Sum256(download)
+	if actual := hex.EncodeToString(sum[:]); actual != strings.ToLower(fields[0]) {
+		return fmt.Errorf("expected sha256 checksum %s, but got %s", fields[0], actual)
+	}
+	return nil
+}
+
+// extract writes the regular files of the mapped directories in a tar.gz archive to the host
+func extract(archive io.Reader, dirs map[string]string) error {
+
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dir, file := filepath.Split(filepath.Clean(strings.TrimPrefix(header.Name, "./")))
+		to, ok := dirs[strings.TrimSuffix(dir, "/")]
+		if !ok {
+			continue
+		}
+
+		if err := writeExecutable(filepath.Join(to, file), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// writeExecutable replaces files atomically, so running binaries are not corrupted
+func writeExecutable(path string, content io.Reader) error {
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".orbos"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
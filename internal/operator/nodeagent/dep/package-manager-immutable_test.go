@@ -0,0 +1,113 @@
+package dep
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	download := []byte("kubelet")
+	sum := sha256.Sum256(download)
+	hexSum := hex.EncodeToString(sum[:])
+
+	for _, tt := range []struct {
+		name     string
+		checksum string
+		fails    bool
+	}{{
+		name:     "It should accept plain checksums",
+		checksum: hexSum + "\n",
+	}, {
+		name:     "It should accept sha256sum outputs",
+		checksum: hexSum + "  cri-containerd-cni-1.4.3-linux-amd64.tar.gz\n",
+	}, {
+		name:     "It should fail for other checksums",
+		checksum: hex.EncodeToString(make([]byte, sha256.Size)),
+		fails:    true,
+	}, {
+		name:  "It should fail for empty checksums",
+		fails: true,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyChecksum(download, []byte(tt.checksum)); (err != nil) != tt.fails {
+				t.Errorf("verifyChecksum() error = %v, expected failing to be %t", err, tt.fails)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+
+	archive := new(bytes.Buffer)
+	gz := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		"usr/local/bin/containerd":     "containerd",
+		"./usr/local/sbin/runc":        "runc",
+		"opt/cni/bin/loopback":         "loopback",
+		"etc/systemd/system/x.service": "ignored",
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	bin, cni := filepath.Join(root, "bin"), filepath.Join(root, "cni")
+	if err := extract(archive, map[string]string{
+		"usr/local/bin":  bin,
+		"usr/local/sbin": bin,
+		"opt/cni/bin":    cni,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expect := range map[string]string{
+		filepath.Join(bin, "containerd"): "containerd",
+		filepath.Join(bin, "runc"):       "runc",
+		filepath.Join(cni, "loopback"):   "loopback",
+	} {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expect {
+			t.Errorf("expected %s to contain %s, but got %s", path, expect, string(content))
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&0100 == 0 {
+			t.Errorf("expected %s to be executable", path)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "x.service")); !os.IsNotExist(err) {
+		t.Errorf("expected unmapped files not to be extracted, but got %v", err)
+	}
+}
+
+func TestInstall_unpinned(t *testing.T) {
+	r := &release{url: func(version, arch string) string { return "http://127.0.0.1:1/" + version + "/" + arch }}
+	if err := r.install("0.0.0", "amd64"); err == nil || !strings.Contains(err.Error(), "no sha256 checksum is pinned") {
+		t.Errorf("expected installing a release without a pinned checksum to fail before downloading it, but got %v", err)
+	}
+}
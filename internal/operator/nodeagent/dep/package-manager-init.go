@@ -24,10 +24,17 @@ func (p *PackageManager) debSpecificUpdatePackages() error {
 	return nil
 }
 func (p *PackageManager) debSpecificInit() error {
+	if p.os == Bionic {
+		return p.debbasedInstall(
+			&Software{Package: "apt-transport-https"},
+			&Software{Package: "gnupg2"},
+			&Software{Package: "software-properties-common"})
+	}
 	return p.debbasedInstall(
 		&Software{Package: "apt-transport-https"},
-		&Software{Package: "gnupg2"},
-		&Software{Package: "software-properties-common"})
+		&Software{Package: "ca-certificates"},
+		&Software{Package: "gnupg"},
+		&Software{Package: "nftables"})
 }
 
 func (p *PackageManager) remSpecificInit() error {
@@ -91,3 +98,36 @@ func (p *PackageManager) remSpecificUpdatePackages() error {
 	}
 	return nil
 }
+
+func (p *PackageManager) dnfSpecificInit() error {
+
+	if err := p.remSpecificDisableGPGRepoCheckForGcloudRepo(); err != nil {
+		return err
+	}
+
+	return p.rembasedInstall(
+		&Software{Package: "dnf-plugins-core"},
+		&Software{Package: "python3-dnf-plugin-versionlock"},
+		&Software{Package: "nftables"},
+	)
+}
+
+func (p *PackageManager) dnfSpecificUpdatePackages() error {
+
+	if err := p.remSpecificDisableGPGRepoCheckForGcloudRepo(); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("dnf", "--assumeyes", "--errorlevel", "0", "--debuglevel", "3", "upgrade")
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+	cmd.Stderr = errBuf
+	if p.monitor.IsVerbose() {
+		fmt.Println(strings.Join(cmd.Args, " "))
+		cmd.Stdout = os.Stdout
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("updating dnf packages failed with stderr %s: %w", errBuf.String(), err)
+	}
+	return nil
+}
@@ -34,7 +34,7 @@ func (p *PackageManager) rembasedInstall(install ...*Software) error {
 
 		installPkg := fmt.Sprintf("%s-%s", sw.Package, sw.Version)
 		installPkgs = append(installPkgs, installPkg)
-		cmd := exec.Command("yum", "versionlock", "delete", sw.Package)
+		cmd := exec.Command(p.rpmManager(), "versionlock", "delete", sw.Package)
 		cmd.Stderr = errBuf
 		if p.monitor.IsVerbose() {
 			fmt.Println(strings.Join(cmd.Args, " "))
@@ -42,12 +42,12 @@ func (p *PackageManager) rembasedInstall(install ...*Software) error {
 		}
 		err := cmd.Run()
 		stderr := errBuf.String()
-		if err != nil && !strings.Contains(stderr, "versionlock delete: no matches") {
+		if err != nil && !strings.Contains(stderr, "versionlock delete: no matches") && !strings.Contains(stderr, "No match") {
 			return fmt.Errorf("unlocking package %s failed with stderr %s: %w", sw.Package, stderr, err)
 		}
 		errBuf.Reset()
 
-		cmd = exec.Command("yum", "versionlock", "add", "-y", installPkg)
+		cmd = exec.Command(p.rpmManager(), "versionlock", "add", "-y", installPkg)
 		cmd.Stderr = errBuf
 		if p.monitor.IsVerbose() {
 			fmt.Println(strings.Join(cmd.Args, " "))
@@ -60,19 +60,19 @@ func (p *PackageManager) rembasedInstall(install ...*Software) error {
 	}
 
 	for _, pkg := range installPkgs {
-		if err := rembasedInstallPkg(p.monitor, pkg); err != nil {
+		if err := rembasedInstallPkg(p.monitor, p.rpmManager(), pkg); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func rembasedInstallPkg(monitor mntr.Monitor, pkg string) error {
+func rembasedInstallPkg(monitor mntr.Monitor, manager, pkg string) error {
 	errBuf := new(bytes.Buffer)
 	defer errBuf.Reset()
 	outBuf := new(bytes.Buffer)
 	defer outBuf.Reset()
-	cmd := exec.Command("yum", "install", "-y", pkg)
+	cmd := exec.Command(manager, "install", "-y", pkg)
 	cmd.Stderr = errBuf
 	cmd.Stdout = outBuf
 	err := cmd.Run()
@@ -82,9 +82,9 @@ func rembasedInstallPkg(monitor mntr.Monitor, pkg string) error {
 		"command": fmt.Sprintf("'%s'", strings.Join(cmd.Args, "' '")),
 		"stdout":  outStr,
 		"stderr":  errStr,
-	}).Debug("Executed yum install")
+	}).Debug(fmt.Sprintf("Executed %s install", manager))
 	if err != nil && !strings.Contains(errStr+outStr, "is already installed") {
-		return fmt.Errorf("installing yum package %s failed with stderr %s: %w", pkg, errStr, err)
+		return fmt.Errorf("installing %s package %s failed with stderr %s: %w", manager, pkg, errStr, err)
 	}
 	return nil
 }
@@ -26,7 +26,7 @@ func (p *PackageManager) rembasedRemove(remove ...*Software) error {
 	outBuf := new(bytes.Buffer)
 	defer outBuf.Reset()
 
-	cmd := exec.Command("yum", append([]string{"--assumeyes", "remove"}, swStrs...)...)
+	cmd := exec.Command(p.rpmManager(), append([]string{"--assumeyes", "remove"}, swStrs...)...)
 	cmd.Stderr = errBuf
 	cmd.Stdout = outBuf
 	err := cmd.Run()
@@ -36,9 +36,46 @@ func (p *PackageManager) rembasedRemove(remove ...*Software) error {
 		"command": fmt.Sprintf("'%s'", strings.Join(cmd.Args, "' '")),
 		"stdout":  outStr,
 		"stderr":  errStr,
-	}).Debug("Executed yum remove")
+	}).Debug(fmt.Sprintf("Executed %s remove", p.rpmManager()))
 	if err != nil {
-		return fmt.Errorf("removing yum packages [%s] failed with stderr %s: %w", strings.Join(swStrs, ", "), errStr, err)
+		return fmt.Errorf("removing %s packages [%s] failed with stderr %s: %w", p.rpmManager(), strings.Join(swStrs, ", "), errStr, err)
+	}
+	return nil
+}
+
+func (p *PackageManager) debbasedRemove(remove ...*Software) error {
+
+	if len(remove) == 0 {
+		return nil
+	}
+
+	pkgs := make([]string, len(remove))
+	for i, sw := range remove {
+		pkgs[i] = sw.Package
+		if sw.Version != "" {
+			pkgs[i] = fmt.Sprintf("%s=%s", sw.Package, sw.Version)
+		}
+	}
+
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+	outBuf := new(bytes.Buffer)
+	defer outBuf.Reset()
+
+	// Packages ORBITER installed with a version are held
+	cmd := exec.Command("apt-get", append(strings.Fields("--assume-yes --allow-change-held-packages remove"), pkgs...)...)
+	cmd.Stderr = errBuf
+	cmd.Stdout = outBuf
+	err := cmd.Run()
+	errStr := errBuf.String()
+	outStr := outBuf.String()
+	p.monitor.WithFields(map[string]interface{}{
+		"command": fmt.Sprintf("'%s'", strings.Join(cmd.Args, "' '")),
+		"stdout":  outStr,
+		"stderr":  errStr,
+	}).Debug("Executed apt-get remove")
+	if err != nil {
+		return fmt.Errorf("removing apt packages [%s] failed with stderr %s: %w", strings.Join(pkgs, ", "), errStr, err)
 	}
 	return nil
 }
@@ -0,0 +1,198 @@
+package dep
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SecurityUpdate is an available package update which fixes vulnerabilities
+type SecurityUpdate struct {
+	Software
+	Advisory string
+	Severity string
+}
+
+// SecurityUpdates lists the available security updates. Locked and held packages are not updated, so kernels pinned by ORBITER stay as they are.
+func (p *PackageManager) SecurityUpdates() ([]*SecurityUpdate, error) {
+	switch p.os.OperatingSystem.Packages {
+	case DebianBased:
+		return p.debbasedSecurityUpdates()
+	case REMBased, DNFBased:
+		return p.rembasedSecurityUpdates()
+	case Immutable:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("package manager %s is not implemented", p.os.OperatingSystem.Packages)
+}
+
+func (p *PackageManager) ApplySecurityUpdates() error {
+	p.monitor.Info("Applying security updates")
+	var err error
+	switch p.os.OperatingSystem.Packages {
+	case DebianBased:
+		err = p.debbasedApplySecurityUpdates()
+	case REMBased, DNFBased:
+		err = p.run(exec.Command(p.rpmManager(), "--assumeyes", "--security", "update"))
+	case Immutable:
+		p.monitor.Debug("Immutable operating systems update themselves")
+		return nil
+	default:
+		err = fmt.Errorf("package manager %s is not implemented", p.os.OperatingSystem.Packages)
+	}
+
+	if err != nil {
+		return fmt.Errorf("applying security updates failed: %w", err)
+	}
+	p.monitor.Info("Security updates applied")
+	return nil
+}
+
+// RebootRequired returns true if updated packages are only used after a reboot
+func (p *PackageManager) RebootRequired() bool {
+	switch p.os.OperatingSystem.Packages {
+	case DebianBased:
+		_, err := os.Stat("/var/run/reboot-required")
+		return err == nil
+	case REMBased, DNFBased:
+		// needs-restarting is part of yum-utils and dnf-plugins-core and exits with 1 if a reboot is required
+		err := exec.Command("needs-restarting", "-r").Run()
+		exitErr := &exec.ExitError{}
+		return errors.As(err, &exitErr) && exitErr.ExitCode() == 1
+	}
+	return false
+}
+
+func (p *PackageManager) rembasedSecurityUpdates() ([]*SecurityUpdate, error) {
+	list := exec.Command("yum", "--quiet", "updateinfo", "list", "security")
+	if p.os.OperatingSystem.Packages == DNFBased {
+		list = exec.Command("dnf", "--quiet", "updateinfo", "list", "--security")
+	}
+	out, err := p.output(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []*SecurityUpdate
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// RHSA-2021:1024 Important/Sec. openssl-1:1.1.1g-15.el8_3.x86_64
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || !strings.HasSuffix(fields[1], "/Sec.") {
+			continue
+		}
+		pkg, version := splitNEVRA(fields[2])
+		updates = append(updates, &SecurityUpdate{
+			Software: Software{Package: pkg, Version: version},
+			Advisory: fields[0],
+			Severity: strings.TrimSuffix(fields[1], "/Sec."),
+		})
+	}
+	return updates, scanner.Err()
+}
+
+// splitNEVRA splits a package like openssl-1:1.1.1g-15.el8_3.x86_64 into the name and the version
+func splitNEVRA(nevra string) (string, string) {
+	if idx := strings.LastIndex(nevra, "."); idx > 0 {
+		nevra = nevra[:idx]
+	}
+	parts := strings.Split(nevra, "-")
+	if len(parts) < 3 {
+		return nevra, ""
+	}
+	return strings.Join(parts[:len(parts)-2], "-"), strings.Join(parts[len(parts)-2:], "-")
+}
+
+func (p *PackageManager) debbasedSecurityUpdates() ([]*SecurityUpdate, error) {
+	if err := p.debSpecificUpdatePackages(); err != nil {
+		return nil, err
+	}
+
+	out, err := p.output(exec.Command("apt", "list", "--upgradable"))
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := p.debbasedHeld()
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []*SecurityUpdate
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// openssl/focal-security,focal-updates 1.1.1f-1ubuntu2.16 amd64 [upgradable from: 1.1.1f-1ubuntu2.1]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		parts := strings.SplitN(fields[0], "/", 2)
+		if len(parts) != 2 || !strings.Contains(parts[1], "-security") || held[parts[0]] {
+			continue
+		}
+		updates = append(updates, &SecurityUpdate{
+			Software: Software{Package: parts[0], Version: fields[1]},
+		})
+	}
+	return updates, scanner.Err()
+}
+
+func (p *PackageManager) debbasedHeld() (map[string]bool, error) {
+	out, err := p.output(exec.Command("apt-mark", "showhold"))
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[string]bool)
+	for _, pkg := range strings.Fields(string(out)) {
+		held[pkg] = true
+	}
+	return held, nil
+}
+
+func (p *PackageManager) debbasedApplySecurityUpdates() error {
+	updates, err := p.debbasedSecurityUpdates()
+	if err != nil || len(updates) == 0 {
+		return err
+	}
+
+	pkgs := make([]string, len(updates))
+	for idx, update := range updates {
+		pkgs[idx] = update.Package
+	}
+
+	cmd := exec.Command("apt-get", append([]string{"--assume-yes", "--only-upgrade", "install"}, pkgs...)...)
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	return p.run(cmd)
+}
+
+func (p *PackageManager) output(cmd *exec.Cmd) ([]byte, error) {
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+	cmd.Stderr = errBuf
+	if p.monitor.IsVerbose() {
+		fmt.Println(strings.Join(cmd.Args, " "))
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %s failed with stderr %s: %w", strings.Join(cmd.Args, " "), errBuf.String(), err)
+	}
+	return out, nil
+}
+
+func (p *PackageManager) run(cmd *exec.Cmd) error {
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+	cmd.Stderr = errBuf
+	if p.monitor.IsVerbose() {
+		fmt.Println(strings.Join(cmd.Args, " "))
+		cmd.Stdout = os.Stdout
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s failed with stderr %s: %w", strings.Join(cmd.Args, " "), errBuf.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package dep
+
+import "testing"
+
+func TestSplitNEVRA(t *testing.T) {
+	for _, testCase := range []struct {
+		nevra, pkg, version string
+	}{
+		{"openssl-1:1.1.1g-15.el8_3.x86_64", "openssl", "1:1.1.1g-15.el8_3"},
+		{"python3-libs-3.6.8-41.el8.x86_64", "python3-libs", "3.6.8-41.el8"},
+		{"tzdata-2021a-1.el8.noarch", "tzdata", "2021a-1.el8"},
+	} {
+		pkg, version := splitNEVRA(testCase.nevra)
+		if pkg != testCase.pkg || version != testCase.version {
+			t.Errorf("splitNEVRA(%s) = %s, %s, want %s, %s", testCase.nevra, pkg, version, testCase.pkg, testCase.version)
+		}
+	}
+}
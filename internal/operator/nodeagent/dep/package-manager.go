@@ -23,18 +23,20 @@ type Repository struct {
 
 type PackageManager struct {
 	monitor   mntr.Monitor
-	os        OperatingSystem
+	os        OperatingSystemMajor
 	installed map[string][]string
 	systemd   *SystemD
 }
 
 func (p *PackageManager) RefreshInstalled(filter []string) error {
 	var err error
-	switch p.os.Packages {
+	switch p.os.OperatingSystem.Packages {
 	case DebianBased:
 		err = p.debbasedInstalled()
-	case REMBased:
+	case REMBased, DNFBased:
 		err = p.rembasedInstalled(filter)
+	case Immutable:
+		err = p.immutableInstalled(filter)
 	}
 
 	if err != nil {
@@ -50,15 +52,17 @@ func (p *PackageManager) Init() error {
 
 	p.monitor.Debug("Initializing package manager")
 	var err error
-	switch p.os.Packages {
+	switch p.os.OperatingSystem.Packages {
 	case DebianBased:
 		err = p.debSpecificInit()
 	case REMBased:
 		err = p.remSpecificInit()
+	case DNFBased:
+		err = p.dnfSpecificInit()
 	}
 
 	if err != nil {
-		return fmt.Errorf("initializing packages %s failed: %w", p.os.Packages, err)
+		return fmt.Errorf("initializing packages %s failed: %w", p.os.OperatingSystem.Packages, err)
 	}
 
 	p.monitor.Debug("Package manager initialized")
@@ -68,22 +72,27 @@ func (p *PackageManager) Init() error {
 func (p *PackageManager) Update() error {
 	p.monitor.Debug("Updating packages")
 	var err error
-	switch p.os.Packages {
+	switch p.os.OperatingSystem.Packages {
 	case DebianBased:
 		err = p.debSpecificUpdatePackages()
 	case REMBased:
 		err = p.remSpecificUpdatePackages()
+	case DNFBased:
+		err = p.dnfSpecificUpdatePackages()
+	case Immutable:
+		p.monitor.Debug("Immutable operating systems update themselves")
+		return nil
 	}
 
 	if err != nil {
-		return fmt.Errorf("updating packages %s failed: %w", p.os.Packages, err)
+		return fmt.Errorf("updating packages %s failed: %w", p.os.OperatingSystem.Packages, err)
 	}
 
 	p.monitor.Info("Packages updated")
 	return nil
 }
 
-func NewPackageManager(monitor mntr.Monitor, os OperatingSystem, systemd *SystemD) *PackageManager {
+func NewPackageManager(monitor mntr.Monitor, os OperatingSystemMajor, systemd *SystemD) *PackageManager {
 	return &PackageManager{monitor, os, nil, systemd}
 }
 
@@ -111,33 +120,52 @@ func (p *PackageManager) CurrentVersions(possiblePackages ...string) []*Software
 }
 
 func (p *PackageManager) Install(installVersion ...*Software) error {
-	switch p.os.Packages {
+	switch p.os.OperatingSystem.Packages {
 	case DebianBased:
 		return p.debbasedInstall(installVersion...)
-	case REMBased:
+	case REMBased, DNFBased:
 		return p.rembasedInstall(installVersion...)
+	case Immutable:
+		return p.immutableInstall(installVersion...)
 	}
-	return fmt.Errorf("package manager %s is not implemented", p.os.Packages)
+	return fmt.Errorf("package manager %s is not implemented", p.os.OperatingSystem.Packages)
 }
 
 func (p *PackageManager) Add(repo *Repository) error {
-	switch p.os.Packages {
+	switch p.os.OperatingSystem.Packages {
 	case DebianBased:
-		return p.debbasedAdd(repo)
+		if p.os == Bionic {
+			return p.debbasedAdd(repo)
+		}
+		return p.debbasedAddSigned(repo)
 	case REMBased:
 		return p.rembasedAdd(repo)
+	case DNFBased:
+		return p.dnfbasedAdd(repo)
+	case Immutable:
+		return fmt.Errorf("adding repository %s is not possible on %s", repo.Repository, p.os)
 	default:
-		return fmt.Errorf("package manager %s is not implemented", p.os.Packages)
+		return fmt.Errorf("package manager %s is not implemented", p.os.OperatingSystem.Packages)
 	}
 }
 
 func (p *PackageManager) Remove(remove ...*Software) error {
-	switch p.os.Packages {
+	switch p.os.OperatingSystem.Packages {
 	case DebianBased:
-		panic("removing software on debian bases systems is not yet implemented")
-	case REMBased:
+		return p.debbasedRemove(remove...)
+	case REMBased, DNFBased:
 		return p.rembasedRemove(remove...)
+	case Immutable:
+		return p.immutableRemove(remove...)
 	default:
-		return fmt.Errorf("package manager %s is not implemented", p.os.Packages)
+		return fmt.Errorf("package manager %s is not implemented", p.os.OperatingSystem.Packages)
+	}
+}
+
+// rpmManager is the command line tool which manages rpm packages
+func (p *PackageManager) rpmManager() string {
+	if p.os.OperatingSystem.Packages == DNFBased {
+		return "dnf"
 	}
+	return "yum"
 }
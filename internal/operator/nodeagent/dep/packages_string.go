@@ -11,11 +11,13 @@ func _() {
 	_ = x[UnknownPkg-0]
 	_ = x[DebianBased-1]
 	_ = x[REMBased-2]
+	_ = x[DNFBased-3]
+	_ = x[Immutable-4]
 }
 
-const _Packages_name = "UnknownPkgDebianBasedREMBased"
+const _Packages_name = "UnknownPkgDebianBasedREMBasedDNFBasedImmutable"
 
-var _Packages_index = [...]uint8{0, 10, 21, 29}
+var _Packages_index = [...]uint8{0, 10, 21, 29, 37, 46}
 
 func (i Packages) String() string {
 	if i < 0 || i >= Packages(len(_Packages_index)-1) {
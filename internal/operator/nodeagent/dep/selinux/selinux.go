@@ -14,7 +14,7 @@ import (
 
 func Current(os dep.OperatingSystem, pkg *common.Package) (err error) {
 
-	if os != dep.CentOS {
+	if !os.RedHatBased() {
 		return nil
 	}
 
@@ -56,7 +56,7 @@ func Current(os dep.OperatingSystem, pkg *common.Package) (err error) {
 
 func EnsurePermissive(monitor mntr.Monitor, opsys dep.OperatingSystem, remove common.Package) error {
 
-	if opsys != dep.CentOS || remove.Config["selinux"] == "permissive" {
+	if !opsys.RedHatBased() || remove.Config["selinux"] == "permissive" {
 		return nil
 	}
 
@@ -3,9 +3,10 @@
 package dep
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
-	"os/exec"
+	"io/ioutil"
 	"strings"
 )
 
@@ -15,27 +16,32 @@ const (
 	UnknownPkg Packages = iota
 	DebianBased
 	REMBased
+	DNFBased
+	Immutable
 )
 
 type OperatingSystem struct {
 	Packages Packages
+	Name     string
 }
 
 func (o OperatingSystem) String() string {
-	var os string
-	switch o {
-	case Ubuntu:
-		os = "Ubuntu"
-	case CentOS:
-		os = "CentOS"
-	}
-	return os
+	return o.Name
+}
+
+// RedHatBased operating systems need SELinux to be permissive and install rpm packages
+func (o OperatingSystem) RedHatBased() bool {
+	return o.Packages == REMBased || o.Packages == DNFBased
 }
 
 var (
 	UnknownOS = OperatingSystem{}
-	Ubuntu    = OperatingSystem{DebianBased}
-	CentOS    = OperatingSystem{REMBased}
+	Ubuntu    = OperatingSystem{DebianBased, "Ubuntu"}
+	Debian    = OperatingSystem{DebianBased, "Debian"}
+	CentOS    = OperatingSystem{REMBased, "CentOS"}
+	Rocky     = OperatingSystem{DNFBased, "Rocky Linux"}
+	Alma      = OperatingSystem{DNFBased, "AlmaLinux"}
+	Flatcar   = OperatingSystem{Immutable, "Flatcar Container Linux"}
 )
 
 type OperatingSystemMajor struct {
@@ -48,61 +54,102 @@ func (o OperatingSystemMajor) String() string {
 	switch o {
 	case Bionic:
 		versionName = "18.04 LTS Bionic Beaver"
-	case CentOS7:
-		versionName = "7"
+	case Focal:
+		versionName = "20.04 LTS Focal Fossa"
+	case Jammy:
+		versionName = "22.04 LTS Jammy Jellyfish"
+	case Bullseye:
+		versionName = "11 Bullseye"
+	case Bookworm:
+		versionName = "12 Bookworm"
+	default:
+		versionName = o.Version
 	}
 
-	return fmt.Sprintf("%s %s", o.OperatingSystem, versionName)
+	return strings.TrimSpace(fmt.Sprintf("%s %s", o.OperatingSystem, versionName))
 }
 
 var (
-	Unknown = OperatingSystemMajor{UnknownOS, ""}
-	Bionic  = OperatingSystemMajor{Ubuntu, "bionic"}
-	CentOS7 = OperatingSystemMajor{CentOS, "7"}
-
-	//	Debian OperatingSystem = OperatingSystem{DebianBased}
+	Unknown  = OperatingSystemMajor{UnknownOS, ""}
+	Bionic   = OperatingSystemMajor{Ubuntu, "bionic"}
+	Focal    = OperatingSystemMajor{Ubuntu, "focal"}
+	Jammy    = OperatingSystemMajor{Ubuntu, "jammy"}
+	Bullseye = OperatingSystemMajor{Debian, "bullseye"}
+	Bookworm = OperatingSystemMajor{Debian, "bookworm"}
+	CentOS7  = OperatingSystemMajor{CentOS, "7"}
+	Rocky8   = OperatingSystemMajor{Rocky, "8"}
+	Rocky9   = OperatingSystemMajor{Rocky, "9"}
+	Alma8    = OperatingSystemMajor{Alma, "8"}
+	Alma9    = OperatingSystemMajor{Alma, "9"}
+	// Flatcar is released continuously, so its versions are not distinguished
+	FlatcarStable = OperatingSystemMajor{Flatcar, ""}
 )
 
 func GetOperatingSystem() (OperatingSystemMajor, error) {
-	buf := new(bytes.Buffer)
-	defer buf.Reset()
-
-	hostnamectl := exec.Command("hostnamectl")
-	hostnamectl.Stdout = buf
-
-	if err := hostnamectl.Run(); err != nil {
-		return Unknown, fmt.Errorf("running hostnamectl in order to get operating system information failed: %w", err)
+	osRelease, err := ioutil.ReadFile("/etc/os-release")
+	if err != nil {
+		return Unknown, fmt.Errorf("reading /etc/os-release in order to get operating system information failed: %w", err)
 	}
+	return parseOSRelease(osRelease)
+}
+
+func parseOSRelease(osRelease []byte) (OperatingSystemMajor, error) {
 
-	var (
-		osLine string
-		err    error
-	)
-	for err == nil {
-		osLine, err = buf.ReadString('\n')
-		if strings.Contains(osLine, "Operating System") {
-			break
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(osRelease))
+	for scanner.Scan() {
+		kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(kv) != 2 {
+			continue
 		}
+		fields[kv[0]] = strings.Trim(kv[1], `"'`)
 	}
-
-	if err != nil {
-		return Unknown, fmt.Errorf("finding line containing \"Operating System\" in hostnamectl output failed: %w", err)
+	if err := scanner.Err(); err != nil {
+		return Unknown, err
 	}
 
-	os := strings.Fields(osLine)[2]
-	switch os {
-	case "Ubuntu":
-		version := strings.Fields(osLine)[3]
-		if strings.HasPrefix(version, "18.04") {
-			return Bionic, nil
+	id, version := fields["ID"], fields["VERSION_ID"]
+	major := strings.Split(version, ".")[0]
+
+	switch id {
+	case "ubuntu":
+		for _, supported := range []OperatingSystemMajor{Bionic, Focal, Jammy} {
+			if fields["VERSION_CODENAME"] == supported.Version || fields["UBUNTU_CODENAME"] == supported.Version {
+				return supported, nil
+			}
 		}
 		return Unknown, fmt.Errorf("unsupported ubuntu version %s", version)
-	case "CentOS":
-		version := strings.Fields(osLine)[4]
-		if version == "7" {
+	case "debian":
+		switch major {
+		case "11":
+			return Bullseye, nil
+		case "12":
+			return Bookworm, nil
+		}
+		return Unknown, fmt.Errorf("unsupported debian version %s", version)
+	case "centos":
+		if major == "7" {
 			return CentOS7, nil
 		}
 		return Unknown, fmt.Errorf("unsupported centOS version %s", version)
+	case "rocky":
+		switch major {
+		case "8":
+			return Rocky8, nil
+		case "9":
+			return Rocky9, nil
+		}
+		return Unknown, fmt.Errorf("unsupported rocky linux version %s", version)
+	case "almalinux":
+		switch major {
+		case "8":
+			return Alma8, nil
+		case "9":
+			return Alma9, nil
+		}
+		return Unknown, fmt.Errorf("unsupported almalinux version %s", version)
+	case "flatcar":
+		return FlatcarStable, nil
 	}
-	return Unknown, fmt.Errorf("unknown operating system %s", os)
+	return Unknown, fmt.Errorf("unknown operating system %s", id)
 }
@@ -0,0 +1,77 @@
+package dep
+
+import "testing"
+
+func TestParseOSRelease(t *testing.T) {
+	for _, testCase := range []struct {
+		osRelease string
+		expect    OperatingSystemMajor
+		fails     bool
+	}{{
+		osRelease: `NAME="Ubuntu"
+VERSION="22.04.3 LTS (Jammy Jellyfish)"
+ID=ubuntu
+ID_LIKE=debian
+VERSION_ID="22.04"
+VERSION_CODENAME=jammy
+UBUNTU_CODENAME=jammy`,
+		expect: Jammy,
+	}, {
+		osRelease: `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"
+ID=debian
+VERSION_ID="12"
+VERSION_CODENAME=bookworm`,
+		expect: Bookworm,
+	}, {
+		osRelease: `NAME="CentOS Linux"
+VERSION="7 (Core)"
+ID="centos"
+ID_LIKE="rhel fedora"
+VERSION_ID="7"`,
+		expect: CentOS7,
+	}, {
+		osRelease: `NAME="Rocky Linux"
+ID="rocky"
+ID_LIKE="rhel centos fedora"
+VERSION_ID="9.2"`,
+		expect: Rocky9,
+	}, {
+		osRelease: `NAME="AlmaLinux"
+ID="almalinux"
+VERSION_ID="8.8"`,
+		expect: Alma8,
+	}, {
+		osRelease: `NAME="Flatcar Container Linux by Kinvolk"
+ID=flatcar
+VERSION_ID=3510.2.0`,
+		expect: FlatcarStable,
+	}, {
+		osRelease: `ID=ubuntu
+VERSION_ID="16.04"
+VERSION_CODENAME=xenial`,
+		fails: true,
+	}, {
+		osRelease: `ID=arch`,
+		fails:     true,
+	}} {
+		os, err := parseOSRelease([]byte(testCase.osRelease))
+		if (err != nil) != testCase.fails {
+			t.Errorf("expected failing to be %t, but got error %v for\n%s", testCase.fails, err, testCase.osRelease)
+			continue
+		}
+		if os != testCase.expect {
+			t.Errorf("expected %s, but got %s", testCase.expect, os)
+		}
+	}
+}
+
+func TestDebSignedBy(t *testing.T) {
+	for repository, expect := range map[string]string{
+		"deb https://apt.kubernetes.io/ kubernetes-xenial main":                     "deb [signed-by=/k.gpg] https://apt.kubernetes.io/ kubernetes-xenial main",
+		"deb [arch=amd64] https://download.docker.com/linux/debian bookworm stable": "deb [signed-by=/k.gpg arch=amd64] https://download.docker.com/linux/debian bookworm stable",
+	} {
+		if signed := debSignedBy(repository, "/k.gpg"); signed != expect {
+			t.Errorf("expected %s, but got %s", expect, signed)
+		}
+	}
+}
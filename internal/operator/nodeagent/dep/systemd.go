@@ -131,3 +131,30 @@ func (s *SystemD) UnitPath(unit string) (string, error) {
 
 	return strings.Trim(strings.TrimPrefix(outStr, expectOutputPrefix), "\n"), nil
 }
+
+// Reload lets the unit reload its configuration gracefully if it supports it and restarts it otherwise
+func (s *SystemD) Reload(binary string) error {
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+
+	cmd := exec.Command("systemctl", "reload-or-restart", binary)
+	cmd.Stderr = errBuf
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("reloading %s from systemd failed with stderr %s: %w", binary, errBuf.String(), err)
+	}
+	return nil
+}
+
+func (s *SystemD) DaemonReload() error {
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+
+	cmd := exec.Command("systemctl", "daemon-reload")
+	cmd.Stderr = errBuf
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("reloading systemd units failed with stderr %s: %w", errBuf.String(), err)
+	}
+	return nil
+}
@@ -14,25 +14,35 @@ import (
 )
 
 type FirewallEnsurer interface {
-	Query(desired common.Firewall) (current common.FirewallCurrent, ensure func() error, err error)
+	Query(desired *common.Firewall) (current common.FirewallCurrent, ensure func() error, err error)
 }
 
-type FirewallEnsurerFunc func(desired common.Firewall) (current common.FirewallCurrent, ensure func() error, err error)
+type FirewallEnsurerFunc func(desired *common.Firewall) (current common.FirewallCurrent, ensure func() error, err error)
 
-func (f FirewallEnsurerFunc) Query(desired common.Firewall) (current common.FirewallCurrent, ensure func() error, err error) {
+func (f FirewallEnsurerFunc) Query(desired *common.Firewall) (current common.FirewallCurrent, ensure func() error, err error) {
 	return f(desired)
 }
 
 type NetworkingEnsurer interface {
-	Query(desired common.Networking) (current common.NetworkingCurrent, ensure func() error, err error)
+	Query(desired *common.Networking) (current common.NetworkingCurrent, ensure func() error, err error)
 }
 
-type NetworkingEnsurerFunc func(desired common.Networking) (current common.NetworkingCurrent, ensure func() error, err error)
+type NetworkingEnsurerFunc func(desired *common.Networking) (current common.NetworkingCurrent, ensure func() error, err error)
 
-func (f NetworkingEnsurerFunc) Query(desired common.Networking) (current common.NetworkingCurrent, ensure func() error, err error) {
+func (f NetworkingEnsurerFunc) Query(desired *common.Networking) (current common.NetworkingCurrent, ensure func() error, err error) {
 	return f(desired)
 }
 
+type CustomEnsurer interface {
+	Query(files []*common.File, units []*common.Unit) (current *common.CustomCurrent, ensure func() error, err error)
+}
+
+type CustomEnsurerFunc func(files []*common.File, units []*common.Unit) (current *common.CustomCurrent, ensure func() error, err error)
+
+func (f CustomEnsurerFunc) Query(files []*common.File, units []*common.Unit) (current *common.CustomCurrent, ensure func() error, err error) {
+	return f(files, units)
+}
+
 type Dependency struct {
 	Installer Installer
 	Desired   common.Package
@@ -42,6 +52,10 @@ type Dependency struct {
 type Converter interface {
 	ToDependencies(common.Software) []*Dependency
 	ToSoftware([]*Dependency, func(Dependency) common.Package) common.Software
+	SecurityUpdates() ([]*common.SecurityUpdate, error)
+	ApplySecurityUpdates() error
+	// RebootRequired is true if updated packages are only used after a reboot
+	RebootRequired() bool
 }
 
 type Installer interface {
@@ -58,6 +72,7 @@ func prepareQuery(
 	commit string,
 	firewallEnsurer FirewallEnsurer,
 	networkingEnsurer NetworkingEnsurer,
+	customEnsurer CustomEnsurer,
 	conv Converter,
 ) func(common.NodeAgentSpec, *common.NodeAgentCurrent) (func() error, error) {
 
@@ -83,6 +98,7 @@ func prepareQuery(
 		}
 
 		curr.Booted = t
+		curr.RebootRequired = rebootRequired(monitor, desired.RebootSentinel)
 
 		if desired.RebootRequired.After(curr.Booted) {
 			curr.NodeIsReady = false
@@ -100,14 +116,14 @@ func prepareQuery(
 		}
 
 		var ensureNetworking func() error
-		curr.Networking, ensureNetworking, err = networkingEnsurer.Query(*desired.Networking)
+		curr.Networking, ensureNetworking, err = networkingEnsurer.Query(desired.Networking)
 		if err != nil {
 			return noop, err
 		}
 		curr.Networking.Sort()
 
 		var ensureFirewall func() error
-		curr.Open, ensureFirewall, err = firewallEnsurer.Query(*desired.Firewall)
+		curr.Open, ensureFirewall, err = firewallEnsurer.Query(desired.Firewall)
 		if err != nil {
 			return noop, err
 		}
@@ -122,10 +138,32 @@ func prepareQuery(
 			return dep.Current
 		})
 
+		var ensureCustom func() error
+		curr.Custom, ensureCustom, err = customEnsurer.Query(desired.Files, desired.Units)
+		if err != nil {
+			return noop, err
+		}
+
+		ensureSecurityUpdates := querySecurityUpdates(monitor, conv, desired.SecurityUpdates, curr)
+
 		divergentSw := deriveFilter(divergent, append([]*Dependency(nil), installedSw...))
 		if len(divergentSw) == 0 && ensureFirewall == nil && ensureNetworking == nil {
 			curr.NodeIsReady = true
-			return noop, nil
+			if !desired.ChangesAllowed {
+				return noop, nil
+			}
+			// Custom files and units and security updates don't make the node unready
+			return func() error {
+				if ensureCustom != nil {
+					if err := ensureCustom(); err != nil {
+						return err
+					}
+				}
+				if ensureSecurityUpdates != nil {
+					return ensureSecurityUpdates()
+				}
+				return nil
+			}, nil
 		}
 
 		if curr.NodeIsReady {
@@ -165,8 +203,14 @@ func prepareQuery(
 				}, divergentSw)).Info("Ensuring software")
 			}
 			ensureDep := ensureFunc(monitor, conv, curr, desired.LeaveOSRepositories)
-			_, err := deriveTraverse(ensureDep, divergentSw)
-			return err
+			if _, err := deriveTraverse(ensureDep, divergentSw); err != nil {
+				return err
+			}
+
+			if ensureCustom != nil {
+				return ensureCustom()
+			}
+			return nil
 		}, nil
 	}
 }
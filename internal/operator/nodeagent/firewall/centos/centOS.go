@@ -13,7 +13,7 @@ import (
 )
 
 func Ensurer(monitor mntr.Monitor, open []string) nodeagent.FirewallEnsurer {
-	return nodeagent.FirewallEnsurerFunc(func(desired common.Firewall) (common.FirewallCurrent, func() error, error) {
+	return nodeagent.FirewallEnsurerFunc(func(desired *common.Firewall) (common.FirewallCurrent, func() error, error) {
 		ensurers := make([]func() error, 0)
 		current := make(common.FirewallCurrent, 0)
 
@@ -81,7 +81,7 @@ func Ensurer(monitor mntr.Monitor, open []string) nodeagent.FirewallEnsurer {
 	})
 }
 
-func ensureZone(monitor mntr.Monitor, zoneName string, desired common.Firewall, currentFW map[string]Zone, open []string) (*common.ZoneDesc, func() error, error) {
+func ensureZone(monitor mntr.Monitor, zoneName string, desired *common.Firewall, currentFW map[string]Zone, open []string) (*common.ZoneDesc, func() error, error) {
 	current := &common.ZoneDesc{
 		Name:       zoneName,
 		Interfaces: []string{},
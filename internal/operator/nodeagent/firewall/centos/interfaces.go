@@ -6,7 +6,7 @@ import (
 	"github.com/caos/orbos/internal/operator/common"
 )
 
-func getEnsureAndRemoveInterfaces(zoneName string, current *common.ZoneDesc, desired common.Firewall) ([]string, []string) {
+func getEnsureAndRemoveInterfaces(zoneName string, current *common.ZoneDesc, desired *common.Firewall) ([]string, []string) {
 
 	ensureIfaces := make([]string, 0)
 	removeIfaces := make([]string, 0)
@@ -7,7 +7,7 @@ import (
 func getEnsureMasquerade(
 	zoneName string,
 	current *common.ZoneDesc,
-	desired common.Firewall,
+	desired *common.Firewall,
 	currentZone Zone,
 ) string {
 	ensureMasquerade := ""
@@ -11,7 +11,7 @@ func getAddAndRemoveSources(
 	monitor mntr.Monitor,
 	zoneName string,
 	current *common.ZoneDesc,
-	desired common.Firewall,
+	desired *common.Firewall,
 ) (
 	[]string,
 	[]string,
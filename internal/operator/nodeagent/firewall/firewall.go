@@ -4,13 +4,20 @@ import (
 	"github.com/caos/orbos/internal/operator/nodeagent"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep"
 	"github.com/caos/orbos/internal/operator/nodeagent/firewall/centos"
+	"github.com/caos/orbos/internal/operator/nodeagent/firewall/nftables"
 	"github.com/caos/orbos/mntr"
 )
 
-func Ensurer(monitor mntr.Monitor, os dep.OperatingSystem, open []string) nodeagent.FirewallEnsurer {
-	switch os {
+func Ensurer(monitor mntr.Monitor, os dep.OperatingSystemMajor, open []string) nodeagent.FirewallEnsurer {
+	switch os.OperatingSystem {
 	case dep.CentOS:
 		return centos.Ensurer(monitor, open)
+	case dep.Rocky, dep.Alma, dep.Ubuntu, dep.Debian, dep.Flatcar:
+		// Bionics nftables version is too old
+		if os == dep.Bionic {
+			return noopEnsurer()
+		}
+		return nftables.Ensurer(monitor, open)
 	default:
 		return noopEnsurer()
 	}
@@ -0,0 +1,114 @@
+package nftables
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	rulesetPath = "/etc/orbos/firewall.nft"
+	unitPath    = "/etc/systemd/system/orbos-firewall.service"
+	unit        = `[Unit]
+Description=ORBOS Firewall
+Wants=network-pre.target
+Before=network-pre.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStart=/usr/sbin/nft -f ` + rulesetPath + `
+
+[Install]
+WantedBy=multi-user.target
+`
+)
+
+// Ensurer manages an nftables ruleset for operating systems where firewalld is not available or not desired.
+// The ruleset is loaded at boot time by a dedicated systemd unit.
+func Ensurer(monitor mntr.Monitor, open []string) nodeagent.FirewallEnsurer {
+	return nodeagent.FirewallEnsurerFunc(func(desired *common.Firewall) (common.FirewallCurrent, func() error, error) {
+
+		desiredRuleset := ruleset(desired, open)
+
+		appliedRuleset, err := ioutil.ReadFile(rulesetPath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+		_, tableErr := runCommand(monitor, "nft", "list", "table", "inet", table)
+		_, firewalldInactiveErr := runCommand(monitor, "systemctl", "is-active", "firewalld")
+		_, disabledErr := runCommand(monitor, "systemctl", "is-enabled", "orbos-firewall")
+
+		if string(appliedRuleset) == desiredRuleset && tableErr == nil && firewalldInactiveErr != nil && disabledErr == nil {
+			monitor.Debug("Not changing firewall")
+			return desired.ToCurrent(), nil, nil
+		}
+
+		return make(common.FirewallCurrent, 0), func() error {
+			monitor.Debug("Ensuring firewall")
+
+			if firewalldInactiveErr == nil {
+				monitor.Info("Disabling firewalld in favour of nftables")
+				if _, err := runCommand(monitor, "systemctl", "disable", "--now", "firewalld"); err != nil {
+					return err
+				}
+			}
+
+			if err := os.MkdirAll(filepath.Dir(rulesetPath), 0700); err != nil {
+				return err
+			}
+
+			if err := ioutil.WriteFile(rulesetPath, []byte(desiredRuleset), 0600); err != nil {
+				return err
+			}
+
+			if err := ioutil.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+				return err
+			}
+
+			if _, err := runCommand(monitor, "systemctl", "daemon-reload"); err != nil {
+				return err
+			}
+
+			if _, err := runCommand(monitor, "systemctl", "enable", "orbos-firewall"); err != nil {
+				return err
+			}
+
+			_, err := runCommand(monitor, "nft", "-f", rulesetPath)
+			return err
+		}, nil
+	})
+}
+
+func runCommand(monitor mntr.Monitor, binary string, args ...string) (string, error) {
+
+	outBuf := new(bytes.Buffer)
+	defer outBuf.Reset()
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+
+	cmd := exec.Command(binary, args...)
+	cmd.Stderr = errBuf
+	cmd.Stdout = outBuf
+
+	fullCmd := fmt.Sprintf("'%s'", strings.Join(cmd.Args, "' '"))
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf(`running %s failed with stderr %s: %w`, fullCmd, errBuf.String(), err)
+	}
+
+	stdout := outBuf.String()
+	if monitor.IsVerbose() {
+		fmt.Println(fullCmd)
+		fmt.Println(stdout)
+	}
+
+	return strings.TrimSuffix(stdout, "\n"), nil
+}
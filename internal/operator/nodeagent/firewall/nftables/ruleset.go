@@ -0,0 +1,164 @@
+package nftables
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/common"
+)
+
+const (
+	table = "orbos"
+	// defaultZone classifies all packets which match no zones sources or interfaces, like firewalld does
+	defaultZone = "public"
+)
+
+// ruleset renders the desired zones to an nftables script which atomically replaces ORBOS' tables.
+// Like with firewalld, packets are classified into zones by their source first and by their input interface second.
+// Each zone accepts its ports and the always open ports and rejects everything else.
+func ruleset(desired *common.Firewall, open []string) string {
+
+	zones := make(map[string]*common.Zone)
+	for name, zone := range desired.Zones {
+		if zone != nil {
+			zones[name] = zone
+		}
+	}
+	if _, ok := zones[defaultZone]; !ok {
+		zones[defaultZone] = &common.Zone{}
+	}
+
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var classify, chains, masquerade []string
+	for _, name := range names {
+		zone := zones[name]
+		chain := "zone_" + name
+		v4, v6 := splitSources(zone.Sources)
+		if len(v4) > 0 {
+			classify = append(classify, fmt.Sprintf("ip saddr %s jump %s", set(v4), chain))
+		}
+		if len(v6) > 0 {
+			classify = append(classify, fmt.Sprintf("ip6 saddr %s jump %s", set(v6), chain))
+		}
+		if zone.Masquerade {
+			if len(v4) > 0 {
+				masquerade = append(masquerade, fmt.Sprintf(`ip saddr %s oifname != "lo" masquerade`, set(v4)))
+			}
+			if len(zone.Interfaces) > 0 {
+				masquerade = append(masquerade, fmt.Sprintf("oifname %s masquerade", set(quote(unique(zone.Interfaces)))))
+			}
+		}
+		chains = append(chains, zoneChain(chain, desired.Ports(name), open))
+	}
+
+	for _, name := range names {
+		if interfaces := zones[name].Interfaces; len(interfaces) > 0 {
+			classify = append(classify, fmt.Sprintf("iifname %s jump zone_%s", set(quote(unique(interfaces))), name))
+		}
+	}
+	classify = append(classify, "jump zone_"+defaultZone)
+
+	script := fmt.Sprintf(`table inet %s
+delete table inet %s
+table inet %s {
+	chain input {
+		type filter hook input priority 0; policy accept;
+		ct state established,related accept
+		ct state invalid drop
+		iifname "lo" accept
+		meta l4proto { icmp, ipv6-icmp } accept
+		# Pod traffic is subject to the network policies of the CNI plugin
+		iifname "cali*" accept
+		iifname "tunl0" accept
+		%s
+	}
+%s}
+`, table, table, table, strings.Join(classify, "\n\t\t"), strings.Join(chains, ""))
+
+	// Declaring the table before deleting it makes deleting it never fail
+	script += fmt.Sprintf("table ip %s_nat\ndelete table ip %s_nat\n", table, table)
+	if len(masquerade) == 0 {
+		return script
+	}
+
+	return script + fmt.Sprintf(`table ip %s_nat {
+	chain postrouting {
+		type nat hook postrouting priority 100; policy accept;
+		%s
+	}
+}
+`, table, strings.Join(masquerade, "\n\t\t"))
+}
+
+func zoneChain(chain string, ports common.Ports, open []string) string {
+	byProtocol := make(map[string][]string)
+	for _, port := range open {
+		if port != "" {
+			byProtocol["tcp"] = append(byProtocol["tcp"], port)
+		}
+	}
+	for _, port := range ports {
+		if port != nil && port.Port != "" {
+			byProtocol[port.Protocol] = append(byProtocol[port.Protocol], port.Port)
+		}
+	}
+
+	protocols := make([]string, 0, len(byProtocol))
+	for protocol := range byProtocol {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	rules := ""
+	for _, protocol := range protocols {
+		rules += fmt.Sprintf("\t\t%s dport %s accept\n", protocol, set(unique(byProtocol[protocol])))
+	}
+
+	return fmt.Sprintf(`
+	chain %s {
+%s		reject with icmpx type admin-prohibited
+	}
+`, chain, rules)
+}
+
+func splitSources(sources []string) (v4 []string, v6 []string) {
+	for _, source := range sources {
+		if strings.Contains(source, ":") {
+			v6 = append(v6, source)
+			continue
+		}
+		v4 = append(v4, source)
+	}
+	return unique(v4), unique(v6)
+}
+
+func quote(strs []string) []string {
+	quoted := make([]string, len(strs))
+	for idx, str := range strs {
+		quoted[idx] = `"` + str + `"`
+	}
+	return quoted
+}
+
+func unique(strs []string) []string {
+	seen := make(map[string]bool)
+	var uniq []string
+	for _, str := range strs {
+		if !seen[str] {
+			seen[str] = true
+			uniq = append(uniq, str)
+		}
+	}
+	sort.Strings(uniq)
+	return uniq
+}
+
+func set(elements []string) string {
+	return "{ " + strings.Join(elements, ", ") + " }"
+}
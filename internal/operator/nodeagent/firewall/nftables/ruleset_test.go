@@ -0,0 +1,74 @@
+package nftables
+
+import (
+	"testing"
+
+	"github.com/caos/orbos/internal/operator/common"
+)
+
+func TestRuleset(t *testing.T) {
+
+	desired := common.Firewall{Zones: map[string]*common.Zone{
+		"internal": {
+			Sources: []string{"10.0.0.2/32", "10.0.0.1/32"},
+			FW: map[string]*common.Allowed{
+				"kubeapi": {Port: "6443", Protocol: "tcp"},
+				"calico":  {Port: "4789", Protocol: "udp"},
+			},
+		},
+		"external": {
+			Masquerade: true,
+			Interfaces: []string{"eth0"},
+			FW: map[string]*common.Allowed{
+				"https": {Port: "443", Protocol: "tcp"},
+			},
+		},
+	}}
+
+	expect := `table inet orbos
+delete table inet orbos
+table inet orbos {
+	chain input {
+		type filter hook input priority 0; policy accept;
+		ct state established,related accept
+		ct state invalid drop
+		iifname "lo" accept
+		meta l4proto { icmp, ipv6-icmp } accept
+		# Pod traffic is subject to the network policies of the CNI plugin
+		iifname "cali*" accept
+		iifname "tunl0" accept
+		ip saddr { 10.0.0.1/32, 10.0.0.2/32 } jump zone_internal
+		iifname { "eth0" } jump zone_external
+		jump zone_public
+	}
+
+	chain zone_external {
+		tcp dport { 22, 443 } accept
+		reject with icmpx type admin-prohibited
+	}
+
+	chain zone_internal {
+		tcp dport { 22, 6443 } accept
+		udp dport { 4789 } accept
+		reject with icmpx type admin-prohibited
+	}
+
+	chain zone_public {
+		tcp dport { 22 } accept
+		reject with icmpx type admin-prohibited
+	}
+}
+table ip orbos_nat
+delete table ip orbos_nat
+table ip orbos_nat {
+	chain postrouting {
+		type nat hook postrouting priority 100; policy accept;
+		oifname { "eth0" } masquerade
+	}
+}
+`
+
+	if rendered := ruleset(&desired, []string{"22"}); rendered != expect {
+		t.Errorf("expected\n%s\nbut got\n%s", expect, rendered)
+	}
+}
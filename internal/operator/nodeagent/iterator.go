@@ -3,8 +3,10 @@
 package nodeagent
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
@@ -17,6 +19,12 @@ type Rebooter interface {
 	Reboot() error
 }
 
+const (
+	desiredFile = "caos-internal/orbiter/node-agents-desired.yml"
+	// fullReconciliationInterval bounds how long iterations are skipped when nothing changed, so drift is still healed
+	fullReconciliationInterval = 5 * time.Minute
+)
+
 type event struct {
 	commit  string
 	current *common.NodeAgentCurrent
@@ -36,50 +44,54 @@ func RepoKey() ([]byte, error) {
 func Iterator(
 	monitor mntr.Monitor,
 	gitClient *git.Client,
+	nodeAgentVersion string,
 	nodeAgentCommit string,
 	id string,
 	firewallEnsurer FirewallEnsurer,
 	networkingEnsurer NetworkingEnsurer,
+	customEnsurer CustomEnsurer,
 	conv Converter,
 	before func() error,
+	status *StatusRecorder,
 ) func() {
 
-	doQuery := prepareQuery(monitor, nodeAgentCommit, firewallEnsurer, networkingEnsurer, conv)
+	doQuery := prepareQuery(monitor, nodeAgentCommit, firewallEnsurer, networkingEnsurer, customEnsurer, conv)
 
-	return func() {
+	skipper := &iterationSkipper{}
+
+	iterate := func() error {
 
 		repoKey, err := RepoKey()
 		if err != nil {
-			monitor.Error(err)
-			return
+			return err
 		}
 
 		repoURL, err := ioutil.ReadFile("/var/orbiter/repo-url")
 		if err != nil {
-			monitor.Error(err)
-			return
+			return err
 		}
 
 		if err := gitClient.Configure(string(repoURL), repoKey); err != nil {
-			monitor.Error(err)
-			return
+			return err
 		}
 
 		if err := gitClient.Clone(); err != nil {
-			monitor.Error(err)
-			return
+			return err
+		}
+
+		if skipper.skip(gitClient.Fingerprint(desiredFile), time.Now()) {
+			monitor.Debug("Desired state is unchanged, skipping iteration")
+			return nil
 		}
 
 		desired := common.NodeAgentsDesiredKind{}
-		if err := yaml.Unmarshal(gitClient.Read("caos-internal/orbiter/node-agents-desired.yml"), &desired); err != nil {
-			monitor.Error(err)
-			return
+		if err := yaml.Unmarshal(gitClient.Read(desiredFile), &desired); err != nil {
+			return err
 		}
 
 		naDesired, ok := desired.Spec.NodeAgents.Get(id)
 		if !ok {
-			monitor.Error(fmt.Errorf("no desired state for node agent with id %s found", id))
-			return
+			return fmt.Errorf("no desired state for node agent with id %s found", id)
 		}
 
 		if nodeAgentCommit != "debug" && desired.Spec.Commit != nodeAgentCommit {
@@ -87,7 +99,15 @@ func Iterator(
 				"desired": desired.Spec.Commit,
 				"current": nodeAgentCommit,
 			}).Info("Node Agent is on the wrong commit")
-			return
+			wrongCommit := fmt.Errorf("node agent is on commit %s but %s is desired", nodeAgentCommit, desired.Spec.Commit)
+			if naDesired.Update != nil && naDesired.Update.Commit == desired.Spec.Commit {
+				if err := selfUpdate(monitor, naDesired.Update, nodeAgentVersion, nodeAgentCommit); err != nil {
+					wrongCommit = fmt.Errorf("%s and updating failed: %w", wrongCommit.Error(), err)
+					monitor.Error(wrongCommit)
+				}
+			}
+			status.record(naDesired, nil, wrongCommit)
+			return nil
 		}
 
 		curr := &common.NodeAgentCurrent{}
@@ -98,10 +118,12 @@ func Iterator(
 
 		ensure, err := doQuery(*naDesired, curr)
 		if err != nil {
-			monitor.Error(err)
-			return
-
+			return err
 		}
+		queried := *curr
+		status.record(naDesired, &queried, nil)
+		queriedYAML := common.MarshalYAML(queried)
+
 		readCurrent := func() common.NodeAgentsCurrentKind {
 			current := common.NodeAgentsCurrentKind{}
 			yaml.Unmarshal(gitClient.Read("caos-internal/orbiter/node-agents-current.yml"), &current)
@@ -121,10 +143,53 @@ func Iterator(
 				Content: common.MarshalYAML(current),
 			}}
 		}); err != nil {
-			monitor.Error(fmt.Errorf("commiting event \"%s\" failed: %w", reconciledCurrentStateMsg, err))
-			return
+			return fmt.Errorf("commiting event \"%s\" failed: %w", reconciledCurrentStateMsg, err)
 		}
 
-		monitor.Error(ensure())
+		if err := confirmUpdate(monitor, nodeAgentCommit); err != nil {
+			return err
+		}
+
+		if err := ensure(); err != nil {
+			return err
+		}
+		status.record(nil, nil, nil)
+		skipper.reconciled(queriedYAML)
+		return nil
+	}
+
+	return func() {
+		if err := iterate(); err != nil {
+			monitor.Error(err)
+			status.record(nil, nil, err)
+		}
 	}
 }
+
+// iterationSkipper decides if an iteration can be skipped, because neither the desired nor the current state changed
+type iterationSkipper struct {
+	fingerprint    string
+	queried        []byte
+	lastFull       time.Time
+	desiredChanged bool
+	// idle is true when the last iteration neither found a changed desired nor a changed current state
+	idle bool
+}
+
+// skip returns true if the desired state file didn't change since the last idle iteration and the last full reconciliation is recent
+func (s *iterationSkipper) skip(fingerprint string, now time.Time) bool {
+	if s.idle && fingerprint != "" && fingerprint == s.fingerprint && now.Sub(s.lastFull) < fullReconciliationInterval {
+		return true
+	}
+	s.desiredChanged = fingerprint == "" || fingerprint != s.fingerprint
+	s.idle = false
+	s.fingerprint = fingerprint
+	s.lastFull = now
+	return false
+}
+
+// reconciled records the queried current state of a completed iteration
+func (s *iterationSkipper) reconciled(queried []byte) {
+	s.idle = !s.desiredChanged && bytes.Equal(queried, s.queried)
+	s.queried = queried
+}
@@ -0,0 +1,85 @@
+package nodeagent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIterationSkipper(t *testing.T) {
+
+	start := time.Date(2021, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	type iteration struct {
+		fingerprint string
+		after       time.Duration
+		queried     string
+		// failed iterations don't reconcile the current state
+		failed   bool
+		wantSkip bool
+	}
+
+	tests := []struct {
+		name       string
+		iterations []iteration
+	}{{
+		name: "It should skip iterations when neither the desired nor the current state changed",
+		iterations: []iteration{
+			{fingerprint: "a", queried: "current"},
+			{fingerprint: "a", after: time.Minute, queried: "current"},
+			{fingerprint: "a", after: 2 * time.Minute, wantSkip: true},
+		},
+	}, {
+		name: "It should not skip the iteration after the desired state changed",
+		iterations: []iteration{
+			{fingerprint: "a", queried: "current"},
+			{fingerprint: "a", after: time.Minute, queried: "current"},
+			{fingerprint: "b", after: 2 * time.Minute, queried: "current"},
+			{fingerprint: "b", after: 3 * time.Minute, queried: "current"},
+			{fingerprint: "b", after: 4 * time.Minute, wantSkip: true},
+		},
+	}, {
+		name: "It should not skip the iteration after the current state changed",
+		iterations: []iteration{
+			{fingerprint: "a", queried: "current"},
+			{fingerprint: "a", after: time.Minute, queried: "drifted"},
+			{fingerprint: "a", after: 2 * time.Minute, queried: "drifted"},
+			{fingerprint: "a", after: 3 * time.Minute, wantSkip: true},
+		},
+	}, {
+		name: "It should reconcile fully when the last full reconciliation is too old",
+		iterations: []iteration{
+			{fingerprint: "a", queried: "current"},
+			{fingerprint: "a", after: time.Minute, queried: "current"},
+			{fingerprint: "a", after: time.Minute + fullReconciliationInterval, queried: "current"},
+			{fingerprint: "a", after: 2*time.Minute + fullReconciliationInterval, wantSkip: true},
+		},
+	}, {
+		name: "It should never skip when the desired state file is missing",
+		iterations: []iteration{
+			{fingerprint: "", queried: "current"},
+			{fingerprint: "", after: time.Minute, queried: "current"},
+			{fingerprint: "", after: 2 * time.Minute, queried: "current"},
+		},
+	}, {
+		name: "It should not skip the iteration after a full reconciliation failed",
+		iterations: []iteration{
+			{fingerprint: "a", queried: "current"},
+			{fingerprint: "a", after: time.Minute, queried: "current"},
+			{fingerprint: "a", after: time.Minute + fullReconciliationInterval, failed: true},
+			{fingerprint: "a", after: 2*time.Minute + fullReconciliationInterval, queried: "current"},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			skipper := &iterationSkipper{}
+			for i, it := range tt.iterations {
+				if got := skipper.skip(it.fingerprint, start.Add(it.after)); got != it.wantSkip {
+					t.Fatalf("iteration %d: skip = %t, want %t", i, got, it.wantSkip)
+				}
+				if !it.wantSkip && !it.failed {
+					skipper.reconciled([]byte(it.queried))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Port is where the node agent serves its metrics
+const Port = 29990
+
+// RejectedConnections is reported by the load balancing engines collectors.
+// Listeners are named like the engines frontends, which is the transports name or the transports name suffixed by an index.
+var RejectedConnections = prometheus.NewDesc(
+	"orbos_loadbalancer_rejected_connections_total",
+	"Connections rejected by whitelists, denylists or limits",
+	[]string{"engine", "listener"},
+	nil,
+)
+
+// Serve blocks until serving the collectors metrics fails
+func Serve(port int, collectors ...prometheus.Collector) error {
+	registry := prometheus.NewRegistry()
+	for _, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+}
+
+// Rejected sends a counter for each listener
+func Rejected(ch chan<- prometheus.Metric, engine string, rejected map[string]float64) {
+	for listener, count := range rejected {
+		ch <- prometheus.MustNewConstMetric(RejectedConnections, prometheus.CounterValue, count, engine, listener)
+	}
+}
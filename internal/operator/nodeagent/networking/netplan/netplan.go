@@ -0,0 +1,69 @@
+package netplan
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/networking/link"
+	"github.com/caos/orbos/mntr"
+)
+
+// dummyDevicesVersion is the first netplan version which supports dummy devices
+const dummyDevicesVersion = "0.106"
+
+// Supported returns true if netplan is installed and supports dummy devices
+func Supported() bool {
+	version, err := exec.Command("dpkg-query", "--show", "--showformat=${Version}", "netplan.io").Output()
+	if err != nil || len(version) == 0 {
+		return false
+	}
+	return exec.Command("dpkg", "--compare-versions", string(version), "ge", dummyDevicesVersion).Run() == nil
+}
+
+// Ensurer persists interfaces as netplan configurations, which netplan renders to systemd-networkd units
+func Ensurer(monitor mntr.Monitor) nodeagent.NetworkingEnsurer {
+	return link.Ensurer(monitor, link.Persistence{
+		Files: getConfigs,
+		// netplan warns about configurations which are readable by others than root
+		Mode: 0600,
+		Reload: func() error {
+			if err := run("netplan", "generate"); err != nil {
+				return err
+			}
+			return run("networkctl", "reload")
+		},
+	})
+}
+
+func run(binary string, args ...string) error {
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+	cmd := exec.Command(binary, args...)
+	cmd.Stderr = errBuf
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s %s failed with stderr %s: %w", binary, strings.Join(args, " "), errBuf.String(), err)
+	}
+	return nil
+}
+
+func getConfigs(name string, ty string, ips []string) map[string]string {
+	addresses := ""
+	for _, ip := range ips {
+		addresses += fmt.Sprintf("\n        - %s/32", ip)
+	}
+	if addresses == "" {
+		addresses = " []"
+	}
+
+	return map[string]string{
+		"/etc/netplan/60-" + name + ".yaml": fmt.Sprintf(`network:
+  version: 2
+  %s-devices:
+    %s:
+      addresses:%s
+`, ty, name, addresses),
+	}
+}
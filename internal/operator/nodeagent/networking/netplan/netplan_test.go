@@ -0,0 +1,40 @@
+package netplan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetConfigs(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		ips  []string
+		want string
+	}{{
+		name: "It should configure all addresses",
+		ips:  []string{"10.0.0.1", "10.0.0.2"},
+		want: `network:
+  version: 2
+  dummy-devices:
+    orbosdummy:
+      addresses:
+        - 10.0.0.1/32
+        - 10.0.0.2/32
+`,
+	}, {
+		name: "It should configure a device without addresses",
+		want: `network:
+  version: 2
+  dummy-devices:
+    orbosdummy:
+      addresses: []
+`,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			want := map[string]string{"/etc/netplan/60-orbosdummy.yaml": tt.want}
+			if got := getConfigs("orbosdummy", "dummy", tt.ips); !reflect.DeepEqual(got, want) {
+				t.Errorf("getConfigs() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package networkd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/networking/link"
+	"github.com/caos/orbos/mntr"
+)
+
+// Ensurer persists interfaces as systemd-networkd units.
+// It is used on hosts without netplan and on hosts with netplan versions which don't support dummy devices yet.
+func Ensurer(monitor mntr.Monitor) nodeagent.NetworkingEnsurer {
+	return link.Ensurer(monitor, link.Persistence{
+		Files: getUnits,
+		// systemd-networkd doesn't run as root
+		Mode: 0644,
+		Reload: func() error {
+			if err := run("systemctl", "enable", "--now", "systemd-networkd"); err != nil {
+				return err
+			}
+			return run("networkctl", "reload")
+		},
+	})
+}
+
+func run(binary string, args ...string) error {
+	errBuf := new(bytes.Buffer)
+	defer errBuf.Reset()
+	cmd := exec.Command(binary, args...)
+	cmd.Stderr = errBuf
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running %s %s failed with stderr %s: %w", binary, strings.Join(args, " "), errBuf.String(), err)
+	}
+	return nil
+}
+
+func getUnits(name string, ty string, ips []string) map[string]string {
+	addresses := ""
+	for _, ip := range ips {
+		addresses += fmt.Sprintf("\nAddress=%s/32", ip)
+	}
+
+	return map[string]string{
+		"/etc/systemd/network/50-" + name + ".netdev": fmt.Sprintf(`[NetDev]
+Name=%s
+Kind=%s
+`, name, ty),
+		"/etc/systemd/network/50-" + name + ".network": fmt.Sprintf(`[Match]
+Name=%s
+
+[Network]%s
+`, name, addresses),
+	}
+}
@@ -4,13 +4,27 @@ import (
 	"github.com/caos/orbos/internal/operator/nodeagent"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep"
 	"github.com/caos/orbos/internal/operator/nodeagent/networking/centos"
+	"github.com/caos/orbos/internal/operator/nodeagent/networking/netplan"
+	"github.com/caos/orbos/internal/operator/nodeagent/networking/networkd"
+	"github.com/caos/orbos/internal/operator/nodeagent/networking/networkmanager"
 	"github.com/caos/orbos/mntr"
 )
 
-func Ensurer(monitor mntr.Monitor, os dep.OperatingSystem) nodeagent.NetworkingEnsurer {
-	switch os {
+func Ensurer(monitor mntr.Monitor, os dep.OperatingSystemMajor) nodeagent.NetworkingEnsurer {
+	switch os.OperatingSystem {
 	case dep.CentOS:
 		return centos.Ensurer(monitor)
+	case dep.Rocky, dep.Alma:
+		return networkmanager.Ensurer(monitor)
+	case dep.Ubuntu, dep.Debian, dep.Flatcar:
+		// Bionics systemd-networkd can't be reloaded
+		if os == dep.Bionic {
+			return noopEnsurer()
+		}
+		if netplan.Supported() {
+			return netplan.Ensurer(monitor)
+		}
+		return networkd.Ensurer(monitor)
 	default:
 		return noopEnsurer()
 	}
@@ -0,0 +1,57 @@
+package networkmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/nodeagent"
+	"github.com/caos/orbos/internal/operator/nodeagent/networking/link"
+	"github.com/caos/orbos/mntr"
+)
+
+// Ensurer persists interfaces as NetworkManager keyfiles, which replace the deprecated network scripts on EL8 and newer
+func Ensurer(monitor mntr.Monitor) nodeagent.NetworkingEnsurer {
+	return link.Ensurer(monitor, link.Persistence{
+		Files: getKeyfiles,
+		// NetworkManager ignores keyfiles which are readable by others than root
+		Mode: 0600,
+		Reload: func() error {
+			errBuf := new(bytes.Buffer)
+			defer errBuf.Reset()
+			cmd := exec.Command("nmcli", "connection", "reload")
+			cmd.Stderr = errBuf
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("reloading NetworkManager connections failed with stderr %s: %w", errBuf.String(), err)
+			}
+			return nil
+		},
+	})
+}
+
+func getKeyfiles(name string, ty string, ips []string) map[string]string {
+	ipv4 := "method=disabled"
+	if len(ips) > 0 {
+		addresses := make([]string, len(ips))
+		for idx, ip := range ips {
+			addresses[idx] = fmt.Sprintf("address%d=%s/32", idx+1, ip)
+		}
+		ipv4 = "method=manual\n" + strings.Join(addresses, "\n")
+	}
+
+	return map[string]string{
+		"/etc/NetworkManager/system-connections/" + name + ".nmconnection": fmt.Sprintf(`[connection]
+id=%s
+type=%s
+interface-name=%s
+autoconnect=true
+
+[ipv4]
+%s
+
+[ipv6]
+method=disabled
+`, name, ty, name, ipv4),
+	}
+}
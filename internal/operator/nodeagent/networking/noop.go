@@ -6,7 +6,7 @@ import (
 )
 
 func noopEnsurer() nodeagent.NetworkingEnsurer {
-	return nodeagent.NetworkingEnsurerFunc(func(desired common.Networking) (common.NetworkingCurrent, func() error, error) {
+	return nodeagent.NetworkingEnsurerFunc(func(desired *common.Networking) (common.NetworkingCurrent, func() error, error) {
 		return nil, nil, nil
 	})
 }
@@ -0,0 +1,30 @@
+package nodeagent
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/caos/orbos/mntr"
+)
+
+// rebootRequired checks whether the sentinel file exists or whether the sentinel command exits with 0
+func rebootRequired(monitor mntr.Monitor, sentinel string) bool {
+	if sentinel == "" {
+		return false
+	}
+
+	if strings.HasPrefix(sentinel, "/") && !strings.ContainsAny(sentinel, " \t") {
+		_, err := os.Stat(sentinel)
+		return err == nil
+	}
+
+	cmd := exec.Command("sh", "-c", sentinel)
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			monitor.WithField("sentinel", sentinel).Error(err)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package nodeagent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	securityUpdatesAppliedPath   = "/var/orbiter/security-updates-applied"
+	checkSecurityUpdatesInterval = time.Hour
+)
+
+var (
+	// checkedSecurityUpdates caches the available security updates, as listing them queries the package repositories
+	checkedSecurityUpdates       *common.SecurityUpdatesCurrent
+	securityUpdatesRequireReboot bool
+)
+
+// querySecurityUpdates reports the available security updates and returns a func which applies them if ORBITER requires it
+func querySecurityUpdates(monitor mntr.Monitor, conv Converter, desired *common.SecurityUpdatesSpec, curr *common.NodeAgentCurrent) func() error {
+	if desired == nil {
+		return nil
+	}
+
+	if checkedSecurityUpdates == nil || time.Since(checkedSecurityUpdates.Checked) > checkSecurityUpdatesInterval {
+		available, err := conv.SecurityUpdates()
+		if err != nil {
+			monitor.Error(fmt.Errorf("listing security updates failed: %w", err))
+		} else {
+			applied := readAppliedSecurityUpdates()
+			checkedSecurityUpdates = &common.SecurityUpdatesCurrent{
+				Available:  available,
+				Checked:    time.Now(),
+				Applied:    applied.Applied,
+				Generation: applied.Generation,
+			}
+			securityUpdatesRequireReboot = conv.RebootRequired()
+		}
+	}
+
+	if checkedSecurityUpdates == nil {
+		return nil
+	}
+	curr.SecurityUpdates = checkedSecurityUpdates
+	curr.RebootRequired = curr.RebootRequired || securityUpdatesRequireReboot
+
+	if desired.Generation <= checkedSecurityUpdates.Generation {
+		return nil
+	}
+
+	generation := desired.Generation
+	return func() error {
+		if err := conv.ApplySecurityUpdates(); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(securityUpdatesAppliedPath, common.MarshalYAML(&appliedSecurityUpdates{
+			Generation: generation,
+			Applied:    time.Now(),
+		}), 0600); err != nil {
+			return fmt.Errorf("persisting the applied security updates generation failed: %w", err)
+		}
+		// Check again in the next iteration
+		checkedSecurityUpdates = nil
+		monitor.Changed("Security updates applied")
+		return nil
+	}
+}
+
+type appliedSecurityUpdates struct {
+	Generation int64
+	Applied    time.Time
+}
+
+func readAppliedSecurityUpdates() *appliedSecurityUpdates {
+	applied := &appliedSecurityUpdates{}
+	content, err := ioutil.ReadFile(securityUpdatesAppliedPath)
+	if err != nil {
+		return applied
+	}
+	if err := yaml.Unmarshal(content, applied); err != nil {
+		return &appliedSecurityUpdates{}
+	}
+	return applied
+}
@@ -0,0 +1,226 @@
+package nodeagent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	BinaryPath   = "/usr/local/bin/node-agent"
+	rollbackUnit = "node-agent-rollback"
+	systemdEntry = "node-agentd"
+
+	// RollbackTimeout is how long an updated node agent has to complete an iteration before the previous binary is restored
+	RollbackTimeout = 10 * time.Minute
+)
+
+// The paths and the command runner are variables, so tests can replace them
+var (
+	binaryPath   = BinaryPath
+	newBinary    = BinaryPath + ".new"
+	backupBinary = BinaryPath + ".previous"
+	updateMarker = "/var/orbiter/node-agent-update"
+	failedPath   = "/var/orbiter/node-agent-update-failed"
+	releaseKey   = common.ReleaseSigningKeyPath
+	runCommand   = func(name string, args ...string) ([]byte, error) {
+		return exec.Command(name, args...).CombinedOutput()
+	}
+)
+
+type pendingUpdate struct {
+	From    string
+	To      string
+	SHA256  string
+	Started time.Time
+}
+
+// selfUpdate replaces the node agents binary and restarts it.
+// The binary must be signed with the release signing key ORBITER pinned when it installed the node agent.
+// Lower versions than the running one are only installed if the update is an explicit rollback, so older releases with known bugs can't be desired.
+// A systemd timer restores the previous binary unless the new node agent confirms the update within RollbackTimeout.
+func selfUpdate(monitor mntr.Monitor, update *common.NodeAgentUpdate, currentVersion, currentCommit string) error {
+
+	downgrades, err := update.Downgrades(currentVersion)
+	if err != nil {
+		return err
+	}
+	if downgrades && !update.Rollback {
+		return fmt.Errorf("version %s is lower than the running version %s and the update is not a rollback", update.Version, currentVersion)
+	}
+
+	pinnedKey, err := ioutil.ReadFile(releaseKey)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no release signing key is pinned at %s, so the node agent can only be reinstalled over SSH", releaseKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	failed, err := ioutil.ReadFile(failedPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if strings.TrimSpace(string(failed)) == update.SHA256 {
+		return fmt.Errorf("updating to %s failed before and was rolled back", update.Commit)
+	}
+
+	monitor = monitor.WithFields(map[string]interface{}{
+		"from": currentCommit,
+		"to":   update.Commit,
+		"url":  update.URL,
+	})
+	monitor.Info("Downloading node agent")
+
+	binary, err := download(update.URL)
+	if err != nil {
+		return err
+	}
+
+	if err := update.Verify(binary, pinnedKey); err != nil {
+		return fmt.Errorf("verifying downloaded node agent failed: %w", err)
+	}
+
+	if err := ioutil.WriteFile(newBinary, binary, 0700); err != nil {
+		return err
+	}
+
+	version, err := exec.Command(newBinary, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("running %s --version failed: %w", newBinary, err)
+	}
+	if fields := strings.Fields(string(version)); len(fields) < 2 || fields[1] != update.Commit {
+		return fmt.Errorf("downloaded node agent reports version %s instead of commit %s", strings.TrimSpace(string(version)), update.Commit)
+	}
+
+	previous, err := ioutil.ReadFile(binaryPath)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(backupBinary, previous, 0700); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(updateMarker, common.MarshalYAML(&pendingUpdate{
+		From:    currentCommit,
+		To:      update.Commit,
+		SHA256:  update.SHA256,
+		Started: time.Now(),
+	}), 0600); err != nil {
+		return err
+	}
+
+	runCommand("systemctl", "reset-failed", rollbackUnit)
+	if out, err := runCommand(
+		"systemd-run",
+		"--unit", rollbackUnit,
+		fmt.Sprintf("--on-active=%d", int(RollbackTimeout.Seconds())),
+		backupBinary, "--rollback",
+	); err != nil {
+		os.Remove(updateMarker)
+		return fmt.Errorf("scheduling rollback failed: %s: %w", string(out), err)
+	}
+
+	if err := os.Rename(newBinary, binaryPath); err != nil {
+		return err
+	}
+
+	monitor.Changed("Node agent updated, restarting")
+	_, err = runCommand("systemctl", "restart", "--no-block", systemdEntry)
+	return err
+}
+
+func download(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading node agent from %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading node agent from %s returned %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func readPendingUpdate() (*pendingUpdate, error) {
+	data, err := ioutil.ReadFile(updateMarker)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pending := &pendingUpdate{}
+	return pending, yaml.Unmarshal(data, pending)
+}
+
+// confirmUpdate cancels the scheduled rollback as soon as the updated node agent reconciled its current state once
+func confirmUpdate(monitor mntr.Monitor, commit string) error {
+	pending, err := readPendingUpdate()
+	if err != nil || pending == nil || pending.To != commit {
+		return err
+	}
+
+	if out, err := runCommand("systemctl", "stop", rollbackUnit+".timer"); err != nil {
+		return fmt.Errorf("cancelling rollback failed: %s: %w", string(out), err)
+	}
+
+	if err := os.Remove(updateMarker); err != nil {
+		return err
+	}
+
+	monitor.WithFields(map[string]interface{}{
+		"from": pending.From,
+		"to":   pending.To,
+		"took": time.Since(pending.Started),
+	}).Changed("Node agent update confirmed")
+	return nil
+}
+
+// Rollback restores the previous binary if the pending update was not confirmed.
+// The failed checksum is remembered, so the node agent doesn't retry the same update.
+func Rollback(monitor mntr.Monitor) error {
+	pending, err := readPendingUpdate()
+	if err != nil || pending == nil {
+		return err
+	}
+
+	previous, err := ioutil.ReadFile(backupBinary)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(newBinary, previous, 0700); err != nil {
+		return err
+	}
+
+	if err := os.Rename(newBinary, binaryPath); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(failedPath, []byte(pending.SHA256), 0600); err != nil {
+		return err
+	}
+
+	if err := os.Remove(updateMarker); err != nil {
+		return err
+	}
+
+	monitor.WithFields(map[string]interface{}{
+		"from": pending.To,
+		"to":   pending.From,
+	}).Changed("Node agent update was not confirmed in time, rolling back")
+	_, err = runCommand("systemctl", "restart", systemdEntry)
+	return err
+}
@@ -0,0 +1,314 @@
+package nodeagent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/ssh"
+	"github.com/caos/orbos/mntr"
+)
+
+// testSelfUpdate replaces the paths by temporary files and records the commands instead of running them
+func testSelfUpdate(t *testing.T) *[]string {
+	dir := t.TempDir()
+	restore := []string{binaryPath, newBinary, backupBinary, updateMarker, failedPath, releaseKey}
+	restoreRun := runCommand
+	t.Cleanup(func() {
+		binaryPath, newBinary, backupBinary, updateMarker, failedPath, releaseKey = restore[0], restore[1], restore[2], restore[3], restore[4], restore[5]
+		runCommand = restoreRun
+	})
+
+	binaryPath = filepath.Join(dir, "node-agent")
+	newBinary = binaryPath + ".new"
+	backupBinary = binaryPath + ".previous"
+	updateMarker = filepath.Join(dir, "node-agent-update")
+	failedPath = filepath.Join(dir, "node-agent-update-failed")
+	releaseKey = filepath.Join(dir, "release-signing-key")
+
+	commands := make([]string, 0)
+	runCommand = func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+		return nil, nil
+	}
+
+	if err := ioutil.WriteFile(binaryPath, []byte("previous"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	_, public := testReleaseKeys()
+	if err := ioutil.WriteFile(releaseKey, []byte(public), 0400); err != nil {
+		t.Fatal(err)
+	}
+	return &commands
+}
+
+var (
+	releaseKeysOnce                     sync.Once
+	releaseKeyPrivate, releaseKeyPublic string
+)
+
+// testReleaseKeys generates the keys once, as generating RSA keys is slow
+func testReleaseKeys() (string, string) {
+	releaseKeysOnce.Do(func() { releaseKeyPrivate, releaseKeyPublic = ssh.Generate() })
+	return releaseKeyPrivate, releaseKeyPublic
+}
+
+// testUpdate serves a node agent binary, which reports the passed commit and is signed with the release signing key
+func testUpdate(t *testing.T, commit string) *common.NodeAgentUpdate {
+	binary := []byte(fmt.Sprintf("#!/bin/sh\necho node-agent %s\n", commit))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(binary)
+	}))
+	t.Cleanup(server.Close)
+
+	private, _ := testReleaseKeys()
+	signature, err := common.SignNodeAgent("v1.1.0", commit, binary, []byte(private))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return common.NewNodeAgentUpdate("v1.1.0", commit, server.URL, binary, signature)
+}
+
+func writePendingUpdate(t *testing.T, to, sha string) {
+	if err := ioutil.WriteFile(updateMarker, common.MarshalYAML(&pendingUpdate{
+		From:    "old",
+		To:      to,
+		SHA256:  sha,
+		Started: time.Now(),
+	}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("expected %s to contain %q, but got %q", path, want, got)
+	}
+}
+
+func assertNotExists(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected %s not to exist, but got %v", path, err)
+	}
+}
+
+func TestSelfUpdate(t *testing.T) {
+	commands := testSelfUpdate(t)
+	update := testUpdate(t, "new")
+
+	if err := selfUpdate(mntr.Monitor{}, update, "v1.0.0", "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFile(t, backupBinary, "previous")
+	assertFile(t, binaryPath, "#!/bin/sh\necho node-agent new\n")
+	assertNotExists(t, newBinary)
+
+	pending, err := readPendingUpdate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending == nil || pending.From != "old" || pending.To != "new" || pending.SHA256 != update.SHA256 {
+		t.Errorf("unexpected pending update %+v", pending)
+	}
+
+	want := []string{
+		"systemctl reset-failed " + rollbackUnit,
+		fmt.Sprintf("systemd-run --unit %s --on-active=%d %s --rollback", rollbackUnit, int(RollbackTimeout.Seconds()), backupBinary),
+		"systemctl restart --no-block " + systemdEntry,
+	}
+	if strings.Join(*commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected commands\n%s\nbut got\n%s", strings.Join(want, "\n"), strings.Join(*commands, "\n"))
+	}
+}
+
+func TestSelfUpdate_Rejects(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		prepare func(update *common.NodeAgentUpdate) error
+	}{{
+		name: "It should not retry updates which were rolled back",
+		prepare: func(update *common.NodeAgentUpdate) error {
+			return ioutil.WriteFile(failedPath, []byte(update.SHA256), 0600)
+		},
+	}, {
+		name: "It should reject binaries which don't match the checksum",
+		prepare: func(update *common.NodeAgentUpdate) error {
+			update.SHA256 = strings.Repeat("0", 64)
+			return nil
+		},
+	}, {
+		name: "It should reject checksums which are not signed with the pinned release signing key",
+		prepare: func(*common.NodeAgentUpdate) error {
+			_, otherKey := ssh.Generate()
+			return ioutil.WriteFile(releaseKey, []byte(otherKey), 0400)
+		},
+	}, {
+		name: "It should reject checksums which are signed with another key than the release signing key",
+		prepare: func(update *common.NodeAgentUpdate) error {
+			otherKey, _ := ssh.Generate()
+			signature, err := common.SignNodeAgent(update.Version, update.Commit, []byte("#!/bin/sh\necho node-agent new\n"), []byte(otherKey))
+			update.Signature = signature
+			return err
+		},
+	}, {
+		name: "It should reject signatures which are made for another version",
+		prepare: func(update *common.NodeAgentUpdate) error {
+			update.Version = "v1.2.0"
+			return nil
+		},
+	}, {
+		name: "It should reject lower versions than the running one",
+		prepare: func(update *common.NodeAgentUpdate) error {
+			private, _ := testReleaseKeys()
+			update.Version = "v0.9.0"
+			signature, err := common.SignNodeAgent(update.Version, update.Commit, []byte("#!/bin/sh\necho node-agent new\n"), []byte(private))
+			update.Signature = signature
+			return err
+		},
+	}, {
+		name: "It should reject updates if no release signing key is pinned",
+		prepare: func(*common.NodeAgentUpdate) error {
+			return os.Remove(releaseKey)
+		},
+	}, {
+		name: "It should reject binaries which don't report the desired commit",
+		prepare: func(update *common.NodeAgentUpdate) error {
+			update.Commit = "other"
+			return nil
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := testSelfUpdate(t)
+			update := testUpdate(t, "new")
+			if err := tt.prepare(update); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := selfUpdate(mntr.Monitor{}, update, "v1.0.0", "old"); err == nil {
+				t.Fatal("expected the update to be rejected")
+			}
+			assertFile(t, binaryPath, "previous")
+			assertNotExists(t, updateMarker)
+			if len(*commands) > 0 {
+				t.Errorf("expected no commands, but got %v", *commands)
+			}
+		})
+	}
+}
+
+func TestSelfUpdate_Rollback(t *testing.T) {
+	testSelfUpdate(t)
+	update := testUpdate(t, "new")
+	update.Rollback = true
+
+	if err := selfUpdate(mntr.Monitor{}, update, "v1.2.0", "old"); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, binaryPath, "#!/bin/sh\necho node-agent new\n")
+}
+
+func TestConfirmUpdate(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		pendingTo     string
+		commit        string
+		wantConfirmed bool
+	}{{
+		name:          "It should cancel the rollback when the updated node agent runs",
+		pendingTo:     "new",
+		commit:        "new",
+		wantConfirmed: true,
+	}, {
+		name:      "It should keep the rollback when another node agent runs",
+		pendingTo: "new",
+		commit:    "old",
+	}, {
+		name:   "It should do nothing without a pending update",
+		commit: "new",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := testSelfUpdate(t)
+			if tt.pendingTo != "" {
+				writePendingUpdate(t, tt.pendingTo, "sha")
+			}
+
+			if err := confirmUpdate(mntr.Monitor{}, tt.commit); err != nil {
+				t.Fatal(err)
+			}
+
+			pending, err := readPendingUpdate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantConfirmed {
+				if pending != nil {
+					t.Error("expected the pending update to be removed")
+				}
+				if len(*commands) != 1 || (*commands)[0] != "systemctl stop "+rollbackUnit+".timer" {
+					t.Errorf("expected the rollback timer to be stopped, but got commands %v", *commands)
+				}
+				return
+			}
+			if tt.pendingTo != "" && pending == nil {
+				t.Error("expected the pending update to be kept")
+			}
+			if len(*commands) > 0 {
+				t.Errorf("expected no commands, but got %v", *commands)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	commands := testSelfUpdate(t)
+	update := testUpdate(t, "new")
+
+	if err := selfUpdate(mntr.Monitor{}, update, "v1.0.0", "old"); err != nil {
+		t.Fatal(err)
+	}
+	*commands = (*commands)[:0]
+
+	if err := Rollback(mntr.Monitor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFile(t, binaryPath, "previous")
+	assertFile(t, failedPath, update.SHA256)
+	assertNotExists(t, updateMarker)
+	if len(*commands) != 1 || (*commands)[0] != "systemctl restart "+systemdEntry {
+		t.Errorf("expected the node agent to be restarted, but got commands %v", *commands)
+	}
+
+	if err := selfUpdate(mntr.Monitor{}, update, "v1.0.0", "old"); err == nil {
+		t.Error("expected the rolled back update not to be retried")
+	}
+
+	// A confirmed update is not rolled back
+	*commands = (*commands)[:0]
+	if err := Rollback(mntr.Monitor{}); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, binaryPath, "previous")
+	if len(*commands) > 0 {
+		t.Errorf("expected no commands without a pending update, but got %v", *commands)
+	}
+}
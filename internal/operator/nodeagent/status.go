@@ -0,0 +1,132 @@
+package nodeagent
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+// StatusSocket is only accessible by root, as /var/orbiter is.
+// Its file permissions are the only authentication, so the status is read only and serves diagnostics like orbctl node status.
+// ORBITER doesn't query it, but keeps relying on the current states the node agents push to the repository.
+const StatusSocket = "/var/orbiter/node-agent.sock"
+
+// StatusRecorder holds the outcome of the last iteration
+type StatusRecorder struct {
+	mux    sync.Mutex
+	status common.NodeAgentStatus
+}
+
+func NewStatusRecorder(id, commit string) *StatusRecorder {
+	return &StatusRecorder{status: common.NodeAgentStatus{ID: id, Commit: commit}}
+}
+
+func (s *StatusRecorder) record(desired *common.NodeAgentSpec, current *common.NodeAgentCurrent, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if desired != nil {
+		s.status.Desired = desired
+	}
+	if current != nil {
+		s.status.Current = current
+	}
+	if s.status.Desired != nil && s.status.Current != nil {
+		s.status.RebootPending = s.status.Desired.RebootRequired.After(s.status.Current.Booted)
+	}
+	s.status.LastIteration = time.Now()
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+}
+
+func (s *StatusRecorder) yaml() []byte {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return common.MarshalYAML(s.status)
+}
+
+// ServeStatus serves the recorded status at StatusSocket until the context is done
+func ServeStatus(ctx context.Context, monitor mntr.Monitor, recorder *StatusRecorder) error {
+	return serveStatus(ctx, monitor, recorder, StatusSocket)
+}
+
+func serveStatus(ctx context.Context, monitor mntr.Monitor, recorder *StatusRecorder, socket string) error {
+
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return err
+	}
+
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(socket, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		if _, err := w.Write(recorder.yaml()); err != nil {
+			monitor.Error(fmt.Errorf("writing status failed: %w", err))
+		}
+	})
+
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err := srv.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// QueryStatus requests the status from the running node agent
+func QueryStatus() ([]byte, error) {
+	return queryStatus(StatusSocket)
+}
+
+func queryStatus(socket string) ([]byte, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://node-agent/status")
+	if err != nil {
+		return nil, fmt.Errorf("querying node agent status at %s failed: %w", socket, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("querying node agent status returned %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,124 @@
+package nodeagent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+func TestStatusRecorder_record(t *testing.T) {
+
+	booted := time.Date(2021, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		desired           *common.NodeAgentSpec
+		current           *common.NodeAgentCurrent
+		err               error
+		wantRebootPending bool
+		wantLastError     string
+	}{{
+		name:              "It should report a pending reboot when a reboot was required after booting",
+		desired:           &common.NodeAgentSpec{RebootRequired: booted.Add(time.Minute)},
+		current:           &common.NodeAgentCurrent{Booted: booted},
+		wantRebootPending: true,
+	}, {
+		name:    "It should not report a pending reboot when the machine booted after the reboot was required",
+		desired: &common.NodeAgentSpec{RebootRequired: booted.Add(-time.Minute)},
+		current: &common.NodeAgentCurrent{Booted: booted},
+	}, {
+		name:          "It should report the iterations error",
+		err:           errors.New("ensuring failed"),
+		wantLastError: "ensuring failed",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := NewStatusRecorder("machine", "commit")
+			recorder.record(tt.desired, tt.current, tt.err)
+
+			if recorder.status.RebootPending != tt.wantRebootPending {
+				t.Errorf("RebootPending = %t, want %t", recorder.status.RebootPending, tt.wantRebootPending)
+			}
+			if recorder.status.LastError != tt.wantLastError {
+				t.Errorf("LastError = %s, want %s", recorder.status.LastError, tt.wantLastError)
+			}
+			if recorder.status.LastIteration.IsZero() {
+				t.Error("expected the iteration time to be recorded")
+			}
+		})
+	}
+}
+
+func TestStatusRecorder_recordKeepsLastKnownState(t *testing.T) {
+	recorder := NewStatusRecorder("machine", "commit")
+	desired := &common.NodeAgentSpec{}
+	current := &common.NodeAgentCurrent{}
+	recorder.record(desired, current, errors.New("ensuring failed"))
+
+	// Iterations which fail before reading the desired state or querying the current state don't reset them
+	recorder.record(nil, nil, nil)
+
+	if recorder.status.Desired != desired || recorder.status.Current != current {
+		t.Error("expected the last known desired and current state to be kept")
+	}
+	if recorder.status.LastError != "" {
+		t.Errorf("expected the last error to be reset, but got %s", recorder.status.LastError)
+	}
+}
+
+func TestServeStatus(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "orbiter", "node-agent.sock")
+
+	recorder := NewStatusRecorder("machine", "commit")
+	recorder.record(&common.NodeAgentSpec{}, &common.NodeAgentCurrent{}, errors.New("ensuring failed"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() { served <- serveStatus(ctx, mntr.Monitor{}, recorder, socket) }()
+
+	var (
+		out []byte
+		err error
+	)
+	for i := 0; i < 50; i++ {
+		if out, err = queryStatus(socket); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := common.NodeAgentStatus{}
+	if err := yaml.Unmarshal(out, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.ID != "machine" || status.Commit != "commit" || status.LastError != "ensuring failed" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected the socket to be accessible by its owner only, but its permissions are %o", perm)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("expected serving to stop without an error, but got %v", err)
+	}
+	if _, err := queryStatus(socket); err == nil {
+		t.Error("expected querying to fail after the server stopped")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/caos/orbos/internal/operator/orbiter"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes"
+	"github.com/caos/orbos/internal/operator/orbiter/maintenance"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/git"
 	"github.com/caos/orbos/pkg/labels"
@@ -19,6 +20,7 @@ func GetQueryAndDestroyFuncs(
 	clusterTree *tree.Tree,
 	oneoff bool,
 	pprof bool,
+	maintenanceWindows maintenance.Windows,
 	deployOrbiter bool,
 	clusterCurrent *tree.Tree,
 	destroyProviders func(map[string]interface{}) (map[string]interface{}, error),
@@ -42,6 +44,7 @@ func GetQueryAndDestroyFuncs(
 			oneoff,
 			deployOrbiter,
 			pprof,
+			maintenanceWindows,
 			destroyProviders,
 			func(whitelist []*orbiter.CIDR) {
 				go func() {
@@ -54,6 +54,7 @@ type Machine interface {
 	IP() string
 	Destroy() (remove func() error, err error)
 	Execute(stdin io.Reader, cmd string) ([]byte, error)
+	RemoteUser() string
 	Shell() error
 	WriteFile(path string, data io.Reader, permissions uint16) error
 	ReadFile(path string, data io.Writer) error
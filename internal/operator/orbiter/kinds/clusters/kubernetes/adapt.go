@@ -8,6 +8,7 @@ import (
 	"github.com/caos/orbos/internal/operator/common"
 	"github.com/caos/orbos/internal/operator/orbiter"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/maintenance"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/git"
 	"github.com/caos/orbos/pkg/kubernetes"
@@ -24,6 +25,7 @@ func AdaptFunc(
 	oneoff bool,
 	deployOrbiter bool,
 	pprof bool,
+	maintenanceWindows maintenance.Windows,
 	destroyProviders func(map[string]interface{}) (map[string]interface{}, error),
 	whitelist func(whitelist []*orbiter.CIDR),
 	gitClient *git.Client,
@@ -79,6 +81,10 @@ func AdaptFunc(
 			return nil, nil, nil, migrate, nil, err
 		}
 
+		for _, pool := range append([]*Pool{&desiredKind.Spec.ControlPlane}, desiredKind.Spec.Workers...) {
+			pool.maintenance = pool.MaintenanceWindows.Or(maintenanceWindows)
+		}
+
 		if desiredKind.Spec.Verbose && !monitor.IsVerbose() {
 			monitor = monitor.Verbose()
 		}
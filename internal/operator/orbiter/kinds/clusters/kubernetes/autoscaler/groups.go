@@ -0,0 +1,198 @@
+package autoscaler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/autoscaler/protos"
+)
+
+// group is an autoscaled worker pool
+type group struct {
+	id       string
+	cluster  string
+	provider string
+	pool     string
+	nodes    int
+	minNodes int
+	maxNodes int
+	machines map[string]*kubernetes.Machine
+}
+
+type groups map[string]*group
+
+func groupID(cluster, provider, pool string) string {
+	return fmt.Sprintf("%s/%s/%s", cluster, provider, pool)
+}
+
+func (g *group) nodeGroup() *protos.NodeGroup {
+	return &protos.NodeGroup{
+		Id:      g.id,
+		MinSize: int32(g.minNodes),
+		MaxSize: int32(g.maxNodes),
+		Debug:   fmt.Sprintf("pool %s from provider %s with %d desired nodes", g.pool, g.provider, g.nodes),
+	}
+}
+
+func (g *group) contains(node *protos.ExternalGrpcNode) bool {
+	for id := range g.machines {
+		if node.GetName() == id || node.GetProviderID() == kubernetes.ProviderID(g.provider, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *group) instances() []*protos.Instance {
+	instances := make([]*protos.Instance, 0, len(g.machines))
+	for id, machine := range g.machines {
+		state := protos.InstanceStatus_instanceRunning
+		switch {
+		case machine.Replacement == kubernetes.ReplacementDestroying:
+			state = protos.InstanceStatus_instanceDeleting
+		case !machine.Joined:
+			state = protos.InstanceStatus_instanceCreating
+		}
+		instances = append(instances, &protos.Instance{
+			Id:     kubernetes.ProviderID(g.provider, id),
+			Status: &protos.InstanceStatus{InstanceState: state},
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Id < instances[j].Id })
+	return instances
+}
+
+func (g groups) sorted() []*group {
+	sorted := make([]*group, 0, len(g))
+	for _, grp := range g {
+		sorted = append(sorted, grp)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].id < sorted[j].id })
+	return sorted
+}
+
+func (g groups) forNode(node *protos.ExternalGrpcNode) *group {
+	for _, grp := range g.sorted() {
+		if grp.contains(node) {
+			return grp
+		}
+	}
+	return nil
+}
+
+// parseGroups reads the autoscaled worker pools from the orbs desired state and their machines from the orbs current state
+func parseGroups(orbiterYAML, currentYAML []byte) (groups, error) {
+
+	desired := struct {
+		Clusters map[string]struct {
+			Kind string
+			Spec struct {
+				Workers []*kubernetes.Pool
+			}
+		}
+	}{}
+	if err := yaml.Unmarshal(orbiterYAML, &desired); err != nil {
+		return nil, fmt.Errorf("parsing desired state failed: %w", err)
+	}
+
+	current := struct {
+		Clusters map[string]struct {
+			Current struct {
+				Machines map[string]*kubernetes.Machine
+			}
+		}
+	}{}
+	if err := yaml.Unmarshal(currentYAML, &current); err != nil {
+		return nil, fmt.Errorf("parsing current state failed: %w", err)
+	}
+
+	parsed := make(groups)
+	for clusterID, cluster := range desired.Clusters {
+		if cluster.Kind != "orbiter.caos.ch/KubernetesCluster" {
+			continue
+		}
+		for _, worker := range cluster.Spec.Workers {
+			if worker == nil || !worker.Autoscaled() {
+				continue
+			}
+			grp := &group{
+				id:       groupID(clusterID, worker.Provider, worker.Pool),
+				cluster:  clusterID,
+				provider: worker.Provider,
+				pool:     worker.Pool,
+				nodes:    worker.Nodes,
+				minNodes: worker.MinNodes,
+				maxNodes: worker.MaxNodes,
+				machines: make(map[string]*kubernetes.Machine),
+			}
+			for machineID, machine := range current.Clusters[clusterID].Current.Machines {
+				if machine != nil &&
+					machine.Metadata.Tier == kubernetes.Workers &&
+					machine.Metadata.Provider == worker.Provider &&
+					machine.Metadata.Pool == worker.Pool {
+					grp.machines[machineID] = machine
+				}
+			}
+			parsed[grp.id] = grp
+		}
+	}
+	return parsed, nil
+}
+
+// setNodes changes the groups nodes in orbiter.yml and leaves everything else untouched
+func setNodes(orbiterYAML []byte, g *group, nodes int) ([]byte, error) {
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(orbiterYAML, doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, errors.New("desired state is empty")
+	}
+
+	workers := mappingValue(doc.Content[0], "clusters", g.cluster, "spec", "workers")
+	if workers == nil || workers.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("no workers found in cluster %s", g.cluster)
+	}
+
+	for _, worker := range workers.Content {
+		provider, pool := mappingValue(worker, "provider"), mappingValue(worker, "pool")
+		if provider == nil || pool == nil || provider.Value != g.provider || pool.Value != g.pool {
+			continue
+		}
+		value := strconv.Itoa(nodes)
+		if nodesNode := mappingValue(worker, "nodes"); nodesNode != nil {
+			nodesNode.Value = value
+		} else {
+			worker.Content = append(worker.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "nodes"},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value},
+			)
+		}
+		return common.MarshalYAML(doc), nil
+	}
+	return nil, fmt.Errorf("pool %s from provider %s not found in cluster %s", g.pool, g.provider, g.cluster)
+}
+
+func mappingValue(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var found *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				found = node.Content[i+1]
+				break
+			}
+		}
+		node = found
+	}
+	return node
+}
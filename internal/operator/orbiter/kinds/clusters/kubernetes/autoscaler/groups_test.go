@@ -0,0 +1,109 @@
+package autoscaler
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/autoscaler/protos"
+)
+
+const (
+	testOrbiterYAML = `kind: orbiter.caos.ch/Orb
+version: v0
+clusters:
+  k8s:
+    kind: orbiter.caos.ch/KubernetesCluster
+    version: v0
+    spec:
+      controlplane:
+        provider: gce
+        nodes: 1
+        pool: management
+      workers:
+        - provider: gce
+          nodes: 2
+          pool: application
+          minnodes: 1
+          maxnodes: 5
+        - provider: gce
+          nodes: 1
+          pool: storage
+`
+	testCurrentYAML = `clusters:
+  k8s:
+    current:
+      status: running
+      machines:
+        orbos-app-1:
+          joined: true
+          tier: workers
+          provider: gce
+          pool: application
+        orbos-app-2:
+          joined: false
+          tier: workers
+          provider: gce
+          pool: application
+        orbos-storage-1:
+          joined: true
+          tier: workers
+          provider: gce
+          pool: storage
+`
+)
+
+func TestParseGroups(t *testing.T) {
+	parsed, err := parseGroups([]byte(testOrbiterYAML), []byte(testCurrentYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed) != 1 {
+		t.Fatalf("expected only the autoscaled pool, but got %d groups", len(parsed))
+	}
+
+	g := parsed["k8s/gce/application"]
+	if g == nil || g.nodes != 2 || g.minNodes != 1 || g.maxNodes != 5 {
+		t.Fatalf("unexpected group %+v", g)
+	}
+
+	expect := []*protos.Instance{
+		{Id: "orbos://gce/orbos-app-1", Status: &protos.InstanceStatus{InstanceState: protos.InstanceStatus_instanceRunning}},
+		{Id: "orbos://gce/orbos-app-2", Status: &protos.InstanceStatus{InstanceState: protos.InstanceStatus_instanceCreating}},
+	}
+	instances := g.instances()
+	if len(instances) != len(expect) {
+		t.Fatalf("expected instances %v, but got %v", expect, instances)
+	}
+	for i := range expect {
+		if !proto.Equal(instances[i], expect[i]) {
+			t.Errorf("expected instance %v, but got %v", expect[i], instances[i])
+		}
+	}
+
+	if parsed.forNode(&protos.ExternalGrpcNode{ProviderID: "orbos://gce/orbos-app-2"}) != g {
+		t.Error("expected node to be found by its provider ID")
+	}
+	if parsed.forNode(&protos.ExternalGrpcNode{Name: "orbos-storage-1"}) != nil {
+		t.Error("expected node from a not autoscaled pool to have no group")
+	}
+}
+
+func TestSetNodes(t *testing.T) {
+	parsed, err := parseGroups([]byte(testOrbiterYAML), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scaled, err := setNodes([]byte(testOrbiterYAML), parsed["k8s/gce/application"], 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := strings.Replace(testOrbiterYAML, "nodes: 2", "nodes: 4", 1)
+	if string(scaled) != expect {
+		t.Errorf("expected\n%s\nbut got\n%s", expect, string(scaled))
+	}
+}
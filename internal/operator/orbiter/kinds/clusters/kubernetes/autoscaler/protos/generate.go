@@ -0,0 +1,3 @@
+package protos
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative externalgrpc.proto
@@ -0,0 +1,372 @@
+package autoscaler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/autoscaler/protos"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
+)
+
+const (
+	serviceName = "clusterautoscaler.cloudprovider.v1.externalgrpc.CloudProvider"
+	gpuLabel    = "orbos.ch/gpu"
+	minRetry    = 5 * time.Second
+	maxRetry    = 5 * time.Minute
+)
+
+type server struct {
+	monitor   mntr.Monitor
+	gitClient *git.Client
+	mux       sync.Mutex
+	groups    groups
+}
+
+// Serve implements the cluster-autoscalers external gRPC cloud provider for all autoscaled worker pools.
+// The gitClient must be configured and must not be shared, as it is synced whenever the cluster-autoscaler refreshes.
+// Serve only listens as soon as a pool is autoscaled. Clients must authenticate with a certificate signed by the CA in tlsDir.
+// As long as the repository or the certificates are unavailable, Serve retries with an exponential backoff until ctx is done.
+func Serve(ctx context.Context, monitor mntr.Monitor, gitClient *git.Client, addr, tlsDir string) error {
+
+	srv := &server{
+		monitor:   monitor,
+		gitClient: gitClient,
+	}
+
+	var tlsConfig *tls.Config
+	for failures := 0; ; {
+		wait := time.Minute
+		err := srv.refresh()
+		if err == nil && len(srv.groups) > 0 {
+			tlsConfig, err = serverTLS(tlsDir)
+			if err == nil {
+				break
+			}
+		}
+		switch {
+		case err != nil:
+			failures++
+			wait = retryAfter(failures)
+			monitor.WithField("retry", wait).Error(fmt.Errorf("preparing the cluster-autoscaler cloud provider failed: %w", err))
+		default:
+			failures = 0
+			monitor.Debug("No pool is autoscaled")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	grpcServer := newGRPCServer(srv, tlsConfig)
+
+	go func() {
+		<-ctx.Done()
+		grpcServer.GracefulStop()
+	}()
+
+	monitor.WithField("address", addr).Info("Serving cluster-autoscaler cloud provider")
+	return grpcServer.Serve(listener)
+}
+
+// retryAfter doubles the wait for each failure up to maxRetry
+func retryAfter(failures int) time.Duration {
+	if failures > 7 {
+		return maxRetry
+	}
+	if wait := minRetry << uint(failures-1); wait < maxRetry {
+		return wait
+	}
+	return maxRetry
+}
+
+func newGRPCServer(srv *server, tlsConfig *tls.Config) *grpc.Server {
+	grpcServer := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	grpcServer.RegisterService(&serviceDesc, srv)
+	return grpcServer
+}
+
+// serverTLS requires client certificates signed by the ca.crt in dir.
+// The files are read for each connection, so rotated certificates are used as soon as they are mounted.
+func serverTLS(dir string) (*tls.Config, error) {
+
+	load := func() (*tls.Config, error) {
+		caPEM, err := ioutil.ReadFile(filepath.Join(dir, "ca.crt"))
+		if err != nil {
+			return nil, fmt.Errorf("reading autoscaler certificate authority failed: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificate found in %s", filepath.Join(dir, "ca.crt"))
+		}
+		cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"))
+		if err != nil {
+			return nil, fmt.Errorf("loading autoscaler server certificate failed: %w", err)
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+			MinVersion:   tls.VersionTLS12,
+		}, nil
+	}
+
+	initial, err := load()
+	if err != nil {
+		return nil, err
+	}
+	initial.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return load()
+	}
+	return initial, nil
+}
+
+func (s *server) refresh() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if err := s.gitClient.Clone(); err != nil {
+		return err
+	}
+	return s.parse()
+}
+
+// parse must be called with s.mux locked
+func (s *server) parse() error {
+	parsed, err := parseGroups(s.gitClient.Read(string(git.OrbiterFile)), s.gitClient.Read("caos-internal/orbiter/current.yml"))
+	if err != nil {
+		return err
+	}
+	s.groups = parsed
+	return nil
+}
+
+func (s *server) group(id string) (*group, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	g, ok := s.groups[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "node group %s not found", id)
+	}
+	return g, nil
+}
+
+// scale writes the nodes returned by resize to the pools desired state in orbiter.yml.
+// resize is called with the pool as it is in the remote repository.
+func (s *server) scale(id string, resize func(g *group) (int, error)) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var resizeErr error
+	err := s.gitClient.UpdateRemote(fmt.Sprintf("Autoscale node group %s", id), func() []git.File {
+		resizeErr = nil
+		orbiterYAML := s.gitClient.Read(string(git.OrbiterFile))
+		remoteGroups, err := parseGroups(orbiterYAML, s.gitClient.Read("caos-internal/orbiter/current.yml"))
+		if err != nil {
+			resizeErr = err
+			return nil
+		}
+		g, ok := remoteGroups[id]
+		if !ok {
+			resizeErr = status.Errorf(codes.NotFound, "node group %s not found", id)
+			return nil
+		}
+		nodes, err := resize(g)
+		if err != nil {
+			resizeErr = err
+			return nil
+		}
+		scaled, err := setNodes(orbiterYAML, g, nodes)
+		if err != nil {
+			resizeErr = err
+			return nil
+		}
+		s.monitor.WithFields(map[string]interface{}{
+			"group": id,
+			"from":  g.nodes,
+			"to":    nodes,
+		}).Changed("Autoscaling node group")
+		return []git.File{{
+			Path:    string(git.OrbiterFile),
+			Content: scaled,
+		}}
+	})
+	if resizeErr != nil {
+		return resizeErr
+	}
+	if err != nil {
+		return err
+	}
+	return s.parse()
+}
+
+func (s *server) nodeGroups() *protos.NodeGroupsResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	resp := &protos.NodeGroupsResponse{}
+	for _, g := range s.groups.sorted() {
+		resp.NodeGroups = append(resp.NodeGroups, g.nodeGroup())
+	}
+	return resp
+}
+
+// nodeGroupForNode returns an empty node group id for nodes which are not autoscaled
+func (s *server) nodeGroupForNode(req *protos.NodeGroupForNodeRequest) *protos.NodeGroupForNodeResponse {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	resp := &protos.NodeGroupForNodeResponse{NodeGroup: &protos.NodeGroup{}}
+	if g := s.groups.forNode(req.GetNode()); g != nil {
+		resp.NodeGroup = g.nodeGroup()
+	}
+	return resp
+}
+
+func (s *server) targetSize(req *protos.NodeGroupTargetSizeRequest) (*protos.NodeGroupTargetSizeResponse, error) {
+	g, err := s.group(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return &protos.NodeGroupTargetSizeResponse{TargetSize: int32(g.nodes)}, nil
+}
+
+func (s *server) increaseSize(req *protos.NodeGroupIncreaseSizeRequest) (*protos.NodeGroupIncreaseSizeResponse, error) {
+	if req.GetDelta() <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "size increase must be positive but is %d", req.GetDelta())
+	}
+	return &protos.NodeGroupIncreaseSizeResponse{}, s.scale(req.GetId(), func(g *group) (int, error) {
+		nodes := g.nodes + int(req.GetDelta())
+		if nodes > g.maxNodes {
+			return 0, status.Errorf(codes.OutOfRange, "increasing node group %s to %d nodes exceeds its maximum of %d", g.id, nodes, g.maxNodes)
+		}
+		return nodes, nil
+	})
+}
+
+// decreaseTargetSize only decreases the nodes which are not created yet
+func (s *server) decreaseTargetSize(req *protos.NodeGroupDecreaseTargetSizeRequest) (*protos.NodeGroupDecreaseTargetSizeResponse, error) {
+	if req.GetDelta() >= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "size decrease must be negative but is %d", req.GetDelta())
+	}
+	return &protos.NodeGroupDecreaseTargetSizeResponse{}, s.scale(req.GetId(), func(g *group) (int, error) {
+		nodes := g.nodes + int(req.GetDelta())
+		if existing := len(g.machines); nodes < existing {
+			return 0, status.Errorf(codes.FailedPrecondition, "decreasing node group %s to %d nodes would delete some of its %d existing nodes", g.id, nodes, existing)
+		}
+		if nodes < g.minNodes {
+			return 0, status.Errorf(codes.OutOfRange, "decreasing node group %s to %d nodes undercuts its minimum of %d", g.id, nodes, g.minNodes)
+		}
+		return nodes, nil
+	})
+}
+
+// deleteNodes decreases the pools nodes.
+// ORBITER downscales the nodes first, which the cluster-autoscaler tainted before calling deleteNodes
+func (s *server) deleteNodes(req *protos.NodeGroupDeleteNodesRequest) (*protos.NodeGroupDeleteNodesResponse, error) {
+	return &protos.NodeGroupDeleteNodesResponse{}, s.scale(req.GetId(), func(g *group) (int, error) {
+		for _, node := range req.GetNodes() {
+			if !g.contains(node) {
+				return 0, status.Errorf(codes.InvalidArgument, "node %s doesn't belong to node group %s", node.GetName(), g.id)
+			}
+		}
+		nodes := g.nodes - len(req.GetNodes())
+		if nodes < g.minNodes {
+			return 0, status.Errorf(codes.OutOfRange, "deleting %d nodes from node group %s undercuts its minimum of %d", len(req.GetNodes()), g.id, g.minNodes)
+		}
+		return nodes, nil
+	})
+}
+
+func (s *server) nodeGroupNodes(req *protos.NodeGroupNodesRequest) (*protos.NodeGroupNodesResponse, error) {
+	g, err := s.group(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return &protos.NodeGroupNodesResponse{Instances: g.instances()}, nil
+}
+
+// serviceDesc registers the CloudProvider service from externalgrpc.proto
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("NodeGroups", func() proto.Message { return &protos.NodeGroupsRequest{} }, func(s *server, _ proto.Message) (proto.Message, error) {
+			return s.nodeGroups(), nil
+		}),
+		unary("NodeGroupForNode", func() proto.Message { return &protos.NodeGroupForNodeRequest{} }, func(s *server, req proto.Message) (proto.Message, error) {
+			return s.nodeGroupForNode(req.(*protos.NodeGroupForNodeRequest)), nil
+		}),
+		unary("GPULabel", func() proto.Message { return &protos.GPULabelRequest{} }, func(*server, proto.Message) (proto.Message, error) {
+			return &protos.GPULabelResponse{Label: gpuLabel}, nil
+		}),
+		unary("GetAvailableGPUTypes", func() proto.Message { return &protos.GetAvailableGPUTypesRequest{} }, func(*server, proto.Message) (proto.Message, error) {
+			return &protos.GetAvailableGPUTypesResponse{}, nil
+		}),
+		unary("Cleanup", func() proto.Message { return &protos.CleanupRequest{} }, func(*server, proto.Message) (proto.Message, error) {
+			return &protos.CleanupResponse{}, nil
+		}),
+		unary("Refresh", func() proto.Message { return &protos.RefreshRequest{} }, func(s *server, _ proto.Message) (proto.Message, error) {
+			if err := s.refresh(); err != nil {
+				return nil, status.Errorf(codes.Unavailable, "refreshing node groups failed: %s", err.Error())
+			}
+			return &protos.RefreshResponse{}, nil
+		}),
+		unary("NodeGroupTargetSize", func() proto.Message { return &protos.NodeGroupTargetSizeRequest{} }, func(s *server, req proto.Message) (proto.Message, error) {
+			return s.targetSize(req.(*protos.NodeGroupTargetSizeRequest))
+		}),
+		unary("NodeGroupIncreaseSize", func() proto.Message { return &protos.NodeGroupIncreaseSizeRequest{} }, func(s *server, req proto.Message) (proto.Message, error) {
+			return s.increaseSize(req.(*protos.NodeGroupIncreaseSizeRequest))
+		}),
+		unary("NodeGroupDeleteNodes", func() proto.Message { return &protos.NodeGroupDeleteNodesRequest{} }, func(s *server, req proto.Message) (proto.Message, error) {
+			return s.deleteNodes(req.(*protos.NodeGroupDeleteNodesRequest))
+		}),
+		unary("NodeGroupDecreaseTargetSize", func() proto.Message { return &protos.NodeGroupDecreaseTargetSizeRequest{} }, func(s *server, req proto.Message) (proto.Message, error) {
+			return s.decreaseTargetSize(req.(*protos.NodeGroupDecreaseTargetSizeRequest))
+		}),
+		unary("NodeGroupNodes", func() proto.Message { return &protos.NodeGroupNodesRequest{} }, func(s *server, req proto.Message) (proto.Message, error) {
+			return s.nodeGroupNodes(req.(*protos.NodeGroupNodesRequest))
+		}),
+	},
+	Metadata: "externalgrpc.proto",
+}
+
+func unary(name string, newRequest func() proto.Message, call func(s *server, req proto.Message) (proto.Message, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newRequest()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handle := func(_ context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(*server), req.(proto.Message))
+			}
+			if interceptor == nil {
+				return handle(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: fmt.Sprintf("/%s/%s", serviceName, name),
+			}, handle)
+		},
+	}
+}
@@ -0,0 +1,142 @@
+package autoscaler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/autoscaler/protos"
+	"github.com/caos/orbos/internal/ssh"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
+	"github.com/caos/orbos/pkg/kubernetes"
+)
+
+func TestServe_RequiresClientCertificates(t *testing.T) {
+
+	certs, err := kubernetes.NewAutoscalerTLS("orbiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for file, content := range map[string][]byte{
+		"ca.crt":  certs.CA,
+		"tls.crt": certs.ServerCert,
+		"tls.key": certs.ServerKey,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tlsConfig, err := serverTLS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := parseGroups([]byte(testOrbiterYAML), []byte(testCurrentYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := newGRPCServer(&server{groups: groups}, tlsConfig)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certs.CA)
+	clientCert, err := tls.X509KeyPair(certs.ClientCert, certs.ClientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCerts, err := kubernetes.NewAutoscalerTLS("orbiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherClientCert, err := tls.X509KeyPair(otherCerts.ClientCert, otherCerts.ClientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		certs   []tls.Certificate
+		wantErr bool
+	}{{
+		name:  "It should serve clients with a certificate signed by the certificate authority",
+		certs: []tls.Certificate{clientCert},
+	}, {
+		name:    "It should reject clients without a certificate",
+		wantErr: true,
+	}, {
+		name:    "It should reject clients with a certificate signed by another certificate authority",
+		certs:   []tls.Certificate{otherClientCert},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			conn, err := grpc.DialContext(ctx, listener.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				ServerName:   "orbiter",
+				RootCAs:      pool,
+				Certificates: tt.certs,
+			})))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			resp := &protos.NodeGroupsResponse{}
+			err = conn.Invoke(ctx, "/"+serviceName+"/NodeGroups", &protos.NodeGroupsRequest{}, resp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NodeGroups() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(resp.GetNodeGroups()) != 1 {
+				t.Errorf("expected one node group, got %d", len(resp.GetNodeGroups()))
+			}
+		})
+	}
+}
+
+func TestServe_RetriesUnavailableRepository(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	deployKey, _ := ssh.Generate()
+	gitClient := git.New(ctx, mntr.Monitor{}, "orbos", "orbos@caos.ch")
+	if err := gitClient.Configure("ssh://git@127.0.0.1:1/unavailable/repository.git", []byte(deployKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Serve(ctx, mntr.Monitor{}, gitClient, "127.0.0.1:0", t.TempDir()); err != nil {
+		t.Errorf("expected Serve to retry until the context is done, but it returned %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for failures, want := range map[int]time.Duration{
+		1:  minRetry,
+		2:  2 * minRetry,
+		6:  160 * time.Second,
+		7:  maxRetry,
+		64: maxRetry,
+	} {
+		if got := retryAfter(failures); got != want {
+			t.Errorf("retryAfter(%d) = %s, want %s", failures, got, want)
+		}
+	}
+}
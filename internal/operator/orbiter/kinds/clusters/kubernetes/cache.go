@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"sync"
+	"time"
+)
+
+// clusterCache keeps what ORBITER looked up about a cluster, so it is not looked up again in every iteration.
+// It is empty after ORBITER restarts, so everything cached must be recoverable from the machines, the cluster or the repository.
+type clusterCache struct {
+	mux sync.Mutex
+	// certificates are the expiries read from the control plane machines
+	certificates map[string]checkedCertificates
+	// rebootLocks are the names of the leases machines hold, an empty name means they hold none
+	rebootLocks   map[string]string
+	etcdSnapshots etcdSnapshots
+}
+
+type checkedCertificates struct {
+	expiries map[string]time.Time
+	at       time.Time
+}
+
+type etcdSnapshots struct {
+	// latest caches the latest snapshot id, so the store is only listed once per process
+	latest      string
+	latestKnown bool
+	nextAttempt time.Time
+}
+
+var (
+	// clusterCaches is keyed by the cluster ids, as an orb can have several clusters
+	clusterCachesMux sync.Mutex
+	clusterCaches    = make(map[string]*clusterCache)
+)
+
+func cacheOf(clusterID string) *clusterCache {
+	clusterCachesMux.Lock()
+	defer clusterCachesMux.Unlock()
+	cache, ok := clusterCaches[clusterID]
+	if !ok {
+		cache = &clusterCache{
+			certificates: make(map[string]checkedCertificates),
+			rebootLocks:  make(map[string]string),
+		}
+		clusterCaches[clusterID] = cache
+	}
+	return cache
+}
+
+// prune forgets machines which don't exist anymore
+func (c *clusterCache) prune(machines []*initializedMachine) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	existing := make(map[string]struct{}, len(machines))
+	for _, machine := range machines {
+		existing[machine.infra.ID()] = struct{}{}
+	}
+	for machineID := range c.certificates {
+		if _, ok := existing[machineID]; !ok {
+			delete(c.certificates, machineID)
+		}
+	}
+	for machineID := range c.rebootLocks {
+		if _, ok := existing[machineID]; !ok {
+			delete(c.rebootLocks, machineID)
+		}
+	}
+}
+
+func (c *clusterCache) cacheCertificates(machineID string, expiries map[string]time.Time, at time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.certificates[machineID] = checkedCertificates{expiries: expiries, at: at}
+}
+
+func (c *clusterCache) cachedCertificates(machineID string) (checkedCertificates, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	checked, ok := c.certificates[machineID]
+	return checked, ok
+}
+
+// forgetCertificates lets the next iteration read the expiries from the machine again
+func (c *clusterCache) forgetCertificates(machineID string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.certificates, machineID)
+}
+
+func (c *clusterCache) cacheRebootLock(machineID, name string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.rebootLocks[machineID] = name
+}
+
+func (c *clusterCache) cachedRebootLock(machineID string) (string, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	name, ok := c.rebootLocks[machineID]
+	return name, ok
+}
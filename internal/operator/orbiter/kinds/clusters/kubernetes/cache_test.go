@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClusterCache_Prune(t *testing.T) {
+
+	now := time.Now()
+	first, second := cacheOf("first"), cacheOf("second")
+	first.cacheCertificates("kept", nil, now)
+	first.cacheCertificates("removed", nil, now)
+	first.cacheRebootLock("kept", "orbos-reboot-pool-0")
+	first.cacheRebootLock("removed", "orbos-reboot-pool-1")
+	second.cacheCertificates("removed", nil, now)
+	second.cacheRebootLock("removed", "orbos-reboot-pool-0")
+
+	first.prune([]*initializedMachine{{infra: &fakeMachine{id: "kept"}}})
+
+	if checked, ok := first.cachedCertificates("kept"); !ok || !checked.at.Equal(now) {
+		t.Errorf("expected the expiries of an existing machine to be kept, but got %v, %t", checked, ok)
+	}
+	if name, ok := first.cachedRebootLock("kept"); !ok || name != "orbos-reboot-pool-0" {
+		t.Errorf("expected the lock of an existing machine to be kept, but got %s, %t", name, ok)
+	}
+	if _, ok := first.cachedCertificates("removed"); ok {
+		t.Error("expected the expiries of a removed machine to be pruned")
+	}
+	if _, ok := first.cachedRebootLock("removed"); ok {
+		t.Error("expected the lock of a removed machine to be pruned")
+	}
+	if _, ok := second.cachedCertificates("removed"); !ok {
+		t.Error("expected the expiries of other clusters to be kept")
+	}
+	if _, ok := second.cachedRebootLock("removed"); !ok {
+		t.Error("expected the locks of other clusters to be kept")
+	}
+	if cacheOf("first") != first {
+		t.Error("expected the same cache for the same cluster")
+	}
+}
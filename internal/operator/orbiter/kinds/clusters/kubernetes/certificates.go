@@ -0,0 +1,250 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/kubernetes"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+const (
+	defaultRenewCertificatesBefore = 30 * 24 * time.Hour
+	checkCertificatesInterval      = time.Hour
+)
+
+// certificates are the files kubeadm certs renew all renews. The certificate authorities are valid for ten years and are not renewed.
+var certificates = map[string]string{
+	"apiserver":                "/etc/kubernetes/pki/apiserver.crt",
+	"apiserver-kubelet-client": "/etc/kubernetes/pki/apiserver-kubelet-client.crt",
+	"apiserver-etcd-client":    "/etc/kubernetes/pki/apiserver-etcd-client.crt",
+	"front-proxy-client":       "/etc/kubernetes/pki/front-proxy-client.crt",
+	"etcd-server":              "/etc/kubernetes/pki/etcd/server.crt",
+	"etcd-peer":                "/etc/kubernetes/pki/etcd/peer.crt",
+	"etcd-healthcheck-client":  "/etc/kubernetes/pki/etcd/healthcheck-client.crt",
+	"admin.conf":               "/etc/kubernetes/admin.conf",
+	"controller-manager.conf":  "/etc/kubernetes/controller-manager.conf",
+	"scheduler.conf":           "/etc/kubernetes/scheduler.conf",
+}
+
+var certificateExpiry = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "kubernetes_certificate_expiry_timestamp_seconds",
+		Help: "Expiry of the kubernetes control plane certificates as unix timestamp.",
+	},
+	[]string{"cluster", "machine", "certificate"},
+)
+
+func init() {
+	prometheus.MustRegister(certificateExpiry)
+}
+
+func (s *Spec) renewCertificatesBefore() time.Duration {
+	if s.RenewCertificatesBefore == "" {
+		return defaultRenewCertificatesBefore
+	}
+	before, err := time.ParseDuration(s.RenewCertificatesBefore)
+	if err != nil {
+		return defaultRenewCertificatesBefore
+	}
+	return before
+}
+
+// ensureCertificates reports the expiries of all control plane certificates and renews the certificates of one machine at a time.
+// When the kubeconfig in orbiter.yml is due, it is replaced by the admin.conf of a renewed machine.
+func ensureCertificates(
+	monitor mntr.Monitor,
+	clusterID string,
+	desired *DesiredV0,
+	current *CurrentCluster,
+	pdf func(mntr.Monitor) error,
+	k8sClient *kubernetes.Client,
+	controlplaneMachines []*initializedMachine,
+) error {
+
+	now := time.Now()
+	renewAt := now.Add(desired.Spec.renewCertificatesBefore())
+
+	cache := cacheOf(clusterID)
+
+	var (
+		renew       *initializedMachine
+		refreshFrom *initializedMachine
+	)
+	for _, machine := range controlplaneMachines {
+		if !machine.currentMachine.Joined {
+			continue
+		}
+		id := machine.infra.ID()
+
+		checked, ok := cache.cachedCertificates(id)
+		if !ok || now.Sub(checked.at) > checkCertificatesInterval {
+			expiries, err := readCertificateExpiries(machine.infra)
+			if err != nil {
+				return err
+			}
+			checked = checkedCertificates{expiries: expiries, at: now}
+			cache.cacheCertificates(id, expiries, now)
+		}
+
+		expiries := checked.expiries
+		machine.currentMachine.CertificatesExpire = expiries
+		for name, expiry := range expiries {
+			certificateExpiry.With(prometheus.Labels{
+				"cluster":     clusterID,
+				"machine":     id,
+				"certificate": name,
+			}).Set(float64(expiry.Unix()))
+		}
+
+		if firstExpiry(expiries).Before(renewAt) {
+			if renew == nil {
+				renew = machine
+			}
+			continue
+		}
+
+		if refreshFrom == nil {
+			refreshFrom = machine
+		}
+	}
+
+	if desired.Spec.Kubeconfig != nil && desired.Spec.Kubeconfig.Value != "" {
+		kubeconfigExpires, err := kubeconfigExpiry([]byte(desired.Spec.Kubeconfig.Value))
+		if err != nil {
+			return err
+		}
+		current.KubeconfigExpires = &kubeconfigExpires
+		certificateExpiry.With(prometheus.Labels{
+			"cluster":     clusterID,
+			"machine":     "",
+			"certificate": "kubeconfig",
+		}).Set(float64(kubeconfigExpires.Unix()))
+
+		if kubeconfigExpires.Before(renewAt) && refreshFrom != nil {
+			if err := refreshKubeconfig(monitor, clusterID, desired, pdf, refreshFrom.infra); err != nil {
+				return err
+			}
+		}
+	}
+
+	if renew == nil {
+		return nil
+	}
+
+	healthy, reason, err := upgradeHealthy(k8sClient, controlplaneMachines)
+	if err != nil {
+		return err
+	}
+	machineMonitor := monitor.WithField("machine", renew.infra.ID())
+	if !healthy {
+		machineMonitor.WithField("reason", reason).Info("Awaiting a healthy cluster before renewing certificates")
+		return nil
+	}
+
+	return renewCertificates(machineMonitor, clusterID, renew.infra)
+}
+
+func readCertificateExpiries(machine infra.Machine) (map[string]time.Time, error) {
+	expiries := make(map[string]time.Time)
+	for name, path := range certificates {
+		buf := new(bytes.Buffer)
+		if err := machine.ReadFile(path, buf); err != nil {
+			return nil, err
+		}
+
+		parse := certificateExpiryFromPEM
+		if strings.HasSuffix(path, ".conf") {
+			parse = kubeconfigExpiry
+		}
+
+		expiry, err := parse(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s from machine %s failed: %w", path, machine.ID(), err)
+		}
+		expiries[name] = expiry
+	}
+	return expiries, nil
+}
+
+// renewCertificates lets kubeadm renew all certificates and restarts the control plane static pods, so they load the renewed certificates
+func renewCertificates(monitor mntr.Monitor, clusterID string, machine infra.Machine) error {
+
+	cmd := `set -e
+sudo kubeadm certs renew all || sudo kubeadm alpha certs renew all
+sudo mkdir -p /etc/kubernetes/manifests-renewing
+sudo mv /etc/kubernetes/manifests/*.yaml /etc/kubernetes/manifests-renewing/
+sleep 20
+sudo mv /etc/kubernetes/manifests-renewing/*.yaml /etc/kubernetes/manifests/
+sudo systemctl restart kubelet`
+
+	if stdout, err := machine.Execute(nil, cmd); err != nil {
+		return fmt.Errorf("renewing certificates on machine %s failed: %s: %w", machine.ID(), string(stdout), err)
+	}
+
+	cacheOf(clusterID).forgetCertificates(machine.ID())
+	monitor.Changed("Control plane certificates renewed")
+	return nil
+}
+
+func refreshKubeconfig(monitor mntr.Monitor, clusterID string, desired *DesiredV0, pdf func(mntr.Monitor) error, machine infra.Machine) error {
+
+	adminConf := new(bytes.Buffer)
+	defer adminConf.Reset()
+	if err := machine.ReadFile("/etc/kubernetes/admin.conf", adminConf); err != nil {
+		return err
+	}
+
+	// Updating the existing secret keeps a reference to an external store
+	desired.Spec.Kubeconfig = secret.InitIfNil(desired.Spec.Kubeconfig)
+	desired.Spec.Kubeconfig.Value = strings.ReplaceAll(adminConf.String(), "kubernetes-admin", strings.Join([]string{clusterID, "admin"}, "-"))
+	return pdf(monitor.WithFields(map[string]interface{}{
+		"type":    "kubeconfig",
+		"machine": machine.ID(),
+	}))
+}
+
+func firstExpiry(expiries map[string]time.Time) time.Time {
+	var first time.Time
+	for _, expiry := range expiries {
+		if first.IsZero() || expiry.Before(first) {
+			first = expiry
+		}
+	}
+	return first
+}
+
+func certificateExpiryFromPEM(data []byte) (time.Time, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, errors.New("no PEM data found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+func kubeconfigExpiry(data []byte) (time.Time, error) {
+	kubeconfig, err := clientcmd.Load(data)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, authInfo := range kubeconfig.AuthInfos {
+		if len(authInfo.ClientCertificateData) > 0 {
+			return certificateExpiryFromPEM(authInfo.ClientCertificateData)
+		}
+	}
+	return time.Time{}, errors.New("no client certificate found in kubeconfig")
+}
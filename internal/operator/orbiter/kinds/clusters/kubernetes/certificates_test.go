@@ -0,0 +1,112 @@
+package kubernetes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+func testCertificate(t *testing.T, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kubernetes-admin"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCertificateExpiries(t *testing.T) {
+	notAfter := time.Date(2022, 10, 18, 12, 0, 0, 0, time.UTC)
+	cert := testCertificate(t, notAfter)
+
+	expiry, err := certificateExpiryFromPEM(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(notAfter) {
+		t.Errorf("expected certificate to expire at %s, but got %s", notAfter, expiry)
+	}
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://10.0.0.1:6443
+  name: orbos
+contexts:
+- context:
+    cluster: orbos
+    user: orbos-admin
+  name: orbos-admin@orbos
+current-context: orbos-admin@orbos
+users:
+- name: orbos-admin
+  user:
+    client-certificate-data: %s
+`, base64.StdEncoding.EncodeToString(cert))
+
+	expiry, err = kubeconfigExpiry([]byte(kubeconfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiry.Equal(notAfter) {
+		t.Errorf("expected kubeconfig to expire at %s, but got %s", notAfter, expiry)
+	}
+
+	first := firstExpiry(map[string]time.Time{
+		"apiserver":  notAfter,
+		"admin.conf": notAfter.Add(-time.Hour),
+	})
+	if !first.Equal(notAfter.Add(-time.Hour)) {
+		t.Errorf("expected the earliest expiry, but got %s", first)
+	}
+}
+
+type adminConfMachine struct {
+	fakeMachine
+	adminConf string
+}
+
+func (a *adminConfMachine) ReadFile(_ string, to io.Writer) error {
+	_, err := to.Write([]byte(a.adminConf))
+	return err
+}
+
+func TestRefreshKubeconfig_KeepsRef(t *testing.T) {
+	desired := &DesiredV0{Spec: Spec{Kubeconfig: &secret.Secret{Ref: "vault://secret/data/orbos#kubeconfig", Value: "expiring"}}}
+
+	if err := refreshKubeconfig(mntr.Monitor{}, "orb", desired, func(mntr.Monitor) error { return nil }, &adminConfMachine{
+		fakeMachine: fakeMachine{id: "controlplane"},
+		adminConf:   "user: kubernetes-admin",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if desired.Spec.Kubeconfig.Ref != "vault://secret/data/orbos#kubeconfig" {
+		t.Errorf("expected the reference to be kept, but got %s", desired.Spec.Kubeconfig.Ref)
+	}
+	if desired.Spec.Kubeconfig.Value != "user: orb-admin" {
+		t.Errorf("expected the refreshed kubeconfig, but got %s", desired.Spec.Kubeconfig.Value)
+	}
+}
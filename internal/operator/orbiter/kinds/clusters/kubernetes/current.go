@@ -2,15 +2,41 @@ package kubernetes
 
 import (
 	"sync"
+	"time"
 
+	"github.com/caos/orbos/internal/operator/orbiter"
 	"github.com/caos/orbos/pkg/tree"
 )
 
 type CurrentCluster struct {
 	Status   string
 	Machines Machines
+	Upgrade  *UpgradeProgress `yaml:",omitempty"`
+	// KubeconfigExpires is the expiry of the client certificate in the kubeconfig in orbiter.yml
+	KubeconfigExpires *time.Time `yaml:",omitempty"`
+	plan              orbiter.Plan
 }
 
+// UpgradeProgress reports a kubernetes upgrade over one or more minor versions.
+// As the progress is derived from the nodes kubelet versions, an interrupted upgrade resumes at the recorded step.
+type UpgradeProgress struct {
+	From string
+	To   string
+	// Path lists all versions the cluster is upgraded through, the last one being the desired version
+	Path  []string
+	Step  string
+	Phase UpgradePhase `yaml:",omitempty"`
+}
+
+type UpgradePhase string
+
+const (
+	UpgradeAwaitingHealth UpgradePhase = "awaitinghealth"
+	UpgradeControlplane   UpgradePhase = UpgradePhase(Controlplane)
+	UpgradeWorkers        UpgradePhase = UpgradePhase(Workers)
+	UpgradeDeferred       UpgradePhase = "deferred"
+)
+
 type Machines struct {
 	// M is exported for yaml (de)serialization and not intended to be accessed by any other code outside this package
 	M   map[string]*Machine `yaml:",inline"`
@@ -46,7 +72,12 @@ type Machine struct {
 	Ready           bool
 	FirewallIsReady bool
 	Unknown         bool
-	Metadata        MachineMetadata `yaml:",inline"`
+	Replacement     ReplacementState `yaml:",omitempty"`
+	Metadata        MachineMetadata  `yaml:",inline"`
+	// Maintenance is the disruptive action, which waits for the next maintenance window
+	Maintenance *QueuedMaintenance `yaml:",omitempty"`
+	// CertificatesExpire is only reported for control plane machines
+	CertificatesExpire map[string]time.Time `yaml:",omitempty"`
 }
 
 func (m *Machine) GetUpdating() bool {
@@ -1,11 +1,16 @@
 package kubernetes
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	core "k8s.io/api/core/v1"
 
+	"github.com/caos/orbos/internal/operator/common"
 	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/etcd"
+	"github.com/caos/orbos/internal/operator/orbiter/maintenance"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/secret"
 	"github.com/caos/orbos/pkg/tree"
@@ -34,6 +39,18 @@ type Spec struct {
 	//@default: ghcr.io
 	CustomImageRegistry string
 	Workers             []*Pool
+	// ContainerRuntime is either docker or containerd. Changing it migrates the nodes one by one
+	//@default: docker
+	ContainerRuntime ContainerRuntime `yaml:",omitempty"`
+	// EtcdBackup schedules etcd snapshots, which can be restored using orbctl restore etcd
+	EtcdBackup *etcd.Backup `yaml:",omitempty"`
+	// RenewCertificatesBefore is the duration before their expiry, when ORBITER renews the control plane certificates
+	//@default: 720h
+	RenewCertificatesBefore string `yaml:",omitempty"`
+	// RebootSentinel is a file like /var/run/reboot-required or a shell command exiting with 0, which tells the node agents that their machine needs a reboot
+	RebootSentinel string `yaml:",omitempty"`
+	// SecurityUpdates lets the node agents apply security updates of the operating system packages pool by pool
+	SecurityUpdates *SecurityUpdates `yaml:",omitempty"`
 }
 
 func parseDesiredV0(desiredTree *tree.Tree) (*DesiredV0, error) {
@@ -62,6 +79,10 @@ func (d *DesiredV0) validate() (err error) {
 		return fmt.Errorf("unknown kubernetes version %s", d.Spec.Versions.Kubernetes)
 	}
 
+	if err := d.Spec.ContainerRuntime.validate(); err != nil {
+		return err
+	}
+
 	if err := d.Spec.Networking.ServiceCidr.Validate(); err != nil {
 		return err
 	}
@@ -70,6 +91,56 @@ func (d *DesiredV0) validate() (err error) {
 		return err
 	}
 
+	for _, pool := range append([]*Pool{&d.Spec.ControlPlane}, d.Spec.Workers...) {
+		if pool.MaxUnavailable < 0 {
+			return fmt.Errorf("maxunavailable of pool %s from provider %s must not be negative", pool.Pool, pool.Provider)
+		}
+		if err := pool.MaintenanceWindows.Or(nil).Validate(); err != nil {
+			return fmt.Errorf("configuring maintenance windows of pool %s from provider %s failed: %w", pool.Pool, pool.Provider, err)
+		}
+		if err := common.ValidateCustom(pool.Files, pool.Units); err != nil {
+			return fmt.Errorf("configuring custom files and units of pool %s from provider %s failed: %w", pool.Pool, pool.Provider, err)
+		}
+	}
+
+	if d.Spec.ControlPlane.MinNodes != 0 || d.Spec.ControlPlane.MaxNodes != 0 {
+		return errors.New("controlplane nodes can not be autoscaled")
+	}
+
+	for _, worker := range d.Spec.Workers {
+		if !worker.Autoscaled() {
+			if worker.MinNodes != 0 {
+				return fmt.Errorf("minnodes of pool %s from provider %s require maxnodes", worker.Pool, worker.Provider)
+			}
+			continue
+		}
+		if worker.MinNodes < 0 || worker.MinNodes > worker.Nodes || worker.Nodes > worker.MaxNodes {
+			return fmt.Errorf("pool %s from provider %s must satisfy 0 <= minnodes <= nodes <= maxnodes, but minnodes are %d, nodes are %d and maxnodes are %d", worker.Pool, worker.Provider, worker.MinNodes, worker.Nodes, worker.MaxNodes)
+		}
+	}
+
+	if d.Spec.EtcdBackup != nil {
+		if err := d.Spec.EtcdBackup.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if d.Spec.SecurityUpdates != nil {
+		if err := d.Spec.SecurityUpdates.validate(); err != nil {
+			return err
+		}
+	}
+
+	if d.Spec.RenewCertificatesBefore != "" {
+		before, err := time.ParseDuration(d.Spec.RenewCertificatesBefore)
+		if err != nil {
+			return fmt.Errorf("parsing renewcertificatesbefore failed: %w", err)
+		}
+		if before <= 0 || before >= 365*24*time.Hour {
+			return fmt.Errorf("renewcertificatesbefore must be between 0 and 8760h, but is %s", d.Spec.RenewCertificatesBefore)
+		}
+	}
+
 	seenPools := map[string][]string{
 		d.Spec.ControlPlane.Provider: {d.Spec.ControlPlane.Pool},
 	}
@@ -97,6 +168,31 @@ type Pool struct {
 	Nodes           int
 	Pool            string
 	Taints          *Taints `yaml:"taints,omitempty"`
+	// MaxUnavailable is the number of machines which are replaced at once. It defaults to 1
+	MaxUnavailable int `yaml:",omitempty"`
+	// MinNodes and MaxNodes bound the nodes the cluster-autoscaler can scale a worker pool to.
+	// The pool is only autoscaled if MaxNodes is set
+	MinNodes int `yaml:",omitempty"`
+	MaxNodes int `yaml:",omitempty"`
+	// MaintenanceWindows override the orbs maintenance windows. An empty list allows disruptive changes at any time, while omitting them inherits the orbs maintenance windows
+	MaintenanceWindows *maintenance.Windows `yaml:",omitempty"`
+	// Files are written to the pools machines by the node agents
+	Files []*common.File `yaml:",omitempty"`
+	// Units are systemd units the node agents write to /etc/systemd/system
+	Units []*common.Unit `yaml:",omitempty"`
+	// maintenance are the effective maintenance windows
+	maintenance maintenance.Windows
+}
+
+func (p *Pool) Autoscaled() bool {
+	return p.MaxNodes > 0
+}
+
+func (p *Pool) maxUnavailable() int {
+	if p.MaxUnavailable <= 0 {
+		return 1
+	}
+	return p.MaxUnavailable
 }
 
 type Taint struct {
@@ -0,0 +1,52 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/orbiter/maintenance"
+)
+
+func TestPool_MaintenanceWindowsRoundTrip(t *testing.T) {
+	orbWindows := maintenance.Windows{{Start: "0 2 * * 6", Duration: "4h"}}
+
+	for _, tt := range []struct {
+		name        string
+		pool        string
+		wantWindows int
+	}{{
+		name:        "It should inherit the orbs maintenance windows if the pool has none",
+		pool:        "pool: workers\n",
+		wantWindows: 1,
+	}, {
+		name:        "It should keep empty maintenance windows, which allow disruptive changes at any time",
+		pool:        "pool: workers\nmaintenancewindows: []\n",
+		wantWindows: 0,
+	}, {
+		name:        "It should keep the pools own maintenance windows",
+		pool:        "pool: workers\nmaintenancewindows:\n- start: 0 3 * * 0\n  duration: 2h\n- start: 0 3 * * 3\n  duration: 2h\n",
+		wantWindows: 2,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := &Pool{}
+			if err := yaml.Unmarshal([]byte(tt.pool), pool); err != nil {
+				t.Fatal(err)
+			}
+
+			marshalled, err := yaml.Marshal(pool)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			roundTripped := &Pool{}
+			if err := yaml.Unmarshal(marshalled, roundTripped); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := roundTripped.MaintenanceWindows.Or(orbWindows); len(got) != tt.wantWindows {
+				t.Errorf("expected %d effective maintenance windows after marshalling\n%s\nbut got %d", tt.wantWindows, string(marshalled), len(got))
+			}
+		})
+	}
+}
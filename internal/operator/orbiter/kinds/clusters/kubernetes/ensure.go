@@ -1,6 +1,8 @@
 package kubernetes
 
 import (
+	"fmt"
+
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/git"
@@ -11,6 +13,7 @@ func ensure(
 	monitor mntr.Monitor,
 	clusterID string,
 	desired *DesiredV0,
+	current *CurrentCluster,
 	kubeAPIAddress *infra.Address,
 	pdf func(mntr.Monitor) error,
 	k8sClient *kubernetes.Client,
@@ -35,12 +38,17 @@ func ensure(
 		return false, err
 	}
 
-	done, err = maintainNodes(append(controlplaneMachines, workerMachines...), monitor, k8sClient, pdf)
+	done, err = maintainNodes(append(controlplaneMachines, workerMachines...), monitor, clusterID, k8sClient, pdf)
 	if err != nil || !done {
 		return done, err
 	}
 
+	if err := drainReplacements(append(workers, controlplane), k8sClient, monitor); err != nil {
+		return false, err
+	}
+
 	targetVersion := ParseString(desired.Spec.Versions.Kubernetes)
+	containerRuntime := desired.Spec.ContainerRuntime.software(desired.Spec.CustomImageRegistry)
 
 	machinesDone, initializedMachines, err := alignMachines(
 		monitor,
@@ -48,7 +56,7 @@ func ensure(
 		workers,
 		func(created infra.Machine, pool *initializedPool) initializedMachine {
 			machine := initializeMachine(created, pool)
-			target := targetVersion.DefineSoftware()
+			target := targetVersion.DefineSoftware(containerRuntime)
 			machine.desiredNodeagent.Software.Merge(target, true)
 			return *machine
 		},
@@ -58,14 +66,28 @@ func ensure(
 		return machinesDone, err
 	}
 
-	done, err = ensureSoftware(
+	done, err = migrateContainerRuntime(
+		monitor,
+		k8sClient,
+		desired.Spec.ContainerRuntime,
+		desired.Spec.CustomImageRegistry,
+		append(controlplaneMachines, workerMachines...),
+	)
+	if err != nil || !done {
+		monitor.Info("Migrating the container runtime is not done yet")
+		return done, err
+	}
+
+	done, upgradeDeferred, joinVersion, err := ensureSoftware(
 
 		monitor,
 		targetVersion,
 		k8sClient,
 		controlplaneMachines,
-		workerMachines)
-	if err != nil || !done {
+		workerMachines,
+		containerRuntime,
+		current)
+	if err != nil || !done && !upgradeDeferred {
 		monitor.Info("Upgrading is not done yet")
 		return done, err
 	}
@@ -76,11 +98,12 @@ func ensure(
 		desired,
 		pdf,
 		kubeAPIAddress,
-		targetVersion,
+		joinVersion,
 		k8sClient,
 		oneoff,
 		providerK8sSpec,
 		initializedMachines,
+		gitClient,
 	)
 	if err != nil {
 		return done, err
@@ -90,5 +113,16 @@ func ensure(
 		monitor.Info("Scaling is not done yet")
 	}
 
-	return done, ensureK8sPlugins(monitor, gitClient, k8sClient, *desired, providerK8sSpec, privateInterface)
+	if done && !oneoff {
+		if err := ensureEtcdBackup(monitor, clusterID, desired, gitClient, controlplaneMachines); err != nil {
+			monitor.Error(fmt.Errorf("backing up etcd failed: %w", err))
+		}
+		if err := ensureCertificates(monitor, clusterID, desired, current, pdf, k8sClient, controlplaneMachines); err != nil {
+			monitor.Error(fmt.Errorf("ensuring control plane certificates failed: %w", err))
+		}
+		ensureSecurityUpdates(monitor, clusterID, desired, append(controlplaneMachines, workerMachines...))
+	}
+
+	// Deferred upgrades don't block the tasks above, but the cluster is only done when it is upgraded
+	return done && !upgradeDeferred, ensureK8sPlugins(monitor, gitClient, k8sClient, *desired, providerK8sSpec, privateInterface)
 }
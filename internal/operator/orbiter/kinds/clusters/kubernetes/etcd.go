@@ -0,0 +1,76 @@
+package kubernetes
+
+import (
+	"errors"
+	"time"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/etcd"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
+)
+
+func ensureEtcdBackup(monitor mntr.Monitor, clusterID string, desired *DesiredV0, gitClient *git.Client, controlplaneMachines []*initializedMachine) error {
+
+	backup := desired.Spec.EtcdBackup
+	if backup == nil || backup.Restore != "" {
+		return nil
+	}
+
+	cache := cacheOf(clusterID)
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+	snapshots := &cache.etcdSnapshots
+
+	now := time.Now()
+	if now.Before(snapshots.nextAttempt) {
+		return nil
+	}
+
+	store, err := etcd.NewStore(backup, gitClient, clusterID)
+	if err != nil {
+		return err
+	}
+
+	if !snapshots.latestKnown {
+		if snapshots.latest, err = etcd.Latest(store); err != nil {
+			return err
+		}
+		snapshots.latestKnown = true
+	}
+
+	if !backup.Due(snapshots.latest, now) {
+		return nil
+	}
+
+	// Failing snapshots are not retried in every iteration
+	snapshots.nextAttempt = now.Add(10 * time.Minute)
+
+	var snapshotAt *initializedMachine
+	for _, machine := range controlplaneMachines {
+		current := machine.currentMachine
+		if current.Joined && current.Ready && !current.Updating && !current.Rebooting && current.Replacement == "" {
+			snapshotAt = machine
+			break
+		}
+	}
+	if snapshotAt == nil {
+		return errors.New("no ready control plane machine found")
+	}
+
+	archive, err := etcd.Snapshot(monitor, snapshotAt.infra)
+	if err != nil {
+		return err
+	}
+
+	id := etcd.NewID(now)
+	if err := store.Put(id, archive); err != nil {
+		return err
+	}
+	snapshots.latest = id
+	monitor.WithFields(map[string]interface{}{
+		"snapshot": id,
+		"machine":  snapshotAt.infra.ID(),
+	}).Changed("Etcd snapshot taken")
+
+	return etcd.Prune(backup, store)
+}
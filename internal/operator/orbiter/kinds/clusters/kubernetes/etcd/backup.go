@@ -0,0 +1,168 @@
+package etcd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caos/orbos/pkg/git"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+const (
+	idLayout         = "20060102-150405"
+	archiveExtension = ".tar.gz"
+	defaultRetention = 7
+)
+
+type Backup struct {
+	// Interval between two snapshots, for example 6h
+	//@default: 24h
+	Interval string `yaml:",omitempty"`
+	// Retention is the number of snapshots which are kept
+	//@default: 7
+	Retention int `yaml:",omitempty"`
+	// S3 stores snapshots in an S3 compatible bucket. If omitted, snapshots are committed to the orphan branch orbos-etcd-snapshots of the git repository
+	S3 *S3 `yaml:",omitempty"`
+	// Restore is set by orbctl restore etcd and is removed as soon as the control plane is initialized from the snapshot
+	Restore string `yaml:",omitempty"`
+}
+
+type S3 struct {
+	// Endpoint overrides the AWS S3 endpoint, e.g. for a MinIO server
+	Endpoint        string `yaml:",omitempty"`
+	Region          string
+	Bucket          string
+	Prefix          string         `yaml:",omitempty"`
+	AccessKeyID     *secret.Secret `yaml:",omitempty"`
+	SecretAccessKey *secret.Secret `yaml:",omitempty"`
+}
+
+func (b *Backup) Validate() error {
+	if _, err := b.interval(); err != nil {
+		return err
+	}
+
+	if b.Retention < 0 {
+		return fmt.Errorf("etcd backup retention must not be negative, but is %d", b.Retention)
+	}
+
+	if b.Restore != "" {
+		if _, err := time.Parse(idLayout, b.Restore); err != nil {
+			return fmt.Errorf("etcd snapshot %s to restore is not a valid snapshot id", b.Restore)
+		}
+	}
+
+	if b.S3 != nil && (b.S3.Bucket == "" || b.S3.Region == "") {
+		return errors.New("etcd backups to s3 need a bucket and a region")
+	}
+	return nil
+}
+
+func (b *Backup) interval() (time.Duration, error) {
+	if b.Interval == "" {
+		return 24 * time.Hour, nil
+	}
+	interval, err := time.ParseDuration(b.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("parsing etcd backup interval %s failed: %w", b.Interval, err)
+	}
+	if interval < time.Hour {
+		return 0, fmt.Errorf("etcd backup interval must be at least an hour, but is %s", b.Interval)
+	}
+	return interval, nil
+}
+
+func (b *Backup) retention() int {
+	if b.Retention == 0 {
+		return defaultRetention
+	}
+	return b.Retention
+}
+
+// Due returns true if the latest snapshot is older than the configured interval
+func (b *Backup) Due(latest string, now time.Time) bool {
+	if latest == "" {
+		return true
+	}
+	interval, err := b.interval()
+	if err != nil {
+		return false
+	}
+	taken, err := time.Parse(idLayout, latest)
+	if err != nil {
+		return true
+	}
+	return now.Sub(taken) >= interval
+}
+
+// Store persists snapshot archives. Snapshot ids are sortable timestamps.
+type Store interface {
+	List() ([]string, error)
+	Get(id string) ([]byte, error)
+	Put(id string, archive []byte) error
+	Delete(ids ...string) error
+}
+
+// NewStore returns a store which encrypts the archives like secrets.
+// Without S3, the snapshots are stored in an orphan branch of the git repository, never in the desired state branch.
+func NewStore(backup *Backup, gitClient *git.Client, clusterID string) (Store, error) {
+	if backup.S3 != nil {
+		store, err := newS3Store(backup.S3, clusterID)
+		if err != nil {
+			return nil, err
+		}
+		return &encryptedStore{store}, nil
+	}
+	return &encryptedStore{newGitStore(gitClient, clusterID)}, nil
+}
+
+// NewID returns the id of a snapshot taken at the passed time
+func NewID(taken time.Time) string {
+	return taken.UTC().Format(idLayout)
+}
+
+// Latest returns the id of the latest snapshot or an empty string
+func Latest(store Store) (string, error) {
+	ids, err := store.List()
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[len(ids)-1], nil
+}
+
+// Prune deletes the oldest snapshots exceeding the configured retention
+func Prune(backup *Backup, store Store) error {
+	ids, err := store.List()
+	if err != nil {
+		return err
+	}
+	if expired := expired(ids, backup.retention()); len(expired) > 0 {
+		return store.Delete(expired...)
+	}
+	return nil
+}
+
+func expired(ids []string, retention int) []string {
+	if len(ids) <= retention {
+		return nil
+	}
+	return ids[:len(ids)-retention]
+}
+
+func idsFromFiles(files []string) []string {
+	ids := make([]string, 0, len(files))
+	for _, file := range files {
+		if !strings.HasSuffix(file, archiveExtension) {
+			continue
+		}
+		id := strings.TrimSuffix(file, archiveExtension)
+		if _, err := time.Parse(idLayout, id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
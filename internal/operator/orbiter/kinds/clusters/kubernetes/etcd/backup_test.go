@@ -0,0 +1,74 @@
+package etcd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackup_Due(t *testing.T) {
+	now := time.Date(2021, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name     string
+		interval string
+		latest   string
+		want     bool
+	}{
+		{name: "no snapshot yet", latest: "", want: true},
+		{name: "default interval not elapsed", latest: "20211018-000001", want: false},
+		{name: "default interval elapsed", latest: "20211017-120000", want: true},
+		{name: "custom interval elapsed", interval: "6h", latest: "20211018-060000", want: true},
+		{name: "invalid interval", interval: "often", latest: "20211001-000000", want: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (&Backup{Interval: tt.interval}).Due(tt.latest, now); got != tt.want {
+				t.Errorf("Due() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackup_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		backup  Backup
+		wantErr bool
+	}{
+		{name: "defaults", backup: Backup{}},
+		{name: "interval too short", backup: Backup{Interval: "5m"}, wantErr: true},
+		{name: "negative retention", backup: Backup{Retention: -1}, wantErr: true},
+		{name: "invalid restore id", backup: Backup{Restore: "latest"}, wantErr: true},
+		{name: "valid restore id", backup: Backup{Restore: "20211018-120000"}},
+		{name: "s3 without bucket", backup: Backup{S3: &S3{Region: "eu-central-1"}}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.backup.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIDsAndExpiry(t *testing.T) {
+	ids := idsFromFiles([]string{
+		"20211018-120000.tar.gz",
+		"README.md",
+		"20211016-120000.tar.gz",
+		"latest.tar.gz",
+		"20211017-120000.tar.gz",
+	})
+
+	expectIDs := []string{"20211016-120000", "20211017-120000", "20211018-120000"}
+	if !reflect.DeepEqual(ids, expectIDs) {
+		t.Fatalf("expected ids %v, but got %v", expectIDs, ids)
+	}
+
+	if exp := expired(ids, 2); !reflect.DeepEqual(exp, []string{"20211016-120000"}) {
+		t.Errorf("expected only the oldest snapshot to expire, but got %v", exp)
+	}
+
+	if exp := expired(ids, 3); len(exp) != 0 {
+		t.Errorf("expected no snapshots to expire, but got %v", exp)
+	}
+}
@@ -0,0 +1,44 @@
+package etcd
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/caos/orbos/pkg/secret"
+)
+
+var _ Store = (*encryptedStore)(nil)
+
+// gzipMagic starts the unencrypted archives of older versions
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// encryptedStore encrypts archives like secrets before they are stored, as they contain the etcd keyspace and the clusters private keys
+type encryptedStore struct {
+	Store
+}
+
+func (e *encryptedStore) Get(id string) ([]byte, error) {
+	sealed, err := e.Store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Snapshots of older versions are not encrypted
+	if bytes.HasPrefix(sealed, gzipMagic) {
+		return sealed, nil
+	}
+
+	archive, err := secret.Open(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting etcd snapshot %s failed: %w", id, err)
+	}
+	return archive, nil
+}
+
+func (e *encryptedStore) Put(id string, archive []byte) error {
+	sealed, err := secret.Seal(archive)
+	if err != nil {
+		return fmt.Errorf("encrypting etcd snapshot %s failed: %w", id, err)
+	}
+	return e.Store.Put(id, sealed)
+}
@@ -0,0 +1,44 @@
+package etcd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/caos/orbos/pkg/secret"
+)
+
+type memoryStore map[string][]byte
+
+func (m memoryStore) List() ([]string, error)             { return nil, nil }
+func (m memoryStore) Get(id string) ([]byte, error)       { return m[id], nil }
+func (m memoryStore) Put(id string, archive []byte) error { m[id] = archive; return nil }
+func (m memoryStore) Delete(ids ...string) error          { return nil }
+
+func TestEncryptedStore(t *testing.T) {
+	secret.Masterkey = "a masterkey"
+	defer func() { secret.Masterkey = "empty" }()
+
+	archive := append(gzipMagic, []byte("etcd keyspace and private keys")...)
+	stored := make(memoryStore)
+	store := &encryptedStore{stored}
+
+	if err := store.Put("20211018-120000", archive); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored["20211018-120000"], []byte("private keys")) {
+		t.Error("expected the stored archive to be encrypted")
+	}
+
+	got, err := store.Get("20211018-120000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, archive) {
+		t.Errorf("expected the decrypted archive %q, but got %q", archive, got)
+	}
+
+	stored["20211017-120000"] = archive
+	if got, err = store.Get("20211017-120000"); err != nil || !bytes.Equal(got, archive) {
+		t.Errorf("expected unencrypted archives of older versions to be returned as they are, but got %q, %v", got, err)
+	}
+}
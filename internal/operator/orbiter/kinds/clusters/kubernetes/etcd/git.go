@@ -0,0 +1,69 @@
+package etcd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/caos/orbos/pkg/git"
+)
+
+var _ Store = (*gitStore)(nil)
+
+// snapshotsBranch keeps the snapshots apart from the desired state, so they neither bloat its history nor trigger the operators
+const snapshotsBranch = "orbos-etcd-snapshots"
+
+// gitStore commits snapshots to an orphan branch of the orbs git repository.
+// The branch is replaced on each change, so pruned snapshots don't remain in the repository.
+// As all retained snapshots are fetched and pushed on each change, it is only suitable for small clusters.
+type gitStore struct {
+	branch *git.OrphanBranch
+	folder string
+}
+
+func newGitStore(gitClient *git.Client, clusterID string) *gitStore {
+	return &gitStore{
+		branch: gitClient.OrphanBranch(snapshotsBranch),
+		folder: clusterID,
+	}
+}
+
+func (g *gitStore) path(id string) string {
+	return filepath.Join(g.folder, id+archiveExtension)
+}
+
+func (g *gitStore) List() ([]string, error) {
+	files, err := g.branch.ListFolder(g.folder)
+	if err != nil {
+		return nil, err
+	}
+	return idsFromFiles(files), nil
+}
+
+func (g *gitStore) Get(id string) ([]byte, error) {
+	archive, err := g.branch.Read(g.path(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(archive) == 0 {
+		return nil, fmt.Errorf("etcd snapshot %s not found in git branch %s", id, snapshotsBranch)
+	}
+	return archive, nil
+}
+
+func (g *gitStore) Put(id string, archive []byte) error {
+	return g.branch.Update("etcd snapshot "+id, git.File{
+		Path:    g.path(id),
+		Content: archive,
+	})
+}
+
+func (g *gitStore) Delete(ids ...string) error {
+	files := make([]git.File, len(ids))
+	for idx, id := range ids {
+		files[idx] = git.File{
+			Path:   g.path(id),
+			Delete: true,
+		}
+	}
+	return g.branch.Update("prune etcd snapshots", files...)
+}
@@ -0,0 +1,113 @@
+package etcd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/caos/orbos/mntr"
+)
+
+var _ Store = (*s3Store)(nil)
+
+type s3Store struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+func newS3Store(desired *S3, clusterID string) (*s3Store, error) {
+
+	var accessKeyID, secretAccessKey string
+	if desired.AccessKeyID != nil {
+		accessKeyID = desired.AccessKeyID.Value
+	}
+	if desired.SecretAccessKey != nil {
+		secretAccessKey = desired.SecretAccessKey.Value
+	}
+
+	cfg := &aws.Config{
+		Region:      aws.String(desired.Region),
+		Credentials: credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""),
+	}
+	if desired.Endpoint != "" {
+		cfg.Endpoint = aws.String(desired.Endpoint)
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, mntr.ToUserError(err)
+	}
+
+	return &s3Store{
+		client: s3.New(sess),
+		bucket: desired.Bucket,
+		prefix: path.Join(desired.Prefix, clusterID) + "/",
+	}, nil
+}
+
+func (s *s3Store) key(id string) string {
+	return s.prefix + id + archiveExtension
+}
+
+func (s *s3Store) List() ([]string, error) {
+	var files []string
+	if err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			files = append(files, path.Base(aws.StringValue(obj.Key)))
+		}
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("listing etcd snapshots in bucket %s failed: %w", s.bucket, err)
+	}
+	return idsFromFiles(files), nil
+}
+
+func (s *s3Store) Get(id string) ([]byte, error) {
+	obj, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(id)),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, fmt.Errorf("etcd snapshot %s not found in bucket %s", id, s.bucket)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("downloading etcd snapshot %s failed: %w", id, err)
+	}
+	defer obj.Body.Close()
+	return ioutil.ReadAll(obj.Body)
+}
+
+func (s *s3Store) Put(id string, archive []byte) error {
+	if _, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(id)),
+		Body:   bytes.NewReader(archive),
+	}); err != nil {
+		return fmt.Errorf("uploading etcd snapshot %s failed: %w", id, err)
+	}
+	return nil
+}
+
+func (s *s3Store) Delete(ids ...string) error {
+	for _, id := range ids {
+		if _, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.key(id)),
+		}); err != nil {
+			return fmt.Errorf("deleting etcd snapshot %s failed: %w", id, err)
+		}
+	}
+	return nil
+}
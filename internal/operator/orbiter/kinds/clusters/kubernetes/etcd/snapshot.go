@@ -0,0 +1,108 @@
+package etcd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	workDir     = "/var/orbiter/etcd"
+	archivePath = "/var/orbiter/etcd.tar.gz"
+	// ctrNamespace separates the images ORBITER pulls from the ones the container runtime manages
+	ctrNamespace = "orbiter"
+)
+
+// pkiFiles are the keys which need to survive a control plane loss, as all other certificates are derived from them
+var pkiFiles = []string{
+	"pki/ca.crt",
+	"pki/ca.key",
+	"pki/sa.key",
+	"pki/sa.pub",
+	"pki/front-proxy-ca.crt",
+	"pki/front-proxy-ca.key",
+	"pki/etcd/ca.crt",
+	"pki/etcd/ca.key",
+}
+
+// Snapshot saves the etcd keyspace of a control plane machine and archives it together with the clusters certificate authorities.
+// etcdctl is run from the same image the machines etcd member runs from.
+func Snapshot(monitor mntr.Monitor, machine infra.Machine) ([]byte, error) {
+
+	cmd := fmt.Sprintf(`set -e
+IMAGE=$(sudo sed -n 's/^ *image: *//p' /etc/kubernetes/manifests/etcd.yaml | head -n 1)
+sudo rm -rf %s %s
+sudo mkdir -p %s
+%s
+sudo %s etcdctl --endpoints https://127.0.0.1:2379 --cacert /etc/kubernetes/pki/etcd/ca.crt --cert /etc/kubernetes/pki/apiserver-etcd-client.crt --key /etc/kubernetes/pki/apiserver-etcd-client.key snapshot save /backup/snapshot.db
+sudo tar -czf %s -C %s snapshot.db -C /etc/kubernetes %s
+sudo rm -rf %s`,
+		workDir, archivePath,
+		workDir,
+		pull,
+		run("orbiter-etcd-snapshot", "--net-host"),
+		archivePath, workDir, strings.Join(pkiFiles, " "),
+		workDir,
+	)
+
+	if stdout, err := machine.Execute(nil, cmd); err != nil {
+		return nil, fmt.Errorf("taking etcd snapshot on machine %s failed: %s: %w", machine.ID(), string(stdout), err)
+	}
+
+	archive := new(bytes.Buffer)
+	if err := machine.ReadFile(archivePath, archive); err != nil {
+		return nil, err
+	}
+
+	if _, err := machine.Execute(nil, "sudo rm -f "+archivePath); err != nil {
+		monitor.WithField("machine", machine.ID()).Info(fmt.Sprintf("Cleaning up etcd snapshot archive failed: %s", err.Error()))
+	}
+
+	return archive.Bytes(), nil
+}
+
+// Restore prepares a reset control plane machine, so that kubeadm init starts etcd from the archived snapshot.
+// The member is restored as the only member of a new etcd cluster named like the machine, which matches kubeadms etcd manifest.
+// Further control plane machines join as usual.
+func Restore(monitor mntr.Monitor, machine infra.Machine, archive []byte, imageRepository, kubernetesVersion string) error {
+
+	if err := machine.WriteFile(archivePath, bytes.NewReader(archive), 600); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf(`set -e
+IMAGE=$(sudo kubeadm config images list --kubernetes-version %s --image-repository %s 2>/dev/null | grep /etcd:)
+sudo rm -rf %s /var/lib/etcd
+sudo mkdir -p %s /etc/kubernetes
+sudo tar -xzf %s -C %s snapshot.db
+sudo tar -xzf %s -C /etc/kubernetes %s
+%s
+sudo %s etcdctl snapshot restore /backup/snapshot.db --name %s --initial-cluster %s=https://%s:2380 --initial-advertise-peer-urls https://%s:2380 --data-dir /var/lib/etcd
+sudo rm -rf %s %s`,
+		kubernetesVersion, imageRepository,
+		workDir,
+		workDir,
+		archivePath, workDir,
+		archivePath, strings.Join(pkiFiles, " "),
+		pull,
+		run("orbiter-etcd-restore", "--mount type=bind,src=/var/lib,dst=/var/lib,options=rbind:rw"),
+		machine.ID(), machine.ID(), machine.IP(), machine.IP(),
+		workDir, archivePath,
+	)
+
+	if stdout, err := machine.Execute(nil, cmd); err != nil {
+		return fmt.Errorf("restoring etcd snapshot on machine %s failed: %s: %w", machine.ID(), string(stdout), err)
+	}
+
+	monitor.WithField("machine", machine.ID()).Changed("Etcd snapshot restored")
+	return nil
+}
+
+var pull = fmt.Sprintf("sudo ctr -n %s images pull $IMAGE > /dev/null", ctrNamespace)
+
+func run(name, flags string) string {
+	return fmt.Sprintf("ctr -n %s run --rm %s --env ETCDCTL_API=3 --mount type=bind,src=/etc/kubernetes/pki,dst=/etc/kubernetes/pki,options=rbind:ro --mount type=bind,src=%s,dst=/backup,options=rbind:rw $IMAGE %s", ctrNamespace, flags, workDir, name)
+}
@@ -16,13 +16,21 @@ import (
 	v1 "k8s.io/api/core/v1"
 )
 
+const (
+	autoscalerDeletionTaint  = "ToBeDeletedByClusterAutoscaler"
+	autoscalerCandidateTaint = "DeletionCandidateOfClusterAutoscaler"
+)
+
 type initializedPool struct {
-	upscaling   int
-	downscaling []*initializedMachine
-	infra       infra.Pool
-	tier        Tier
-	desired     Pool
-	machines    func() ([]*initializedMachine, error)
+	upscaling    int
+	downscaling  []*initializedMachine
+	replacing    []*initializedMachine
+	replacements map[string]ReplacementState
+	queued       map[string]*QueuedMaintenance
+	infra        infra.Pool
+	tier         Tier
+	desired      Pool
+	machines     func() ([]*initializedMachine, error)
 }
 
 func (i *initializedMachines) forEach(baseMonitor mntr.Monitor, do func(machine *initializedMachine, machineMonitor mntr.Monitor) (goon bool)) {
@@ -55,8 +63,10 @@ func (i *initializedPool) enhance(initialize initializeFunc) {
 }
 
 type initializedMachine struct {
-	infra            infra.Machine
-	reconcile        func() error
+	infra     infra.Machine
+	reconcile func() error
+	// reconciling lists what reconcile changes on the node
+	reconciling      map[string]interface{}
 	currentNodeagent *common.NodeAgentCurrent
 	desiredNodeagent *common.NodeAgentSpec
 	currentMachine   *Machine
@@ -66,10 +76,12 @@ type initializedMachine struct {
 
 func initialize(
 	monitor mntr.Monitor,
+	clusterID string,
 	curr *CurrentCluster,
 	desired DesiredV0,
 	nodeAgentsCurrent *common.CurrentNodeAgents,
 	nodeAgentsDesired *common.DesiredNodeAgents,
+	pushedNodeAgents map[string]*common.NodeAgentSpec,
 	providerPools map[string]map[string]infra.Pool,
 	k8s *kubernetes.Client,
 	postInit func(machine *initializedMachine)) (
@@ -88,6 +100,7 @@ func initialize(
 			infra:   infraPool,
 			tier:    tier,
 			desired: desired,
+			queued:  make(map[string]*QueuedMaintenance),
 		}
 		pool.machines = func() ([]*initializedMachine, error) {
 			infraMachines, err := infraPool.GetMachines()
@@ -110,16 +123,13 @@ func initialize(
 			return pool, err
 		}
 
-		var replace initializedMachines
-		var backReplacement int
+		var replace, keep initializedMachines
 		for _, machine := range machines {
 			if req, _, _ := machine.infra.ReplacementRequired(); req {
 				replace = append(replace, machine)
 				continue
 			}
-			if machine.currentMachine.Joined {
-				backReplacement++
-			}
+			keep = append(keep, machine)
 		}
 
 		machinesPerDesired := 1
@@ -127,22 +137,18 @@ func initialize(
 			machinesPerDesired = pool.infra.DesiredMembers(desired.Nodes) / desired.Nodes
 		}
 
-		upscale := (desired.Nodes * machinesPerDesired) + len(replace) - len(machines)
-		if upscale > 0 {
-			pool.upscaling = upscale
+		if len(replace) > 0 {
+			pool.planReplacements(monitor, replace, keep, desired.Nodes*machinesPerDesired)
 			return pool, nil
 		}
 
-		if len(replace) > 0 {
-			for backReplacement >= (desired.Nodes*machinesPerDesired) && len(replace) > 0 {
-				backReplacement--
-				pool.downscaling = append(pool.downscaling, replace[0])
-				replace = replace[1:]
-			}
+		upscale := (desired.Nodes * machinesPerDesired) - len(machines)
+		if upscale > 0 {
+			pool.upscaling = upscale
 			return pool, nil
 		}
 
-		pool.downscaling = machines[(desired.Nodes * machinesPerDesired):]
+		pool.downscaling = downscaleCandidates(machines)[(desired.Nodes * machinesPerDesired):]
 
 		return pool, nil
 	}
@@ -150,6 +156,8 @@ func initialize(
 	initializeMachine = func(machine infra.Machine, pool *initializedPool) *initializedMachine {
 
 		current := &Machine{
+			Replacement: pool.replacements[machine.ID()],
+			Maintenance: pool.queued[machine.ID()],
 			Metadata: MachineMetadata{
 				Tier:     pool.tier,
 				Provider: pool.desired.Provider,
@@ -176,8 +184,9 @@ func initialize(
 		// Retry if kubeapi returns other error than "NotFound"
 
 		reconcile := func() error { return nil }
+		var reconciling map[string]interface{}
 		if node != nil && !current.Unknown {
-			reconcile = reconcileNodeFunc(*node, monitor, pool.desired, k8s, pool.tier, naSpec, naCurr)
+			reconcile, reconciling = reconcileNodeFunc(*node, monitor, pool.desired, k8s, pool.tier, naSpec, naCurr, ProviderID(pool.desired.Provider, machine.ID()))
 			current.Joined = true
 			for _, cond := range node.Status.Conditions {
 				if cond.Type == v1.NodeReady {
@@ -193,7 +202,11 @@ func initialize(
 		machineMonitor := monitor.WithField("machine", machine.ID())
 
 		naSpec.ChangesAllowed = !pool.desired.UpdatesDisabled
-		k8sSoftware := ParseString(desired.Spec.Versions.Kubernetes).DefineSoftware()
+		naSpec.RebootSentinel = desired.Spec.RebootSentinel
+		desireSecurityUpdates(desired.Spec.SecurityUpdates, pushedNodeAgents[machine.ID()], naSpec)
+		naSpec.Files = pool.desired.Files
+		naSpec.Units = pool.desired.Units
+		k8sSoftware := ParseString(desired.Spec.Versions.Kubernetes).DefineSoftware(desired.Spec.ContainerRuntime.software(desired.Spec.CustomImageRegistry))
 
 		if !softwareDefines(*naSpec.Software, k8sSoftware) {
 			k8sSoftware.Merge(KubernetesSoftware(naCurr.Software), false)
@@ -208,6 +221,7 @@ func initialize(
 			currentNodeagent: naCurr,
 			desiredNodeagent: naSpec,
 			reconcile:        reconcile,
+			reconciling:      reconciling,
 			currentMachine:   current,
 			pool:             pool,
 			node:             node,
@@ -295,28 +309,34 @@ func initialize(
 		}, nil
 }
 
-func reconcileNodeFunc(node v1.Node, monitor mntr.Monitor, pool Pool, k8s *kubernetes.Client, tier Tier, naSpec *common.NodeAgentSpec, naCurr *common.NodeAgentCurrent) func() error {
-	n := &node
-	reconcileNode := false
-	reconcileMonitor := monitor.WithField("node", n.Name)
+// reconcileNodeFunc only changes a copy of the node, so querying doesn't change anything until the returned func is called
+func reconcileNodeFunc(node v1.Node, monitor mntr.Monitor, pool Pool, k8s *kubernetes.Client, tier Tier, naSpec *common.NodeAgentSpec, naCurr *common.NodeAgentCurrent, providerID string) (func() error, map[string]interface{}) {
+	n := node.DeepCopy()
+	var reconciling map[string]interface{}
 	handleMaybe := func(maybeMonitorFields map[string]interface{}) {
-		if maybeMonitorFields != nil {
-			reconcileNode = true
-			reconcileMonitor = monitor.WithFields(maybeMonitorFields)
+		if maybeMonitorFields == nil {
+			return
+		}
+		if reconciling == nil {
+			reconciling = make(map[string]interface{})
+		}
+		for k, v := range maybeMonitorFields {
+			reconciling[k] = v
 		}
 	}
 
 	handleMaybe(reconcileLabel(n, "orbos.ch/pool", pool.Pool))
 	handleMaybe(reconcileLabel(n, "orbos.ch/tier", string(tier)))
 	handleMaybe(reconcileTaints(n, pool, k8s, naSpec, naCurr))
+	handleMaybe(reconcileProviderID(n, providerID))
 
-	if !reconcileNode {
-		return func() error { return nil }
+	if reconciling == nil {
+		return func() error { return nil }, nil
 	}
 	return func() error {
-		reconcileMonitor.Info("Reconciling node")
+		monitor.WithField("node", n.Name).WithFields(reconciling).Info("Reconciling node")
 		return k8s.UpdateNode(n)
-	}
+	}, reconciling
 }
 
 func reconcileTaints(node *v1.Node, pool Pool, k8s *kubernetes.Client, naSpec *common.NodeAgentSpec, naCurr *common.NodeAgentCurrent) map[string]interface{} {
@@ -327,7 +347,10 @@ func reconcileTaints(node *v1.Node, pool Pool, k8s *kubernetes.Client, naSpec *c
 	// user defined taints
 outer:
 	for _, existing := range node.Spec.Taints {
-		if strings.HasPrefix(existing.Key, "node.kubernetes.io/") || strings.HasPrefix(existing.Key, kubernetes.TaintKeyPrefix) {
+		if strings.HasPrefix(existing.Key, "node.kubernetes.io/") ||
+			strings.HasPrefix(existing.Key, kubernetes.TaintKeyPrefix) ||
+			existing.Key == autoscalerDeletionTaint ||
+			existing.Key == autoscalerCandidateTaint {
 			newTaints = append(newTaints, existing)
 			continue
 		}
@@ -359,6 +382,41 @@ outer:
 	return map[string]interface{}{"taints": desiredTaints}
 }
 
+// ProviderID identifies a machine for the cluster-autoscaler.
+// Kubernetes doesn't allow changing it, so it is only set on nodes which have none yet
+func ProviderID(provider, machineID string) string {
+	return fmt.Sprintf("orbos://%s/%s", provider, machineID)
+}
+
+func reconcileProviderID(node *v1.Node, providerID string) map[string]interface{} {
+	if node.Spec.ProviderID != "" {
+		return nil
+	}
+	node.Spec.ProviderID = providerID
+	return map[string]interface{}{"providerID": providerID}
+}
+
+// downscaleCandidates returns the machines ordered so that the ones the cluster-autoscaler chose to remove come last
+func downscaleCandidates(machines []*initializedMachine) []*initializedMachine {
+	ordered := append([]*initializedMachine{}, machines...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return !autoscalerRemoves(ordered[i].node) && autoscalerRemoves(ordered[j].node)
+	})
+	return ordered
+}
+
+func autoscalerRemoves(node *v1.Node) bool {
+	if node == nil {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == autoscalerDeletionTaint {
+			return true
+		}
+	}
+	return false
+}
+
 func reconcileLabel(node *v1.Node, key, value string) map[string]interface{} {
 	if node.Labels[key] == value {
 		return nil
@@ -4,8 +4,11 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/caos/orbos/mntr"
 	v1 "k8s.io/api/core/v1"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/kubernetes"
 )
 
 func Test_reconcileTaints(t *testing.T) {
@@ -49,10 +52,10 @@ func Test_reconcileTaints(t *testing.T) {
 			}, want: nodePtr(node(someNodeTaint)),
 		},
 		{
-			name: "It should leave the taints as they are if the taints property is nil",
+			name: "It should leave the taints as they are if they are configured",
 			args: args{
 				node: node(someNodeTaint),
-				pool: Pool{},
+				pool: pool(someDesiredTaint),
 			}, want: nil,
 		},
 		{
@@ -65,9 +68,60 @@ func Test_reconcileTaints(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := reconcileTaints(tt.args.node, tt.args.pool, mntr.Monitor{})
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("reconcileTaints() got = %v, want %v", got, tt.want)
+			got := tt.args.node
+			changed := reconcileTaints(&got, tt.args.pool, &kubernetes.Client{}, &common.NodeAgentSpec{}, &common.NodeAgentCurrent{})
+			if tt.want == nil {
+				if changed != nil {
+					t.Errorf("reconcileTaints() changed the node to %v, want it unchanged", got)
+				}
+				return
+			}
+			if changed == nil || !reflect.DeepEqual(got, *tt.want) {
+				t.Errorf("reconcileTaints() got = %v, want %v", got, *tt.want)
+			}
+		})
+	}
+}
+
+func Test_reconcileNodeFunc(t *testing.T) {
+	taints := Taints(make([]Taint, 0))
+	pool := Pool{Provider: "static", Pool: "workers", Taints: &taints}
+	node := v1.Node{}
+	node.Name = "worker-0"
+	node.Labels = map[string]string{"custom": "label"}
+
+	tests := []struct {
+		name string
+		node v1.Node
+		want map[string]interface{}
+	}{{
+		name: "It should report the labels and the provider id it would reconcile",
+		node: node,
+		want: map[string]interface{}{
+			"label.orbos.ch/pool": "workers",
+			"label.orbos.ch/tier": "workers",
+			"providerID":          ProviderID("static", "worker-0"),
+		},
+	}, {
+		name: "It should report nothing if the node is reconciled",
+		node: func() v1.Node {
+			n := *node.DeepCopy()
+			n.Labels["orbos.ch/pool"] = "workers"
+			n.Labels["orbos.ch/tier"] = "workers"
+			n.Spec.ProviderID = ProviderID("static", "worker-0")
+			return n
+		}(),
+		want: nil,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queried := *tt.node.DeepCopy()
+			_, reconciling := reconcileNodeFunc(queried, mntr.Monitor{}, pool, &kubernetes.Client{}, Workers, &common.NodeAgentSpec{}, &common.NodeAgentCurrent{}, ProviderID("static", "worker-0"))
+			if !reflect.DeepEqual(reconciling, tt.want) {
+				t.Errorf("reconcileNodeFunc() reconciles %v, want %v", reconciling, tt.want)
+			}
+			if !reflect.DeepEqual(queried, tt.node) {
+				t.Errorf("reconcileNodeFunc() changed the queried node to %v", queried)
 			}
 		})
 	}
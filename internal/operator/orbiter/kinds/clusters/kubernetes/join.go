@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/etcd"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/kubernetes"
 	"io"
@@ -24,7 +25,8 @@ func join(
 	certKey string,
 	client *kubernetes.Client,
 	imageRepository string,
-	providerK8sSpec infra.Kubernetes) (*string, error) {
+	providerK8sSpec infra.Kubernetes,
+	etcdRestoreArchive []byte) (*string, error) {
 
 	monitor = monitor.WithFields(map[string]interface{}{
 		"machine": joining.infra.ID(),
@@ -88,6 +90,7 @@ discovery:
     unsafeSkipCAVerification: true
   timeout: 5m0s
 nodeRegistration:
+  criSocket: "{{ .CRISocket }}"
   kubeletExtraArgs:
     cgroup-driver: "systemd"
     node-ip: "{{ .Node.IP }}"{{if .ProviderK8sSpec.CloudController.Supported}}
@@ -113,7 +116,7 @@ localAPIEndpoint:
   advertiseAddress: "{{ .Node.IP }}"
   bindPort: {{ .BindPort }}
 nodeRegistration:
-#	criSocket: /var/run/dockershim.sock
+  criSocket: "{{ .CRISocket }}"
   name:  "{{ .Node.ID }}"
   kubeletExtraArgs:
     cgroup-driver: "systemd"
@@ -141,6 +144,7 @@ nodeRegistration:
 		CertKey              string
 		ProviderK8sSpec      infra.Kubernetes
 		CloudConfigPath      string
+		CRISocket            string
 	}{
 		Token:                joinToken,
 		Node:                 joining.infra,
@@ -158,6 +162,7 @@ nodeRegistration:
 		CertKey:              certKey,
 		ProviderK8sSpec:      providerK8sSpec,
 		CloudConfigPath:      cloudCfgPath,
+		CRISocket:            desired.Spec.ContainerRuntime.criSocket(),
 	}); err != nil {
 		return nil, err
 	}
@@ -224,12 +229,20 @@ nodeRegistration:
 		return nil, err
 	}
 
+	ignorePreflightErrors := fmt.Sprintf("Port-%d", kubeAPI.BackendPort)
+	if etcdRestoreArchive != nil {
+		if err := etcd.Restore(monitor, joining.infra, etcdRestoreArchive, imageRepository, kubernetesVersion.String()); err != nil {
+			return nil, err
+		}
+		ignorePreflightErrors += ",DirAvailable--var-lib-etcd"
+	}
+
 	initCmd := fmt.Sprintf(`\
-sudo kubeadm init --ignore-preflight-errors=Port-%d --config %s && \
+sudo kubeadm init --ignore-preflight-errors=%s --config %s && \
 mkdir -p ${HOME}/.kube && yes | sudo cp -rf /etc/kubernetes/admin.conf ${HOME}/.kube/config && \
 sudo chown $(id -u):$(id -g) ${HOME}/.kube/config && \
 kubectl -n kube-system patch deployment coredns --type='json' \
--p='[{"op": "add", "path": "/spec/template/spec/tolerations/0", "value": {"effect": "NoSchedule", key: "node.cloudprovider.kubernetes.io/uninitialized", value: "true" } }]'`, kubeAPI.BackendPort, kubeadmCfgPath)
+-p='[{"op": "add", "path": "/spec/template/spec/tolerations/0", "value": {"effect": "NoSchedule", key: "node.cloudprovider.kubernetes.io/uninitialized", value: "true" } }]'`, ignorePreflightErrors, kubeadmCfgPath)
 	initStdout, err := joining.infra.Execute(nil, initCmd)
 	if err != nil {
 		return nil, fmt.Errorf(`error initializing kubernetes by executing command (%s): %s: %w`, initCmd, initStdout, err)
@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"time"
+
+	"github.com/caos/orbos/mntr"
+)
+
+type MaintenanceAction string
+
+const (
+	MaintenanceReboot      MaintenanceAction = "reboot"
+	MaintenanceUpgrade     MaintenanceAction = "upgrade"
+	MaintenanceReplacement MaintenanceAction = "replacement"
+)
+
+// QueuedMaintenance is a disruptive action which waits for the next maintenance window of the machines pool
+type QueuedMaintenance struct {
+	Action MaintenanceAction
+	Runs   time.Time
+}
+
+// deferred queues the action and returns true if the maintenance windows of the machines pool are closed
+func (m *initializedMachine) deferred(monitor mntr.Monitor, action MaintenanceAction) bool {
+	now := time.Now()
+	windows := m.pool.desired.maintenance
+	if windows.Open(now) {
+		return false
+	}
+
+	queued := &QueuedMaintenance{
+		Action: action,
+		Runs:   windows.Next(now),
+	}
+	m.pool.queued[m.infra.ID()] = queued
+	m.currentMachine.Maintenance = queued
+	monitor.WithFields(map[string]interface{}{
+		"action": action,
+		"runs":   queued.Runs,
+	}).Info("Deferring disruptive action until the next maintenance window")
+	return true
+}
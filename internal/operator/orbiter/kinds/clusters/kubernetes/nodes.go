@@ -10,7 +10,7 @@ import (
 	"github.com/caos/orbos/pkg/kubernetes"
 )
 
-func maintainNodes(allInitializedMachines initializedMachines, monitor mntr.Monitor, k8sClient *kubernetes.Client, pdf func(mntr.Monitor) error) (done bool, err error) {
+func maintainNodes(allInitializedMachines initializedMachines, monitor mntr.Monitor, clusterID string, k8sClient *kubernetes.Client, pdf func(mntr.Monitor) error) (done bool, err error) {
 
 	// Delete kubernetes nodes for unexisting machines
 	if k8sClient != nil {
@@ -56,10 +56,28 @@ func maintainNodes(allInitializedMachines initializedMachines, monitor mntr.Moni
 		return false, err
 	}
 
+	cacheOf(clusterID).prune(allInitializedMachines)
+
+	var rebooting bool
 	allInitializedMachines.forEach(monitor, func(machine *initializedMachine, machineMonitor mntr.Monitor) bool {
+		if machine.rebootInProgress() {
+			_, err = machine.lockReboot(k8sClient, clusterID)
+			return err == nil
+		}
+
 		req, _, unreq := machine.infra.RebootRequired()
-		if !req {
-			return true
+		sentinel := machine.currentNodeagent.RebootRequired
+		if (!req && !sentinel) || machine.deferred(machineMonitor, MaintenanceReboot) {
+			err = machine.unlockReboot(k8sClient, clusterID)
+			return err == nil
+		}
+
+		var locked bool
+		if locked, err = machine.lockReboot(k8sClient, clusterID); err != nil || !locked {
+			if err == nil {
+				machineMonitor.Info("Awaiting reboot lock")
+			}
+			return err == nil
 		}
 
 		if machine.node != nil {
@@ -68,16 +86,25 @@ func maintainNodes(allInitializedMachines initializedMachines, monitor mntr.Moni
 			}
 		}
 		machine.currentMachine.Rebooting = true
-		machineMonitor.Info("Requiring reboot")
-		unreq()
+		machineMonitor.WithField("sentinel", sentinel).Info("Requiring reboot")
+		if req {
+			unreq()
+		}
 		machine.desiredNodeagent.RebootRequired = time.Now().Truncate(time.Minute)
-		err = pdf(monitor.WithField("reason", "remove machine from reboot list"))
-		return false
+		rebooting = true
+		// Without a cluster lock, machines are rebooted one after another
+		return k8sClient != nil
 	})
 	if err != nil {
 		return false, err
 	}
 
+	if rebooting {
+		if err = pdf(monitor.WithField("reason", "remove machine from reboot list")); err != nil {
+			return false, err
+		}
+	}
+
 	done = true
 	allInitializedMachines.forEach(monitor, func(machine *initializedMachine, machineMonitor mntr.Monitor) bool {
 		if !machine.currentMachine.FirewallIsReady {
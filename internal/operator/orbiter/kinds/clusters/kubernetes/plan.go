@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/caos/orbos/internal/operator/orbiter"
+)
+
+var _ orbiter.Planner = (*Current)(nil)
+
+func (c *Current) Plan(plan *orbiter.Plan) error {
+	plan.Merge(c.Current.plan)
+	return nil
+}
+
+// planChanges lists the machines ensure would create or destroy, the machines it would up- or downgrade
+// and the nodes whose labels, taints or provider id it would reconcile
+func planChanges(desired *DesiredV0, pools []*initializedPool, machines []*initializedMachine) orbiter.Plan {
+	var p orbiter.Plan
+	for _, pool := range pools {
+		if pool == nil {
+			continue
+		}
+		for i := 0; i < pool.upscaling; i++ {
+			p.Machines = append(p.Machines, &orbiter.Change{
+				Action: orbiter.ActionCreate,
+				Object: pool.desired.Provider + "/" + pool.desired.Pool,
+			})
+		}
+		for _, machine := range pool.downscaling {
+			p.Machines = append(p.Machines, &orbiter.Change{
+				Action: orbiter.ActionDestroy,
+				Object: machine.infra.ID(),
+			})
+		}
+	}
+
+	target := ParseString(desired.Spec.Versions.Kubernetes)
+	for _, machine := range machines {
+		if machine.node == nil {
+			continue
+		}
+		kubelet := machine.node.Status.NodeInfo.KubeletVersion
+		if !ParseString(kubelet).equals(target) {
+			p.Kubernetes = append(p.Kubernetes, &orbiter.Change{
+				Action: orbiter.ActionChange,
+				Object: machine.infra.ID(),
+				From:   kubelet,
+				To:     target.String(),
+			})
+		}
+		if machine.reconciling != nil {
+			p.Kubernetes = append(p.Kubernetes, &orbiter.Change{
+				Action: orbiter.ActionChange,
+				Object: "node/" + machine.node.Name,
+				Diff:   reconcilingDiff(machine.reconciling),
+			})
+		}
+	}
+	return p
+}
+
+func reconcilingDiff(reconciling map[string]interface{}) []string {
+	diff := make([]string, 0, len(reconciling))
+	for field, value := range reconciling {
+		diff = append(diff, fmt.Sprintf("+ %s: %v", field, value))
+	}
+	sort.Strings(diff)
+	return diff
+}
@@ -40,23 +40,33 @@ func query(
 		}
 	}
 
+	pushed, err := pushedNodeAgents(desired, gitClient)
+	if err != nil {
+		return nil, err
+	}
+
 	controlplane, controlplaneMachines, workers, workerMachines, initializeMachine, uninitializeMachine, err := initialize(
 		monitor,
+		clusterID,
 		current,
 		*desired,
 		nodeAgentsCurrent,
 		nodeAgentsDesired,
+		pushed,
 		cloudPools,
 		k8sClient,
 		func(machine *initializedMachine) {
 			firewallFunc(monitor, *desired)(machine)
 		})
 
+	current.plan = planChanges(desired, append(workers, controlplane), append(controlplaneMachines, workerMachines...))
+
 	return func(psf func(mntr.Monitor) error) *orbiter.EnsureResult {
 		return orbiter.ToEnsureResult(ensure(
 			monitor,
 			clusterID,
 			desired,
+			current,
 			kubeAPIAddress,
 			psf,
 			k8sClient,
@@ -0,0 +1,102 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/caos/orbos/pkg/kubernetes"
+)
+
+const (
+	rebootLockNamespace = "kube-system"
+	rebootLockPrefix    = "orbos-reboot-"
+	// rebootLockDuration bounds how long a lock outlives an ORBITER which stopped renewing it
+	rebootLockDuration = 30 * time.Minute
+)
+
+var invalidLeaseNameChars = regexp.MustCompile("[^a-z0-9-]+")
+
+func rebootLockName(pool Pool, slot int) string {
+	return invalidLeaseNameChars.ReplaceAllString(strings.ToLower(fmt.Sprintf("%s%s-%s-%d", rebootLockPrefix, pool.Provider, pool.Pool, slot)), "-")
+}
+
+// lockReboot renews the reboot lock the machine holds or acquires a free one.
+// Each pool has maxunavailable locks, so at most maxunavailable machines of a pool reboot at once.
+// Without a kubernetes client, there is nothing to coordinate with.
+func (m *initializedMachine) lockReboot(k8sClient *kubernetes.Client, clusterID string) (bool, error) {
+	if k8sClient == nil {
+		return true, nil
+	}
+
+	id := m.infra.ID()
+	held, err := heldRebootLocks(k8sClient, clusterID, id)
+	if err != nil {
+		return false, err
+	}
+	for i, name := range held {
+		renewed, err := k8sClient.AcquireLease(rebootLockNamespace, name, id, rebootLockDuration)
+		if err != nil {
+			return false, err
+		}
+		if renewed {
+			for _, surplus := range held[i+1:] {
+				if err := k8sClient.ReleaseLease(rebootLockNamespace, surplus, id); err != nil {
+					return false, err
+				}
+			}
+			cacheOf(clusterID).cacheRebootLock(id, name)
+			return true, nil
+		}
+	}
+	cacheOf(clusterID).cacheRebootLock(id, "")
+
+	for slot := 0; slot < m.pool.desired.maxUnavailable(); slot++ {
+		name := rebootLockName(m.pool.desired, slot)
+		acquired, err := k8sClient.AcquireLease(rebootLockNamespace, name, id, rebootLockDuration)
+		if err != nil {
+			return false, err
+		}
+		if acquired {
+			cacheOf(clusterID).cacheRebootLock(id, name)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *initializedMachine) unlockReboot(k8sClient *kubernetes.Client, clusterID string) error {
+	if k8sClient == nil {
+		return nil
+	}
+	id := m.infra.ID()
+	held, err := heldRebootLocks(k8sClient, clusterID, id)
+	if err != nil {
+		return err
+	}
+	for _, name := range held {
+		if err := k8sClient.ReleaseLease(rebootLockNamespace, name, id); err != nil {
+			return err
+		}
+	}
+	cacheOf(clusterID).cacheRebootLock(id, "")
+	return nil
+}
+
+// heldRebootLocks returns the cached lock of the machine or looks up the leases it holds.
+// After ORBITER restarts, the leases are looked up by their holder.
+func heldRebootLocks(k8sClient kubernetes.ClientInt, clusterID, id string) ([]string, error) {
+	if held, ok := cacheOf(clusterID).cachedRebootLock(id); ok {
+		if held == "" {
+			return nil, nil
+		}
+		return []string{held}, nil
+	}
+	return k8sClient.HeldLeases(rebootLockNamespace, rebootLockPrefix, id)
+}
+
+// rebootInProgress is true from requiring a reboot until the node agent reports the machine booted and kubernetes untainted it
+func (m *initializedMachine) rebootInProgress() bool {
+	return m.desiredNodeagent.RebootRequired.After(m.currentNodeagent.Booted) || m.currentMachine.Rebooting
+}
@@ -0,0 +1,116 @@
+package kubernetes
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/kubernetes"
+)
+
+type ReplacementState string
+
+const (
+	// ReplacementPending machines wait until the pools maxUnavailable allows replacing them
+	ReplacementPending ReplacementState = "pending"
+	// ReplacementDraining machines are drained before their successor is created
+	ReplacementDraining ReplacementState = "draining"
+	// ReplacementAwaitingSuccessor machines are drained and wait for their successor to join and become ready
+	ReplacementAwaitingSuccessor ReplacementState = "awaitingsuccessor"
+	// ReplacementDestroying machines are removed, as their successors are ready
+	ReplacementDestroying ReplacementState = "destroying"
+)
+
+// planReplacements decides what happens to machines which require replacement.
+// At most maxUnavailable machines of a pool are replaced at once. They are drained first, then they get successors.
+// Only when all other machines of the pool are ready, the replaced machines are destroyed.
+func (i *initializedPool) planReplacements(monitor mntr.Monitor, replace, keep []*initializedMachine, desiredMachines int) {
+
+	i.replacements = make(map[string]ReplacementState)
+
+	// Replacements which didn't start draining yet wait for the next maintenance window
+	var started, deferred []*initializedMachine
+	for _, machine := range replace {
+		if machine.node != nil && !machine.node.Spec.Unschedulable && machine.deferred(monitor.WithField("machine", machine.infra.ID()), MaintenanceReplacement) {
+			deferred = append(deferred, machine)
+			continue
+		}
+		started = append(started, machine)
+	}
+	for _, machine := range deferred {
+		i.setReplacement(machine, ReplacementPending)
+	}
+	replace, keep = started, append(keep, deferred...)
+
+	// If the pool shrinks, surplus machines are removed as usual, so replaced machines need no successors
+	if len(keep) > desiredMachines {
+		candidates := downscaleCandidates(keep)
+		keep, i.downscaling = candidates[:desiredMachines], candidates[desiredMachines:]
+	}
+
+	i.replacing = replace
+	if max := i.desired.maxUnavailable(); len(replace) > max {
+		i.replacing = replace[:max]
+	}
+
+	for _, machine := range replace[len(i.replacing):] {
+		i.setReplacement(machine, ReplacementPending)
+	}
+
+	for _, machine := range i.replacing {
+		state := ReplacementAwaitingSuccessor
+		if machine.node != nil && !machine.node.Spec.Unschedulable {
+			state = ReplacementDraining
+		}
+		i.setReplacement(machine, state)
+	}
+
+	if upscale := desiredMachines + len(i.replacing) - len(keep) - len(replace); upscale > 0 {
+		i.upscaling = upscale
+		return
+	}
+
+	for _, machine := range keep {
+		if !nodeReady(machine.node) {
+			return
+		}
+	}
+
+	for _, machine := range i.replacing {
+		i.setReplacement(machine, ReplacementDestroying)
+		i.downscaling = append(i.downscaling, machine)
+	}
+}
+
+func (i *initializedPool) setReplacement(machine *initializedMachine, state ReplacementState) {
+	i.replacements[machine.infra.ID()] = state
+	machine.currentMachine.Replacement = state
+}
+
+func nodeReady(node *v1.Node) bool {
+	if node == nil || node.Spec.Unschedulable {
+		return false
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// drainReplacements drains the machines which are replaced before their successors are created
+func drainReplacements(pools []*initializedPool, k8sClient *kubernetes.Client, monitor mntr.Monitor) error {
+	for _, pool := range pools {
+		for _, machine := range pool.replacing {
+			if machine.currentMachine.Replacement != ReplacementDraining || k8sClient == nil {
+				continue
+			}
+			if err := k8sClient.Drain(machine.currentMachine, machine.node, kubernetes.Deleting, false); err != nil {
+				return err
+			}
+			pool.setReplacement(machine, ReplacementAwaitingSuccessor)
+			monitor.WithField("machine", machine.infra.ID()).Changed("Machine is drained and awaits its successor")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package kubernetes
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/maintenance"
+	"github.com/caos/orbos/mntr"
+)
+
+type fakeMachine struct {
+	infra.Machine
+	id string
+}
+
+func (f *fakeMachine) ID() string { return f.id }
+
+func Test_planReplacements(t *testing.T) {
+
+	type node int
+	const (
+		noNode node = iota
+		ready
+		notReady
+		cordoned
+	)
+
+	toNode := func(n node) *v1.Node {
+		switch n {
+		case ready:
+			return &v1.Node{Status: v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}}}
+		case notReady:
+			return &v1.Node{Status: v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionFalse}}}}
+		case cordoned:
+			return &v1.Node{Spec: v1.NodeSpec{Unschedulable: true}}
+		}
+		return nil
+	}
+
+	// closed opens in half an hour for a minute, so it is closed while the test runs
+	closed := maintenance.Windows{{
+		Start:    fmt.Sprintf("%d * * * *", (time.Now().UTC().Minute()+30)%60),
+		Duration: "1m",
+	}}
+
+	tests := []struct {
+		name            string
+		desiredMachines int
+		maxUnavailable  int
+		windows         maintenance.Windows
+		keep            map[string]node
+		replace         []string
+		replaceNodes    map[string]node
+		wantStates      map[string]ReplacementState
+		wantUpscaling   int
+		wantDownscaling []string
+	}{{
+		name:            "It should replace one machine at once by default",
+		desiredMachines: 3,
+		keep:            map[string]node{"k1": ready},
+		replace:         []string{"r1", "r2"},
+		replaceNodes:    map[string]node{"r1": ready, "r2": ready},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementDraining, "r2": ReplacementPending},
+		wantUpscaling:   1,
+	}, {
+		name:            "It should replace up to maxUnavailable machines at once",
+		desiredMachines: 3,
+		maxUnavailable:  2,
+		keep:            map[string]node{"k1": ready},
+		replace:         []string{"r1", "r2"},
+		replaceNodes:    map[string]node{"r1": ready, "r2": ready},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementDraining, "r2": ReplacementDraining},
+		wantUpscaling:   2,
+	}, {
+		name:            "It should await successors for drained machines and machines without nodes",
+		desiredMachines: 2,
+		maxUnavailable:  2,
+		keep:            map[string]node{},
+		replace:         []string{"r1", "r2"},
+		replaceNodes:    map[string]node{"r1": cordoned, "r2": noNode},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementAwaitingSuccessor, "r2": ReplacementAwaitingSuccessor},
+		wantUpscaling:   2,
+	}, {
+		name:            "It should not destroy replaced machines before their successors are ready",
+		desiredMachines: 2,
+		keep:            map[string]node{"k1": ready, "successor": notReady},
+		replace:         []string{"r1"},
+		replaceNodes:    map[string]node{"r1": cordoned},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementAwaitingSuccessor},
+	}, {
+		name:            "It should destroy replaced machines when all other machines are ready",
+		desiredMachines: 2,
+		keep:            map[string]node{"k1": ready, "successor": ready},
+		replace:         []string{"r1"},
+		replaceNodes:    map[string]node{"r1": cordoned},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementDestroying},
+		wantDownscaling: []string{"r1"},
+	}, {
+		name:            "It should defer replacements which didn't start outside maintenance windows",
+		desiredMachines: 2,
+		windows:         closed,
+		keep:            map[string]node{"k1": ready},
+		replace:         []string{"r1"},
+		replaceNodes:    map[string]node{"r1": ready},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementPending},
+	}, {
+		name:            "It should continue started replacements outside maintenance windows",
+		desiredMachines: 2,
+		windows:         closed,
+		keep:            map[string]node{"k1": ready},
+		replace:         []string{"r1"},
+		replaceNodes:    map[string]node{"r1": cordoned},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementAwaitingSuccessor},
+		wantUpscaling:   1,
+	}, {
+		name:            "It should remove surplus machines without successors while replacing",
+		desiredMachines: 1,
+		keep:            map[string]node{"k1": ready, "k2": ready},
+		replace:         []string{"r1"},
+		replaceNodes:    map[string]node{"r1": ready},
+		wantStates:      map[string]ReplacementState{"r1": ReplacementDestroying},
+		wantDownscaling: []string{"k2", "r1"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			pool := &initializedPool{
+				desired: Pool{MaxUnavailable: tt.maxUnavailable, maintenance: tt.windows},
+				queued:  make(map[string]*QueuedMaintenance),
+			}
+			machine := func(id string, n node) *initializedMachine {
+				return &initializedMachine{
+					infra:          &fakeMachine{id: id},
+					currentMachine: &Machine{},
+					pool:           pool,
+					node:           toNode(n),
+				}
+			}
+
+			var keep, replace []*initializedMachine
+			for _, id := range []string{"k1", "k2", "successor"} {
+				if n, ok := tt.keep[id]; ok {
+					keep = append(keep, machine(id, n))
+				}
+			}
+			for _, id := range tt.replace {
+				replace = append(replace, machine(id, tt.replaceNodes[id]))
+			}
+
+			pool.planReplacements(mntr.Monitor{}, replace, keep, tt.desiredMachines)
+
+			if !reflect.DeepEqual(pool.replacements, tt.wantStates) {
+				t.Errorf("replacements = %v, want %v", pool.replacements, tt.wantStates)
+			}
+			for _, m := range replace {
+				if m.currentMachine.Replacement != tt.wantStates[m.infra.ID()] {
+					t.Errorf("current state of %s = %s, want %s", m.infra.ID(), m.currentMachine.Replacement, tt.wantStates[m.infra.ID()])
+				}
+			}
+			if pool.upscaling != tt.wantUpscaling {
+				t.Errorf("upscaling = %d, want %d", pool.upscaling, tt.wantUpscaling)
+			}
+			var downscaling []string
+			for _, m := range pool.downscaling {
+				downscaling = append(downscaling, m.infra.ID())
+			}
+			if !reflect.DeepEqual(downscaling, tt.wantDownscaling) {
+				t.Errorf("downscaling = %v, want %v", downscaling, tt.wantDownscaling)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/kubernetes"
+)
+
+type ContainerRuntime string
+
+const (
+	Docker     ContainerRuntime = "docker"
+	Containerd ContainerRuntime = "containerd"
+
+	migratingRuntimeLabel = "orbos.ch/migrating-runtime"
+	criSocketAnnotation   = "kubeadm.alpha.kubernetes.io/cri-socket"
+	sandboxImage          = "pause:3.2"
+	containerdVersion     = "1.4.3"
+)
+
+func (c ContainerRuntime) orDefault() ContainerRuntime {
+	if c == "" {
+		return Docker
+	}
+	return c
+}
+
+func (c ContainerRuntime) validate() error {
+	switch c.orDefault() {
+	case Docker, Containerd:
+		return nil
+	}
+	return fmt.Errorf("container runtime %s is not supported, choose either %s or %s", c, Docker, Containerd)
+}
+
+// software returns the package the node agents ensure
+func (c ContainerRuntime) software(imageRegistry string) common.Package {
+	if c.orDefault() == Containerd {
+		pkg := common.Package{Version: "containerd.io v" + containerdVersion}
+		if imageRegistry != "" {
+			pkg.Config = map[string]string{"sandboximage": fmt.Sprintf("%s/%s", imageRegistry, sandboxImage)}
+		}
+		return pkg
+	}
+	return common.Package{
+		Version: "docker-ce v19.03.5",
+		Config: map[string]string{
+			"daemon.json": `{
+	"exec-opts": ["native.cgroupdriver=systemd"],
+	"log-driver": "json-file",
+	"log-opts": {
+		"max-size": "100m"
+	},
+	"storage-driver": "overlay2"
+}`,
+		}}
+}
+
+func (c ContainerRuntime) criSocket() string {
+	if c.orDefault() == Containerd {
+		return "/run/containerd/containerd.sock"
+	}
+	return "/var/run/dockershim.sock"
+}
+
+// runs returns true if the kubelet reports using the container runtime
+func (c ContainerRuntime) runs(node *core.Node) bool {
+	return strings.HasPrefix(node.Status.NodeInfo.ContainerRuntimeVersion, string(c.orDefault())+"://")
+}
+
+// migrateContainerRuntime moves joined nodes one by one to the desired container runtime.
+// Each node is drained before its node agent replaces the runtime and is only uncordoned when its kubelet reports the new runtime.
+func migrateContainerRuntime(
+	monitor mntr.Monitor,
+	k8sClient *kubernetes.Client,
+	runtime ContainerRuntime,
+	imageRegistry string,
+	machines []*initializedMachine,
+) (bool, error) {
+
+	pkg := runtime.software(imageRegistry)
+
+	for _, machine := range machines {
+		node := machine.node
+		if node == nil || !machine.currentMachine.Joined {
+			continue
+		}
+
+		migrating := node.Labels[migratingRuntimeLabel] == string(runtime.orDefault())
+		if !migrating && runtime.runs(node) {
+			continue
+		}
+
+		machineMonitor := monitor.WithFields(map[string]interface{}{
+			"machine": machine.infra.ID(),
+			"from":    node.Status.NodeInfo.ContainerRuntimeVersion,
+			"to":      runtime.orDefault(),
+		})
+
+		if !migrating {
+			node.Labels[migratingRuntimeLabel] = string(runtime.orDefault())
+			if err := k8sClient.UpdateNode(node); err != nil {
+				return false, err
+			}
+			if err := k8sClient.Drain(machine.currentMachine, node, kubernetes.Updating, false); err != nil {
+				return false, err
+			}
+			machineMonitor.Changed("Node drained for migrating the container runtime")
+		}
+
+		if !contains(machine.desiredNodeagent.Software.Containerruntime, pkg) {
+			machine.desiredNodeagent.Software.Merge(common.Software{Containerruntime: pkg}, false)
+			machineMonitor.Changed("Container runtime desired")
+			return false, nil
+		}
+
+		if !runtime.runs(node) || !machine.currentNodeagent.NodeIsReady {
+			machineMonitor.Info("Awaiting container runtime migration")
+			return false, nil
+		}
+
+		delete(node.Labels, migratingRuntimeLabel)
+		if node.Annotations == nil {
+			node.Annotations = make(map[string]string)
+		}
+		node.Annotations[criSocketAnnotation] = runtime.criSocket()
+		node.Spec.Taints = k8sClient.RemoveFromTaints(node.Spec.Taints, kubernetes.Updating)
+		if err := k8sClient.UpdateNode(node); err != nil {
+			return false, err
+		}
+		machine.currentMachine.Updating = false
+		machineMonitor.Changed("Container runtime migrated")
+		return false, nil
+	}
+	return true, nil
+}
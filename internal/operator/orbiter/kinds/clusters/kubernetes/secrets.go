@@ -8,7 +8,19 @@ func getSecretsMap(desiredKind *DesiredV0) map[string]*secret.Secret {
 	if desiredKind.Spec.Kubeconfig == nil {
 		desiredKind.Spec.Kubeconfig = &secret.Secret{}
 	}
-	return map[string]*secret.Secret{
+	secrets := map[string]*secret.Secret{
 		"kubeconfig": desiredKind.Spec.Kubeconfig,
 	}
+
+	if backup := desiredKind.Spec.EtcdBackup; backup != nil && backup.S3 != nil {
+		if backup.S3.AccessKeyID == nil {
+			backup.S3.AccessKeyID = &secret.Secret{}
+		}
+		if backup.S3.SecretAccessKey == nil {
+			backup.S3.SecretAccessKey = &secret.Secret{}
+		}
+		secrets["etcdbackupaccesskeyid"] = backup.S3.AccessKeyID
+		secrets["etcdbackupsecretaccesskey"] = backup.S3.SecretAccessKey
+	}
+	return secrets
 }
@@ -0,0 +1,136 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
+)
+
+const defaultSecurityUpdatesInterval = 7 * 24 * time.Hour
+
+// SecurityUpdates lets the node agents apply security updates of the operating system packages.
+// Machines which need a reboot afterwards are rebooted like any other machine requiring a reboot.
+type SecurityUpdates struct {
+	// Interval is the minimum duration between two security updates of a machine
+	//@default: 168h
+	Interval string `yaml:",omitempty"`
+}
+
+var securityUpdatesAvailable = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "kubernetes_machine_security_updates_available",
+		Help: "The number of security updates available for the operating system packages of a machine",
+	},
+	[]string{"cluster", "machine"},
+)
+
+func init() {
+	prometheus.MustRegister(securityUpdatesAvailable)
+}
+
+func (s *SecurityUpdates) validate() error {
+	if s.Interval == "" {
+		return nil
+	}
+	interval, err := time.ParseDuration(s.Interval)
+	if err != nil {
+		return fmt.Errorf("parsing security updates interval failed: %w", err)
+	}
+	if interval < time.Hour {
+		return fmt.Errorf("security updates interval must be at least an hour, but is %s", s.Interval)
+	}
+	return nil
+}
+
+func (s *SecurityUpdates) interval() time.Duration {
+	interval, err := time.ParseDuration(s.Interval)
+	if err != nil {
+		return defaultSecurityUpdatesInterval
+	}
+	return interval
+}
+
+// ensureSecurityUpdates rolls security updates out pool by pool, starting with the control plane.
+// Within a pool, at most maxunavailable machines are updated at once.
+// The next pool is only updated when all machines of the previous pool are updated and rebooted if needed.
+func ensureSecurityUpdates(monitor mntr.Monitor, clusterID string, desired *DesiredV0, machines []*initializedMachine) {
+	if desired.Spec.SecurityUpdates == nil {
+		return
+	}
+	interval := desired.Spec.SecurityUpdates.interval()
+
+	var (
+		pool     *initializedPool
+		updating int
+		blocked  bool
+	)
+	for _, machine := range machines {
+		if machine.pool != pool {
+			blocked = blocked || updating > 0
+			pool = machine.pool
+			updating = 0
+		}
+
+		current := machine.currentNodeagent.SecurityUpdates
+		if current == nil || machine.desiredNodeagent.SecurityUpdates == nil {
+			continue
+		}
+		securityUpdatesAvailable.With(prometheus.Labels{
+			"cluster": clusterID,
+			"machine": machine.infra.ID(),
+		}).Set(float64(len(current.Available)))
+
+		if machine.desiredNodeagent.SecurityUpdates.Generation > current.Generation ||
+			machine.rebootInProgress() ||
+			machine.currentNodeagent.RebootRequired {
+			updating++
+			continue
+		}
+
+		if blocked ||
+			len(current.Available) == 0 ||
+			time.Since(current.Applied) < interval ||
+			updating >= pool.desired.maxUnavailable() {
+			continue
+		}
+
+		generation := current.Generation + 1
+		machine.desiredNodeagent.SecurityUpdates.Generation = generation
+		updating++
+		monitor.WithFields(map[string]interface{}{
+			"machine":    machine.infra.ID(),
+			"updates":    len(current.Available),
+			"generation": generation,
+		}).Info("Applying security updates")
+	}
+}
+
+// pushedNodeAgents returns the desired node agents ORBITER pushed in the last iteration
+func pushedNodeAgents(desired *DesiredV0, gitClient *git.Client) (map[string]*common.NodeAgentSpec, error) {
+	if desired.Spec.SecurityUpdates == nil || gitClient == nil {
+		return nil, nil
+	}
+	pushed := common.NodeAgentsDesiredKind{}
+	if err := gitClient.ReadYamlIntoStruct("caos-internal/orbiter/node-agents-desired.yml", &pushed); err != nil {
+		return nil, err
+	}
+	return pushed.Spec.NodeAgents.NA, nil
+}
+
+// desireSecurityUpdates keeps the generation ORBITER required in the node agents desired state it pushed last,
+// as the desired node agents are rebuilt in each iteration and a pending generation must survive ORBITER restarts.
+func desireSecurityUpdates(spec *SecurityUpdates, previous *common.NodeAgentSpec, naSpec *common.NodeAgentSpec) {
+	if spec == nil {
+		naSpec.SecurityUpdates = nil
+		return
+	}
+	naSpec.SecurityUpdates = &common.SecurityUpdatesSpec{}
+	if previous != nil && previous.SecurityUpdates != nil {
+		naSpec.SecurityUpdates.Generation = previous.SecurityUpdates.Generation
+	}
+}
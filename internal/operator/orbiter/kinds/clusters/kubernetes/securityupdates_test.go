@@ -0,0 +1,103 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+func TestEnsureSecurityUpdates(t *testing.T) {
+
+	available := []*common.SecurityUpdate{{Package: "openssl", Version: "1.1.1k"}}
+	due := time.Now().Add(-30 * 24 * time.Hour)
+
+	type machineState struct {
+		current   common.SecurityUpdatesCurrent
+		requested int64
+	}
+
+	tests := []struct {
+		name     string
+		machines []machineState
+		want     []int64
+	}{{
+		name: "It should require the next generation when updates are due",
+		machines: []machineState{
+			{current: common.SecurityUpdatesCurrent{Available: available, Applied: due, Generation: 2}},
+		},
+		want: []int64{3},
+	}, {
+		name: "It should not require updates when they were applied recently",
+		machines: []machineState{
+			{current: common.SecurityUpdatesCurrent{Available: available, Applied: time.Now().Add(-time.Hour), Generation: 2}},
+		},
+		want: []int64{0},
+	}, {
+		name: "It should not require updates when none are available",
+		machines: []machineState{
+			{current: common.SecurityUpdatesCurrent{Applied: due, Generation: 2}},
+		},
+		want: []int64{0},
+	}, {
+		name: "It should keep a requested generation pending until the node agent reports it, even if the machines clock is ahead",
+		machines: []machineState{
+			{current: common.SecurityUpdatesCurrent{Available: available, Applied: time.Now().Add(time.Hour), Generation: 2}, requested: 3},
+			{current: common.SecurityUpdatesCurrent{Available: available, Applied: due, Generation: 5}},
+		},
+		want: []int64{3, 0},
+	}, {
+		name: "It should require updates for the first machine of a new node agent",
+		machines: []machineState{
+			{current: common.SecurityUpdatesCurrent{Available: available}},
+		},
+		want: []int64{1},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := &initializedPool{tier: Controlplane}
+			machines := make([]*initializedMachine, len(tt.machines))
+			for i := range tt.machines {
+				current := tt.machines[i].current
+				machines[i] = &initializedMachine{
+					infra:            &fakeMachine{id: string(rune('a' + i))},
+					currentMachine:   &Machine{},
+					currentNodeagent: &common.NodeAgentCurrent{SecurityUpdates: &current},
+					desiredNodeagent: &common.NodeAgentSpec{SecurityUpdates: &common.SecurityUpdatesSpec{Generation: tt.machines[i].requested}},
+					pool:             pool,
+				}
+			}
+
+			ensureSecurityUpdates(mntr.Monitor{}, "cluster", &DesiredV0{Spec: Spec{SecurityUpdates: &SecurityUpdates{}}}, machines)
+
+			for i, machine := range machines {
+				if got := machine.desiredNodeagent.SecurityUpdates.Generation; got != tt.want[i] {
+					t.Errorf("machine %d: Generation = %d, want %d", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDesireSecurityUpdates_KeepsPushedGeneration(t *testing.T) {
+
+	pushed := &common.NodeAgentSpec{SecurityUpdates: &common.SecurityUpdatesSpec{Generation: 3}}
+
+	naSpec := &common.NodeAgentSpec{}
+	desireSecurityUpdates(&SecurityUpdates{}, pushed, naSpec)
+	if naSpec.SecurityUpdates == nil || naSpec.SecurityUpdates.Generation != 3 {
+		t.Errorf("expected the pushed generation 3 to be kept, but got %v", naSpec.SecurityUpdates)
+	}
+
+	naSpec = &common.NodeAgentSpec{}
+	desireSecurityUpdates(&SecurityUpdates{}, nil, naSpec)
+	if naSpec.SecurityUpdates == nil || naSpec.SecurityUpdates.Generation != 0 {
+		t.Errorf("expected generation 0 for a new node agent, but got %v", naSpec.SecurityUpdates)
+	}
+
+	desireSecurityUpdates(nil, pushed, naSpec)
+	if naSpec.SecurityUpdates != nil {
+		t.Error("expected no security updates when they are not configured")
+	}
+}
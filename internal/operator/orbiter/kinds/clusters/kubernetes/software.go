@@ -116,33 +116,36 @@ var kubernetesVersions = []string{
 	"v1.21.0",
 }
 
+// ReleasedVersions returns the versions of the packages the node agents install from release downloads on immutable operating systems
+func ReleasedVersions() map[string][]string {
+	k8sVersions := make([]string, 0, len(kubernetesVersions)-1)
+	for _, version := range kubernetesVersions[1:] {
+		k8sVersions = append(k8sVersions, strings.TrimPrefix(version, "v"))
+	}
+	return map[string][]string{
+		"kubelet":       k8sVersions,
+		"kubeadm":       k8sVersions,
+		"kubectl":       k8sVersions,
+		"containerd.io": {containerdVersion},
+	}
+}
+
 func (k KubernetesVersion) String() string {
 	return kubernetesVersions[k]
 }
 
-func (k KubernetesVersion) DefineSoftware() common.Software {
+func (k KubernetesVersion) DefineSoftware(containerRuntime common.Package) common.Software {
 	sysctlPkg := common.Package{}
 	sysctl.Enable(&sysctlPkg, common.IpForward)
 	sysctl.Enable(&sysctlPkg, common.BridgeNfCallIptables)
 	sysctl.Enable(&sysctlPkg, common.BridgeNfCallIp6tables)
 	return common.Software{
-		Swap: common.Package{Version: "disabled"},
-		Containerruntime: common.Package{
-			Version: "docker-ce v19.03.5",
-			Config: map[string]string{
-				"daemon.json": `{
-	"exec-opts": ["native.cgroupdriver=systemd"],
-	"log-driver": "json-file",
-	"log-opts": {
-		"max-size": "100m"
-	},
-	"storage-driver": "overlay2"
-}`,
-			}},
-		Kubelet: common.Package{Version: k.String()},
-		Kubeadm: common.Package{Version: k.String()},
-		Kubectl: common.Package{Version: k.String()},
-		Sysctl:  sysctlPkg,
+		Swap:             common.Package{Version: "disabled"},
+		Containerruntime: containerRuntime,
+		Kubelet:          common.Package{Version: k.String()},
+		Kubeadm:          common.Package{Version: k.String()},
+		Kubectl:          common.Package{Version: k.String()},
+		Sysctl:           sysctlPkg,
 		Kernel: common.Package{
 			Version: "3.10.0",
 			Config: map[string]string{
@@ -174,7 +177,7 @@ func ParseString(version string) KubernetesVersion {
 }
 
 func (k KubernetesVersion) equals(other KubernetesVersion) bool {
-	return string(k) == string(other)
+	return k == other
 }
 
 func (k KubernetesVersion) NextHighestMinor() KubernetesVersion {
@@ -232,6 +235,9 @@ func softwareContains(this common.Software, that common.Software) bool {
 		contains(this.Containerruntime, that.Containerruntime) &&
 		contains(this.KeepaliveD, that.KeepaliveD) &&
 		contains(this.Nginx, that.Nginx) &&
+		contains(this.HAProxy, that.HAProxy) &&
+		contains(this.Envoy, that.Envoy) &&
+		contains(this.Bird, that.Bird) &&
 		contains(this.Hostname, that.Hostname) &&
 		sysctl.Contains(this.Sysctl, that.Sysctl) &&
 		contains(this.Health, that.Health) &&
@@ -250,6 +256,9 @@ func softwareDefines(this common.Software, that common.Software) bool {
 		defines(this.Containerruntime, that.Containerruntime) &&
 		defines(this.KeepaliveD, that.KeepaliveD) &&
 		defines(this.Nginx, that.Nginx) &&
+		defines(this.HAProxy, that.HAProxy) &&
+		defines(this.Envoy, that.Envoy) &&
+		defines(this.Bird, that.Bird) &&
 		defines(this.Hostname, that.Hostname) &&
 		defines(this.Sysctl, that.Sysctl) &&
 		defines(this.Health, that.Health) &&
@@ -3,6 +3,8 @@ package kubernetes
 import (
 	"fmt"
 
+	core "k8s.io/api/core/v1"
+
 	"github.com/caos/orbos/internal/operator/common"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/kubernetes"
@@ -14,38 +16,88 @@ func (c initializedMachines) Len() int           { return len(c) }
 func (c initializedMachines) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
 func (c initializedMachines) Less(i, j int) bool { return c[i].infra.ID() < c[j].infra.ID() }
 
+// ensureSoftware returns the kubernetes version new machines join with.
+// If an upgrade is deferred until the next maintenance window, deferred is true and new machines join with the current version,
+// as kubelets must not be newer than the control plane.
 func ensureSoftware(
 	monitor mntr.Monitor,
 	target KubernetesVersion,
 	k8sClient *kubernetes.Client,
 	controlplane []*initializedMachine,
-	workers []*initializedMachine) (bool, error) {
+	workers []*initializedMachine,
+	containerRuntime common.Package,
+	current *CurrentCluster) (done bool, deferred bool, joinVersion KubernetesVersion, err error) {
 
 	sortedMachines := append(controlplane, workers...)
-	from, to, err := findPath(monitor, sortedMachines, target)
+	lowest, path, err := findPath(monitor, sortedMachines, target)
 	if err != nil {
-		return false, err
+		return false, false, target, err
 	}
 
-	monitor.WithFields(map[string]interface{}{
+	if len(path) == 0 {
+		current.Upgrade = nil
+		targetSoftware := target.DefineSoftware(containerRuntime)
+		done, _, deferred, err := step(k8sClient, monitor, sortedMachines, targetSoftware, targetSoftware)
+		return done, deferred, target, err
+	}
+
+	from, to := lowest.DefineSoftware(containerRuntime), path[0].DefineSoftware(containerRuntime)
+	upgrade := &UpgradeProgress{
+		From: lowest.String(),
+		To:   target.String(),
+		Step: path[0].String(),
+	}
+	for _, version := range path {
+		upgrade.Path = append(upgrade.Path, version.String())
+	}
+	current.Upgrade = upgrade
+
+	monitor = monitor.WithFields(map[string]interface{}{
 		"currentSoftware":   from,
 		"currentKubernetes": from.Kubelet,
 		"desiredSofware":    to,
 		"desiredKubernetes": to.Kubelet,
-	}).Debug("Ensuring kubernetes version")
+	})
+
+	if !stepStarted(sortedMachines, to) {
+		healthy, reason, err := upgradeHealthy(k8sClient, sortedMachines)
+		if err != nil {
+			return false, false, target, err
+		}
+		if !healthy {
+			upgrade.Phase = UpgradeAwaitingHealth
+			monitor.WithField("reason", reason).Info("Awaiting a healthy cluster before upgrading kubernetes to the next version")
+			return false, false, target, nil
+		}
+	}
+
+	monitor.Debug("Ensuring kubernetes version")
+
+	done, stepping, deferred, err := step(k8sClient, monitor, sortedMachines, from, to)
+	upgrade.Phase = UpgradePhase(stepping)
+	if !deferred {
+		return done, false, target, err
+	}
 
-	return step(k8sClient, monitor, sortedMachines, from, to)
+	upgrade.Phase = UpgradeDeferred
+	for _, machine := range sortedMachines {
+		if !machine.currentMachine.Joined {
+			machine.desiredNodeagent.Software.Merge(from, true)
+		}
+	}
+	return false, true, lowest, err
 }
 
+// findPath returns the lowest kubelet version of all nodes and the versions the cluster needs to be upgraded through.
+// As kubeadm only supports upgrading one minor at a time, each intermediate minor is stepped through with its highest known patch.
 func findPath(
 	monitor mntr.Monitor,
 	machines []*initializedMachine,
 	target KubernetesVersion,
-) (common.Software, common.Software, error) {
+) (KubernetesVersion, []KubernetesVersion, error) {
 
 	var overallLowKubelet KubernetesVersion
 	var overallLowKubeletMinor int
-	zeroSW := common.Software{}
 
 	for _, machine := range machines {
 		id := machine.infra.ID()
@@ -61,12 +113,12 @@ func findPath(
 		}).Debug("Found kubelet version from node info")
 		kubelet := ParseString(nodeinfoKubelet)
 		if kubelet == Unknown {
-			return zeroSW, zeroSW, fmt.Errorf("parsing version %s from nodes %s info failed", nodeinfoKubelet, id)
+			return Unknown, nil, fmt.Errorf("parsing version %s from nodes %s info failed", nodeinfoKubelet, id)
 		}
 
 		kubeletMinor, err := kubelet.ExtractMinor(monitor)
 		if err != nil {
-			return zeroSW, zeroSW, fmt.Errorf("extracting minor from kubelet version %s from nodes %s info failed: %w", nodeinfoKubelet, id, err)
+			return Unknown, nil, fmt.Errorf("extracting minor from kubelet version %s from nodes %s info failed: %w", nodeinfoKubelet, id, err)
 		}
 
 		if overallLowKubelet == Unknown {
@@ -77,15 +129,15 @@ func findPath(
 
 		kubeletPatch, err := kubelet.ExtractPatch(monitor)
 		if err != nil {
-			return zeroSW, zeroSW, fmt.Errorf("extracting patch from kubelet version %s from nodes %s info failed: %w", nodeinfoKubelet, id, err)
+			return Unknown, nil, fmt.Errorf("extracting patch from kubelet version %s from nodes %s info failed: %w", nodeinfoKubelet, id, err)
 		}
 		tmpOverallLowKubeletMinor, err := overallLowKubelet.ExtractMinor(monitor)
 		if err != nil {
-			return zeroSW, zeroSW, fmt.Errorf("extracting minor from overall kubelet version %s failed: %w", overallLowKubelet, err)
+			return Unknown, nil, fmt.Errorf("extracting minor from overall kubelet version %s failed: %w", overallLowKubelet, err)
 		}
 		tmpOverallLowKubeletPatch, err := overallLowKubelet.ExtractPatch(monitor)
 		if err != nil {
-			return zeroSW, zeroSW, fmt.Errorf("extracting patch from overall kubelet version %s failed: %w", overallLowKubelet, err)
+			return Unknown, nil, fmt.Errorf("extracting patch from overall kubelet version %s failed: %w", overallLowKubelet, err)
 		}
 
 		if kubeletMinor < tmpOverallLowKubeletMinor ||
@@ -96,43 +148,104 @@ func findPath(
 	}
 
 	if overallLowKubelet == target || overallLowKubelet == Unknown {
-		target := target.DefineSoftware()
 		monitor.WithFields(map[string]interface{}{
 			"from": overallLowKubelet,
 			"to":   target,
 		}).Debug("Cluster is up to date")
-		return target, target, nil
+		return overallLowKubelet, nil, nil
 	}
 
 	targetMinor, err := target.ExtractMinor(monitor)
 	if err != nil {
-		return zeroSW, zeroSW, fmt.Errorf("extracting minor from target version %s failed: %w", target, err)
+		return Unknown, nil, fmt.Errorf("extracting minor from target version %s failed: %w", target, err)
 	}
 
 	if targetMinor < overallLowKubeletMinor {
-		return zeroSW, zeroSW, fmt.Errorf("downgrading from %s to %s is not possible as they are on different minors", overallLowKubelet, target)
+		return Unknown, nil, fmt.Errorf("downgrading from %s to %s is not possible as they are on different minors", overallLowKubelet, target)
 	}
 
-	overallLowKubeletSoftware := overallLowKubelet.DefineSoftware()
-	if (targetMinor - overallLowKubeletMinor) < 2 {
-		monitor.WithFields(map[string]interface{}{
-			"from":                   overallLowKubelet,
-			"to":                     target,
-			"targetMinor":            targetMinor,
-			"overallLowKubeletMinor": overallLowKubeletMinor,
-		}).Debug("Desired version can be reached directly")
-		return overallLowKubeletSoftware, target.DefineSoftware(), nil
+	path, err := upgradePath(monitor, overallLowKubelet, target)
+	if err != nil {
+		return Unknown, nil, err
 	}
 
-	nextHighestMinor := overallLowKubelet.NextHighestMinor()
 	monitor.WithFields(map[string]interface{}{
-		"from":         overallLowKubelet,
-		"fromMinor":    overallLowKubeletMinor,
-		"intermediate": nextHighestMinor,
-		"to":           target,
-		"toMinor":      targetMinor,
-	}).Debug("Desired version can be reached via an intermediate version")
-	return overallLowKubeletSoftware, nextHighestMinor.DefineSoftware(), nil
+		"from": overallLowKubelet,
+		"to":   target,
+		"path": path,
+	}).Debug("Found upgrade path")
+	return overallLowKubelet, path, nil
+}
+
+func upgradePath(monitor mntr.Monitor, from, to KubernetesVersion) ([]KubernetesVersion, error) {
+
+	toMinor, err := to.ExtractMinor(monitor)
+	if err != nil {
+		return nil, err
+	}
+
+	var path []KubernetesVersion
+	for intermediate := from; ; {
+		intermediateMinor, err := intermediate.ExtractMinor(monitor)
+		if err != nil {
+			return nil, err
+		}
+
+		if toMinor-intermediateMinor < 2 {
+			return append(path, to), nil
+		}
+
+		next := intermediate.NextHighestMinor()
+		if next == Unknown {
+			return nil, fmt.Errorf("no kubernetes version is known for upgrading from %s to the next minor", intermediate)
+		}
+		path = append(path, next)
+		intermediate = next
+	}
+}
+
+// stepStarted returns true if any machine is already desired to run the kubeadm version of the step
+func stepStarted(machines []*initializedMachine, to common.Software) bool {
+	for _, machine := range machines {
+		if machine.desiredNodeagent.Software.Kubeadm.Equals(to.Kubeadm) {
+			return true
+		}
+	}
+	return false
+}
+
+// upgradeHealthy is the gate between two upgrade steps. All nodes have to be ready and schedulable and all control plane pods have to be ready.
+func upgradeHealthy(k8sClient *kubernetes.Client, machines []*initializedMachine) (bool, string, error) {
+
+	for _, machine := range machines {
+		if machine.node == nil {
+			continue
+		}
+		if !nodeReady(machine.node) {
+			return false, fmt.Sprintf("node %s is not ready or unschedulable", machine.node.Name), nil
+		}
+	}
+
+	pods, err := k8sClient.ListPods("kube-system", map[string]string{"tier": "control-plane"})
+	if err != nil {
+		return false, "", err
+	}
+
+	for _, pod := range pods.Items {
+		if !podReady(pod) {
+			return false, fmt.Sprintf("pod %s is not ready", pod.Name), nil
+		}
+	}
+	return true, "", nil
+}
+
+func podReady(pod core.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == core.PodReady {
+			return cond.Status == core.ConditionTrue
+		}
+	}
+	return false
 }
 
 func step(
@@ -141,7 +254,7 @@ func step(
 	sortedMachines initializedMachines,
 	from common.Software,
 	to common.Software,
-) (bool, error) {
+) (done bool, tier Tier, deferred bool, err error) {
 
 	for _, machine := range sortedMachines {
 		if machine.node != nil && machine.node.Labels["orbos.ch/updating"] == machine.node.Status.NodeInfo.KubeletVersion {
@@ -150,7 +263,7 @@ func step(
 				machine.node.Spec.Taints = k8sClient.RemoveFromTaints(machine.node.Spec.Taints, kubernetes.Updating)
 			}
 			if err := k8sClient.UpdateNode(machine.node); err != nil {
-				return false, err
+				return false, "", false, err
 			}
 		}
 	}
@@ -159,15 +272,20 @@ func step(
 
 		next, err := plan(k8sClient, monitor, machine, idx == 0, from, to)
 		if err != nil {
-			return false, fmt.Errorf("planning machine %s failed: %w", machine.infra.ID(), err)
+			return false, machine.pool.tier, false, fmt.Errorf("planning machine %s failed: %w", machine.infra.ID(), err)
 		}
 
 		if next == nil {
 			continue
 		}
-		return false, next()
+
+		// Upgrades of joined machines only start within maintenance windows, but started upgrades are completed
+		if machine.currentMachine.Joined && !machine.currentMachine.Updating && machine.deferred(monitor.WithField("machine", machine.infra.ID()), MaintenanceUpgrade) {
+			return false, machine.pool.tier, true, nil
+		}
+		return false, machine.pool.tier, false, next()
 	}
-	return true, nil
+	return true, "", false, nil
 }
 
 func plan(
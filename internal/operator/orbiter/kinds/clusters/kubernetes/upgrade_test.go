@@ -0,0 +1,110 @@
+package kubernetes
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/orbiter/maintenance"
+	"github.com/caos/orbos/mntr"
+)
+
+func Test_upgradePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    KubernetesVersion
+		to      KubernetesVersion
+		want    []KubernetesVersion
+		wantErr bool
+	}{{
+		name: "It should upgrade patches directly",
+		from: V1x18x0,
+		to:   V1x18x19,
+		want: []KubernetesVersion{V1x18x19},
+	}, {
+		name: "It should upgrade to the next minor directly",
+		from: V1x18x4,
+		to:   V1x19x2,
+		want: []KubernetesVersion{V1x19x2},
+	}, {
+		name: "It should step through the highest patches of all intermediate minors",
+		from: V1x16x3,
+		to:   V1x20x0,
+		want: []KubernetesVersion{V1x17x17, V1x18x19, V1x19x10, V1x20x0},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := upgradePath(mntr.Monitor{}, tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("upgradePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("upgradePath() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_step_deferred(t *testing.T) {
+
+	// closed opens in half an hour for a minute, so it is closed while the test runs
+	closed := maintenance.Windows{{
+		Start:    fmt.Sprintf("%d * * * *", (time.Now().UTC().Minute()+30)%60),
+		Duration: "1m",
+	}}
+
+	from := common.Software{Kernel: common.Package{Version: "1"}}
+	to := common.Software{Kernel: common.Package{Version: "2"}}
+
+	tests := []struct {
+		name         string
+		windows      maintenance.Windows
+		updating     bool
+		wantDeferred bool
+		wantKernel   string
+	}{{
+		name:       "It should upgrade machines within maintenance windows",
+		wantKernel: "2",
+	}, {
+		name:         "It should defer upgrades outside maintenance windows",
+		windows:      closed,
+		wantDeferred: true,
+		wantKernel:   "1",
+	}, {
+		name:       "It should continue started upgrades outside maintenance windows",
+		windows:    closed,
+		updating:   true,
+		wantKernel: "2",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &initializedMachine{
+				infra:            &fakeMachine{id: "cp1"},
+				currentNodeagent: &common.NodeAgentCurrent{Software: from},
+				desiredNodeagent: &common.NodeAgentSpec{Software: &common.Software{Kernel: from.Kernel}},
+				currentMachine:   &Machine{Joined: true, Updating: tt.updating},
+				pool: &initializedPool{
+					tier:    Controlplane,
+					desired: Pool{maintenance: tt.windows},
+					queued:  make(map[string]*QueuedMaintenance),
+				},
+			}
+
+			done, _, deferred, err := step(nil, mntr.Monitor{}, initializedMachines{machine}, from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if done {
+				t.Error("expected step not to be done")
+			}
+			if deferred != tt.wantDeferred {
+				t.Errorf("deferred = %t, want %t", deferred, tt.wantDeferred)
+			}
+			if kernel := machine.desiredNodeagent.Software.Kernel.Version; kernel != tt.wantKernel {
+				t.Errorf("desired kernel = %s, want %s", kernel, tt.wantKernel)
+			}
+		})
+	}
+}
@@ -6,7 +6,9 @@ import (
 
 	"github.com/caos/orbos/internal/helpers"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes/etcd"
 	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
 	"github.com/caos/orbos/pkg/kubernetes"
 	"github.com/caos/orbos/pkg/secret"
 )
@@ -22,7 +24,7 @@ func ensureNodes(
 	oneoff bool,
 	providerK8sSpec infra.Kubernetes,
 	machines []*initializedMachine,
-
+	gitClient *git.Client,
 ) (done bool, err error) {
 
 	var joinCP *initializedMachine
@@ -42,6 +44,13 @@ nodes:
 			return false, nil
 		}
 
+		if machine.currentMachine.Replacement != "" {
+			if machine.pool.tier == Controlplane && machine.currentMachine.Joined && certsCP == nil {
+				certsCP = machine.infra
+			}
+			continue nodes
+		}
+
 		isJoinedControlPlane := machine.pool.tier == Controlplane && machine.currentMachine.Joined
 
 		if isJoinedControlPlane && !machine.currentMachine.Updating && !machine.currentMachine.Rebooting {
@@ -116,6 +125,19 @@ nodes:
 			monitor.Info("Refreshed certs")
 		}
 
+		var etcdRestoreArchive []byte
+		restoring := doKubeadmInit && desired.Spec.EtcdBackup != nil && desired.Spec.EtcdBackup.Restore != ""
+		if restoring {
+			store, err := etcd.NewStore(desired.Spec.EtcdBackup, gitClient, clusterID)
+			if err != nil {
+				return false, err
+			}
+			if etcdRestoreArchive, err = store.Get(desired.Spec.EtcdBackup.Restore); err != nil {
+				return false, err
+			}
+			monitor.WithField("snapshot", desired.Spec.EtcdBackup.Restore).Info("Initializing control plane from etcd snapshot")
+		}
+
 		var joinKubeconfig *string
 		joinKubeconfig, err = join(
 			monitor,
@@ -130,6 +152,7 @@ nodes:
 			k8sClient,
 			imageRepository,
 			providerK8sSpec,
+			etcdRestoreArchive,
 		)
 
 		if err != nil {
@@ -140,6 +163,9 @@ nodes:
 			return false, err
 		}
 		desired.Spec.Kubeconfig = &secret.Secret{Value: *joinKubeconfig}
+		if restoring {
+			desired.Spec.EtcdBackup.Restore = ""
+		}
 		return false, psf(monitor.WithFields(map[string]interface{}{
 			"type": "kubeconfig",
 		}))
@@ -164,6 +190,7 @@ nodes:
 			k8sClient,
 			imageRepository,
 			providerK8sSpec,
+			nil,
 		); err != nil {
 			return false, fmt.Errorf("joining worker %s failed: %w", worker.infra.ID(), err)
 		}
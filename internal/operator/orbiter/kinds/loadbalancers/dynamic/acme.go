@@ -0,0 +1,352 @@
+package dynamic
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"golang.org/x/crypto/acme"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+const (
+	HTTP01 = "http-01"
+	DNS01  = "dns-01"
+
+	letsEncryptDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+	acmeChallengeDir     = "/var/lib/orbos/acme"
+	acmeTimeout          = 5 * time.Minute
+	acmeRetryInterval    = 15 * time.Minute
+	dnsPropagation       = 30 * time.Second
+	renewBefore          = 30 * 24 * time.Hour
+)
+
+// ACME obtains and renews the certificate of a transport
+type ACME struct {
+	Email   string
+	Domains []string
+	// Directory defaults to Let's Encrypt
+	Directory string `yaml:",omitempty"`
+	// Challenge is either http-01 or dns-01
+	Challenge string
+	// CloudflareAPIToken lets ORBITER create the TXT records dns-01 challenges need
+	CloudflareAPIToken *secret.Secret `yaml:",omitempty"`
+	// AccountKey is generated by ORBITER
+	AccountKey *secret.Secret `yaml:",omitempty"`
+}
+
+func (a *ACME) validate() error {
+	if a.Email == "" {
+		return errors.New("no acme email configured")
+	}
+
+	if len(a.Domains) == 0 {
+		return errors.New("at least one acme domain is needed")
+	}
+
+	if a.Challenge != HTTP01 && a.Challenge != DNS01 {
+		return fmt.Errorf("acme challenge %s is not supported, choose %s or %s", a.Challenge, HTTP01, DNS01)
+	}
+	return nil
+}
+
+func (a *ACME) directory() string {
+	if a.Directory == "" {
+		return letsEncryptDirectory
+	}
+	return a.Directory
+}
+
+var (
+	// acmeAttempts limits the orders per transport, so failing challenges don't exceed the acme servers rate limits
+	acmeAttempts    = make(map[string]time.Time)
+	acmeAttemptsMux sync.Mutex
+)
+
+// ensureCertificates obtains the certificates of all transports that have no certificate or a certificate that expires soon.
+// As only ORBITER orders certificates, only one request per certificate is sent to the acme server.
+// Failing orders don't block the node agents, as NGINX needs to be configured before it can serve http-01 challenges.
+func ensureCertificates(monitor mntr.Monitor, desired *Desired, svc core.MachinesService, pdf func(mntr.Monitor) error) error {
+
+	var obtained bool
+	for pool, vips := range desired.Spec {
+		for _, vip := range vips {
+			for _, transport := range vip.Transport {
+				if transport.TLS == nil || transport.TLS.ACME == nil || !dueForRenewal(transport.TLS) {
+					continue
+				}
+
+				acmeAttemptsMux.Lock()
+				lastAttempt, attempted := acmeAttempts[transport.Name]
+				if attempted && time.Since(lastAttempt) < acmeRetryInterval {
+					acmeAttemptsMux.Unlock()
+					continue
+				}
+				acmeAttempts[transport.Name] = time.Now()
+				acmeAttemptsMux.Unlock()
+
+				transportMonitor := monitor.WithFields(map[string]interface{}{
+					"transport": transport.Name,
+					"domains":   strings.Join(transport.TLS.ACME.Domains, ","),
+				})
+
+				solve := solveDNS01
+				if transport.TLS.ACME.Challenge == HTTP01 {
+					machines, err := svc.List(pool)
+					if err != nil {
+						return err
+					}
+					solve = solveHTTP01(machines)
+				}
+
+				if err := obtainCertificate(transportMonitor, transport.TLS, solve); err != nil {
+					transportMonitor.Error(fmt.Errorf("obtaining certificate failed, retrying in %s: %w", acmeRetryInterval, err))
+					continue
+				}
+				transportMonitor.Changed("Certificate obtained")
+				obtained = true
+			}
+		}
+	}
+
+	if !obtained {
+		return nil
+	}
+	return pdf(monitor.WithField("type", "certificates"))
+}
+
+func dueForRenewal(tls *TLS) bool {
+	if tls.ready() != nil {
+		return true
+	}
+	notAfter, err := certificateNotAfter([]byte(tls.Certificate.Value))
+	return err != nil || time.Until(notAfter) < renewBefore
+}
+
+func certificateNotAfter(data []byte) (time.Time, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, errors.New("no PEM data found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cert.NotAfter, nil
+}
+
+// solveFunc prepares the challenge and returns a function that cleans it up
+type solveFunc func(ctx context.Context, client *acme.Client, spec *ACME, domain, token string) (func(), error)
+
+func obtainCertificate(monitor mntr.Monitor, tls *TLS, solve solveFunc) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), acmeTimeout)
+	defer cancel()
+
+	spec := tls.ACME
+	accountKey, err := spec.accountKey()
+	if err != nil {
+		return err
+	}
+
+	client := &acme.Client{Key: accountKey, DirectoryURL: spec.directory()}
+	if _, err := client.Register(ctx, &acme.Account{Contact: []string{"mailto:" + spec.Email}}, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("registering acme account failed: %w", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(spec.Domains...))
+	if err != nil {
+		return err
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+
+		var challenge *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == spec.Challenge {
+				challenge = c
+			}
+		}
+		if challenge == nil {
+			return fmt.Errorf("acme server offers no %s challenge for %s", spec.Challenge, authz.Identifier.Value)
+		}
+
+		cleanup, err := solve(ctx, client, spec, authz.Identifier.Value, challenge.Token)
+		if err != nil {
+			return err
+		}
+		//noinspection GoDeferInLoop
+		defer cleanup()
+
+		monitor.WithField("domain", authz.Identifier.Value).Info("Accepting acme challenge")
+		if _, err := client.Accept(ctx, challenge); err != nil {
+			return err
+		}
+		if _, err := client.WaitAuthorization(ctx, authzURL); err != nil {
+			return err
+		}
+	}
+
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return err
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: spec.Domains[0]},
+		DNSNames: spec.Domains,
+	}, certKey)
+	if err != nil {
+		return err
+	}
+
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return err
+	}
+
+	certPEM := new(strings.Builder)
+	for _, der := range chain {
+		if err := pem.Encode(certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+			return err
+		}
+	}
+
+	keyPEM, err := encodeECKey(certKey)
+	if err != nil {
+		return err
+	}
+
+	tls.Certificate.Value = certPEM.String()
+	tls.Key.Value = keyPEM
+	return nil
+}
+
+// accountKey parses the account key or generates one, which is persisted together with the obtained certificate
+func (a *ACME) accountKey() (crypto.Signer, error) {
+	if a.AccountKey.Value != "" {
+		block, _ := pem.Decode([]byte(a.AccountKey.Value))
+		if block == nil {
+			return nil, errors.New("no PEM data found in acme account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	a.AccountKey.Value, err = encodeECKey(key)
+	return key, err
+}
+
+func encodeECKey(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+// solveHTTP01 writes the key authorization to all machines of the pool, where NGINX serves it
+func solveHTTP01(machines infra.Machines) solveFunc {
+	return func(_ context.Context, client *acme.Client, _ *ACME, _, token string) (func(), error) {
+		keyAuth, err := client.HTTP01ChallengeResponse(token)
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(acmeChallengeDir, client.HTTP01ChallengePath(token))
+		for _, machine := range machines {
+			if err := machine.WriteFile(path, strings.NewReader(keyAuth), 644); err != nil {
+				return nil, err
+			}
+		}
+		return func() {
+			for _, machine := range machines {
+				machine.Execute(nil, "sudo rm -f "+path)
+			}
+		}, nil
+	}
+}
+
+// solveDNS01 creates the TXT record using the Cloudflare API
+func solveDNS01(ctx context.Context, client *acme.Client, spec *ACME, domain, token string) (func(), error) {
+	if spec.CloudflareAPIToken == nil || spec.CloudflareAPIToken.Value == "" {
+		return nil, mntr.ToUserError(errors.New("dns-01 challenges need a cloudflare api token"))
+	}
+
+	api, err := cloudflare.NewWithAPIToken(spec.CloudflareAPIToken.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	domain = strings.TrimPrefix(domain, "*.")
+	zoneID, err := zoneID(api, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := client.DNS01ChallengeRecord(token)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := api.CreateDNSRecord(ctx, zoneID, cloudflare.DNSRecord{
+		Type:    "TXT",
+		Name:    "_acme-challenge." + domain,
+		Content: value,
+		TTL:     120,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cleanup := func() { api.DeleteDNSRecord(context.Background(), zoneID, record.Result.ID) }
+
+	select {
+	case <-ctx.Done():
+		cleanup()
+		return nil, ctx.Err()
+	case <-time.After(dnsPropagation):
+	}
+	return cleanup, nil
+}
+
+// zoneID finds the zone the domain belongs to
+func zoneID(api *cloudflare.API, domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for idx := 0; idx < len(labels)-1; idx++ {
+		if id, err := api.ZoneIDByName(strings.Join(labels[idx:], ".")); err == nil {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no cloudflare zone found for %s", domain)
+}
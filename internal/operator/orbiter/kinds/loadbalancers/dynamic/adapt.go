@@ -1,16 +1,15 @@
 package dynamic
 
 import (
-	"bytes"
 	"fmt"
 	"sort"
 	"strconv"
-	"strings"
 	"text/template"
 
 	"github.com/caos/orbos/internal/helpers"
 	"github.com/caos/orbos/internal/operator/common"
 	"github.com/caos/orbos/internal/operator/nodeagent/dep/sysctl"
+	"github.com/caos/orbos/internal/operator/nodeagent/metrics"
 	"github.com/caos/orbos/internal/operator/orbiter"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
@@ -21,10 +20,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-const (
-	nginxVersion      = "v1.18.0"
-	keepalivedVersion = "v1.3.5"
-)
+const keepalivedVersion = "v1.3.5"
 
 var probes = prometheus.NewGaugeVec(
 	prometheus.GaugeOpts{
@@ -127,12 +123,30 @@ func AdaptFunc(whitelist WhiteListFunc) orbiter.AdaptFunc {
 			enrichedVIPs := curryEnrichedVIPs(*desiredKind, poolMachines, wl, nodeAgentsCurrent)
 
 			current.Current.Spec = enrichedVIPs
-			current.Current.Desire = func(forPool string, svc core.MachinesService, vrrp *VRRP, mapVIP func(*VIP) string) (bool, error) {
+			current.Current.EnsureCertificates = func(svc core.MachinesService, pdf func(mntr.Monitor) error) error {
+				return ensureCertificates(monitor, desiredKind, svc, pdf)
+			}
+			// desire desires the load balancing node agent configs. In dryrun mode, it neither distributes certificates nor probes the load balancers
+			desire := func(dryrun bool, forPool string, svc core.MachinesService, vrrp *VRRP, mapVIP func(*VIP) string) (bool, error) {
 				var lbMachines infra.Machines
 
 				done := true
-				desireNodeAgent := func(machine infra.Machine, fw common.Firewall, nginx, keepalived common.Package) {
+				// enginePkgs contains a package for each engine the machine should run or not run, zero packages remove the engine.
+				// Nil keepalived and bird packages are not touched, zero packages remove them.
+				desireNodeAgent := func(machine infra.Machine, fw common.Firewall, enginePkgs map[Engine]common.Package, keepalived, bird *common.Package) {
 					machineMonitor := monitor.WithField("machine", machine.ID())
+					for _, pkg := range enginePkgs {
+						if !pkg.Equals(common.Package{}) {
+							// Let the orbs Prometheus scrape the rejected connections
+							fw.Merge(common.ToFirewall("internal", map[string]*common.Allowed{
+								"nodeagent-metrics": {
+									Port:     fmt.Sprintf("%d", metrics.Port),
+									Protocol: "tcp",
+								},
+							}))
+							break
+						}
+					}
 					deepNa, _ := nodeagents.Get(machine.ID())
 					deepNaCurr, _ := nodeAgentsCurrent.Get(machine.ID())
 
@@ -162,15 +176,27 @@ func AdaptFunc(whitelist WhiteListFunc) orbiter.AdaptFunc {
 						deepNa.Software = &common.Software{}
 					}
 
-					if !nginx.Equals(common.Package{}) {
-						if !deepNa.Software.Nginx.Equals(nginx) {
-							machineMonitor.WithField("pkg", nginx).Debug("NGINX desired")
+					var proxies bool
+					for _, engine := range engines {
+						pkg, ok := enginePkgs[engine]
+						if !ok {
+							continue
+						}
+						desired := engine.software(deepNa.Software)
+						if !desired.Equals(pkg) {
+							machineMonitor.WithField("pkg", pkg).Debug(fmt.Sprintf("%s desired", engine))
 						}
-						deepNa.Software.Nginx = nginx
-						if !deepNa.Software.Nginx.Equals(deepNaCurr.Software.Nginx) {
-							machineMonitor.Info("Awaiting NGINX")
+						*desired = pkg
+						if !desired.Equals(*engine.software(&deepNaCurr.Software)) {
+							machineMonitor.Info(fmt.Sprintf("Awaiting %s", engine))
 							done = false
 						}
+						if !pkg.Equals(common.Package{}) {
+							proxies = true
+						}
+					}
+
+					if proxies {
 						if !sysctl.Contains(deepNa.Software.Sysctl, common.Package{
 							Config: map[string]string{
 								string(common.IpForward):    "1",
@@ -187,20 +213,31 @@ func AdaptFunc(whitelist WhiteListFunc) orbiter.AdaptFunc {
 						}
 					}
 
-					if !keepalived.Equals(common.Package{}) {
-						if !deepNa.Software.KeepaliveD.Equals(keepalived) {
-							machineMonitor.WithField("pkg", keepalived).Debug("Keepalived desired")
+					if keepalived != nil {
+						if !deepNa.Software.KeepaliveD.Equals(*keepalived) {
+							machineMonitor.WithField("pkg", *keepalived).Debug("Keepalived desired")
 						}
-						deepNa.Software.KeepaliveD = keepalived
+						deepNa.Software.KeepaliveD = *keepalived
 						if !deepNa.Software.KeepaliveD.Equals(deepNaCurr.Software.KeepaliveD) {
 							monitor.Info("Awaiting keepalived")
 							done = false
 						}
 					}
+
+					if bird != nil {
+						if !deepNa.Software.Bird.Equals(*bird) {
+							machineMonitor.WithField("pkg", *bird).Debug("BIRD desired")
+						}
+						deepNa.Software.Bird = *bird
+						if !deepNa.Software.Bird.Equals(deepNaCurr.Software.Bird) {
+							machineMonitor.Info("Awaiting BIRD")
+							done = false
+						}
+					}
 				}
 
-				templateFuncs := template.FuncMap(map[string]interface{}{
-					"forMachines": func(poolName string) (infra.Machines, error) {
+				funcs := templateFuncs(
+					func(poolName string) (infra.Machines, error) {
 						machines, err := svc.List(poolName)
 						if err != nil {
 							return nil, err
@@ -208,62 +245,10 @@ func AdaptFunc(whitelist WhiteListFunc) orbiter.AdaptFunc {
 						sort.Sort(machines)
 						return machines, nil
 					},
-					"add": func(i, y int) int { return i + y },
-					"user": func(machine infra.Machine) (string, error) {
-						var user string
-						whoami := "whoami"
-						stdout, err := machine.Execute(nil, whoami)
-						if err != nil {
-							return "", fmt.Errorf("running command %s remotely failed", whoami)
-						}
-						user = strings.TrimSuffix(string(stdout), "\n")
-						monitor.WithFields(map[string]interface{}{
-							"user":    user,
-							"machine": machine.ID(),
-							"command": whoami,
-						}).Debug("Executed command")
-						return user, nil
-					},
-					"vip": mapVIP,
-					"routerID": func(vip *VIP) string {
-						vipParts := strings.Split(mapVIP(vip), ".")
-						if len(vipParts) != 4 || vipParts[3] == "0" {
-							return "55"
-						}
-						return vipParts[3]
-					},
-					"derefBool": func(in *bool) bool { return in != nil && *in },
-				})
+					mapVIP,
+				)
 
-				var nginxNATTemplate *template.Template
-				var vips []*VIP
-
-				if vrrp == nil {
-					for _, desiredVIPs := range desiredKind.Spec {
-						vips = append(vips, desiredVIPs...)
-					}
-					nginxNATTemplate = template.Must(template.New("").Funcs(templateFuncs).Parse(`worker_rlimit_nofile 8192;
-
-events {
-	worker_connections  4096;  ## Default: 1024
-}
-stream { {{ range $nat := .NATs }}
-	upstream {{ $nat.Name }} {
-		server {{ $nat.To }};
-	}
-
-{{ range $from := $nat.From }}	server {
-		listen {{ $from }};
-
-{{ range $white := $nat.Whitelist }}		allow {{ $white }};
-{{ end }}
-		deny all;
-		proxy_pass {{ $nat.Name }};
-		proxy_protocol {{ if derefBool $nat.ProxyProtocol }}on{{ else }}off{{ end }};
-	}
-{{ end }}{{ end }}}`))
-
-				} else {
+				if vrrp != nil {
 
 					lbMachines = nil
 					if err := poolMachines(svc, func(pool string, machines infra.Machines) {
@@ -299,126 +284,61 @@ stream { {{ range $nat := .NATs }}
 						}
 					}
 
-					keepaliveDTemplate := template.Must(template.New("").Funcs(templateFuncs).Parse(`{{ $root := . }}global_defs {
-	enable_script_security
-	script_user {{ user $root.Self }}
-}
-
-vrrp_sync_group VG1 {
-	group {
-{{ range $idx, $_ := .VIPs }}        VI_{{ $idx }}
-{{ end }}    }
-}
-
-{{ range $idx, $vip := .VIPs }}vrrp_script chk_{{ vip $vip }} {
-	script       "/usr/local/bin/health --protocol http --ip 127.0.0.1 --port 29999 --path /ready --status 200"
-	interval 2   # check every 2 seconds
-	fall 5       # require 5 failures for KO
-	rise 5       # require 5 successes for OK
-	timeout 5    # time out after 5 seconds
-}
-
-vrrp_instance VI_{{ $idx }} {
-	state {{ $root.State }}
-	unicast_src_ip {{ $root.Self.IP }}
-	unicast_peer {
-		{{ range $peer := $root.Peers }}{{ $peer.IP }}
-		{{ end }}    }
-	interface {{ $root.VRRPInterface }}
-	virtual_router_id {{ routerID $vip }}
-	advert_int 1
-	authentication {
-		auth_type PASS
-		auth_pass [ REDACTED ]
-	}
-	track_script {
-		chk_{{ vip $vip }}
-	}
-
-	virtual_ipaddress {
-		{{ vip $vip }} dev {{ $root.VIPInterface }}
-	}
-
-{{ if $root.CustomMasterNotifyer }}	notify_master "/etc/keepalived/notifymaster.sh"
-{{ else }}	virtual_ipaddress {
-		{{ vip $vip }}
-	}
-{{ end }}
-}
-{{ end }}`))
-
-					nginxLBTemplate := template.Must(template.New("").Funcs(templateFuncs).Parse(`{{ $root := . }}worker_rlimit_nofile 8192;
-
-events {
-	worker_connections  4096;  ## Default: 1024
-}
-
-stream { {{ range $vip := .VIPs }}{{ range $src := $vip.Transport }}
-	upstream {{ $src.Name }} {    {{ range $dest := $src.BackendPools }}{{ range $machine := forMachines $dest }}
-		server {{ $machine.IP }}:{{ $src.BackendPort }}; # {{ $dest }}{{end}}{{ end }}
-	}
-	server {
-		listen {{ vip $vip }}:{{ $src.FrontendPort }};
-{{ range $white := $src.Whitelist }}		allow {{ $white }};
-{{ end }}
-		deny all;
-		proxy_pass {{ $src.Name }};
-		proxy_protocol {{ if derefBool $src.ProxyProtocol }}on{{ else }}off{{ end }};
-	}
-{{ end }}{{ end }}}
-
-http {
-	server {
-		listen 29999;
-
-		location /ready {
-			return 200;
-		}
-	}
-}`))
-
 					for _, d := range lbData {
 
 						if len(d.VIPs) == 0 {
 							continue
 						}
 
-						ngxBuf := new(bytes.Buffer)
-						//noinspection GoDeferInLoop
-						defer ngxBuf.Reset()
-						kaBuf := new(bytes.Buffer)
-						defer kaBuf.Reset()
+						vrrpLB, bgpVIPs := d.splitBGP()
 
-						if err := keepaliveDTemplate.Execute(kaBuf, d); err != nil {
-							return false, err
-						}
-
-						kaPkg := common.Package{Version: keepalivedVersion, Config: map[string]string{"keepalived.conf": kaBuf.String()}}
-						kaBuf.Reset()
-
-						if d.CustomMasterNotifyer {
-							var enforceEnsuring bool
-							kaPkg.Config["notifymaster.sh"], enforceEnsuring = vrrp.NotifyMaster(d.Self)
-							if enforceEnsuring {
-								kaPkg.Config["reensure"] = "true"
+						var kaPkg common.Package
+						if len(vrrpLB.VIPs) > 0 {
+							kaPkg, err = desireKeepalived(funcs, vrrp, vrrpLB)
+							if err != nil {
+								return false, err
 							}
 						}
 
-						if vrrp.AuthCheck != nil {
-							authCheck, expectedExitCode := vrrp.AuthCheck(d.Self)
-							if authCheck != "" {
-								kaPkg.Config["authcheck.sh"] = authCheck
-								kaPkg.Config["authcheckexitcode"] = strconv.Itoa(expectedExitCode)
+						fw := make(map[string]*common.Allowed)
+						var birdPkg common.Package
+						if len(bgpVIPs) > 0 {
+							birdPkg, err = desireBird(funcs, d.Self, bgpVIPs)
+							if err != nil {
+								return false, err
+							}
+							fw[fmt.Sprintf("bgp-%d", bgpPort)] = &common.Allowed{
+								Port:     fmt.Sprintf("%d", bgpPort),
+								Protocol: "tcp",
 							}
 						}
 
-						if err := nginxLBTemplate.Execute(ngxBuf, d); err != nil {
+						enginePkgs, err := desireLB(funcs, d)
+						if err != nil {
 							return false, err
 						}
-						ngxPkg := desireNginx(ngxBuf.String())
-						ngxBuf.Reset()
 
-						desireNodeAgent(d.Self, common.ToFirewall("external", make(map[string]*common.Allowed)), ngxPkg, kaPkg)
+						for _, vip := range d.VIPs {
+							for _, transport := range vip.Transport {
+								if transport.TLS == nil {
+									continue
+								}
+								if transport.TLS.ACME != nil && transport.TLS.ACME.Challenge == HTTP01 {
+									fw["acme-http-01"] = &common.Allowed{
+										Port:     "80",
+										Protocol: "tcp",
+									}
+								}
+								if dryrun {
+									continue
+								}
+								if err := distributeCertificates(monitor, d.Self, transport.Name, transport.TLS); err != nil {
+									return false, err
+								}
+							}
+						}
+
+						desireNodeAgent(d.Self, common.ToFirewall("external", fw), enginePkgs, &kaPkg, &birdPkg)
 					}
 				}
 
@@ -439,7 +359,7 @@ http {
 							ip := mapVIP(vip)
 							var vipProbed bool
 							probeVIP := func() {
-								if vipProbed {
+								if vipProbed || dryrun {
 									return
 								}
 								probe("VIP", ip, uint16(transport.FrontendPort), false, transport.HealthChecks, *transport)
@@ -448,7 +368,7 @@ http {
 
 							if vrrp != nil && forPool == srcPool {
 								for _, machine := range lbMachines {
-									desireNodeAgent(machine, common.ToFirewall("external", srcFW), common.Package{}, common.Package{})
+									desireNodeAgent(machine, common.ToFirewall("external", srcFW), nil, nil, nil)
 								}
 								probeVIP()
 							}
@@ -468,8 +388,10 @@ http {
 
 								for idx := range destMachines {
 									machine := destMachines[idx]
-									desireNodeAgent(machine, common.ToFirewall("internal", destFW), common.Package{}, common.Package{})
-									probe("Upstream", machine.IP(), uint16(transport.BackendPort), *transport.ProxyProtocol, transport.HealthChecks, *transport)
+									desireNodeAgent(machine, common.ToFirewall("internal", destFW), nil, nil, nil)
+									if !dryrun {
+										probe("Upstream", machine.IP(), uint16(transport.BackendPort), *transport.ProxyProtocol, transport.HealthChecks, *transport)
+									}
 									if vrrp != nil || forPool != dest {
 										continue
 									}
@@ -491,6 +413,10 @@ http {
 										},
 										To:            fmt.Sprintf("%s:%d", machine.IP(), transport.BackendPort),
 										ProxyProtocol: *transport.ProxyProtocol,
+										Engine:        vip.Engine.orDefault(),
+										TLS:           transport.TLS,
+										Denylist:      transport.Denylist,
+										Limits:        transport.Limits,
 									})
 									nodesNats[machine.IP()] = nodeNatDesires
 								}
@@ -500,24 +426,30 @@ http {
 				}
 
 				for _, node := range nodesNats {
-					ngxBuf := new(bytes.Buffer)
-					//noinspection GoDeferInLoop
-					defer ngxBuf.Reset()
-					if err := nginxNATTemplate.Execute(ngxBuf, struct {
-						NATs []*NAT
-					}{
-						NATs: node.NATs,
-					}); err != nil {
+					enginePkgs, err := desireNATs(funcs, node.NATs)
+					if err != nil {
 						return false, err
 					}
-					ngxPkg := desireNginx(ngxBuf.String())
-					ngxBuf.Reset()
-					desireNodeAgent(node.Machine, node.Firewall, ngxPkg, common.Package{})
+					for _, nat := range node.NATs {
+						if nat.TLS == nil || dryrun {
+							continue
+						}
+						if err := distributeCertificates(monitor, node.Machine, nat.Name, nat.TLS); err != nil {
+							return false, err
+						}
+					}
+					desireNodeAgent(node.Machine, node.Firewall, enginePkgs, nil, nil)
 				}
 				return done, nil
 			}
+			current.Current.Desire = func(forPool string, svc core.MachinesService, vrrp *VRRP, mapVIP func(*VIP) string) (bool, error) {
+				return desire(false, forPool, svc, vrrp, mapVIP)
+			}
+			current.Current.Plan = func(forPool string, svc core.MachinesService, vrrp *VRRP, mapVIP func(*VIP) string) (bool, error) {
+				return desire(true, forPool, svc, vrrp, mapVIP)
+			}
 			return orbiter.NoopEnsure, nil
-		}, orbiter.NoopDestroy, orbiter.NoopConfigure, migrate, make(map[string]*secret.Secret, 0), nil
+		}, orbiter.NoopDestroy, orbiter.NoopConfigure, migrate, tlsSecrets(desiredKind), nil
 	}
 }
 
@@ -534,6 +466,9 @@ func addToWhitelists(makeUnique bool, vips []*VIP, cidr ...*orbiter.CIDR) []*VIP
 				Whitelist:     append(src.Whitelist, cidr...),
 				HealthChecks:  src.HealthChecks,
 				ProxyProtocol: src.ProxyProtocol,
+				TLS:           src.TLS,
+				Denylist:      src.Denylist,
+				Limits:        src.Limits,
 			}
 			if makeUnique {
 				newSource.Whitelist = unique(newSource.Whitelist)
@@ -543,6 +478,8 @@ func addToWhitelists(makeUnique bool, vips []*VIP, cidr ...*orbiter.CIDR) []*VIP
 		newVIPs[vipIdx] = &VIP{
 			IP:        vip.IP,
 			Transport: newTransport,
+			Engine:    vip.Engine,
+			BGP:       vip.BGP,
 		}
 	}
 	return newVIPs
@@ -575,6 +512,10 @@ type NAT struct {
 	From          []string
 	To            string
 	ProxyProtocol bool
+	Engine        Engine
+	TLS           *TLS
+	Denylist      []*orbiter.CIDR
+	Limits        *Limits
 }
 
 type LB struct {
@@ -678,12 +619,29 @@ func curryEnrichedVIPs(desired Desired, machines poolMachinesFunc, adaptWhitelis
 	}
 }
 
-func desireNginx(cfg string) common.Package {
-	return common.Package{
-		Version: nginxVersion,
-		Config: map[string]string{
-			"nginx.conf":                  cfg,
-			"Systemd[Service]LimitNOFILE": "8192",
-		},
+func desireKeepalived(funcs template.FuncMap, vrrp *VRRP, lb LB) (common.Package, error) {
+
+	kaCfg, err := renderKeepalived(funcs, lb)
+	if err != nil {
+		return common.Package{}, err
+	}
+
+	kaPkg := common.Package{Version: keepalivedVersion, Config: map[string]string{"keepalived.conf": kaCfg}}
+
+	if lb.CustomMasterNotifyer {
+		var enforceEnsuring bool
+		kaPkg.Config["notifymaster.sh"], enforceEnsuring = vrrp.NotifyMaster(lb.Self)
+		if enforceEnsuring {
+			kaPkg.Config["reensure"] = "true"
+		}
+	}
+
+	if vrrp.AuthCheck != nil {
+		authCheck, expectedExitCode := vrrp.AuthCheck(lb.Self)
+		if authCheck != "" {
+			kaPkg.Config["authcheck.sh"] = authCheck
+			kaPkg.Config["authcheckexitcode"] = strconv.Itoa(expectedExitCode)
+		}
 	}
+	return kaPkg, nil
 }
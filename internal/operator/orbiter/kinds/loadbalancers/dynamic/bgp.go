@@ -0,0 +1,89 @@
+package dynamic
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+)
+
+const bgpPort = 179
+
+// BGPSpeaker announces the VIPs from a machine
+type BGPSpeaker struct {
+	Self  infra.Machine
+	VIPs  []*VIP
+	Peers []*BGPNeighbor
+}
+
+// BGPNeighbor is a peer together with the VIPs that are announced to it
+type BGPNeighbor struct {
+	Name     string
+	IP       string
+	ASN      uint32
+	LocalASN uint32
+	VIPs     []*VIP
+}
+
+// splitBGP returns a copy of the LB which only contains the VIPs keepalived fails over and the VIPs BGP announces
+func (l LB) splitBGP() (LB, []*VIP) {
+	vrrpVIPs := make([]*VIP, 0)
+	var bgpVIPs []*VIP
+	for _, vip := range l.VIPs {
+		if vip.BGP != nil {
+			bgpVIPs = append(bgpVIPs, vip)
+			continue
+		}
+		vrrpVIPs = append(vrrpVIPs, vip)
+	}
+	l.VIPs = vrrpVIPs
+	return l, bgpVIPs
+}
+
+func newBGPSpeaker(self infra.Machine, vips []*VIP) (*BGPSpeaker, error) {
+	speaker := &BGPSpeaker{Self: self, VIPs: vips}
+	neighbors := make(map[string]*BGPNeighbor)
+	for _, vip := range vips {
+		for _, peer := range vip.BGP.Peers {
+			neighbor, ok := neighbors[peer.IP]
+			if !ok {
+				neighbor = &BGPNeighbor{
+					Name:     fmt.Sprintf("peer_%d", len(speaker.Peers)),
+					IP:       peer.IP,
+					ASN:      peer.ASN,
+					LocalASN: vip.BGP.LocalASN,
+				}
+				neighbors[peer.IP] = neighbor
+				speaker.Peers = append(speaker.Peers, neighbor)
+			}
+			if neighbor.ASN != peer.ASN || neighbor.LocalASN != vip.BGP.LocalASN {
+				return nil, fmt.Errorf("peer %s is configured with different asns", peer.IP)
+			}
+			neighbor.VIPs = append(neighbor.VIPs, vip)
+		}
+	}
+	return speaker, nil
+}
+
+func desireBird(funcs template.FuncMap, self infra.Machine, vips []*VIP) (common.Package, error) {
+	speaker, err := newBGPSpeaker(self, vips)
+	if err != nil {
+		return common.Package{}, err
+	}
+
+	birdCfg, err := render(funcs, "bird.tmpl", speaker)
+	if err != nil {
+		return common.Package{}, err
+	}
+
+	announce, err := render(funcs, "bgp-announce.tmpl", speaker)
+	if err != nil {
+		return common.Package{}, err
+	}
+
+	return common.Package{Config: map[string]string{
+		"bird.conf":   birdCfg,
+		"announce.sh": announce,
+	}}, nil
+}
@@ -0,0 +1,81 @@
+package dynamic
+
+import (
+	"testing"
+)
+
+func testBGPVIPs() []*VIP {
+	lb := testLB(HAProxy)
+	lb.VIPs[0].BGP = &BGP{
+		LocalASN:    65000,
+		Peers:       []*BGPPeer{{IP: "10.0.0.1", ASN: 65001}},
+		Communities: []string{"65000:100"},
+	}
+	return append(lb.VIPs, &VIP{
+		IP:        "10.0.0.5",
+		Transport: lb.VIPs[0].Transport,
+		BGP: &BGP{
+			LocalASN: 65000,
+			Peers:    []*BGPPeer{{IP: "10.0.0.1", ASN: 65001}, {IP: "10.0.1.1", ASN: 65002}},
+		},
+	})
+}
+
+func TestDesireBird(t *testing.T) {
+	pkg, err := desireBird(testFuncs(), testLB(NGINX).Self, testBGPVIPs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "bird", pkg.Config["bird.conf"])
+	assertGolden(t, "bgp-announce", pkg.Config["announce.sh"])
+}
+
+func TestNewBGPSpeaker_conflictingASNs(t *testing.T) {
+	vips := testBGPVIPs()
+	vips[1].BGP.Peers[0].ASN = 65003
+	if _, err := newBGPSpeaker(testLB(NGINX).Self, vips); err == nil {
+		t.Error("expected an error for a peer with different asns")
+	}
+}
+
+func TestLB_splitBGP(t *testing.T) {
+	lb := testLB(NGINX)
+	lb.VIPs = append(lb.VIPs, testBGPVIPs()...)
+	vrrpLB, bgpVIPs := lb.splitBGP()
+	if len(vrrpLB.VIPs) != 1 || vrrpLB.VIPs[0].BGP != nil {
+		t.Errorf("expected one vrrp vip, got %d", len(vrrpLB.VIPs))
+	}
+	if len(bgpVIPs) != 2 {
+		t.Errorf("expected two bgp vips, got %d", len(bgpVIPs))
+	}
+}
+
+func TestBGP_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		bgp     BGP
+		wantErr bool
+	}{{
+		name: "valid",
+		bgp:  BGP{LocalASN: 65000, Peers: []*BGPPeer{{IP: "10.0.0.1", ASN: 65001}}, Communities: []string{"65000:100"}},
+	}, {
+		name:    "no peers",
+		bgp:     BGP{LocalASN: 65000},
+		wantErr: true,
+	}, {
+		name:    "invalid peer ip",
+		bgp:     BGP{LocalASN: 65000, Peers: []*BGPPeer{{IP: "router", ASN: 65001}}},
+		wantErr: true,
+	}, {
+		name:    "invalid community",
+		bgp:     BGP{LocalASN: 65000, Peers: []*BGPPeer{{IP: "10.0.0.1", ASN: 65001}}, Communities: []string{"65000:100000"}},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.bgp.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
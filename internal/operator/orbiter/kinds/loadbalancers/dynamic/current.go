@@ -3,6 +3,7 @@ package dynamic
 import (
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/tree"
 )
 
@@ -16,5 +17,9 @@ type Current struct {
 	Current struct {
 		Spec   func(svc core.MachinesService) (map[string][]*VIP, []AuthCheckResult, error)
 		Desire func(pool string, svc core.MachinesService, vrrp *VRRP, vip func(*VIP) string) (bool, error)
+		// Plan desires the same node agent configs as Desire without touching any machine
+		Plan func(pool string, svc core.MachinesService, vrrp *VRRP, vip func(*VIP) string) (bool, error)
+		// EnsureCertificates obtains the certificates of transports with acme configured
+		EnsureCertificates func(svc core.MachinesService, pdf func(mntr.Monitor) error) error
 	} `yaml:"-"`
 }
@@ -5,6 +5,9 @@ package dynamic
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 
@@ -49,7 +52,7 @@ func (d *Desired) UnmarshalYAML(node *yaml.Node) (err error) {
 		d.Spec = v1tov2(v0tov1(v0)).Spec
 		return nil
 	}
-	return fmt.Errorf("version %s for kind %s is not supported", d.Common.Version, d.Common.Kind)
+	return fmt.Errorf("version %s for kind %s is not supported", d.Common.Version(), d.Common.Kind)
 }
 
 func (d *Desired) Validate() (err error) {
@@ -84,6 +87,10 @@ func (d *Desired) Validate() (err error) {
 type VIP struct {
 	IP        string `yaml:",omitempty"`
 	Transport []*Transport
+	// Engine proxies the VIPs traffic. It defaults to nginx.
+	Engine Engine `yaml:",omitempty"`
+	// BGP announces the VIP from all healthy machines instead of failing it over using VRRP
+	BGP *BGP `yaml:",omitempty"`
 }
 
 func (v *VIP) validate() (err error) {
@@ -96,15 +103,94 @@ func (v *VIP) validate() (err error) {
 		return fmt.Errorf("vip %s has no transport configured", v.IP)
 	}
 
+	if err := v.Engine.validate(); err != nil {
+		return fmt.Errorf("configuring vip %s failed: %w", v.IP, err)
+	}
+
+	if v.BGP != nil {
+		if err := v.BGP.validate(); err != nil {
+			return fmt.Errorf("configuring bgp for vip %s failed: %w", v.IP, err)
+		}
+	}
+
 	for _, source := range v.Transport {
 		if err := source.validate(); err != nil {
 			return fmt.Errorf("configuring sources for vip %s failed: %w", v.IP, err)
 		}
+		if err := v.Engine.supports(source.Limits); err != nil {
+			return fmt.Errorf("configuring limits of source %s failed: %w", source.Name, err)
+		}
+		if source.TLS == nil || source.TLS.ACME == nil || source.TLS.ACME.Challenge != HTTP01 {
+			continue
+		}
+		if v.Engine.orDefault() != NGINX {
+			return fmt.Errorf("the %s challenge of source %s is only supported by the engine %s", HTTP01, source.Name, NGINX)
+		}
+		for _, other := range v.Transport {
+			if other.FrontendPort == 80 {
+				return fmt.Errorf("the %s challenge of source %s needs port 80, but source %s listens on it", HTTP01, source.Name, other.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+type BGP struct {
+	LocalASN    uint32
+	Peers       []*BGPPeer
+	Communities []string `yaml:",omitempty"`
+}
+
+type BGPPeer struct {
+	IP  string
+	ASN uint32
+}
+
+func (b *BGP) validate() error {
+
+	if b.LocalASN == 0 {
+		return errors.New("no local asn configured")
+	}
+
+	if len(b.Peers) == 0 {
+		return errors.New("at least one peer is needed")
+	}
+
+	for _, peer := range b.Peers {
+		if net.ParseIP(peer.IP) == nil {
+			return fmt.Errorf("peer ip %s is invalid", peer.IP)
+		}
+		if peer.ASN == 0 {
+			return fmt.Errorf("peer %s has no asn configured", peer.IP)
+		}
 	}
 
+	for _, community := range b.Communities {
+		if _, _, err := parseCommunity(community); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// parseCommunity parses standard communities in the form asn:value
+func parseCommunity(community string) (uint16, uint16, error) {
+	parts := strings.Split(community, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("community %s is not in the form asn:value", community)
+	}
+	asn, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("community %s has an invalid asn: %w", community, err)
+	}
+	value, err := strconv.ParseUint(parts[1], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("community %s has an invalid value: %w", community, err)
+	}
+	return uint16(asn), uint16(value), nil
+}
+
 type HealthChecks struct {
 	Protocol string
 	Path     string
@@ -148,6 +234,18 @@ func (s *Transport) validate() (err error) {
 		}
 	}
 
+	for _, cidr := range s.Denylist {
+		if err := cidr.Validate(); err != nil {
+			return fmt.Errorf("configuring denylist failed: %w", err)
+		}
+	}
+
+	if s.Limits != nil {
+		if err := s.Limits.validate(); err != nil {
+			return fmt.Errorf("configuring limits failed: %w", err)
+		}
+	}
+
 	if len(s.BackendPools) < 1 {
 		return errors.New("at least one target pool is needed")
 	}
@@ -156,6 +254,12 @@ func (s *Transport) validate() (err error) {
 		return fmt.Errorf("configuring health checks failed: %w", err)
 	}
 
+	if s.TLS != nil {
+		if err := s.TLS.validate(); err != nil {
+			return fmt.Errorf("configuring tls failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -165,9 +269,30 @@ type Transport struct {
 	BackendPort  Port
 	BackendPools []string
 	Whitelist    []*orbiter.CIDR
+	// Denylist rejects connections from sources the whitelist allows
+	Denylist []*orbiter.CIDR `yaml:",omitempty"`
 	//	DownstreamProxies []*orbiter.IPAddress
 	HealthChecks  HealthChecks
 	ProxyProtocol *bool
+	TLS           *TLS    `yaml:",omitempty"`
+	Limits        *Limits `yaml:",omitempty"`
+}
+
+// Limits throttle the connections to a transport. Zero values are not limited.
+type Limits struct {
+	// MaxConnections limits the concurrent connections to the transport
+	MaxConnections uint32 `yaml:",omitempty"`
+	// MaxConnectionsPerSource limits the concurrent connections per source IP
+	MaxConnectionsPerSource uint32 `yaml:",omitempty"`
+	// ConnectionRatePerSource limits the new connections per source IP and second
+	ConnectionRatePerSource uint32 `yaml:",omitempty"`
+}
+
+func (l *Limits) validate() error {
+	if l.MaxConnections > 0 && l.MaxConnectionsPerSource > l.MaxConnections {
+		return fmt.Errorf("maxconnectionspersource %d exceeds maxconnections %d", l.MaxConnectionsPerSource, l.MaxConnections)
+	}
+	return nil
 }
 
 type Port uint16
@@ -31,7 +31,7 @@ func TestMigration(t *testing.T) {
 		check            func(*Desired) error
 		wantUnmarshalErr bool
 	}{{
-		name:    "Whitelists should be migrated from v0 to v1",
+		name:    "Whitelists should be migrated from v0 to v2",
 		version: "v0",
 		spec: map[string][]map[string]interface{}{
 			"pool": {{
@@ -52,7 +52,7 @@ func TestMigration(t *testing.T) {
 			}},
 		},
 		check: func(desired *Desired) error {
-			if desired.Common.Version != "v1" {
+			if desired.Common.Version() != "v2" {
 				return errors.New("Version not incremented")
 			}
 			if len(desired.Spec["pool"][0].Transport[0].Whitelist) != 2 {
@@ -61,15 +61,15 @@ func TestMigration(t *testing.T) {
 			if len(desired.Spec["pool"][0].Transport[1].Whitelist) != 2 {
 				return errors.New("Whitelist not correctly moved")
 			}
-			if len(desired.Spec["pool"][1].Transport[0].Whitelist) != 3 {
-				return errors.New("Whitelist not correctly moved")
+			if len(desired.Spec["pool"][1].Transport[0].Whitelist) != 0 {
+				return errors.New("Whitelist of a further VIP moved")
 			}
 			return nil
 		},
 		wantUnmarshalErr: false,
 	}, {
-		name:             "V2 should not be supported",
-		version:          "v2",
+		name:             "V3 should not be supported",
+		version:          "v3",
 		spec:             map[string][]*interface{}{},
 		check:            func(desired *Desired) error { return nil },
 		wantUnmarshalErr: true,
@@ -82,7 +82,7 @@ func TestMigration(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			d := &Desired{Common: &tree.Common{Version: tt.version}}
+			d := &Desired{Common: tree.NewCommon("orbiter.caos.ch/DynamicLoadBalancer", tt.version, false)}
 			if unmarshalErr := yaml.Unmarshal(template, d); (unmarshalErr != nil) != tt.wantUnmarshalErr {
 				t.Errorf("%s\nyaml.Unmarshal() error = %v, wantUnmarshalErr %v", string(template), unmarshalErr, tt.wantUnmarshalErr)
 			}
@@ -0,0 +1,259 @@
+package dynamic
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"net"
+	"strings"
+	"text/template"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/envoy"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/haproxy"
+	"github.com/caos/orbos/internal/operator/nodeagent/dep/nginx"
+	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+)
+
+// Engine is the software which proxies the traffic of a VIP
+type Engine string
+
+const (
+	NGINX   Engine = "nginx"
+	HAProxy Engine = "haproxy"
+	Envoy   Engine = "envoy"
+
+	nginxVersion = "v1.18.0"
+	envoyVersion = "v1.19.1"
+)
+
+var engines = []Engine{NGINX, HAProxy, Envoy}
+
+//go:embed templates
+var templates embed.FS
+
+func (e Engine) orDefault() Engine {
+	if e == "" {
+		return NGINX
+	}
+	return e
+}
+
+func (e Engine) validate() error {
+	for _, engine := range engines {
+		if e.orDefault() == engine {
+			return nil
+		}
+	}
+	return fmt.Errorf("engine %s is not supported, choose one of %v", e, engines)
+}
+
+// supports returns an error if the engine can't enforce the limits
+func (e Engine) supports(limits *Limits) error {
+	if limits == nil {
+		return nil
+	}
+	switch e.orDefault() {
+	case NGINX:
+		if limits.ConnectionRatePerSource > 0 {
+			return fmt.Errorf("engine %s doesn't support connectionratepersource, choose %s", NGINX, HAProxy)
+		}
+	case Envoy:
+		if limits.MaxConnectionsPerSource > 0 || limits.ConnectionRatePerSource > 0 {
+			return fmt.Errorf("engine %s only supports maxconnections, choose %s", Envoy, HAProxy)
+		}
+	}
+	return nil
+}
+
+// readyPort is where keepalived checks if the engine is ready
+func (e Engine) readyPort() uint16 {
+	switch e.orDefault() {
+	case HAProxy:
+		return 29998
+	case Envoy:
+		return 29997
+	}
+	return 29999
+}
+
+func (e Engine) desire(cfg string) common.Package {
+	switch e.orDefault() {
+	case HAProxy:
+		// HAProxy is installed from the operating systems repositories, so the version is not managed
+		return common.Package{Config: map[string]string{"haproxy.cfg": cfg}}
+	case Envoy:
+		return common.Package{Version: envoyVersion, Config: map[string]string{"envoy.yaml": cfg}}
+	}
+	return common.Package{
+		Version: nginxVersion,
+		Config: map[string]string{
+			"nginx.conf":                  cfg,
+			"Systemd[Service]LimitNOFILE": "8192",
+		},
+	}
+}
+
+func (e Engine) software(sw *common.Software) *common.Package {
+	switch e.orDefault() {
+	case HAProxy:
+		return &sw.HAProxy
+	case Envoy:
+		return &sw.Envoy
+	}
+	return &sw.Nginx
+}
+
+func (e Engine) renderLB(funcs template.FuncMap, lb LB) (string, error) {
+	return render(funcs, fmt.Sprintf("%s-lb.tmpl", e.orDefault()), lb)
+}
+
+func (e Engine) renderNAT(funcs template.FuncMap, nats []*NAT) (string, error) {
+	return render(funcs, fmt.Sprintf("%s-nat.tmpl", e.orDefault()), struct {
+		NATs []*NAT
+	}{
+		NATs: nats,
+	})
+}
+
+func renderKeepalived(funcs template.FuncMap, lb LB) (string, error) {
+	return render(funcs, "keepalived.tmpl", lb)
+}
+
+func render(funcs template.FuncMap, name string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).ParseFS(templates, "templates/"+name)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	defer buf.Reset()
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("rendering %s failed: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// forEngine returns a copy of the LB which only contains the VIPs the engine proxies
+func (l LB) forEngine(engine Engine) LB {
+	vips := make([]*VIP, 0)
+	for _, vip := range l.VIPs {
+		if vip.Engine.orDefault() == engine {
+			vips = append(vips, vip)
+		}
+	}
+	l.VIPs = vips
+	return l
+}
+
+func templateFuncs(
+	forMachines func(pool string) (infra.Machines, error),
+	mapVIP func(*VIP) string,
+) template.FuncMap {
+	return template.FuncMap(map[string]interface{}{
+		"forMachines": forMachines,
+		"add":         func(i, y int) int { return i + y },
+		"user":        func(machine infra.Machine) string { return machine.RemoteUser() },
+		"vip":         mapVIP,
+		"routerID": func(vip *VIP) string {
+			vipParts := strings.Split(mapVIP(vip), ".")
+			if len(vipParts) != 4 || vipParts[3] == "0" {
+				return "55"
+			}
+			return vipParts[3]
+		},
+		"derefBool":     func(in *bool) bool { return in != nil && *in },
+		"readyPort":     func(vip *VIP) uint16 { return vip.Engine.readyPort() },
+		"haproxySocket": func() string { return haproxy.SocketPath },
+		"envoyAdmin":    func() string { return envoy.AdminAddress },
+		"nginxRejected": func() string { return nginx.RejectedSyslog },
+		"cidrIP": func(cidr *orbiter.CIDR) (string, error) {
+			ip, _, err := net.ParseCIDR(string(*cidr))
+			if err != nil {
+				return "", err
+			}
+			return ip.String(), nil
+		},
+		"cidrBits": func(cidr *orbiter.CIDR) (int, error) {
+			_, ipNet, err := net.ParseCIDR(string(*cidr))
+			if err != nil {
+				return 0, err
+			}
+			ones, _ := ipNet.Mask.Size()
+			return ones, nil
+		},
+		"host": func(hostPort string) (string, error) {
+			host, _, err := net.SplitHostPort(hostPort)
+			return host, err
+		},
+		"port": func(hostPort string) (string, error) {
+			_, port, err := net.SplitHostPort(hostPort)
+			return port, err
+		},
+		"community": func(community string) (string, error) {
+			asn, value, err := parseCommunity(community)
+			return fmt.Sprintf("(%d,%d)", asn, value), err
+		},
+		"transports": func(vip *VIP) []*Transport {
+			transports := make([]*Transport, 0)
+			for _, transport := range vip.Transport {
+				if !transport.TLS.pending() {
+					transports = append(transports, transport)
+				}
+			}
+			return transports
+		},
+		"acmeHTTP01": func(vip *VIP) bool {
+			for _, transport := range vip.Transport {
+				if transport.TLS != nil && transport.TLS.ACME != nil && transport.TLS.ACME.Challenge == HTTP01 {
+					return true
+				}
+			}
+			return false
+		},
+		"acmeChallengeDir": func() string { return acmeChallengeDir },
+		"tlsFile":          tlsFile,
+		"fingerprint":      func(tls *TLS) string { return tls.fingerprint() },
+	})
+}
+
+// desireLB renders the configurations of all engines the LBs VIPs use and zero packages for all other engines
+func desireLB(funcs template.FuncMap, lb LB) (map[Engine]common.Package, error) {
+	pkgs := make(map[Engine]common.Package)
+	for _, engine := range engines {
+		engineLB := lb.forEngine(engine)
+		if len(engineLB.VIPs) == 0 {
+			pkgs[engine] = common.Package{}
+			continue
+		}
+		cfg, err := engine.renderLB(funcs, engineLB)
+		if err != nil {
+			return nil, err
+		}
+		pkgs[engine] = engine.desire(cfg)
+	}
+	return pkgs, nil
+}
+
+// desireNATs renders the configurations of all engines the NATs use and zero packages for all other engines
+func desireNATs(funcs template.FuncMap, nats []*NAT) (map[Engine]common.Package, error) {
+	pkgs := make(map[Engine]common.Package)
+	for _, engine := range engines {
+		var engineNATs []*NAT
+		for _, nat := range nats {
+			if nat.Engine.orDefault() == engine && !nat.TLS.pending() {
+				engineNATs = append(engineNATs, nat)
+			}
+		}
+		if len(engineNATs) == 0 {
+			pkgs[engine] = common.Package{}
+			continue
+		}
+		cfg, err := engine.renderNAT(funcs, engineNATs)
+		if err != nil {
+			return nil, err
+		}
+		pkgs[engine] = engine.desire(cfg)
+	}
+	return pkgs, nil
+}
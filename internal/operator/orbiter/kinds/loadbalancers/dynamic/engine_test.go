@@ -0,0 +1,205 @@
+package dynamic
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+type testMachine struct {
+	id string
+	ip string
+}
+
+func (t *testMachine) ID() string { return t.id }
+func (t *testMachine) IP() string { return t.ip }
+func (t *testMachine) Destroy() (func() error, error) {
+	return func() error { return nil }, nil
+}
+func (t *testMachine) Execute(io.Reader, string) ([]byte, error) { return nil, nil }
+func (t *testMachine) RemoteUser() string                        { return "orbiter" }
+func (t *testMachine) Shell() error                              { return nil }
+func (t *testMachine) WriteFile(string, io.Reader, uint16) error { return nil }
+func (t *testMachine) ReadFile(string, io.Writer) error          { return nil }
+func (t *testMachine) RebootRequired() (bool, func(), func()) {
+	return false, func() {}, func() {}
+}
+func (t *testMachine) ReplacementRequired() (bool, func(), func()) {
+	return false, func() {}, func() {}
+}
+
+func testFuncs() template.FuncMap {
+	return templateFuncs(
+		func(string) (infra.Machines, error) {
+			return infra.Machines{
+				&testMachine{id: "worker-0", ip: "10.0.0.10"},
+				&testMachine{id: "worker-1", ip: "10.0.0.11"},
+			}, nil
+		},
+		func(vip *VIP) string { return vip.IP },
+	)
+}
+
+func testLB(engine Engine) LB {
+	all := orbiter.CIDR("0.0.0.0/0")
+	internal := orbiter.CIDR("10.0.0.0/8")
+	trueVal, falseVal := true, false
+	return LB{
+		VIPs: []*VIP{{
+			IP:     "10.0.0.2",
+			Engine: engine,
+			Transport: []*Transport{{
+				Name:          "kubeapi",
+				FrontendPort:  6443,
+				BackendPort:   6666,
+				BackendPools:  []string{"controlplane"},
+				Whitelist:     []*orbiter.CIDR{&internal},
+				ProxyProtocol: &falseVal,
+			}, {
+				Name:          "httpsingress",
+				FrontendPort:  443,
+				BackendPort:   30443,
+				BackendPools:  []string{"workers"},
+				Whitelist:     []*orbiter.CIDR{&all},
+				ProxyProtocol: &trueVal,
+			}},
+		}},
+		State:         "MASTER",
+		Self:          &testMachine{id: "lb-0", ip: "10.0.0.3"},
+		Peers:         []infra.Machine{&testMachine{id: "lb-1", ip: "10.0.0.4"}},
+		VRRPInterface: "eth0",
+		VIPInterface:  "eth0",
+	}
+}
+
+func testNATs() []*NAT {
+	all := orbiter.CIDR("0.0.0.0/0")
+	return []*NAT{{
+		Name:          "httpsingress",
+		Whitelist:     []*orbiter.CIDR{&all},
+		From:          []string{"10.0.0.2:443", "10.0.0.10:443"},
+		To:            "10.0.0.10:30443",
+		ProxyProtocol: true,
+	}}
+}
+
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("rendered %s differs from %s, run go test with -update to accept the changes\n%s", name, golden, got)
+	}
+}
+
+func TestEngine_renderLB(t *testing.T) {
+	for _, engine := range engines {
+		t.Run(string(engine), func(t *testing.T) {
+			got, err := engine.renderLB(testFuncs(), testLB(engine))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, fmt.Sprintf("%s-lb", engine), got)
+		})
+	}
+}
+
+func TestEngine_renderNAT(t *testing.T) {
+	for _, engine := range engines {
+		t.Run(string(engine), func(t *testing.T) {
+			got, err := engine.renderNAT(testFuncs(), testNATs())
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, fmt.Sprintf("%s-nat", engine), got)
+		})
+	}
+}
+
+func TestRenderKeepalived(t *testing.T) {
+	for _, engine := range engines {
+		t.Run(string(engine), func(t *testing.T) {
+			got, err := renderKeepalived(testFuncs(), testLB(engine))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, fmt.Sprintf("keepalived-%s", engine), got)
+		})
+	}
+}
+
+func TestDesireLB(t *testing.T) {
+	lb := testLB(HAProxy)
+	lb.VIPs = append(lb.VIPs, &VIP{IP: "10.0.0.5", Transport: lb.VIPs[0].Transport})
+
+	pkgs, err := desireLB(testFuncs(), lb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pkgs[NGINX].Config["nginx.conf"]; !ok {
+		t.Error("expected nginx to be desired for the VIP without an engine")
+	}
+	if _, ok := pkgs[HAProxy].Config["haproxy.cfg"]; !ok {
+		t.Error("expected haproxy to be desired")
+	}
+	if pkgs[Envoy].Config != nil || pkgs[Envoy].Version != "" {
+		t.Error("expected envoy to be removed")
+	}
+}
+
+func testLimitsLB(engine Engine) LB {
+	lb := testLB(engine)
+	blocked := orbiter.CIDR("192.168.0.0/16")
+	kubeapi := lb.VIPs[0].Transport[0]
+	kubeapi.Denylist = []*orbiter.CIDR{&blocked}
+	kubeapi.Limits = &Limits{MaxConnections: 1000}
+	if engine != Envoy {
+		kubeapi.Limits.MaxConnectionsPerSource = 20
+	}
+	if engine == HAProxy {
+		kubeapi.Limits.ConnectionRatePerSource = 10
+	}
+	return lb
+}
+
+func TestEngine_renderLB_Limits(t *testing.T) {
+	for _, engine := range engines {
+		t.Run(string(engine), func(t *testing.T) {
+			lb := testLimitsLB(engine)
+			if err := engine.supports(lb.VIPs[0].Transport[0].Limits); err != nil {
+				t.Fatal(err)
+			}
+			got, err := engine.renderLB(testFuncs(), lb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, fmt.Sprintf("%s-lb-limits", engine), got)
+		})
+	}
+}
+
+func TestEngine_supports(t *testing.T) {
+	limits := &Limits{MaxConnections: 1000, MaxConnectionsPerSource: 20, ConnectionRatePerSource: 10}
+	for engine, wantErr := range map[Engine]bool{NGINX: true, HAProxy: false, Envoy: true} {
+		if err := engine.supports(limits); (err != nil) != wantErr {
+			t.Errorf("%s.supports() error = %v, wantErr %t", engine, err, wantErr)
+		}
+	}
+}
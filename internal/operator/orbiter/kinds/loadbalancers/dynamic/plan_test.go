@@ -0,0 +1,113 @@
+package dynamic
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+// writeRecordingMachine fails the test if anything is written to or executed on it
+type writeRecordingMachine struct {
+	testMachine
+	t *testing.T
+}
+
+func (w *writeRecordingMachine) WriteFile(path string, _ io.Reader, _ uint16) error {
+	w.t.Errorf("planning wrote %s to machine %s", path, w.id)
+	return nil
+}
+
+func (w *writeRecordingMachine) Execute(_ io.Reader, cmd string) ([]byte, error) {
+	w.t.Errorf("planning executed %s on machine %s", cmd, w.id)
+	return nil, nil
+}
+
+type testMachinesService map[string]infra.Machines
+
+func (t testMachinesService) ListPools() ([]string, error) {
+	pools := make([]string, 0, len(t))
+	for pool := range t {
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+func (t testMachinesService) List(pool string) (infra.Machines, error) { return t[pool], nil }
+func (t testMachinesService) Create(string, int) (infra.Machines, error) {
+	panic("planning must not create machines")
+}
+func (t testMachinesService) DesiredMachines(_ string, instances int) int { return instances }
+
+func TestCurrent_Plan(t *testing.T) {
+
+	desiredTree := &tree.Tree{}
+	if err := yaml.Unmarshal([]byte(`kind: orbiter.caos.ch/DynamicLoadBalancer
+version: v2
+spec:
+  lb:
+  - ip: 10.0.0.2
+    transport:
+    - name: https
+      frontendport: 443
+      backendport: 30443
+      backendpools:
+      - workers
+      whitelist:
+      - 0.0.0.0/0
+      healthchecks:
+        protocol: http
+        path: /healthz
+        code: 200
+      proxyprotocol: true
+`), desiredTree); err != nil {
+		t.Fatal(err)
+	}
+
+	currentTree := &tree.Tree{}
+	query, _, _, _, _, err := AdaptFunc(func() []*orbiter.CIDR { return nil })(mntr.Monitor{}, make(chan struct{}), desiredTree, currentTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desiredTree.Parsed.(*Desired).Spec["lb"][0].Transport[0].TLS = testCertificate(t, time.Now().Add(90*24*time.Hour))
+
+	current := &common.CurrentNodeAgents{}
+	desired := &common.DesiredNodeAgents{}
+	if _, err := query(current, desired, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := testMachinesService{
+		"lb": infra.Machines{
+			&writeRecordingMachine{testMachine: testMachine{id: "lb-0", ip: "10.0.0.10"}, t: t},
+			&writeRecordingMachine{testMachine: testMachine{id: "lb-1", ip: "10.0.0.11"}, t: t},
+		},
+		"workers": infra.Machines{
+			&writeRecordingMachine{testMachine: testMachine{id: "worker-0", ip: "10.0.0.20"}, t: t},
+		},
+	}
+
+	plan := currentTree.Parsed.(*Current).Current.Plan
+	for _, pool := range []string{"lb", "workers"} {
+		if _, err := plan(pool, svc, &VRRP{VRRPInterface: "eth0", VIPInterface: "eth0"}, func(vip *VIP) string { return vip.IP }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"lb-0", "lb-1"} {
+		na, _ := desired.Get(id)
+		if na.Software.KeepaliveD.Config == nil || na.Software.Nginx.Config == nil {
+			t.Errorf("expected keepalived and nginx to be desired on %s, but got %+v", id, na.Software)
+		}
+	}
+	worker, _ := desired.Get("worker-0")
+	if !worker.Firewall.Contains(common.ToFirewall("internal", map[string]*common.Allowed{"https-30443-dest": {Port: "30443", Protocol: "tcp"}})) {
+		t.Errorf("expected the backend port to be opened on worker-0, but got %+v", worker.Firewall)
+	}
+}
@@ -0,0 +1,128 @@
+package dynamic
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+const tlsDir = "/etc/orbos/tls"
+
+// TLS lets the engine terminate TLS, so the backends receive plain TCP.
+// When ACME is configured, ORBITER writes the obtained certificate and key.
+type TLS struct {
+	Certificate *secret.Secret `yaml:",omitempty"`
+	Key         *secret.Secret `yaml:",omitempty"`
+	ACME        *ACME          `yaml:",omitempty"`
+}
+
+func (t *TLS) validate() error {
+	if t.ACME != nil {
+		return t.ACME.validate()
+	}
+	return nil
+}
+
+// ready returns an error if no certificate is available yet
+func (t *TLS) ready() error {
+	if t.Certificate == nil || t.Certificate.Value == "" || t.Key == nil || t.Key.Value == "" {
+		if t.ACME != nil {
+			return errors.New("no certificate obtained from the acme server yet")
+		}
+		return errors.New("no certificate and key configured")
+	}
+	return nil
+}
+
+// pending is true while ORBITER waits for the acme server, so the engines don't listen for the transport yet
+func (t *TLS) pending() bool {
+	return t != nil && t.ACME != nil && t.ready() != nil
+}
+
+func (t *TLS) fingerprint() string {
+	if t.Certificate == nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(t.Certificate.Value)))
+}
+
+func tlsFile(name, ext string) string {
+	return filepath.Join(tlsDir, fmt.Sprintf("%s.%s", name, ext))
+}
+
+func tlsSecrets(desired *Desired) map[string]*secret.Secret {
+	secrets := make(map[string]*secret.Secret)
+	for _, vips := range desired.Spec {
+		for _, vip := range vips {
+			for _, transport := range vip.Transport {
+				if transport.TLS == nil {
+					continue
+				}
+				transport.TLS.Certificate = secret.InitIfNil(transport.TLS.Certificate)
+				transport.TLS.Key = secret.InitIfNil(transport.TLS.Key)
+				secrets[transport.Name+"tlscertificate"] = transport.TLS.Certificate
+				secrets[transport.Name+"tlskey"] = transport.TLS.Key
+				if transport.TLS.ACME == nil {
+					continue
+				}
+				transport.TLS.ACME.AccountKey = secret.InitIfNil(transport.TLS.ACME.AccountKey)
+				secrets[transport.Name+"acmeaccountkey"] = transport.TLS.ACME.AccountKey
+				if transport.TLS.ACME.Challenge == DNS01 {
+					transport.TLS.ACME.CloudflareAPIToken = secret.InitIfNil(transport.TLS.ACME.CloudflareAPIToken)
+					secrets[transport.Name+"cloudflareapitoken"] = transport.TLS.ACME.CloudflareAPIToken
+				}
+			}
+		}
+	}
+	return secrets
+}
+
+var (
+	// distributed caches the certificate fingerprints written to the machines, so they are not written in every iteration
+	distributed    = make(map[string]string)
+	distributedMux sync.Mutex
+)
+
+// distributeCertificates writes the certificates directly to the machine, as the desired node agents state is not encrypted
+func distributeCertificates(monitor mntr.Monitor, machine infra.Machine, name string, tls *TLS) error {
+	if tls.pending() {
+		return nil
+	}
+
+	if err := tls.ready(); err != nil {
+		return mntr.ToUserError(fmt.Errorf("terminating tls for %s failed: %w", name, err))
+	}
+
+	key := machine.ID() + "/" + name
+	fingerprint := tls.fingerprint()
+
+	distributedMux.Lock()
+	defer distributedMux.Unlock()
+	if distributed[key] == fingerprint {
+		return nil
+	}
+
+	for ext, content := range map[string]string{
+		"crt": tls.Certificate.Value,
+		"key": tls.Key.Value,
+		// HAProxy expects the certificate and the key in one file
+		"pem": strings.TrimSuffix(tls.Certificate.Value, "\n") + "\n" + tls.Key.Value,
+	} {
+		if err := machine.WriteFile(tlsFile(name, ext), strings.NewReader(content), 600); err != nil {
+			return err
+		}
+	}
+	distributed[key] = fingerprint
+	monitor.WithFields(map[string]interface{}{
+		"machine":   machine.ID(),
+		"transport": name,
+	}).Changed("TLS certificate distributed")
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/mntr"
 )
 
 var _ core.MachinesService = (*CmpSvcLB)(nil)
@@ -24,7 +25,11 @@ func MachinesService(svc core.MachinesService, curr dynamic.Current, vrrp *dynam
 	}
 }
 
-func (i *CmpSvcLB) InitializeDesiredNodeAgents() (bool, error) {
+func (i *CmpSvcLB) InitializeDesiredNodeAgents(pdf func(mntr.Monitor) error) (bool, error) {
+	if err := i.dynamic.Current.EnsureCertificates(i, pdf); err != nil {
+		return false, err
+	}
+
 	pools, err := i.ListPools()
 	if err != nil {
 		return false, err
@@ -43,6 +48,32 @@ func (i *CmpSvcLB) InitializeDesiredNodeAgents() (bool, error) {
 	return done, nil
 }
 
+// PlanDesiredNodeAgents desires the load balancing node agent configs like InitializeDesiredNodeAgents does,
+// but it neither obtains nor distributes certificates and doesn't probe the load balancers
+func (i *CmpSvcLB) PlanDesiredNodeAgents() error {
+	pools, err := i.ListPools()
+	if err != nil {
+		return err
+	}
+
+	// Addresses which are allocated while ensuring are not known yet
+	vip := func(v *dynamic.VIP) (address string) {
+		defer func() {
+			if recover() != nil {
+				address = "<not allocated yet>"
+			}
+		}()
+		return i.vip(v)
+	}
+
+	for _, pool := range pools {
+		if _, err := i.dynamic.Current.Plan(pool, i, i.vrrp, vip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (i *CmpSvcLB) Create(poolName string, desiredInstances int) (infra.Machines, error) {
 	cmp, err := i.MachinesService.Create(poolName, desiredInstances)
 	if err != nil {
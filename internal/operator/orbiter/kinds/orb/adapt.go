@@ -145,6 +145,7 @@ func AdaptFunc(
 				clusterTree,
 				oneoff,
 				desiredKind.Spec.PProf,
+				desiredKind.Spec.MaintenanceWindows,
 				deployOrbiter,
 				clusterCurrent,
 				destroyProviders,
@@ -204,6 +205,12 @@ func AdaptFunc(
 						}
 					}()
 
+					if desiredKind.Spec.SelfUpdate != nil {
+						if err := desireNodeAgentUpdates(desiredKind.Spec.SelfUpdate, operatorLabels.Version(), orbiterCommit, nodeAgentsDesired); err != nil {
+							return orbiter.ToEnsureResult(false, fmt.Errorf("desiring node agent updates failed: %w", err))
+						}
+					}
+
 					done := true
 					for _, ensurer := range append(providerEnsurers, clusterEnsurers...) {
 						result := ensurer(psf)
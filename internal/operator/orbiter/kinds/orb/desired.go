@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/caos/orbos/internal/operator/orbiter/maintenance"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/tree"
 )
@@ -13,11 +14,22 @@ type DesiredV0 struct {
 	Spec   struct {
 		Verbose bool
 		PProf   bool
+		// SelfUpdate lets the node agents download and verify new releases themselves instead of being reinstalled over SSH
+		SelfUpdate *SelfUpdate `yaml:",omitempty"`
+		// MaintenanceWindows defer reboots, kubernetes upgrades and machine replacements. Pools can override them.
+		MaintenanceWindows maintenance.Windows `yaml:",omitempty"`
 	}
 	Clusters  map[string]*tree.Tree
 	Providers map[string]*tree.Tree
 }
 
+type SelfUpdate struct {
+	// Mirror serves the node agent binaries at <mirror>/<version>/nodeagent. It defaults to the GitHub releases.
+	Mirror string `yaml:",omitempty"`
+	// Rollback lets node agents update to an ORBITER version lower than the one they run, for example for reverting a faulty release
+	Rollback bool `yaml:",omitempty"`
+}
+
 func ParseDesiredV0(desiredTree *tree.Tree) (*DesiredV0, error) {
 	desiredKind := &DesiredV0{Common: desiredTree.Common}
 
@@ -40,6 +52,10 @@ func (d *DesiredV0) validate() (err error) {
 		return errors.New("no providers configured")
 	}
 
+	if err := d.Spec.MaintenanceWindows.Validate(); err != nil {
+		return fmt.Errorf("configuring maintenance windows failed: %w", err)
+	}
+
 	k8sKind := "orbiter.caos.ch/KubernetesCluster"
 	var k8s int
 	for _, cluster := range d.Clusters {
@@ -39,6 +39,7 @@ func ListMachines(operarorLabels *labels.Operator) MachinesFunc {
 				clusterTree,
 				true,
 				false,
+				nil,
 				false,
 				clusterCurrent,
 				nil,
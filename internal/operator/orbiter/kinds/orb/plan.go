@@ -0,0 +1,21 @@
+package orb
+
+import (
+	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+var _ orbiter.Planner = (*Current)(nil)
+
+func (c *Current) Plan(plan *orbiter.Plan) error {
+	for _, trees := range []map[string]*tree.Tree{c.Providers, c.Clusters} {
+		for _, t := range trees {
+			if planner, ok := t.Parsed.(orbiter.Planner); ok {
+				if err := planner.Plan(plan); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
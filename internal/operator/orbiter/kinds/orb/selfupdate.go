@@ -0,0 +1,49 @@
+package orb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/caos/orbos/internal/executables"
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+const githubReleases = "https://github.com/caos/orbos/releases/download"
+
+var (
+	// nodeAgentUpdate is cached, as checksumming the node agent binary is only needed when ORBITER changes
+	nodeAgentUpdate    *common.NodeAgentUpdate
+	nodeAgentUpdateMux sync.Mutex
+)
+
+// desireNodeAgentUpdates points all node agents to the release of the binary ORBITER would otherwise install over SSH.
+// The signature is made by the release pipeline, so only release builds can desire updates.
+func desireNodeAgentUpdates(spec *SelfUpdate, version, commit string, nodeAgents *common.DesiredNodeAgents) error {
+	nodeAgentUpdateMux.Lock()
+	defer nodeAgentUpdateMux.Unlock()
+
+	mirror := githubReleases
+	if spec.Mirror != "" {
+		mirror = strings.TrimSuffix(spec.Mirror, "/")
+	}
+	url := fmt.Sprintf("%s/%s/nodeagent", mirror, version)
+
+	if nodeAgentUpdate == nil || nodeAgentUpdate.URL != url || nodeAgentUpdate.Commit != commit {
+		signature := executables.PreBuiltOptional(common.NodeAgentSignature)
+		if len(signature) == 0 {
+			return mntr.ToUserError(errors.New("this ORBITER build has no release signature for the node agent, so node agents can't update themselves. Remove the selfUpdate property from the orb spec"))
+		}
+		nodeAgentUpdate = common.NewNodeAgentUpdate(version, commit, url, executables.PreBuilt("nodeagent"), string(signature))
+	}
+
+	update := *nodeAgentUpdate
+	update.Rollback = spec.Rollback
+	for _, id := range nodeAgents.List() {
+		na, _ := nodeAgents.Get(id)
+		na.Update = &update
+	}
+	return nil
+}
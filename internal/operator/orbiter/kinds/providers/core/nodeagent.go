@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/caos/orbos/internal/executables"
@@ -13,12 +14,13 @@ import (
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 	"github.com/caos/orbos/mntr"
 	orbcfg "github.com/caos/orbos/pkg/orb"
+	"gopkg.in/yaml.v3"
 )
 
-type IterateNodeAgentFuncs func(currentNodeAgents *common.CurrentNodeAgents) (queryNodeAgent func(machine infra.Machine, orbiterCommit string) (bool, error), install func(machine infra.Machine) error)
+type IterateNodeAgentFuncs func(desiredNodeAgents *common.DesiredNodeAgents, currentNodeAgents *common.CurrentNodeAgents) (queryNodeAgent func(machine infra.Machine, orbiterCommit string) (bool, error), install func(machine infra.Machine) error)
 
 func ConfigureNodeAgents(svc MachinesService, monitor mntr.Monitor, orb orbcfg.Orb, pprof bool) error {
-	configure, _ := NodeAgentFuncs(monitor, orb.URL, orb.Repokey, pprof)
+	configure, _ := NodeAgentFuncs(monitor, "", orb.URL, orb.Repokey, pprof)
 	return Each(svc, func(pool string, machine infra.Machine) error {
 		err := configure(machine)
 		if err != nil {
@@ -34,6 +36,7 @@ func ConfigureNodeAgents(svc MachinesService, monitor mntr.Monitor, orb orbcfg.O
 
 func NodeAgentFuncs(
 	monitor mntr.Monitor,
+	providerID string,
 	repoURL string,
 	repoKey string,
 	pprof bool,
@@ -110,7 +113,7 @@ After=network.target
 [Service]
 Type=simple
 User=root
-ExecStart=%s --id "%s" %s %s --environment "%s"
+ExecStart=%s --id "%s" --repository-dir /var/orbiter/repository %s %s --environment "%s"
 Restart=on-failure
 MemoryLimit=1G
 MemoryAccounting=yes
@@ -128,7 +131,9 @@ WantedBy=multi-user.target
 
 	return func(machine infra.Machine) error {
 			return configure(machine)()
-		}, func(currentNodeAgents *common.CurrentNodeAgents) (func(infra.Machine, string) (bool, error), func(infra.Machine) error) {
+		}, func(desiredNodeAgents *common.DesiredNodeAgents, currentNodeAgents *common.CurrentNodeAgents) (func(infra.Machine, string) (bool, error), func(infra.Machine) error) {
+
+			pruneSelfUpdates(providerID)
 
 			return func(machine infra.Machine, orbiterCommit string) (running bool, err error) {
 
@@ -165,6 +170,13 @@ WantedBy=multi-user.target
 
 					current, ok := currentNodeAgents.Get(machine.ID())
 					if ok && current.Commit == orbiterCommit {
+						forgetSelfUpdate(providerID, machine.ID())
+						return true, nil
+					}
+
+					desired, _ := desiredNodeAgents.Get(machine.ID())
+					if desired.Update != nil && desired.Update.Commit == orbiterCommit && awaitSelfUpdate(providerID, machine.ID(), orbiterCommit) {
+						machineMonitor.WithField("commit", orbiterCommit).Info("Waiting for node agent to update itself")
 						return true, nil
 					}
 
@@ -245,6 +257,25 @@ WantedBy=multi-user.target
 							}).Debug("Written file")
 							return nil
 						},
+						func() error {
+							// Node agents only accept updates signed with the release signing key pinned here
+							releaseKey := executables.PreBuiltOptional(common.ReleaseSigningKey)
+							if len(releaseKey) == 0 {
+								return nil
+							}
+							if err := infra.Try(machineMonitor, time.NewTimer(8*time.Second), 2*time.Second, machine, func(cmp infra.Machine) error {
+								if cbErr := cmp.WriteFile(common.ReleaseSigningKeyPath, bytes.NewReader(releaseKey), 444); cbErr != nil {
+									return fmt.Errorf("creating remote file %s failed: %w", common.ReleaseSigningKeyPath, cbErr)
+								}
+								return nil
+							}); err != nil {
+								return fmt.Errorf("pinning release signing key failed: %w", err)
+							}
+							machineMonitor.WithFields(map[string]interface{}{
+								"path": common.ReleaseSigningKeyPath,
+							}).Debug("Written file")
+							return nil
+						},
 						func() error {
 							if err := infra.Try(machineMonitor, time.NewTimer(20*time.Second), 2*time.Second, machine, func(cmp infra.Machine) error {
 								if cbErr := cmp.WriteFile(healthPath, bytes.NewReader(executables.PreBuilt("health")), 711); cbErr != nil {
@@ -281,3 +312,76 @@ WantedBy=multi-user.target
 				}
 		}
 }
+
+// selfUpdateTimeout exceeds the node agents rollback timeout, so ORBITER only reinstalls node agents over SSH that failed to update themselves
+const selfUpdateTimeout = 15 * time.Minute
+
+// selfUpdates is keyed by provider and machine, as machine IDs are only unique within a provider
+var (
+	selfUpdates    = make(map[selfUpdateKey]*selfUpdate)
+	selfUpdatesMux sync.Mutex
+)
+
+type selfUpdateKey struct {
+	provider string
+	machine  string
+}
+
+type selfUpdate struct {
+	commit string
+	since  time.Time
+	// queried is false if the machine wasn't queried since the providers previous iteration
+	queried bool
+}
+
+// awaitSelfUpdate returns false as soon as the node agent didn't report the desired commit within selfUpdateTimeout
+func awaitSelfUpdate(providerID, machineID, commit string) bool {
+	selfUpdatesMux.Lock()
+	defer selfUpdatesMux.Unlock()
+
+	key := selfUpdateKey{provider: providerID, machine: machineID}
+	update, ok := selfUpdates[key]
+	if !ok || update.commit != commit {
+		update = &selfUpdate{commit: commit, since: time.Now()}
+		selfUpdates[key] = update
+	}
+	update.queried = true
+	return time.Since(update.since) < selfUpdateTimeout
+}
+
+// forgetSelfUpdate is called as soon as the node agent reports the desired commit
+func forgetSelfUpdate(providerID, machineID string) {
+	selfUpdatesMux.Lock()
+	defer selfUpdatesMux.Unlock()
+	delete(selfUpdates, selfUpdateKey{provider: providerID, machine: machineID})
+}
+
+// pruneSelfUpdates forgets the updates of machines which were not queried since the providers previous iteration, as they don't exist anymore
+func pruneSelfUpdates(providerID string) {
+	selfUpdatesMux.Lock()
+	defer selfUpdatesMux.Unlock()
+	for key, update := range selfUpdates {
+		if key.provider != providerID {
+			continue
+		}
+		if !update.queried {
+			delete(selfUpdates, key)
+			continue
+		}
+		update.queried = false
+	}
+}
+
+// NodeAgentStatus queries the node agents local API over SSH for orbctl node status
+func NodeAgentStatus(machine infra.Machine) (*common.NodeAgentStatus, error) {
+	out, err := machine.Execute(nil, "sudo /usr/local/bin/node-agent --status")
+	if err != nil {
+		return nil, fmt.Errorf("querying node agent status on machine %s failed: %w", machine.ID(), err)
+	}
+
+	status := &common.NodeAgentStatus{}
+	if err := yaml.Unmarshal(out, status); err != nil {
+		return nil, fmt.Errorf("parsing node agent status of machine %s failed: %w", machine.ID(), err)
+	}
+	return status, nil
+}
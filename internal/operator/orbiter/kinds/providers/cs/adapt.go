@@ -90,7 +90,7 @@ func AdaptFunc(
 					return nil, err
 				}
 
-				_, naFuncs := core.NodeAgentFuncs(monitor, repoURL, repoKey, pprof)
+				_, naFuncs := core.NodeAgentFuncs(monitor, providerID, repoURL, repoKey, pprof)
 
 				return query(&desiredKind.Spec, current, lbCurrent.Parsed, ctx, nodeAgentsCurrent, nodeAgentsDesired, naFuncs, orbiterCommit)
 			}, func(delegates map[string]interface{}) error {
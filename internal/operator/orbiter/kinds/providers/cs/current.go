@@ -1,19 +1,24 @@
 package cs
 
 import (
+	"github.com/caos/orbos/internal/operator/orbiter"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
 	"github.com/caos/orbos/pkg/tree"
 )
 
-var _ infra.ProviderCurrent = (*Current)(nil)
+var (
+	_ infra.ProviderCurrent = (*Current)(nil)
+	_ orbiter.Planner       = (*Current)(nil)
+)
 
 type Current struct {
 	Common  *tree.Common `yaml:",inline"`
 	Current struct {
-		pools      map[string]infra.Pool `yaml:"-"`
-		Ingresses  map[string]*infra.Address
-		cleanupped <-chan error `yaml:"-"`
+		pools          map[string]infra.Pool `yaml:"-"`
+		Ingresses      map[string]*infra.Address
+		planNodeAgents func() error `yaml:"-"`
+		cleanupped     <-chan error `yaml:"-"`
 	}
 }
 
@@ -50,3 +55,11 @@ func addPools(current *Current, spec *Spec, machinesSvc core.MachinesService) er
 	}
 	return nil
 }
+
+// Plan desires the load balancing node agent configs without touching any machine, so they are part of the plan
+func (c *Current) Plan(*orbiter.Plan) error {
+	if c.Current.planNodeAgents == nil {
+		return nil
+	}
+	return c.Current.planNodeAgents()
+}
@@ -39,7 +39,7 @@ func query(
 		return nil, err
 	}
 
-	queryNA, installNA := naFuncs(nodeAgentsCurrent)
+	queryNA, installNA := naFuncs(nodeAgentsDesired, nodeAgentsCurrent)
 	ensureNodeAgent := func(m infra.Machine) error {
 		running, err := queryNA(m, orbiterCommit)
 		if err != nil {
@@ -70,6 +70,7 @@ func query(
 		NotifyMaster:  notifyMaster(hostPools, current, poolsWithUnassignedVIPs),
 		AuthCheck:     checkAuth,
 	}, desiredToCurrentVIP(current))
+	current.Current.planNodeAgents = wrappedMachines.PlanDesiredNodeAgents
 	return func(pdf func(mntr.Monitor) error) *orbiter.EnsureResult {
 		var done bool
 		return orbiter.ToEnsureResult(done, helpers.Fanout([]func() error{
@@ -80,7 +81,7 @@ func query(
 			func() error { return helpers.Fanout(removeFIPs)() },
 			func() error { return helpers.Fanout(ensureServers)() },
 			func() error {
-				lbDone, err := wrappedMachines.InitializeDesiredNodeAgents()
+				lbDone, err := wrappedMachines.InitializeDesiredNodeAgents(pdf)
 				if err != nil {
 					return err
 				}
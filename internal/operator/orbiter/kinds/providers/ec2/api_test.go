@@ -0,0 +1,332 @@
+package ec2
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/ssh"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+// fakeEC2 implements the subset of the EC2 query API creating, listing and destroying machines uses
+type fakeEC2 struct {
+	sync.Mutex
+	server         *httptest.Server
+	securityGroups map[string]map[string]string
+	instances      map[string]*fakeInstance
+	authorized     int
+	ids            int
+}
+
+type fakeInstance struct {
+	state    string
+	ip       string
+	publicIP bool
+	tags     map[string]string
+}
+
+func newFakeEC2(t *testing.T) *fakeEC2 {
+	fake := &fakeEC2{
+		securityGroups: make(map[string]map[string]string),
+		instances:      make(map[string]*fakeInstance),
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+func (f *fakeEC2) nextID(prefix string) string {
+	f.ids++
+	return fmt.Sprintf("%s-%d", prefix, f.ids)
+}
+
+type xmlTag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+type xmlInstance struct {
+	InstanceID       string   `xml:"instanceId"`
+	State            string   `xml:"instanceState>name"`
+	PrivateIPAddress string   `xml:"privateIpAddress"`
+	Tags             []xmlTag `xml:"tagSet>item"`
+}
+
+func toXMLTags(tags map[string]string) []xmlTag {
+	xmlTags := make([]xmlTag, 0, len(tags))
+	for k, v := range tags {
+		xmlTags = append(xmlTags, xmlTag{Key: k, Value: v})
+	}
+	return xmlTags
+}
+
+// tags reads tags from query parameters like TagSpecification.1.Tag.1.Key
+func tags(r *http.Request, prefix string) map[string]string {
+	found := make(map[string]string)
+	for i := 1; ; i++ {
+		key := r.PostForm.Get(fmt.Sprintf("%s.%d.Key", prefix, i))
+		if key == "" {
+			return found
+		}
+		found[key] = r.PostForm.Get(fmt.Sprintf("%s.%d.Value", prefix, i))
+	}
+}
+
+// filters reads filters from query parameters like Filter.1.Name and Filter.1.Value.1
+func filters(r *http.Request) map[string][]string {
+	found := make(map[string][]string)
+	for i := 1; ; i++ {
+		name := r.PostForm.Get(fmt.Sprintf("Filter.%d.Name", i))
+		if name == "" {
+			return found
+		}
+		for j := 1; ; j++ {
+			value := r.PostForm.Get(fmt.Sprintf("Filter.%d.Value.%d", i, j))
+			if value == "" {
+				break
+			}
+			found[name] = append(found[name], value)
+		}
+	}
+}
+
+func matches(filters map[string][]string, state string, tags map[string]string) bool {
+	for name, values := range filters {
+		var value string
+		switch {
+		case name == "instance-state-name":
+			value = state
+		case strings.HasPrefix(name, "tag:"):
+			value = tags[strings.TrimPrefix(name, "tag:")]
+		default:
+			continue
+		}
+		found := false
+		for _, v := range values {
+			found = found || v == value
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeEC2) handle(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	respond := func(action string, body interface{}) {
+		w.Header().Set("Content-Type", "text/xml")
+		out, err := xml.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(w, "<%sResponse>%s</%sResponse>", action, out, action)
+	}
+
+	type instanceSet struct {
+		XMLName   xml.Name      `xml:"instancesSet"`
+		Instances []xmlInstance `xml:"item"`
+	}
+
+	action := r.PostForm.Get("Action")
+	switch action {
+	case "DescribeSubnets":
+		respond(action, struct {
+			XMLName xml.Name `xml:"subnetSet"`
+			Items   []struct {
+				SubnetID string `xml:"subnetId"`
+				VpcID    string `xml:"vpcId"`
+			} `xml:"item"`
+		}{Items: []struct {
+			SubnetID string `xml:"subnetId"`
+			VpcID    string `xml:"vpcId"`
+		}{{SubnetID: r.PostForm.Get("SubnetId.1"), VpcID: "vpc-1"}}})
+	case "DescribeVpcs":
+		respond(action, struct {
+			XMLName xml.Name `xml:"vpcSet"`
+			Items   []struct {
+				VpcID     string `xml:"vpcId"`
+				CidrBlock string `xml:"cidrBlock"`
+			} `xml:"item"`
+		}{Items: []struct {
+			VpcID     string `xml:"vpcId"`
+			CidrBlock string `xml:"cidrBlock"`
+		}{{VpcID: "vpc-1", CidrBlock: "10.0.0.0/16"}}})
+	case "DescribeSecurityGroups":
+		type group struct {
+			GroupID string `xml:"groupId"`
+		}
+		groups := struct {
+			XMLName xml.Name `xml:"securityGroupInfo"`
+			Items   []group  `xml:"item"`
+		}{}
+		for id, tags := range f.securityGroups {
+			if matches(filters(r), "", tags) {
+				groups.Items = append(groups.Items, group{GroupID: id})
+			}
+		}
+		respond(action, groups)
+	case "CreateSecurityGroup":
+		id := f.nextID("sg")
+		f.securityGroups[id] = tags(r, "TagSpecification.1.Tag")
+		respond(action, struct {
+			XMLName xml.Name `xml:"groupId"`
+			ID      string   `xml:",chardata"`
+		}{ID: id})
+	case "AuthorizeSecurityGroupIngress":
+		f.authorized++
+		respond(action, struct {
+			XMLName xml.Name `xml:"return"`
+			Value   bool     `xml:",chardata"`
+		}{Value: true})
+	case "RunInstances":
+		if _, ok := f.securityGroups[r.PostForm.Get("NetworkInterface.1.SecurityGroupId.1")]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<Response><Errors><Error><Code>InvalidGroup.NotFound</Code><Message>security group not found</Message></Error></Errors></Response>")
+			return
+		}
+		id := f.nextID("i")
+		f.instances[id] = &fakeInstance{
+			state:    "running",
+			ip:       fmt.Sprintf("10.0.0.%d", f.ids),
+			publicIP: r.PostForm.Get("NetworkInterface.1.AssociatePublicIpAddress") == "true",
+			tags:     tags(r, "TagSpecification.1.Tag"),
+		}
+		respond(action, instanceSet{Instances: []xmlInstance{{InstanceID: id, State: "pending", Tags: toXMLTags(f.instances[id].tags)}}})
+	case "DescribeInstances":
+		ids := make(map[string]bool)
+		for i := 1; r.PostForm.Get(fmt.Sprintf("InstanceId.%d", i)) != ""; i++ {
+			ids[r.PostForm.Get(fmt.Sprintf("InstanceId.%d", i))] = true
+		}
+		instances := instanceSet{}
+		for id, instance := range f.instances {
+			if (len(ids) == 0 || ids[id]) && matches(filters(r), instance.state, instance.tags) {
+				instances.Instances = append(instances.Instances, xmlInstance{
+					InstanceID:       id,
+					State:            instance.state,
+					PrivateIPAddress: instance.ip,
+					Tags:             toXMLTags(instance.tags),
+				})
+			}
+		}
+		respond(action, struct {
+			XMLName      xml.Name `xml:"reservationSet"`
+			Reservations []struct {
+				Instances []xmlInstance `xml:"instancesSet>item"`
+			} `xml:"item"`
+		}{Reservations: []struct {
+			Instances []xmlInstance `xml:"instancesSet>item"`
+		}{{Instances: instances.Instances}}})
+	case "TerminateInstances":
+		id := r.PostForm.Get("InstanceId.1")
+		instance, ok := f.instances[id]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "<Response><Errors><Error><Code>InvalidInstanceID.NotFound</Code><Message>instance not found</Message></Error></Errors></Response>")
+			return
+		}
+		instance.state = "terminated"
+		respond(action, instanceSet{Instances: []xmlInstance{{InstanceID: id, State: "shutting-down"}}})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "<Response><Errors><Error><Code>InvalidAction</Code><Message>%s is not implemented</Message></Error></Errors></Response>", action)
+	}
+}
+
+func testContext(t *testing.T, fake *fakeEC2) *context {
+	private, public := ssh.Generate()
+	desired := &Spec{
+		AccessKeyID:     &secret.Secret{Value: "id"},
+		SecretAccessKey: &secret.Secret{Value: "key"},
+		Region:          "test",
+		Subnet:          "subnet-1",
+		Endpoint:        fake.server.URL,
+		Pools: map[string]*Pool{"workers": {
+			InstanceType: "t3.medium",
+			AMI:          "ami-1",
+			StorageGB:    20,
+		}},
+		SSHKey: &SSHKey{
+			Private: &secret.Secret{Value: private},
+			Public:  &secret.Secret{Value: public},
+		},
+	}
+	ctx, err := buildContext(mntr.Monitor{}, desired, "orb", "provider", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.machinesService.use(desired.SSHKey); err != nil {
+		t.Fatal(err)
+	}
+	ctx.machinesService.onCreate = func(string, infra.Machine) error { return nil }
+	return ctx
+}
+
+func TestMachinesService_CreateListDestroy(t *testing.T) {
+	fake := newFakeEC2(t)
+
+	created, err := testContext(t, fake).machinesService.Create("workers", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].IP() == "" {
+		t.Fatalf("expected one created machine with an ip, got %v", created)
+	}
+	if len(fake.securityGroups) != 1 || fake.authorized != 1 {
+		t.Errorf("expected one authorized security group, got %d groups and %d authorizations", len(fake.securityGroups), fake.authorized)
+	}
+	for _, instance := range fake.instances {
+		if instance.tags["orb"] != "orb" || instance.tags["provider"] != "provider" || instance.tags["pool"] != "workers" {
+			t.Errorf("instance is not tagged with its orb, provider and pool: %v", instance.tags)
+		}
+		if instance.publicIP {
+			t.Error("expected machines created by ORBITER not to get a public ip")
+		}
+	}
+
+	// A second run reuses the security group and only lists the providers machines
+	fake.Lock()
+	fake.instances["i-foreign"] = &fakeInstance{state: "running", tags: map[string]string{"orb": "other", "pool": "workers"}}
+	fake.Unlock()
+
+	listed, err := testContext(t, fake).machinesService.List("workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID() != created[0].ID() {
+		t.Fatalf("expected to list the created machine %s, got %v", created[0].ID(), listed)
+	}
+
+	destroy, err := listed[0].Destroy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := destroy(); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err = testContext(t, fake).machinesService.List("workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 0 {
+		t.Errorf("expected the destroyed machine not to be listed, got %v", listed)
+	}
+	if len(fake.securityGroups) != 1 {
+		t.Errorf("expected the security group to be reused, got %d", len(fake.securityGroups))
+	}
+}
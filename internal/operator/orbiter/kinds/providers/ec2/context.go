@@ -0,0 +1,131 @@
+package ec2
+
+import (
+	ctxpkg "context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	"github.com/caos/orbos/mntr"
+)
+
+type context struct {
+	monitor         mntr.Monitor
+	orbID           string
+	providerID      string
+	desired         *Spec
+	ec2             *ec2.EC2
+	elb             *elbv2.ELBV2
+	vpcID           string
+	vpcCIDR         string
+	mux             sync.Mutex
+	securityGroupID string
+	machinesService *machinesService
+	ctx             ctxpkg.Context
+}
+
+func buildContext(monitor mntr.Monitor, desired *Spec, orbID, providerID string, oneoff bool) (*context, error) {
+
+	cfg := &aws.Config{
+		Region: aws.String(desired.Region),
+		Credentials: credentials.NewStaticCredentials(
+			desired.AccessKeyID.Value,
+			desired.SecretAccessKey.Value,
+			"",
+		),
+	}
+	if desired.Endpoint != "" {
+		cfg.Endpoint = aws.String(desired.Endpoint)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, mntr.ToUserError(err)
+	}
+
+	newContext := &context{
+		monitor:    monitor.WithField("region", desired.Region),
+		orbID:      orbID,
+		providerID: providerID,
+		desired:    desired,
+		ec2:        ec2.New(sess),
+		elb:        elbv2.New(sess),
+		ctx:        ctxpkg.Background(),
+	}
+
+	newContext.machinesService = newMachinesService(newContext, oneoff)
+
+	return newContext, nil
+}
+
+// resourceName returns a name unique per orb and provider which is short enough for load balancers and target groups
+func (c *context) resourceName(suffix string) string {
+	h := fnv.New32()
+	h.Write([]byte(c.orbID + c.providerID))
+	return fmt.Sprintf("orbos-%d-%s", h.Sum32(), suffix)
+}
+
+func (c *context) tags(more map[string]string) []*ec2.Tag {
+	tags := []*ec2.Tag{
+		{Key: aws.String("orb"), Value: aws.String(c.orbID)},
+		{Key: aws.String("provider"), Value: aws.String(c.providerID)},
+	}
+	for k, v := range more {
+		tags = append(tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return tags
+}
+
+func (c *context) filters(more map[string]string) []*ec2.Filter {
+	filters := []*ec2.Filter{
+		{Name: aws.String("tag:orb"), Values: []*string{aws.String(c.orbID)}},
+		{Name: aws.String("tag:provider"), Values: []*string{aws.String(c.providerID)}},
+	}
+	for k, v := range more {
+		filters = append(filters, &ec2.Filter{Name: aws.String(k), Values: []*string{aws.String(v)}})
+	}
+	return filters
+}
+
+func tagValue(tags []*ec2.Tag, key string) string {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == key {
+			return aws.StringValue(t.Value)
+		}
+	}
+	return ""
+}
+
+func (c *context) resolveVPC() error {
+	if c.vpcID != "" {
+		return nil
+	}
+	resp, err := c.ec2.DescribeSubnetsWithContext(c.ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: []*string{aws.String(c.desired.Subnet)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Subnets) != 1 {
+		return mntr.ToUserError(fmt.Errorf("subnet %s not found", c.desired.Subnet))
+	}
+	c.vpcID = aws.StringValue(resp.Subnets[0].VpcId)
+
+	vpcs, err := c.ec2.DescribeVpcsWithContext(c.ctx, &ec2.DescribeVpcsInput{
+		VpcIds: []*string{aws.String(c.vpcID)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(vpcs.Vpcs) != 1 {
+		return fmt.Errorf("vpc %s not found", c.vpcID)
+	}
+	c.vpcCIDR = aws.StringValue(vpcs.Vpcs[0].CidrBlock)
+	return nil
+}
@@ -0,0 +1,120 @@
+package ec2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+type Desired struct {
+	Common        *tree.Common `yaml:",inline"`
+	Spec          Spec
+	Loadbalancing *tree.Tree
+}
+
+type Pool struct {
+	InstanceType string
+	AMI          string
+	StorageGB    int64
+}
+
+func (p Pool) validate() error {
+	if p.InstanceType == "" {
+		return errors.New("no instancetype configured")
+	}
+	if p.AMI == "" {
+		return errors.New("no ami configured")
+	}
+	if p.StorageGB < 20 {
+		return errors.New("at least 20GB of storage is needed for the boot disk")
+	}
+	return nil
+}
+
+type SSHKey struct {
+	Private *secret.Secret `yaml:",omitempty"`
+	Public  *secret.Secret `yaml:",omitempty"`
+}
+
+type Spec struct {
+	Verbose         bool
+	AccessKeyID     *secret.Secret `yaml:",omitempty"`
+	SecretAccessKey *secret.Secret `yaml:",omitempty"`
+	Region          string
+	// Subnet is the id of the subnet machines and load balancers are placed in
+	Subnet string
+	// Endpoint overrides the AWS API endpoints, e.g. for an EC2 compatible stand-in
+	Endpoint            string `yaml:",omitempty"`
+	Pools               map[string]*Pool
+	SSHKey              *SSHKey
+	RebootRequired      []string
+	ReplacementRequired []string
+}
+
+func (d Desired) validateAdapt() (err error) {
+	defer func() {
+		err = mntr.ToUserError(err)
+	}()
+
+	if d.Loadbalancing == nil {
+		return errors.New("no loadbalancing configured")
+	}
+	if d.Spec.Region == "" {
+		return errors.New("no region configured")
+	}
+	if d.Spec.Subnet == "" {
+		return errors.New("no subnet configured")
+	}
+	if len(d.Spec.Pools) == 0 {
+		return errors.New("no pools configured")
+	}
+	for poolName, pool := range d.Spec.Pools {
+		if err := pool.validate(); err != nil {
+			return fmt.Errorf("configuring pool %s failed: %w", poolName, err)
+		}
+	}
+	return nil
+}
+
+func (d Desired) validateAccessKeys() error {
+	if d.Spec.AccessKeyID == nil || d.Spec.AccessKeyID.Value == "" ||
+		d.Spec.SecretAccessKey == nil || d.Spec.SecretAccessKey.Value == "" {
+		return mntr.ToUserError(errors.New("access keys missing... please provide them using orbctl writesecret command"))
+	}
+	return nil
+}
+
+func (d Desired) validateQuery() (err error) {
+	defer func() {
+		err = mntr.ToUserError(err)
+	}()
+
+	if err := d.validateAccessKeys(); err != nil {
+		return err
+	}
+
+	if d.Spec.SSHKey.Private == nil ||
+		d.Spec.SSHKey.Private.Value == "" ||
+		d.Spec.SSHKey.Public == nil ||
+		d.Spec.SSHKey.Public.Value == "" {
+		return errors.New("ssh key missing... please initialize your orb using orbctl configure command")
+	}
+
+	return nil
+}
+
+func parseDesired(desiredTree *tree.Tree) (*Desired, error) {
+	desiredKind := &Desired{
+		Common: desiredTree.Common,
+		Spec:   Spec{},
+	}
+
+	if err := desiredTree.Original.Decode(desiredKind); err != nil {
+		return nil, mntr.ToUserError(fmt.Errorf("parsing desired state failed: %w", err))
+	}
+
+	return desiredKind, nil
+}
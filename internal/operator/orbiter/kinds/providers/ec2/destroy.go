@@ -0,0 +1,59 @@
+package ec2
+
+import (
+	"time"
+
+	"github.com/caos/orbos/internal/helpers"
+)
+
+func destroy(context *context) error {
+
+	var delFuncs []func() error
+	pools, err := context.machinesService.ListPools()
+	if err != nil {
+		return err
+	}
+	for idx := range pools {
+		pool := pools[idx]
+		machines, err := context.machinesService.List(pool)
+		if err != nil {
+			return err
+		}
+		for idx := range machines {
+			machine := machines[idx]
+			delFuncs = append(delFuncs, func() error {
+				remove, err := machine.Destroy()
+				if err != nil {
+					return err
+				}
+				return remove()
+			})
+		}
+	}
+	if err := helpers.Fanout(delFuncs)(); err != nil {
+		return err
+	}
+
+	// Target groups and elastic ips are only released after the load balancers using them are gone
+	var removeErr error
+	if err := helpers.Retry(time.NewTimer(5*time.Minute), 10*time.Second, func() bool {
+		eips, err := queryElasticIPs(context, nil, &Current{})
+		if err != nil {
+			removeErr = err
+			return false
+		}
+		if err := removeLoadBalancing(context, nil, eips); err != nil {
+			removeErr = err
+			return false
+		}
+		tgs, err := ourTargetGroups(context)
+		if err != nil {
+			removeErr = err
+			return false
+		}
+		return len(eips) > 0 || len(tgs) > 0
+	}); err != nil {
+		return err
+	}
+	return removeErr
+}
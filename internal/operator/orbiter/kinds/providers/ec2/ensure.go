@@ -0,0 +1,125 @@
+package ec2
+
+import (
+	"fmt"
+
+	"github.com/caos/orbos/internal/helpers"
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	dynamiclbmodel "github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic/wrap"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/mntr"
+)
+
+func query(
+	desired *Spec,
+	current *Current,
+	lb interface{},
+	context *context,
+	nodeAgentsCurrent *common.CurrentNodeAgents,
+	nodeAgentsDesired *common.DesiredNodeAgents,
+	naFuncs core.IterateNodeAgentFuncs,
+	orbiterCommit string,
+) (ensureFunc orbiter.EnsureFunc, err error) {
+
+	lbCurrent, ok := lb.(*dynamiclbmodel.Current)
+	if !ok {
+		panic(fmt.Errorf("unknown or unsupported load balancing of type %T", lb))
+	}
+
+	if err := context.resolveVPC(); err != nil {
+		return nil, err
+	}
+
+	hostPools, _, err := lbCurrent.Current.Spec(context.machinesService)
+	if err != nil {
+		return nil, err
+	}
+
+	eips, err := queryElasticIPs(context, hostPools, current)
+	if err != nil {
+		return nil, err
+	}
+
+	queryNA, installNA := naFuncs(nodeAgentsDesired, nodeAgentsCurrent)
+	ensureNodeAgent := func(m infra.Machine) error {
+		running, err := queryNA(m, orbiterCommit)
+		if err != nil {
+			return err
+		}
+		if !running {
+			return installNA(m)
+		}
+		return nil
+	}
+
+	machines, err := context.machinesService.machines()
+	if err != nil {
+		return nil, err
+	}
+
+	var ensureNodeAgents []func() error
+	for _, poolMachines := range machines {
+		for idx := range poolMachines {
+			ensureNodeAgents = append(ensureNodeAgents, func(m infra.Machine) func() error {
+				return func() error { return ensureNodeAgent(m) }
+			}(poolMachines[idx]))
+		}
+	}
+
+	context.machinesService.onCreate = func(pool string, m infra.Machine) error {
+		_, err := core.DesireInternalOSFirewall(context.monitor, nodeAgentsDesired, nodeAgentsCurrent, context.machinesService, false, []string{"eth0"})
+		if err != nil {
+			return err
+		}
+
+		return ensureNodeAgent(m)
+	}
+	wrappedMachines := wrap.MachinesService(context.machinesService, *lbCurrent, nil, func(vip *dynamiclbmodel.VIP) string {
+		for _, transport := range vip.Transport {
+			address, ok := current.Current.Ingresses[transport.Name]
+			if ok {
+				return address.Location
+			}
+		}
+		panic(fmt.Errorf("external address for %v is not ensured", vip))
+	})
+	current.Current.planNodeAgents = wrappedMachines.PlanDesiredNodeAgents
+	return func(pdf func(mntr.Monitor) error) *orbiter.EnsureResult {
+		var done bool
+
+		// Elastic ips are allocated sequentially, as they write to the current state
+		if err := ensureElasticIPs(context, hostPools, eips, current); err != nil {
+			return orbiter.ToEnsureResult(false, err)
+		}
+
+		return orbiter.ToEnsureResult(done, helpers.Fanout([]func() error{
+			func() error {
+				if err := ensureLoadBalancers(context, hostPools, eips); err != nil {
+					return err
+				}
+				return removeLoadBalancing(context, hostPools, eips)
+			},
+			func() error { return helpers.Fanout(ensureNodeAgents)() },
+			func() error {
+				lbDone, err := wrappedMachines.InitializeDesiredNodeAgents(pdf)
+				if err != nil {
+					return err
+				}
+
+				fwDone, err := core.DesireInternalOSFirewall(context.monitor, nodeAgentsDesired, nodeAgentsCurrent, context.machinesService, false, []string{"eth0"})
+				if err != nil {
+					return err
+				}
+
+				if err := ensureSecurityGroupRules(context, nodeAgentsDesired); err != nil {
+					return err
+				}
+				done = lbDone && fwDone
+				return nil
+			},
+		})())
+	}, addPools(current, desired, wrappedMachines)
+}
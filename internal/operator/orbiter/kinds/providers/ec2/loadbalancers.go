@@ -0,0 +1,360 @@
+package ec2
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic"
+)
+
+// Each desired VIP is served by a network load balancer with an elastic ip.
+// Every transport gets a listener which forwards to a target group containing the backend pools instances.
+// The node agents NGINX forwards the frontend port to the backend port, just like on GCE.
+
+func shortHash(in string) string {
+	h := fnv.New32()
+	h.Write([]byte(in))
+	return fmt.Sprintf("%x", h.Sum32())
+}
+
+func (c *context) loadBalancerName(hostPool string, vipIdx int) string {
+	return c.resourceName(shortHash(hostPool + strconv.Itoa(vipIdx)))
+}
+
+func (c *context) targetGroupName(transport string) string {
+	return c.resourceName(shortHash(transport))
+}
+
+type elasticIPs map[string]*ec2.Address
+
+func (e elasticIPs) get(hostPool string, vipIdx int) *ec2.Address {
+	return e[hostPool+"/"+strconv.Itoa(vipIdx)]
+}
+
+func queryElasticIPs(context *context, loadbalancing map[string][]*dynamic.VIP, writeTo *Current) (elasticIPs, error) {
+	resp, err := context.ec2.DescribeAddressesWithContext(context.ctx, &ec2.DescribeAddressesInput{
+		Filters: context.filters(nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	eips := make(elasticIPs)
+	for _, addr := range resp.Addresses {
+		eips[tagValue(addr.Tags, "pool")+"/"+tagValue(addr.Tags, "idx")] = addr
+	}
+
+	for hostPool, vips := range loadbalancing {
+		for vipIdx, vip := range vips {
+			if eip := eips.get(hostPool, vipIdx); eip != nil {
+				ensureCurrentIngress(writeTo, vip, aws.StringValue(eip.PublicIp))
+			}
+		}
+	}
+	return eips, nil
+}
+
+func ensureCurrentIngress(writeTo *Current, vip *dynamic.VIP, publicIP string) {
+	if writeTo.Current.Ingresses == nil {
+		writeTo.Current.Ingresses = make(map[string]*infra.Address)
+	}
+	for _, transport := range vip.Transport {
+		writeTo.Current.Ingresses[transport.Name] = &infra.Address{
+			Location:     publicIP,
+			FrontendPort: uint16(transport.FrontendPort),
+			BackendPort:  uint16(transport.BackendPort),
+		}
+	}
+}
+
+// ensureElasticIPs allocates missing elastic ips. It writes to the current state, so it must not run concurrently.
+func ensureElasticIPs(context *context, loadbalancing map[string][]*dynamic.VIP, eips elasticIPs, writeTo *Current) error {
+	for hostPool, vips := range loadbalancing {
+		for vipIdx, vip := range vips {
+			if eips.get(hostPool, vipIdx) != nil {
+				continue
+			}
+			allocated, err := context.ec2.AllocateAddressWithContext(context.ctx, &ec2.AllocateAddressInput{
+				Domain: aws.String(ec2.DomainTypeVpc),
+				TagSpecifications: []*ec2.TagSpecification{{
+					ResourceType: aws.String(ec2.ResourceTypeElasticIp),
+					Tags: context.tags(map[string]string{
+						"pool": hostPool,
+						"idx":  strconv.Itoa(vipIdx),
+					}),
+				}},
+			})
+			if err != nil {
+				return err
+			}
+			eips[hostPool+"/"+strconv.Itoa(vipIdx)] = &ec2.Address{
+				AllocationId: allocated.AllocationId,
+				PublicIp:     allocated.PublicIp,
+			}
+			ensureCurrentIngress(writeTo, vip, aws.StringValue(allocated.PublicIp))
+			context.monitor.WithField("ip", aws.StringValue(allocated.PublicIp)).Info("Elastic ip allocated")
+		}
+	}
+	return nil
+}
+
+func ensureLoadBalancers(context *context, loadbalancing map[string][]*dynamic.VIP, eips elasticIPs) error {
+
+	existingLBs, err := ourLoadBalancers(context)
+	if err != nil {
+		return err
+	}
+
+	existingTGs, err := ourTargetGroups(context)
+	if err != nil {
+		return err
+	}
+
+	for hostPool, vips := range loadbalancing {
+		for vipIdx, vip := range vips {
+			lbName := context.loadBalancerName(hostPool, vipIdx)
+			lb, ok := existingLBs[lbName]
+			if !ok {
+				eip := eips.get(hostPool, vipIdx)
+				created, err := context.elb.CreateLoadBalancerWithContext(context.ctx, &elbv2.CreateLoadBalancerInput{
+					Name:   aws.String(lbName),
+					Type:   aws.String(elbv2.LoadBalancerTypeEnumNetwork),
+					Scheme: aws.String(elbv2.LoadBalancerSchemeEnumInternetFacing),
+					SubnetMappings: []*elbv2.SubnetMapping{{
+						SubnetId:     aws.String(context.desired.Subnet),
+						AllocationId: eip.AllocationId,
+					}},
+					Tags: []*elbv2.Tag{
+						{Key: aws.String("orb"), Value: aws.String(context.orbID)},
+						{Key: aws.String("provider"), Value: aws.String(context.providerID)},
+					},
+				})
+				if err != nil {
+					return err
+				}
+				lb = created.LoadBalancers[0]
+				context.monitor.WithField("name", lbName).Info("Network load balancer created")
+			}
+
+			listeners, err := context.elb.DescribeListenersWithContext(context.ctx, &elbv2.DescribeListenersInput{
+				LoadBalancerArn: lb.LoadBalancerArn,
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, transport := range vip.Transport {
+				tgName := context.targetGroupName(transport.Name)
+				tg, ok := existingTGs[tgName]
+				if !ok {
+					created, err := context.elb.CreateTargetGroupWithContext(context.ctx, &elbv2.CreateTargetGroupInput{
+						Name:                aws.String(tgName),
+						Protocol:            aws.String(elbv2.ProtocolEnumTcp),
+						Port:                aws.Int64(int64(transport.FrontendPort)),
+						VpcId:               aws.String(context.vpcID),
+						TargetType:          aws.String(elbv2.TargetTypeEnumInstance),
+						HealthCheckProtocol: aws.String(elbv2.ProtocolEnumTcp),
+					})
+					if err != nil {
+						return err
+					}
+					tg = created.TargetGroups[0]
+					context.monitor.WithField("name", tgName).Info("Target group created")
+				}
+
+				if err := ensureListener(context, lb, listeners.Listeners, tg, transport); err != nil {
+					return err
+				}
+
+				if err := ensureTargets(context, tg, transport.BackendPools); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func ensureListener(context *context, lb *elbv2.LoadBalancer, listeners []*elbv2.Listener, tg *elbv2.TargetGroup, transport *dynamic.Transport) error {
+	for _, listener := range listeners {
+		if aws.Int64Value(listener.Port) != int64(transport.FrontendPort) {
+			continue
+		}
+		for _, action := range listener.DefaultActions {
+			if aws.StringValue(action.TargetGroupArn) == aws.StringValue(tg.TargetGroupArn) {
+				return nil
+			}
+		}
+		_, err := context.elb.ModifyListenerWithContext(context.ctx, &elbv2.ModifyListenerInput{
+			ListenerArn:    listener.ListenerArn,
+			DefaultActions: forwardTo(tg),
+		})
+		return err
+	}
+
+	_, err := context.elb.CreateListenerWithContext(context.ctx, &elbv2.CreateListenerInput{
+		LoadBalancerArn: lb.LoadBalancerArn,
+		Port:            aws.Int64(int64(transport.FrontendPort)),
+		Protocol:        aws.String(elbv2.ProtocolEnumTcp),
+		DefaultActions:  forwardTo(tg),
+	})
+	return err
+}
+
+func forwardTo(tg *elbv2.TargetGroup) []*elbv2.Action {
+	return []*elbv2.Action{{
+		Type:           aws.String(elbv2.ActionTypeEnumForward),
+		TargetGroupArn: tg.TargetGroupArn,
+	}}
+}
+
+func ensureTargets(context *context, tg *elbv2.TargetGroup, backendPools []string) error {
+	desired := make(map[string]bool)
+	for _, pool := range backendPools {
+		machines, err := context.machinesService.List(pool)
+		if err != nil {
+			return err
+		}
+		for _, m := range machines {
+			desired[aws.StringValue(m.(*machine).instance.InstanceId)] = true
+		}
+	}
+
+	health, err := context.elb.DescribeTargetHealthWithContext(context.ctx, &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: tg.TargetGroupArn,
+	})
+	if err != nil {
+		return err
+	}
+
+	registered := make(map[string]bool)
+	var deregister []*elbv2.TargetDescription
+	for _, target := range health.TargetHealthDescriptions {
+		id := aws.StringValue(target.Target.Id)
+		registered[id] = true
+		if !desired[id] {
+			deregister = append(deregister, &elbv2.TargetDescription{Id: target.Target.Id})
+		}
+	}
+
+	var register []*elbv2.TargetDescription
+	for id := range desired {
+		if !registered[id] {
+			register = append(register, &elbv2.TargetDescription{Id: aws.String(id)})
+		}
+	}
+
+	if len(register) > 0 {
+		if _, err := context.elb.RegisterTargetsWithContext(context.ctx, &elbv2.RegisterTargetsInput{
+			TargetGroupArn: tg.TargetGroupArn,
+			Targets:        register,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(deregister) > 0 {
+		if _, err := context.elb.DeregisterTargetsWithContext(context.ctx, &elbv2.DeregisterTargetsInput{
+			TargetGroupArn: tg.TargetGroupArn,
+			Targets:        deregister,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeLoadBalancing deletes all load balancers, target groups and elastic ips which are not desired anymore.
+// Resources in use by deleted load balancers are cleaned up in subsequent iterations.
+func removeLoadBalancing(context *context, loadbalancing map[string][]*dynamic.VIP, eips elasticIPs) error {
+	desiredLBs := make(map[string]bool)
+	desiredTGs := make(map[string]bool)
+	desiredEIPs := make(map[string]bool)
+	for hostPool, vips := range loadbalancing {
+		for vipIdx, vip := range vips {
+			desiredLBs[context.loadBalancerName(hostPool, vipIdx)] = true
+			desiredEIPs[hostPool+"/"+strconv.Itoa(vipIdx)] = true
+			for _, transport := range vip.Transport {
+				desiredTGs[context.targetGroupName(transport.Name)] = true
+			}
+		}
+	}
+
+	existingLBs, err := ourLoadBalancers(context)
+	if err != nil {
+		return err
+	}
+	for name, lb := range existingLBs {
+		if desiredLBs[name] {
+			continue
+		}
+		if _, err := context.elb.DeleteLoadBalancerWithContext(context.ctx, &elbv2.DeleteLoadBalancerInput{
+			LoadBalancerArn: lb.LoadBalancerArn,
+		}); err != nil {
+			return err
+		}
+		context.monitor.WithField("name", name).Info("Network load balancer deleted")
+	}
+
+	existingTGs, err := ourTargetGroups(context)
+	if err != nil {
+		return err
+	}
+	for name, tg := range existingTGs {
+		if desiredTGs[name] || len(tg.LoadBalancerArns) > 0 {
+			continue
+		}
+		if _, err := context.elb.DeleteTargetGroupWithContext(context.ctx, &elbv2.DeleteTargetGroupInput{
+			TargetGroupArn: tg.TargetGroupArn,
+		}); err != nil {
+			return err
+		}
+		context.monitor.WithField("name", name).Info("Target group deleted")
+	}
+
+	for key, eip := range eips {
+		if desiredEIPs[key] || eip.AssociationId != nil {
+			continue
+		}
+		if _, err := context.ec2.ReleaseAddressWithContext(context.ctx, &ec2.ReleaseAddressInput{
+			AllocationId: eip.AllocationId,
+		}); err != nil {
+			return err
+		}
+		context.monitor.WithField("ip", aws.StringValue(eip.PublicIp)).Info("Elastic ip released")
+	}
+	return nil
+}
+
+func ourLoadBalancers(context *context) (map[string]*elbv2.LoadBalancer, error) {
+	prefix := context.resourceName("")
+	lbs := make(map[string]*elbv2.LoadBalancer)
+	return lbs, context.elb.DescribeLoadBalancersPagesWithContext(context.ctx, &elbv2.DescribeLoadBalancersInput{}, func(page *elbv2.DescribeLoadBalancersOutput, _ bool) bool {
+		for _, lb := range page.LoadBalancers {
+			if strings.HasPrefix(aws.StringValue(lb.LoadBalancerName), prefix) {
+				lbs[aws.StringValue(lb.LoadBalancerName)] = lb
+			}
+		}
+		return true
+	})
+}
+
+func ourTargetGroups(context *context) (map[string]*elbv2.TargetGroup, error) {
+	prefix := context.resourceName("")
+	tgs := make(map[string]*elbv2.TargetGroup)
+	return tgs, context.elb.DescribeTargetGroupsPagesWithContext(context.ctx, &elbv2.DescribeTargetGroupsInput{}, func(page *elbv2.DescribeTargetGroupsOutput, _ bool) bool {
+		for _, tg := range page.TargetGroups {
+			if strings.HasPrefix(aws.StringValue(tg.TargetGroupName), prefix) {
+				tgs[aws.StringValue(tg.TargetGroupName)] = tg
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,320 @@
+package ec2
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/caos/orbos/internal/helpers"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/cs"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/ssh"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+func ListMachines(monitor mntr.Monitor, desiredTree *tree.Tree, orbID, providerID string) (map[string]infra.Machine, error) {
+	desired, err := parseDesired(desiredTree)
+	if err != nil {
+		return nil, fmt.Errorf("parsing desired state failed: %w", err)
+	}
+	desiredTree.Parsed = desired
+
+	_, _, _, _, _, err = loadbalancers.GetQueryAndDestroyFunc(monitor, nil, desired.Loadbalancing, &tree.Tree{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	getSecretsMap(desired)
+	ctx, err := buildContext(monitor, &desired.Spec, orbID, providerID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.machinesService.use(desired.Spec.SSHKey); err != nil {
+		invalidKey := &secret.Secret{Value: "invalid"}
+		if err := ctx.machinesService.use(&SSHKey{
+			Private: invalidKey,
+			Public:  invalidKey,
+		}); err != nil {
+			panic(err)
+		}
+	}
+
+	return core.ListMachines(ctx.machinesService)
+}
+
+var _ core.MachinesService = (*machinesService)(nil)
+
+type machinesService struct {
+	context *context
+	oneoff  bool
+	key     *SSHKey
+	cache   struct {
+		instances map[string][]*machine
+		sync.Mutex
+	}
+	onCreate func(pool string, machine infra.Machine) error
+}
+
+func newMachinesService(context *context, oneoff bool) *machinesService {
+	return &machinesService{
+		context: context,
+		oneoff:  oneoff,
+	}
+}
+
+func (m *machinesService) DesiredMachines(poolName string, instances int) int {
+	_, ok := m.context.desired.Pools[poolName]
+	if !ok {
+		return 0
+	}
+
+	return instances
+}
+
+func (m *machinesService) use(key *SSHKey) error {
+	if key == nil || key.Private == nil || key.Public == nil || key.Private.Value == "" || key.Public.Value == "" {
+		return mntr.ToUserError(errors.New("machines are not connectable. have you configured the orb by running orbctl configure?"))
+	}
+	m.key = key
+	return nil
+}
+
+func (m *machinesService) Create(poolName string, _ int) (infra.Machines, error) {
+
+	desired, ok := m.context.desired.Pools[poolName]
+	if !ok {
+		return nil, fmt.Errorf("Pool %s is not configured", poolName)
+	}
+
+	if err := m.context.ensureSecurityGroup(); err != nil {
+		return nil, err
+	}
+
+	name := newName()
+	monitor := machineMonitor(m.context.monitor, name, poolName)
+
+	monitor.Debug("Creating instance")
+
+	userData, err := cs.NewCloudinit().AddGroupWithoutUsers(
+		"orbiter",
+	).AddUser(
+		"orbiter",
+		true,
+		"",
+		[]string{"orbiter", "wheel"},
+		"orbiter",
+		[]string{m.context.desired.SSHKey.Public.Value},
+		"ALL=(ALL) NOPASSWD:ALL",
+	).AddCmd(
+		"sudo echo \"\n\nPermitRootLogin no\n\" >> /etc/ssh/sshd_config",
+	).AddCmd(
+		"sudo service sshd restart",
+	).ToYamlString()
+	if err != nil {
+		return nil, err
+	}
+
+	reservation, err := m.context.ec2.RunInstancesWithContext(m.context.ctx, &ec2.RunInstancesInput{
+		ImageId:      aws.String(desired.AMI),
+		InstanceType: aws.String(desired.InstanceType),
+		MinCount:     aws.Int64(1),
+		MaxCount:     aws.Int64(1),
+		UserData:     aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
+		BlockDeviceMappings: []*ec2.BlockDeviceMapping{{
+			DeviceName: aws.String("/dev/sda1"),
+			Ebs: &ec2.EbsBlockDevice{
+				DeleteOnTermination: aws.Bool(true),
+				VolumeSize:          aws.Int64(desired.StorageGB),
+				VolumeType:          aws.String("gp3"),
+			},
+		}},
+		NetworkInterfaces: []*ec2.InstanceNetworkInterfaceSpecification{{
+			DeviceIndex:              aws.Int64(0),
+			SubnetId:                 aws.String(m.context.desired.Subnet),
+			Groups:                   []*string{aws.String(m.context.securityGroupID)},
+			AssociatePublicIpAddress: aws.Bool(m.needsPublicIP()),
+		}},
+		TagSpecifications: []*ec2.TagSpecification{{
+			ResourceType: aws.String(ec2.ResourceTypeInstance),
+			Tags: m.context.tags(map[string]string{
+				"pool": poolName,
+				"Name": name,
+			}),
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	instanceIDs := []*string{reservation.Instances[0].InstanceId}
+	if err := m.context.ec2.WaitUntilInstanceRunningWithContext(m.context.ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: instanceIDs,
+	}); err != nil {
+		return nil, err
+	}
+
+	described, err := m.context.ec2.DescribeInstancesWithContext(m.context.ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: instanceIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.Info("Instance created")
+
+	infraMachine, err := m.toMachine(described.Reservations[0].Instances[0], monitor, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.cache.instances != nil {
+		if _, ok := m.cache.instances[poolName]; !ok {
+			m.cache.instances[poolName] = make([]*machine, 0)
+		}
+		m.cache.instances[poolName] = append(m.cache.instances[poolName], infraMachine)
+	}
+
+	if err := m.onCreate(poolName, infraMachine); err != nil {
+		return nil, err
+	}
+
+	monitor.Info("Machine created")
+	return []infra.Machine{infraMachine}, nil
+}
+
+// needsPublicIP is true if ORBITER runs outside of the VPC, so orbctl reaches the machines
+func (m *machinesService) needsPublicIP() bool {
+	return m.oneoff
+}
+
+func (m *machinesService) toMachine(instance *ec2.Instance, monitor mntr.Monitor, poolName string) (*machine, error) {
+	internalIP := aws.StringValue(instance.PrivateIpAddress)
+	externalIP := aws.StringValue(instance.PublicIpAddress)
+	sshIP := internalIP
+	if m.oneoff && externalIP != "" {
+		sshIP = externalIP
+	}
+
+	sshMachine := ssh.NewMachine(monitor, "orbiter", sshIP)
+	if err := sshMachine.UseKey([]byte(m.key.Private.Value)); err != nil {
+		return nil, err
+	}
+
+	infraMachine := newMachine(
+		instance,
+		internalIP,
+		externalIP,
+		sshMachine,
+		m.removeMachineFunc(poolName, aws.StringValue(instance.InstanceId)),
+		m.context,
+		poolName,
+	)
+	return infraMachine, nil
+}
+
+func (m *machinesService) ListPools() ([]string, error) {
+
+	pools, err := m.machines()
+	if err != nil {
+		return nil, err
+	}
+
+	var poolNames []string
+	for poolName := range pools {
+		poolNames = append(poolNames, poolName)
+	}
+	return poolNames, nil
+}
+
+func (m *machinesService) List(poolName string) (infra.Machines, error) {
+	pools, err := m.machines()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := pools[poolName]
+	machines := make([]infra.Machine, len(pool))
+	for idx := range pool {
+		machine := pool[idx]
+		machines[idx] = machine
+	}
+
+	return machines, nil
+}
+
+func (m *machinesService) machines() (map[string][]*machine, error) {
+	if m.cache.instances != nil {
+		return m.cache.instances, nil
+	}
+
+	instances := make(map[string][]*machine)
+	var toMachineErr error
+	if err := m.context.ec2.DescribeInstancesPagesWithContext(m.context.ctx, &ec2.DescribeInstancesInput{
+		Filters: append(m.context.filters(nil), &ec2.Filter{
+			Name:   aws.String("instance-state-name"),
+			Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning, ec2.InstanceStateNameStopping, ec2.InstanceStateNameStopped}),
+		}),
+	}, func(page *ec2.DescribeInstancesOutput, _ bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				pool := tagValue(instance.Tags, "pool")
+				machine, err := m.toMachine(instance, machineMonitor(m.context.monitor, tagValue(instance.Tags, "Name"), pool), pool)
+				if err != nil {
+					toMachineErr = err
+					return false
+				}
+				instances[pool] = append(instances[pool], machine)
+			}
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	if toMachineErr != nil {
+		return nil, toMachineErr
+	}
+
+	m.cache.instances = instances
+	return m.cache.instances, nil
+}
+
+func (m *machinesService) removeMachineFunc(pool, id string) func() error {
+
+	return func() error {
+		m.cache.Lock()
+		cleanMachines := make([]*machine, 0)
+		for idx := range m.cache.instances[pool] {
+			cachedMachine := m.cache.instances[pool][idx]
+			if aws.StringValue(cachedMachine.instance.InstanceId) != id {
+				cleanMachines = append(cleanMachines, cachedMachine)
+			}
+		}
+		m.cache.instances[pool] = cleanMachines
+		m.cache.Unlock()
+
+		_, err := m.context.ec2.TerminateInstancesWithContext(m.context.ctx, &ec2.TerminateInstancesInput{
+			InstanceIds: []*string{aws.String(id)},
+		})
+		return err
+	}
+}
+
+func machineMonitor(monitor mntr.Monitor, name string, poolName string) mntr.Monitor {
+	return monitor.WithFields(map[string]interface{}{
+		"machine": name,
+		"pool":    poolName,
+	})
+}
+
+func newName() string {
+	return "orbos-" + helpers.RandomStringRunes(6, []rune("abcdefghijklmnopqrstuvwxyz0123456789"))
+}
@@ -0,0 +1,262 @@
+package ec2
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/caos/orbos/internal/operator/common"
+)
+
+const anywhere = "0.0.0.0/0"
+
+// ensureSecurityGroup makes sure the orbs security group exists.
+// It allows all traffic between the orbs machines, from within the VPC and SSH from anywhere.
+// The network load balancers health checks originate from the VPC.
+func (c *context) ensureSecurityGroup() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.securityGroupID != "" {
+		return nil
+	}
+
+	if err := c.resolveVPC(); err != nil {
+		return err
+	}
+
+	groups, err := c.ec2.DescribeSecurityGroupsWithContext(c.ctx, &ec2.DescribeSecurityGroupsInput{
+		Filters: c.filters(map[string]string{"vpc-id": c.vpcID}),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(groups.SecurityGroups) > 0 {
+		c.securityGroupID = aws.StringValue(groups.SecurityGroups[0].GroupId)
+		return nil
+	}
+
+	created, err := c.ec2.CreateSecurityGroupWithContext(c.ctx, &ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(c.resourceName("machines")),
+		Description: aws.String(fmt.Sprintf("ORBOS machines of orb %s and provider %s", c.orbID, c.providerID)),
+		VpcId:       aws.String(c.vpcID),
+		TagSpecifications: []*ec2.TagSpecification{{
+			ResourceType: aws.String(ec2.ResourceTypeSecurityGroup),
+			Tags:         c.tags(nil),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	c.securityGroupID = aws.StringValue(created.GroupId)
+
+	if _, err := c.ec2.AuthorizeSecurityGroupIngressWithContext(c.ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: created.GroupId,
+		IpPermissions: []*ec2.IpPermission{{
+			IpProtocol:       aws.String("-1"),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: created.GroupId}},
+		}, toPermission(vpcPermission(c.vpcCIDR)), toPermission(sshPermission)},
+	}); err != nil {
+		return err
+	}
+
+	c.monitor.WithField("id", c.securityGroupID).Info("Security group created")
+	return nil
+}
+
+type permission struct {
+	protocol string
+	from     int64
+	to       int64
+	cidr     string
+}
+
+func (p permission) String() string {
+	return fmt.Sprintf("%s/%d-%d/%s", p.protocol, p.from, p.to, p.cidr)
+}
+
+var sshPermission = permission{protocol: "tcp", from: 22, to: 22, cidr: anywhere}
+
+func vpcPermission(cidr string) permission {
+	return permission{protocol: "-1", cidr: cidr}
+}
+
+func toPermission(p permission) *ec2.IpPermission {
+	perm := &ec2.IpPermission{
+		IpProtocol: aws.String(p.protocol),
+		IpRanges:   []*ec2.IpRange{{CidrIp: aws.String(p.cidr)}},
+	}
+	if p.protocol != "-1" {
+		perm.FromPort = aws.Int64(p.from)
+		perm.ToPort = aws.Int64(p.to)
+	}
+	return perm
+}
+
+// desiredPermissions derives the CIDR based ingress rules from the node agents desired firewalls.
+// The internal zone is covered by the self referencing rule and is therefore skipped.
+func desiredPermissions(vpcCIDR string, firewalls []*common.Firewall) ([]permission, error) {
+	vpc := vpcPermission(vpcCIDR)
+	unique := map[string]permission{
+		sshPermission.String(): sshPermission,
+		vpc.String():           vpc,
+	}
+
+	for _, fw := range firewalls {
+		if fw == nil {
+			continue
+		}
+		for zoneName, zone := range fw.Zones {
+			if zone == nil || zoneName == "internal" {
+				continue
+			}
+			sources := []string(zone.Sources)
+			if len(sources) == 0 {
+				sources = []string{anywhere}
+			}
+			for _, allowed := range zone.FW {
+				from, to, err := portRange(allowed.Port)
+				if err != nil {
+					return nil, err
+				}
+				protocol := strings.ToLower(allowed.Protocol)
+				if protocol == "" {
+					protocol = "tcp"
+				}
+				for _, source := range sources {
+					p := permission{protocol: protocol, from: from, to: to, cidr: source}
+					unique[p.String()] = p
+				}
+			}
+		}
+	}
+
+	permissions := make([]permission, 0, len(unique))
+	for _, p := range unique {
+		permissions = append(permissions, p)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].String() < permissions[j].String() })
+	return permissions, nil
+}
+
+func portRange(port string) (int64, int64, error) {
+	parts := strings.SplitN(port, "-", 2)
+	from, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parsing port %s failed: %w", port, err)
+	}
+	to := from
+	if len(parts) == 2 {
+		if to, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("parsing port %s failed: %w", port, err)
+		}
+	}
+	return from, to, nil
+}
+
+func currentPermissions(group *ec2.SecurityGroup) []permission {
+	var permissions []permission
+	for _, perm := range group.IpPermissions {
+		for _, ipRange := range perm.IpRanges {
+			permissions = append(permissions, permission{
+				protocol: aws.StringValue(perm.IpProtocol),
+				from:     aws.Int64Value(perm.FromPort),
+				to:       aws.Int64Value(perm.ToPort),
+				cidr:     aws.StringValue(ipRange.CidrIp),
+			})
+		}
+	}
+	return permissions
+}
+
+// diffPermissions returns the permissions to authorize and to revoke
+func diffPermissions(current, desired []permission) (authorize []permission, revoke []permission) {
+	currentSet := make(map[string]bool)
+	for _, p := range current {
+		currentSet[p.String()] = true
+	}
+	desiredSet := make(map[string]bool)
+	for _, p := range desired {
+		desiredSet[p.String()] = true
+		if !currentSet[p.String()] {
+			authorize = append(authorize, p)
+		}
+	}
+	for _, p := range current {
+		if !desiredSet[p.String()] {
+			revoke = append(revoke, p)
+		}
+	}
+	return authorize, revoke
+}
+
+func ensureSecurityGroupRules(context *context, nodeAgentsDesired *common.DesiredNodeAgents) error {
+
+	if err := context.ensureSecurityGroup(); err != nil {
+		return err
+	}
+
+	pools, err := context.machinesService.machines()
+	if err != nil {
+		return err
+	}
+
+	var firewalls []*common.Firewall
+	for _, machines := range pools {
+		for _, machine := range machines {
+			na, _ := nodeAgentsDesired.Get(machine.ID())
+			firewalls = append(firewalls, na.Firewall)
+		}
+	}
+
+	desired, err := desiredPermissions(context.vpcCIDR, firewalls)
+	if err != nil {
+		return err
+	}
+
+	groups, err := context.ec2.DescribeSecurityGroupsWithContext(context.ctx, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(context.securityGroupID)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(groups.SecurityGroups) != 1 {
+		return fmt.Errorf("security group %s not found", context.securityGroupID)
+	}
+
+	authorize, revoke := diffPermissions(currentPermissions(groups.SecurityGroups[0]), desired)
+
+	if len(authorize) > 0 {
+		var perms []*ec2.IpPermission
+		for _, p := range authorize {
+			perms = append(perms, toPermission(p))
+		}
+		if _, err := context.ec2.AuthorizeSecurityGroupIngressWithContext(context.ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(context.securityGroupID),
+			IpPermissions: perms,
+		}); err != nil {
+			return err
+		}
+		context.monitor.WithField("rules", authorize).Info("Security group rules authorized")
+	}
+
+	if len(revoke) > 0 {
+		var perms []*ec2.IpPermission
+		for _, p := range revoke {
+			perms = append(perms, toPermission(p))
+		}
+		if _, err := context.ec2.RevokeSecurityGroupIngressWithContext(context.ctx, &ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       aws.String(context.securityGroupID),
+			IpPermissions: perms,
+		}); err != nil {
+			return err
+		}
+		context.monitor.WithField("rules", revoke).Info("Security group rules revoked")
+	}
+	return nil
+}
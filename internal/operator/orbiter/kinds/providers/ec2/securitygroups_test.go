@@ -0,0 +1,71 @@
+package ec2
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caos/orbos/internal/operator/common"
+)
+
+func Test_desiredPermissions(t *testing.T) {
+	firewalls := []*common.Firewall{{
+		Zones: map[string]*common.Zone{
+			"external": {
+				FW: map[string]*common.Allowed{
+					"kubeapi": {Port: "6443", Protocol: "TCP"},
+					"nodes":   {Port: "30000-32767", Protocol: "tcp"},
+				},
+			},
+			"internal": {
+				FW: map[string]*common.Allowed{
+					"etcd": {Port: "2379", Protocol: "tcp"},
+				},
+			},
+		},
+	}, nil, {
+		Zones: map[string]*common.Zone{
+			"external": {
+				Sources: common.MarshallableSlice{"10.0.0.0/8"},
+				FW: map[string]*common.Allowed{
+					"dns": {Port: "53", Protocol: "udp"},
+				},
+			},
+		},
+	}}
+
+	got, err := desiredPermissions("172.31.0.0/16", firewalls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []permission{
+		{protocol: "-1", cidr: "172.31.0.0/16"},
+		{protocol: "tcp", from: 22, to: 22, cidr: anywhere},
+		{protocol: "tcp", from: 30000, to: 32767, cidr: anywhere},
+		{protocol: "tcp", from: 6443, to: 6443, cidr: anywhere},
+		{protocol: "udp", from: 53, to: 53, cidr: "10.0.0.0/8"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("desiredPermissions() = %v, want %v", got, want)
+	}
+
+	if _, err := desiredPermissions("172.31.0.0/16", []*common.Firewall{{Zones: map[string]*common.Zone{
+		"external": {FW: map[string]*common.Allowed{"broken": {Port: "http"}}},
+	}}}); err == nil {
+		t.Error("expected an invalid port to fail")
+	}
+}
+
+func Test_diffPermissions(t *testing.T) {
+	ssh := sshPermission
+	https := permission{protocol: "tcp", from: 443, to: 443, cidr: anywhere}
+	stale := permission{protocol: "tcp", from: 80, to: 80, cidr: anywhere}
+
+	authorize, revoke := diffPermissions([]permission{ssh, stale}, []permission{ssh, https})
+	if !reflect.DeepEqual(authorize, []permission{https}) {
+		t.Errorf("expected to authorize %v, but got %v", https, authorize)
+	}
+	if !reflect.DeepEqual(revoke, []permission{stale}) {
+		t.Errorf("expected to revoke %v, but got %v", stale, revoke)
+	}
+}
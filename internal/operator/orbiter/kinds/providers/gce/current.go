@@ -2,6 +2,7 @@ package gce
 
 import (
 	"bytes"
+	"github.com/caos/orbos/internal/operator/orbiter"
 	"io"
 	"strings"
 
@@ -15,14 +16,18 @@ import (
 	"github.com/caos/orbos/pkg/tree"
 )
 
-var _ infra.ProviderCurrent = (*Current)(nil)
+var (
+	_ infra.ProviderCurrent = (*Current)(nil)
+	_ orbiter.Planner       = (*Current)(nil)
+)
 
 type Current struct {
 	Common  *tree.Common `yaml:",inline"`
 	Current struct {
-		pools      map[string]infra.Pool `yaml:"-"`
-		Ingresses  map[string]*infra.Address
-		cleanupped <-chan error `yaml:"-"`
+		pools          map[string]infra.Pool `yaml:"-"`
+		Ingresses      map[string]*infra.Address
+		planNodeAgents func() error `yaml:"-"`
+		cleanupped     <-chan error `yaml:"-"`
 	}
 }
 
@@ -136,3 +141,11 @@ func initPools(current *Current, desired *Spec, svc *machinesService, normalized
 	}
 	return nil
 }
+
+// Plan desires the load balancing node agent configs without touching any machine, so they are part of the plan
+func (c *Current) Plan(*orbiter.Plan) error {
+	if c.Current.planNodeAgents == nil {
+		return nil
+	}
+	return c.Current.planNodeAgents()
+}
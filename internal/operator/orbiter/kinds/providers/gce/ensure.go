@@ -71,7 +71,7 @@ func query(
 		}
 	}
 
-	queryNA, installNA := naFuncs(nodeAgentsCurrent)
+	queryNA, installNA := naFuncs(nodeAgentsDesired, nodeAgentsCurrent)
 
 	desireNodeAgent := func(pool string, machine infra.Machine) error {
 
@@ -142,6 +142,7 @@ func query(
 		}
 		panic(fmt.Errorf("external address for %v is not ensured", vip))
 	})
+	current.Current.planNodeAgents = wrappedMachines.PlanDesiredNodeAgents
 	return func(pdf func(mntr.Monitor) error) *orbiter.EnsureResult {
 
 		var done bool
@@ -174,7 +175,7 @@ func query(
 			},
 			func() error {
 				var err error
-				lbDone, err := wrappedMachines.InitializeDesiredNodeAgents()
+				lbDone, err := wrappedMachines.InitializeDesiredNodeAgents(pdf)
 				if err != nil {
 					return err
 				}
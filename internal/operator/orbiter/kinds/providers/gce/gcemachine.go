@@ -7,7 +7,6 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"strings"
 
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/ssh"
 	"github.com/caos/orbos/mntr"
@@ -39,6 +38,10 @@ func (c *gceMachine) Zone() string {
 	return c.zone
 }
 
+func (c *gceMachine) RemoteUser() string {
+	return "orbiter"
+}
+
 func (c *gceMachine) Execute(stdin io.Reader, command string) ([]byte, error) {
 	buf, err := c.execute(stdin, command)
 	defer resetBuffer(buf)
@@ -63,7 +66,7 @@ func (c *gceMachine) execute(stdin io.Reader, command string) (outBuf *bytes.Buf
 			"compute",
 			"ssh",
 			"--zone", c.zone,
-			fmt.Sprintf("orbiter@%s", c.id),
+			fmt.Sprintf("%s@%s", c.RemoteUser(), c.id),
 			"--tunnel-through-iap",
 			"--project", c.context.projectID,
 			"--command", command,
@@ -95,7 +98,7 @@ func (c *gceMachine) Shell() error {
 			"compute",
 			"ssh",
 			"--zone", c.zone,
-			fmt.Sprintf("orbiter@%s", c.id),
+			fmt.Sprintf("%s@%s", c.RemoteUser(), c.id),
 			"--tunnel-through-iap",
 			"--project", c.context.projectID,
 		)
@@ -111,18 +114,12 @@ func (c *gceMachine) Shell() error {
 
 func (c *gceMachine) WriteFile(path string, data io.Reader, permissions uint16) error {
 
-	user, err := c.Execute(nil, "whoami")
-	if err != nil {
-		return err
-	}
-
-	mkdir, writeFile := ssh.WriteFileCommands(strings.TrimSpace(string(user)), path, permissions)
-	_, err = c.Execute(nil, mkdir)
-	if err != nil {
+	mkdir, writeFile := ssh.WriteFileCommands(c.RemoteUser(), path, permissions)
+	if _, err := c.Execute(nil, mkdir); err != nil {
 		return err
 	}
 
-	_, err = c.Execute(data, writeFile)
+	_, err := c.Execute(data, writeFile)
 	return err
 }
 
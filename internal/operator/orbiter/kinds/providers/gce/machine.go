@@ -18,6 +18,7 @@ var _ infra.Machine = (*instance)(nil)
 
 type machine interface {
 	Execute(stdin io.Reader, cmd string) ([]byte, error)
+	RemoteUser() string
 	Shell() error
 	WriteFile(path string, data io.Reader, permissions uint16) error
 	ReadFile(path string, data io.Writer) error
@@ -0,0 +1,537 @@
+package openstack
+
+import (
+	"bytes"
+	ctxpkg "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// computeMicroversion enables server tags on creation and tag filters on listing
+const computeMicroversion = "2.52"
+
+var errNotFound = errors.New("not found")
+
+type credentials struct {
+	identityEndpoint            string
+	region                      string
+	username                    string
+	userDomain                  string
+	password                    string
+	project                     string
+	projectDomain               string
+	applicationCredentialID     string
+	applicationCredentialSecret string
+}
+
+type apiClient struct {
+	creds      credentials
+	httpClient *http.Client
+	token      struct {
+		value     string
+		expiresAt time.Time
+		compute   string
+		network   string
+		image     string
+		sync.Mutex
+	}
+}
+
+func newAPIClient(creds credentials, httpClient *http.Client) *apiClient {
+	return &apiClient{
+		creds:      creds,
+		httpClient: httpClient,
+	}
+}
+
+type apiError struct {
+	method string
+	url    string
+	status int
+	body   string
+}
+
+func (a *apiError) Error() string {
+	return fmt.Sprintf("%s %s returned status %d: %s", a.method, a.url, a.status, a.body)
+}
+
+func (a *apiError) Is(target error) bool {
+	return target == errNotFound && a.status == http.StatusNotFound
+}
+
+type authRequest struct {
+	Auth struct {
+		Identity struct {
+			Methods                []string                    `json:"methods"`
+			Password               *authPassword               `json:"password,omitempty"`
+			ApplicationCredentials *authApplicationCredentials `json:"application_credential,omitempty"`
+		} `json:"identity"`
+		Scope *authScope `json:"scope,omitempty"`
+	} `json:"auth"`
+}
+
+type authPassword struct {
+	User struct {
+		Name     string     `json:"name"`
+		Domain   authDomain `json:"domain"`
+		Password string     `json:"password"`
+	} `json:"user"`
+}
+
+type authApplicationCredentials struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type authDomain struct {
+	Name string `json:"name"`
+}
+
+type authScope struct {
+	Project struct {
+		Name   string     `json:"name"`
+		Domain authDomain `json:"domain"`
+	} `json:"project"`
+}
+
+type authResponse struct {
+	Token struct {
+		ExpiresAt time.Time `json:"expires_at"`
+		Catalog   []struct {
+			Type      string `json:"type"`
+			Endpoints []struct {
+				Interface string `json:"interface"`
+				Region    string `json:"region"`
+				RegionID  string `json:"region_id"`
+				URL       string `json:"url"`
+			} `json:"endpoints"`
+		} `json:"catalog"`
+	} `json:"token"`
+}
+
+func (a *apiClient) authenticate(ctx ctxpkg.Context) error {
+	a.token.Lock()
+	defer a.token.Unlock()
+
+	if a.token.value != "" && time.Now().Add(time.Minute).Before(a.token.expiresAt) {
+		return nil
+	}
+
+	req := authRequest{}
+	if a.creds.applicationCredentialID != "" {
+		req.Auth.Identity.Methods = []string{"application_credential"}
+		req.Auth.Identity.ApplicationCredentials = &authApplicationCredentials{
+			ID:     a.creds.applicationCredentialID,
+			Secret: a.creds.applicationCredentialSecret,
+		}
+	} else {
+		req.Auth.Identity.Methods = []string{"password"}
+		req.Auth.Identity.Password = &authPassword{}
+		req.Auth.Identity.Password.User.Name = a.creds.username
+		req.Auth.Identity.Password.User.Domain.Name = a.creds.userDomain
+		req.Auth.Identity.Password.User.Password = a.creds.password
+		req.Auth.Scope = &authScope{}
+		req.Auth.Scope.Project.Name = a.creds.project
+		req.Auth.Scope.Project.Domain.Name = a.creds.projectDomain
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	authURL := strings.TrimSuffix(a.creds.identityEndpoint, "/") + "/auth/tokens"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, authURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp := &authResponse{}
+	header, err := a.do(httpReq, resp)
+	if err != nil {
+		return fmt.Errorf("authenticating against %s failed: %w", authURL, err)
+	}
+
+	a.token.value = header.Get("X-Subject-Token")
+	a.token.expiresAt = resp.Token.ExpiresAt
+	a.token.compute, a.token.network, a.token.image = "", "", ""
+	for _, service := range resp.Token.Catalog {
+		for _, endpoint := range service.Endpoints {
+			if endpoint.Interface != "public" {
+				continue
+			}
+			if a.creds.region != "" && endpoint.Region != a.creds.region && endpoint.RegionID != a.creds.region {
+				continue
+			}
+			switch service.Type {
+			case "compute":
+				a.token.compute = strings.TrimSuffix(endpoint.URL, "/")
+			case "network":
+				a.token.network = strings.TrimSuffix(endpoint.URL, "/") + "/v2.0"
+			case "image":
+				a.token.image = strings.TrimSuffix(endpoint.URL, "/") + "/v2"
+			}
+		}
+	}
+
+	if a.token.value == "" {
+		return errors.New("identity service did not return a token")
+	}
+	if a.token.compute == "" || a.token.network == "" {
+		return fmt.Errorf("service catalog misses a public compute or network endpoint for region %s", a.creds.region)
+	}
+	return nil
+}
+
+type service int
+
+const (
+	computeService service = iota
+	networkService
+	imageService
+)
+
+func (a *apiClient) request(ctx ctxpkg.Context, svc service, method, path string, query url.Values, in, out interface{}) error {
+	if err := a.authenticate(ctx); err != nil {
+		return err
+	}
+
+	a.token.Lock()
+	base := a.token.compute
+	switch svc {
+	case networkService:
+		base = a.token.network
+	case imageService:
+		base = a.token.image
+	}
+	token := a.token.value
+	a.token.Unlock()
+
+	reqURL := base + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if svc == computeService {
+		req.Header.Set("OpenStack-API-Version", "compute "+computeMicroversion)
+		req.Header.Set("X-OpenStack-Nova-API-Version", computeMicroversion)
+	}
+
+	_, err = a.do(req, out)
+	return err
+}
+
+func (a *apiClient) do(req *http.Request, out interface{}) (http.Header, error) {
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &apiError{
+			method: req.Method,
+			url:    req.URL.Path,
+			status: resp.StatusCode,
+			body:   string(data),
+		}
+	}
+
+	if out == nil || len(data) == 0 {
+		return resp.Header, nil
+	}
+	return resp.Header, json.Unmarshal(data, out)
+}
+
+type server struct {
+	ID        string                     `json:"id"`
+	Name      string                     `json:"name"`
+	Status    string                     `json:"status"`
+	Metadata  map[string]string          `json:"metadata"`
+	Tags      []string                   `json:"tags"`
+	Addresses map[string][]serverAddress `json:"addresses"`
+}
+
+type serverAddress struct {
+	Addr    string `json:"addr"`
+	Version int    `json:"version"`
+	Type    string `json:"OS-EXT-IPS:type"`
+}
+
+type serverCreate struct {
+	Name             string              `json:"name"`
+	FlavorRef        string              `json:"flavorRef"`
+	ImageRef         string              `json:"imageRef"`
+	AvailabilityZone string              `json:"availability_zone,omitempty"`
+	Networks         []serverNetwork     `json:"networks"`
+	SecurityGroups   []serverSecurityGrp `json:"security_groups,omitempty"`
+	UserData         string              `json:"user_data,omitempty"`
+	Metadata         map[string]string   `json:"metadata,omitempty"`
+	Tags             []string            `json:"tags,omitempty"`
+}
+
+type serverNetwork struct {
+	UUID string `json:"uuid"`
+}
+
+type serverSecurityGrp struct {
+	Name string `json:"name"`
+}
+
+func (a *apiClient) listServers(ctx ctxpkg.Context, tags []string) ([]server, error) {
+	resp := struct {
+		Servers []server `json:"servers"`
+	}{}
+	return resp.Servers, a.request(ctx, computeService, http.MethodGet, "/servers/detail", url.Values{"tags": {strings.Join(tags, ",")}}, nil, &resp)
+}
+
+func (a *apiClient) getServer(ctx ctxpkg.Context, id string) (*server, error) {
+	resp := struct {
+		Server *server `json:"server"`
+	}{}
+	return resp.Server, a.request(ctx, computeService, http.MethodGet, "/servers/"+id, nil, nil, &resp)
+}
+
+func (a *apiClient) createServer(ctx ctxpkg.Context, create *serverCreate) (*server, error) {
+	resp := struct {
+		Server *server `json:"server"`
+	}{}
+	return resp.Server, a.request(ctx, computeService, http.MethodPost, "/servers", nil, struct {
+		Server *serverCreate `json:"server"`
+	}{Server: create}, &resp)
+}
+
+func (a *apiClient) deleteServer(ctx ctxpkg.Context, id string) error {
+	err := a.request(ctx, computeService, http.MethodDelete, "/servers/"+id, nil, nil, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+type namedResource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (a *apiClient) resolveFlavor(ctx ctxpkg.Context, nameOrID string) (string, error) {
+	resp := struct {
+		Flavors []namedResource `json:"flavors"`
+	}{}
+	if err := a.request(ctx, computeService, http.MethodGet, "/flavors", nil, nil, &resp); err != nil {
+		return "", err
+	}
+	return resolve("flavor", nameOrID, resp.Flavors)
+}
+
+func (a *apiClient) resolveImage(ctx ctxpkg.Context, nameOrID string) (string, error) {
+	resp := struct {
+		Images []namedResource `json:"images"`
+	}{}
+	if err := a.request(ctx, imageService, http.MethodGet, "/images", url.Values{"name": {nameOrID}}, nil, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Images) == 0 {
+		// nameOrID might be an ID
+		return nameOrID, nil
+	}
+	return resolve("image", nameOrID, resp.Images)
+}
+
+func (a *apiClient) resolveNetwork(ctx ctxpkg.Context, nameOrID string) (string, error) {
+	resp := struct {
+		Networks []namedResource `json:"networks"`
+	}{}
+	if err := a.request(ctx, networkService, http.MethodGet, "/networks", nil, nil, &resp); err != nil {
+		return "", err
+	}
+	return resolve("network", nameOrID, resp.Networks)
+}
+
+func resolve(kind, nameOrID string, resources []namedResource) (string, error) {
+	var found []string
+	for _, res := range resources {
+		if res.ID == nameOrID {
+			return res.ID, nil
+		}
+		if res.Name == nameOrID {
+			found = append(found, res.ID)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("%s %s: %w", kind, nameOrID, errNotFound)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("%s name %s is ambiguous, please use one of the ids %s", kind, nameOrID, strings.Join(found, ", "))
+	}
+}
+
+type port struct {
+	ID                  string            `json:"id"`
+	Name                string            `json:"name,omitempty"`
+	NetworkID           string            `json:"network_id,omitempty"`
+	DeviceID            string            `json:"device_id,omitempty"`
+	FixedIPs            []fixedIP         `json:"fixed_ips,omitempty"`
+	AllowedAddressPairs []allowedAddrPair `json:"allowed_address_pairs"`
+	Tags                []string          `json:"tags,omitempty"`
+}
+
+type fixedIP struct {
+	IPAddress string `json:"ip_address"`
+}
+
+type allowedAddrPair struct {
+	IPAddress string `json:"ip_address"`
+}
+
+func (p *port) ip() string {
+	if len(p.FixedIPs) == 0 {
+		return ""
+	}
+	return p.FixedIPs[0].IPAddress
+}
+
+func (a *apiClient) listPorts(ctx ctxpkg.Context, query url.Values) ([]port, error) {
+	resp := struct {
+		Ports []port `json:"ports"`
+	}{}
+	return resp.Ports, a.request(ctx, networkService, http.MethodGet, "/ports", query, nil, &resp)
+}
+
+func (a *apiClient) createPort(ctx ctxpkg.Context, networkID, name string, tags []string) (*port, error) {
+	resp := struct {
+		Port *port `json:"port"`
+	}{}
+	if err := a.request(ctx, networkService, http.MethodPost, "/ports", nil, map[string]interface{}{
+		"port": map[string]string{
+			"network_id": networkID,
+			"name":       name,
+		},
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Port, a.setTags(ctx, "ports", resp.Port.ID, tags)
+}
+
+func (a *apiClient) updateAllowedAddressPairs(ctx ctxpkg.Context, portID string, pairs []allowedAddrPair) error {
+	return a.request(ctx, networkService, http.MethodPut, "/ports/"+portID, nil, map[string]interface{}{
+		"port": map[string]interface{}{
+			"allowed_address_pairs": pairs,
+		},
+	}, nil)
+}
+
+func (a *apiClient) deletePort(ctx ctxpkg.Context, id string) error {
+	err := a.request(ctx, networkService, http.MethodDelete, "/ports/"+id, nil, nil, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+type floatingIP struct {
+	ID                string   `json:"id"`
+	FloatingIPAddress string   `json:"floating_ip_address"`
+	FloatingNetworkID string   `json:"floating_network_id,omitempty"`
+	PortID            *string  `json:"port_id"`
+	Tags              []string `json:"tags,omitempty"`
+}
+
+func (f *floatingIP) tag(key string) string {
+	return tagValue(f.Tags, key)
+}
+
+func (a *apiClient) listFloatingIPs(ctx ctxpkg.Context, tags []string) ([]floatingIP, error) {
+	resp := struct {
+		FloatingIPs []floatingIP `json:"floatingips"`
+	}{}
+	return resp.FloatingIPs, a.request(ctx, networkService, http.MethodGet, "/floatingips", url.Values{"tags": {strings.Join(tags, ",")}}, nil, &resp)
+}
+
+func (a *apiClient) createFloatingIP(ctx ctxpkg.Context, floatingNetworkID, portID string, tags []string) (*floatingIP, error) {
+	create := map[string]interface{}{
+		"floating_network_id": floatingNetworkID,
+	}
+	if portID != "" {
+		create["port_id"] = portID
+	}
+	resp := struct {
+		FloatingIP *floatingIP `json:"floatingip"`
+	}{}
+	if err := a.request(ctx, networkService, http.MethodPost, "/floatingips", nil, map[string]interface{}{
+		"floatingip": create,
+	}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.FloatingIP, a.setTags(ctx, "floatingips", resp.FloatingIP.ID, tags)
+}
+
+func (a *apiClient) associateFloatingIP(ctx ctxpkg.Context, id, portID string) error {
+	return a.request(ctx, networkService, http.MethodPut, "/floatingips/"+id, nil, map[string]interface{}{
+		"floatingip": map[string]string{
+			"port_id": portID,
+		},
+	}, nil)
+}
+
+func (a *apiClient) deleteFloatingIP(ctx ctxpkg.Context, id string) error {
+	err := a.request(ctx, networkService, http.MethodDelete, "/floatingips/"+id, nil, nil, nil)
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (a *apiClient) setTags(ctx ctxpkg.Context, collection, id string, tags []string) error {
+	return a.request(ctx, networkService, http.MethodPut, fmt.Sprintf("/%s/%s/tags", collection, id), nil, map[string][]string{
+		"tags": tags,
+	}, nil)
+}
+
+func tag(key, value string) string {
+	return key + "=" + value
+}
+
+func tagValue(tags []string, key string) string {
+	prefix := key + "="
+	for _, t := range tags {
+		if strings.HasPrefix(t, prefix) {
+			return strings.TrimPrefix(t, prefix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,466 @@
+package openstack
+
+import (
+	ctxpkg "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic"
+	"github.com/caos/orbos/internal/ssh"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+// fakeOpenStack implements the subset of the keystone, nova and neutron APIs the provider uses
+type fakeOpenStack struct {
+	sync.Mutex
+	server      *httptest.Server
+	auths       int
+	servers     map[string]*server
+	ports       map[string]*port
+	floatingIPs map[string]*floatingIP
+	ids         int
+}
+
+func newFakeOpenStack(t *testing.T) *fakeOpenStack {
+	fake := &fakeOpenStack{
+		servers:     make(map[string]*server),
+		ports:       make(map[string]*port),
+		floatingIPs: make(map[string]*floatingIP),
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+func (f *fakeOpenStack) nextID() string {
+	f.ids++
+	return fmt.Sprintf("id-%d", f.ids)
+}
+
+func (f *fakeOpenStack) handle(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if r.URL.Path == "/identity/v3/auth/tokens" {
+		f.auths++
+		w.Header().Set("X-Subject-Token", "token")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token": map[string]interface{}{
+				"expires_at": time.Now().Add(time.Hour),
+				"catalog": []map[string]interface{}{{
+					"type": "compute",
+					"endpoints": []map[string]string{
+						{"interface": "public", "region": "other", "url": "http://wrong"},
+						{"interface": "public", "region": "test", "url": f.server.URL + "/compute"},
+					},
+				}, {
+					"type": "network",
+					"endpoints": []map[string]string{
+						{"interface": "public", "region": "test", "url": f.server.URL + "/network/"},
+					},
+				}, {
+					"type": "image",
+					"endpoints": []map[string]string{
+						{"interface": "public", "region": "test", "url": f.server.URL + "/image"},
+					},
+				}},
+			},
+		})
+		return
+	}
+
+	if r.Header.Get("X-Auth-Token") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body := make(map[string]interface{})
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	field := func(object, key string) string {
+		value, _ := body[object].(map[string]interface{})[key].(string)
+		return value
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/compute/") || strings.HasPrefix(r.URL.Path, "/image/") {
+		f.handleCompute(w, r, body)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/network/v2.0")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && path == "/networks":
+		json.NewEncoder(w).Encode(map[string]interface{}{"networks": []namedResource{
+			{ID: "net-private", Name: "private"},
+			{ID: "net-public", Name: "public"},
+		}})
+	case r.Method == http.MethodGet && path == "/ports":
+		var ports []*port
+		for _, p := range f.ports {
+			if hasTags(p.Tags, r.URL.Query().Get("tags")) &&
+				(r.URL.Query().Get("device_id") == "" || r.URL.Query().Get("device_id") == p.DeviceID) {
+				ports = append(ports, p)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"ports": ports})
+	case r.Method == http.MethodPost && path == "/ports":
+		p := &port{
+			ID:        f.nextID(),
+			NetworkID: field("port", "network_id"),
+			FixedIPs:  []fixedIP{{IPAddress: fmt.Sprintf("10.0.0.%d", f.ids)}},
+		}
+		f.ports[p.ID] = p
+		json.NewEncoder(w).Encode(map[string]interface{}{"port": p})
+	case r.Method == http.MethodGet && path == "/floatingips":
+		var fips []*floatingIP
+		for _, fip := range f.floatingIPs {
+			if hasTags(fip.Tags, r.URL.Query().Get("tags")) {
+				fips = append(fips, fip)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"floatingips": fips})
+	case r.Method == http.MethodPost && path == "/floatingips":
+		portID := field("floatingip", "port_id")
+		fip := &floatingIP{
+			ID:                f.nextID(),
+			FloatingIPAddress: fmt.Sprintf("192.0.2.%d", f.ids),
+			FloatingNetworkID: field("floatingip", "floating_network_id"),
+			PortID:            &portID,
+		}
+		f.floatingIPs[fip.ID] = fip
+		json.NewEncoder(w).Encode(map[string]interface{}{"floatingip": fip})
+	case r.Method == http.MethodPut && len(parts) == 3 && parts[2] == "tags":
+		var tags []string
+		for _, t := range body["tags"].([]interface{}) {
+			tags = append(tags, t.(string))
+		}
+		switch parts[0] {
+		case "ports":
+			f.ports[parts[1]].Tags = tags
+		case "floatingips":
+			f.floatingIPs[parts[1]].Tags = tags
+		}
+	case r.Method == http.MethodDelete && len(parts) == 2:
+		switch parts[0] {
+		case "ports":
+			delete(f.ports, parts[1])
+		case "floatingips":
+			delete(f.floatingIPs, parts[1])
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeOpenStack) handleCompute(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/compute/flavors":
+		json.NewEncoder(w).Encode(map[string]interface{}{"flavors": []namedResource{{ID: "flavor-1", Name: "m1.medium"}}})
+	case r.Method == http.MethodGet && r.URL.Path == "/image/v2/images":
+		json.NewEncoder(w).Encode(map[string]interface{}{"images": []namedResource{{ID: "image-1", Name: r.URL.Query().Get("name")}}})
+	case r.Method == http.MethodGet && r.URL.Path == "/compute/servers/detail":
+		servers := make([]*server, 0)
+		for _, srv := range f.servers {
+			if hasTags(srv.Tags, r.URL.Query().Get("tags")) {
+				servers = append(servers, srv)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"servers": servers})
+	case r.Method == http.MethodPost && r.URL.Path == "/compute/servers":
+		create, _ := body["server"].(map[string]interface{})
+		srv := &server{
+			ID:       f.nextID(),
+			Name:     create["name"].(string),
+			Status:   "ACTIVE",
+			Metadata: make(map[string]string),
+		}
+		for key, value := range create["metadata"].(map[string]interface{}) {
+			srv.Metadata[key] = value.(string)
+		}
+		for _, t := range create["tags"].([]interface{}) {
+			srv.Tags = append(srv.Tags, t.(string))
+		}
+		p := &port{ID: f.nextID(), DeviceID: srv.ID, FixedIPs: []fixedIP{{IPAddress: fmt.Sprintf("10.0.0.%d", f.ids)}}}
+		f.ports[p.ID] = p
+		srv.Addresses = map[string][]serverAddress{"private": {{Addr: p.ip(), Version: 4, Type: "fixed"}}}
+		f.servers[srv.ID] = srv
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{"server": srv})
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[1] == "servers":
+		srv, ok := f.servers[parts[2]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"server": srv})
+	case r.Method == http.MethodDelete && len(parts) == 3 && parts[1] == "servers":
+		if _, ok := f.servers[parts[2]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.servers, parts[2])
+		for id, p := range f.ports {
+			if p.DeviceID == parts[2] {
+				delete(f.ports, id)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func hasTags(tags []string, filter string) bool {
+	if filter == "" {
+		return true
+	}
+filterLoop:
+	for _, f := range strings.Split(filter, ",") {
+		for _, t := range tags {
+			if t == f {
+				continue filterLoop
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func testContext(fake *fakeOpenStack) *context {
+	return testMachinesContext(fake, true, false)
+}
+
+var (
+	testKeyOnce sync.Once
+	testKey     *SSHKey
+)
+
+// testSSHKey is generated once, as generating keys is slow
+func testSSHKey() *SSHKey {
+	testKeyOnce.Do(func() {
+		private, public := ssh.Generate()
+		testKey = &SSHKey{
+			Private: &secret.Secret{Value: private},
+			Public:  &secret.Secret{Value: public},
+		}
+	})
+	return testKey
+}
+
+func testMachinesContext(fake *fakeOpenStack, oneoff, machineFloatingIPs bool) *context {
+	sshKey := testSSHKey()
+	ctx := buildContext(mntr.Monitor{}, &Spec{
+		IdentityEndpoint:   fake.server.URL + "/identity/v3",
+		Region:             "test",
+		User:               "user",
+		Project:            "project",
+		Network:            "private",
+		FloatingNetwork:    "public",
+		MachineFloatingIPs: machineFloatingIPs,
+		Pools: map[string]*Pool{"workers": {
+			Flavor: "m1.medium",
+			Image:  "CentOS-7-x86_64-GenericCloud",
+		}},
+		SSHKey: sshKey,
+	}, "orb", "provider", oneoff)
+	if err := ctx.machinesService.use(sshKey); err != nil {
+		panic(err)
+	}
+	ctx.machinesService.onCreate = func(string, infra.Machine) error { return nil }
+	return ctx
+}
+
+func Test_apiClient_authenticate(t *testing.T) {
+	fake := newFakeOpenStack(t)
+	client := testContext(fake).client
+
+	for i := 0; i < 2; i++ {
+		if err := client.authenticate(ctxpkg.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fake.auths != 1 {
+		t.Errorf("expected the token to be reused, but authenticated %d times", fake.auths)
+	}
+	if client.token.compute != fake.server.URL+"/compute" {
+		t.Errorf("expected compute endpoint of region test, but got %s", client.token.compute)
+	}
+	if client.token.network != fake.server.URL+"/network/v2.0" {
+		t.Errorf("expected versioned network endpoint, but got %s", client.token.network)
+	}
+}
+
+func Test_queryFloatingIPs(t *testing.T) {
+	fake := newFakeOpenStack(t)
+	ctx := testContext(fake)
+	if err := ctx.resolveNetworks(); err != nil {
+		t.Fatal(err)
+	}
+
+	loadbalancing := map[string][]*dynamic.VIP{
+		"lbs": {{Transport: []*dynamic.Transport{{
+			Name:         "kubeapi",
+			FrontendPort: 6443,
+			BackendPort:  6666,
+		}}}},
+	}
+
+	current := &Current{}
+	ensure, remove, err := queryFloatingIPs(ctx, loadbalancing, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ensure) != 1 || len(remove) != 0 {
+		t.Fatalf("expected one ensure and no remove func, but got %d and %d", len(ensure), len(remove))
+	}
+	if err := ensure[0](); err != nil {
+		t.Fatal(err)
+	}
+
+	ingress := current.Current.Ingresses["kubeapi"]
+	if ingress == nil || !strings.HasPrefix(ingress.Location, "192.0.2.") || ingress.FrontendPort != 6443 {
+		t.Errorf("expected a floating ip ingress, but got %+v", ingress)
+	}
+	if vip := desiredToCurrentVIP(current)(loadbalancing["lbs"][0]); !strings.HasPrefix(vip, "10.0.0.") {
+		t.Errorf("expected the vip to be a private address, but got %s", vip)
+	}
+
+	current = &Current{}
+	ensure, remove, err = queryFloatingIPs(ctx, loadbalancing, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ensure) != 0 || len(remove) != 0 {
+		t.Fatalf("expected no changes, but got %d ensure and %d remove funcs", len(ensure), len(remove))
+	}
+	if current.Current.Ingresses["kubeapi"].Location != ingress.Location {
+		t.Errorf("expected ingress %s to be stable, but got %s", ingress.Location, current.Current.Ingresses["kubeapi"].Location)
+	}
+
+	_, remove, err = queryFloatingIPs(ctx, nil, &Current{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range remove {
+		if err := r(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(fake.ports) != 0 || len(fake.floatingIPs) != 0 {
+		t.Errorf("expected all vip resources to be removed, but %d ports and %d floating ips are left", len(fake.ports), len(fake.floatingIPs))
+	}
+}
+
+func TestMachinesService_CreateListDestroy(t *testing.T) {
+	tests := []struct {
+		name               string
+		oneoff             bool
+		machineFloatingIPs bool
+		wantFloatingIP     bool
+	}{{
+		name:           "It should allocate a floating ip when running oneoff",
+		oneoff:         true,
+		wantFloatingIP: true,
+	}, {
+		name:               "It should allocate a floating ip when machine floating ips are configured",
+		machineFloatingIPs: true,
+		wantFloatingIP:     true,
+	}, {
+		name: "It should not allocate a floating ip when it is not needed",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOpenStack(t)
+
+			created, err := testMachinesContext(fake, tt.oneoff, tt.machineFloatingIPs).machinesService.Create("workers", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(created) != 1 || !strings.HasPrefix(created[0].IP(), "10.0.0.") {
+				t.Fatalf("expected one created machine with a private ip, got %v", created)
+			}
+			if allocated := len(fake.floatingIPs) == 1; allocated != tt.wantFloatingIP {
+				t.Errorf("expected a floating ip to be allocated %t, but got %d floating ips", tt.wantFloatingIP, len(fake.floatingIPs))
+			}
+			for _, srv := range fake.servers {
+				if tagValue(srv.Tags, "orb") != "orb" || tagValue(srv.Tags, "provider") != "provider" || srv.Metadata["pool"] != "workers" {
+					t.Errorf("server is not tagged with its orb, provider and pool: %v, %v", srv.Tags, srv.Metadata)
+				}
+			}
+
+			// Listing only returns the providers machines
+			fake.Lock()
+			fake.servers["foreign"] = &server{ID: "foreign", Name: "foreign", Status: "ACTIVE", Tags: []string{tag("orb", "other")}, Metadata: map[string]string{"pool": "workers"}}
+			fake.Unlock()
+
+			listed, err := testMachinesContext(fake, tt.oneoff, tt.machineFloatingIPs).machinesService.List("workers")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listed) != 1 || listed[0].ID() != created[0].ID() {
+				t.Fatalf("expected to list the created machine %s, got %v", created[0].ID(), listed)
+			}
+
+			destroy, err := listed[0].Destroy()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := destroy(); err != nil {
+				t.Fatal(err)
+			}
+
+			listed, err = testMachinesContext(fake, tt.oneoff, tt.machineFloatingIPs).machinesService.List("workers")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(listed) != 0 {
+				t.Errorf("expected the destroyed machine not to be listed, got %v", listed)
+			}
+			if len(fake.floatingIPs) != 0 {
+				t.Errorf("expected the machines floating ip to be released, but %d are left", len(fake.floatingIPs))
+			}
+		})
+	}
+}
+
+func TestMachinesService_CreateConcurrently(t *testing.T) {
+	fake := newFakeOpenStack(t)
+	service := testMachinesContext(fake, false, false).machinesService
+	if _, err := service.List("workers"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Create("workers", 0); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	listed, err := service.List("workers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 5 {
+		t.Errorf("expected all created machines to be cached, but got %d", len(listed))
+	}
+}
@@ -0,0 +1,90 @@
+package openstack
+
+import (
+	ctxpkg "context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/caos/orbos/mntr"
+)
+
+type context struct {
+	monitor           mntr.Monitor
+	orbID             string
+	providerID        string
+	desired           *Spec
+	client            *apiClient
+	networkID         string
+	floatingNetworkID string
+	networksMux       sync.Mutex
+	machinesService   *machinesService
+	ctx               ctxpkg.Context
+}
+
+func buildContext(monitor mntr.Monitor, desired *Spec, orbID, providerID string, oneoff bool) *context {
+
+	newContext := &context{
+		monitor:    monitor,
+		orbID:      orbID,
+		providerID: providerID,
+		desired:    desired,
+		client:     newAPIClient(credentialsFromSpec(desired), &http.Client{Timeout: 30 * time.Second}),
+		ctx:        ctxpkg.Background(),
+	}
+
+	newContext.machinesService = newMachinesService(newContext, oneoff)
+
+	return newContext
+}
+
+func credentialsFromSpec(desired *Spec) credentials {
+	creds := credentials{
+		identityEndpoint:        desired.IdentityEndpoint,
+		region:                  desired.Region,
+		username:                desired.User,
+		userDomain:              defaultDomain(desired.UserDomain),
+		project:                 desired.Project,
+		projectDomain:           defaultDomain(desired.ProjectDomain),
+		applicationCredentialID: desired.ApplicationCredentialID,
+	}
+	if desired.Password != nil {
+		creds.password = desired.Password.Value
+	}
+	if desired.ApplicationCredentialSecret != nil {
+		creds.applicationCredentialSecret = desired.ApplicationCredentialSecret.Value
+	}
+	return creds
+}
+
+func defaultDomain(domain string) string {
+	if domain == "" {
+		return "Default"
+	}
+	return domain
+}
+
+// resolveNetworks translates the configured network names to ids once
+func (c *context) resolveNetworks() (err error) {
+	c.networksMux.Lock()
+	defer c.networksMux.Unlock()
+
+	if c.networkID == "" {
+		if c.networkID, err = c.client.resolveNetwork(c.ctx, c.desired.Network); err != nil {
+			return err
+		}
+	}
+	if c.floatingNetworkID == "" {
+		if c.floatingNetworkID, err = c.client.resolveNetwork(c.ctx, c.desired.FloatingNetwork); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *context) tags(more ...string) []string {
+	return append([]string{
+		tag("orb", c.orbID),
+		tag("provider", c.providerID),
+	}, more...)
+}
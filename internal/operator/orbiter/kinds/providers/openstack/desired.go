@@ -0,0 +1,142 @@
+package openstack
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/caos/orbos/mntr"
+
+	"github.com/caos/orbos/pkg/secret"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+type Desired struct {
+	Common        *tree.Common `yaml:",inline"`
+	Spec          Spec
+	Loadbalancing *tree.Tree
+}
+
+type Pool struct {
+	Flavor string
+	Image  string
+	Zone   string
+}
+
+func (p Pool) validate() error {
+	if p.Flavor == "" {
+		return errors.New("no flavor configured")
+	}
+	if p.Image == "" {
+		return errors.New("no image configured")
+	}
+	return nil
+}
+
+type Spec struct {
+	Verbose bool
+	// IdentityEndpoint is the keystone v3 URL, e.g. https://keystone.example.com:5000/v3
+	IdentityEndpoint string
+	Region           string
+	// Project and User are only used for password authentication
+	Project                     string
+	ProjectDomain               string
+	User                        string
+	UserDomain                  string
+	Password                    *secret.Secret `yaml:",omitempty"`
+	ApplicationCredentialID     string
+	ApplicationCredentialSecret *secret.Secret `yaml:",omitempty"`
+	// Network is the name or id of the private network machines are attached to
+	Network string
+	// FloatingNetwork is the name or id of the external network floating ips are allocated from
+	FloatingNetwork string
+	// MachineFloatingIPs lets ORBITER allocate a floating ip for each machine, so orbctl reaches them from outside the private network.
+	// Machines created by orbctl always get a floating ip.
+	MachineFloatingIPs  bool
+	SecurityGroups      []string
+	Pools               map[string]*Pool
+	SSHKey              *SSHKey
+	RebootRequired      []string
+	ReplacementRequired []string
+}
+
+type SSHKey struct {
+	Private *secret.Secret `yaml:",omitempty"`
+	Public  *secret.Secret `yaml:",omitempty"`
+}
+
+func (d Desired) validateAdapt() (err error) {
+
+	defer func() {
+		err = mntr.ToUserError(err)
+	}()
+
+	if d.Loadbalancing == nil {
+		return errors.New("no loadbalancing configured")
+	}
+	if d.Spec.IdentityEndpoint == "" {
+		return errors.New("no identityendpoint configured")
+	}
+	if d.Spec.Network == "" {
+		return errors.New("no network configured")
+	}
+	if d.Spec.FloatingNetwork == "" {
+		return errors.New("no floatingnetwork configured")
+	}
+	if len(d.Spec.Pools) == 0 {
+		return errors.New("no pools configured")
+	}
+	for poolName, pool := range d.Spec.Pools {
+		if err := pool.validate(); err != nil {
+			return fmt.Errorf("configuring pool %s failed: %w", poolName, err)
+		}
+	}
+	return nil
+}
+
+func (d Desired) validateCredentials() error {
+	if d.Spec.ApplicationCredentialID != "" {
+		if d.Spec.ApplicationCredentialSecret == nil || d.Spec.ApplicationCredentialSecret.Value == "" {
+			return mntr.ToUserError(errors.New("applicationcredentialsecret missing... please provide it using orbctl writesecret command"))
+		}
+		return nil
+	}
+	if d.Spec.User == "" || d.Spec.Project == "" {
+		return mntr.ToUserError(errors.New("either applicationcredentialid or user and project must be configured"))
+	}
+	if d.Spec.Password == nil || d.Spec.Password.Value == "" {
+		return mntr.ToUserError(errors.New("password missing... please provide an openstack password using orbctl writesecret command"))
+	}
+	return nil
+}
+
+func (d Desired) validateQuery() (err error) {
+	defer func() {
+		err = mntr.ToUserError(err)
+	}()
+
+	if err := d.validateCredentials(); err != nil {
+		return err
+	}
+
+	if d.Spec.SSHKey.Private == nil ||
+		d.Spec.SSHKey.Private.Value == "" ||
+		d.Spec.SSHKey.Public == nil ||
+		d.Spec.SSHKey.Public.Value == "" {
+		return errors.New("ssh key missing... please initialize your orb using orbctl configure command")
+	}
+
+	return nil
+}
+
+func parseDesired(desiredTree *tree.Tree) (*Desired, error) {
+	desiredKind := &Desired{
+		Common: desiredTree.Common,
+		Spec:   Spec{},
+	}
+
+	if err := desiredTree.Original.Decode(desiredKind); err != nil {
+		return nil, mntr.ToUserError(fmt.Errorf("parsing desired state failed: %w", err))
+	}
+
+	return desiredKind, nil
+}
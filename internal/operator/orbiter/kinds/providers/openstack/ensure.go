@@ -0,0 +1,122 @@
+package openstack
+
+import (
+	"fmt"
+
+	"github.com/caos/orbos/internal/helpers"
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	dynamiclbmodel "github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic/wrap"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/mntr"
+)
+
+func query(
+	desired *Spec,
+	current *Current,
+	lb interface{},
+	context *context,
+	nodeAgentsCurrent *common.CurrentNodeAgents,
+	nodeAgentsDesired *common.DesiredNodeAgents,
+	naFuncs core.IterateNodeAgentFuncs,
+	orbiterCommit string,
+) (ensureFunc orbiter.EnsureFunc, err error) {
+
+	lbCurrent, ok := lb.(*dynamiclbmodel.Current)
+	if !ok {
+		panic(fmt.Errorf("unknown or unsupported load balancing of type %T", lb))
+	}
+
+	if err := context.resolveNetworks(); err != nil {
+		return nil, err
+	}
+
+	hostPools, _, err := lbCurrent.Current.Spec(context.machinesService)
+	if err != nil {
+		return nil, err
+	}
+
+	ensureFIPs, removeFIPs, err := queryFloatingIPs(context, hostPools, current)
+	if err != nil {
+		return nil, err
+	}
+
+	queryNA, installNA := naFuncs(nodeAgentsDesired, nodeAgentsCurrent)
+	ensureNodeAgent := func(m infra.Machine) error {
+		running, err := queryNA(m, orbiterCommit)
+		if err != nil {
+			return err
+		}
+		if !running {
+			return installNA(m)
+		}
+		return nil
+	}
+
+	context.machinesService.cache.Lock()
+	machines, err := context.machinesService.machines()
+	if err != nil {
+		context.machinesService.cache.Unlock()
+		return nil, err
+	}
+
+	var ensureNodeAgents []func() error
+	for _, poolMachines := range machines {
+		for idx := range poolMachines {
+			ensureNodeAgents = append(ensureNodeAgents, func(m infra.Machine) func() error {
+				return func() error { return ensureNodeAgent(m) }
+			}(poolMachines[idx]))
+		}
+	}
+	context.machinesService.cache.Unlock()
+
+	context.machinesService.onCreate = func(pool string, m infra.Machine) error {
+		_, err := core.DesireInternalOSFirewall(context.monitor, nodeAgentsDesired, nodeAgentsCurrent, context.machinesService, false, []string{"eth0"})
+		if err != nil {
+			return err
+		}
+
+		return ensureNodeAgent(m)
+	}
+	wrappedMachines := wrap.MachinesService(context.machinesService, *lbCurrent, &dynamiclbmodel.VRRP{
+		VRRPInterface: "eth0",
+		VIPInterface:  "eth0",
+	}, desiredToCurrentVIP(current))
+	current.Current.planNodeAgents = wrappedMachines.PlanDesiredNodeAgents
+	return func(pdf func(mntr.Monitor) error) *orbiter.EnsureResult {
+		var done bool
+
+		// VIPs are ensured sequentially, as they write to the current state
+		for _, ensureFIP := range ensureFIPs {
+			if err := ensureFIP(); err != nil {
+				return orbiter.ToEnsureResult(false, err)
+			}
+		}
+
+		ensurePairs, err := ensureAllowedAddressPairs(context, hostPools, current)
+		if err != nil {
+			return orbiter.ToEnsureResult(false, err)
+		}
+
+		return orbiter.ToEnsureResult(done, helpers.Fanout([]func() error{
+			func() error { return helpers.Fanout(removeFIPs)() },
+			func() error { return helpers.Fanout(ensurePairs)() },
+			func() error { return helpers.Fanout(ensureNodeAgents)() },
+			func() error {
+				lbDone, err := wrappedMachines.InitializeDesiredNodeAgents(pdf)
+				if err != nil {
+					return err
+				}
+
+				fwDone, err := core.DesireInternalOSFirewall(context.monitor, nodeAgentsDesired, nodeAgentsCurrent, context.machinesService, false, []string{"eth0"})
+				if err != nil {
+					return err
+				}
+				done = lbDone && fwDone
+				return nil
+			},
+		})())
+	}, addPools(current, desired, wrappedMachines)
+}
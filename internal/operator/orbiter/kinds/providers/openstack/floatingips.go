@@ -0,0 +1,185 @@
+package openstack
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic"
+)
+
+// Each desired VIP is backed by an unbound neutron port which reserves a private address for keepalived.
+// A floating ip is associated with that port and serves as ingress.
+func queryFloatingIPs(context *context, loadbalancing map[string][]*dynamic.VIP, writeTo *Current) ([]func() error, []func() error, error) {
+
+	vipPorts, err := context.client.listPorts(context.ctx, url.Values{"tags": {strings.Join(context.tags(tag("role", "vip")), ",")}})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	floatingIPs, err := context.client.listFloatingIPs(context.ctx, context.tags(tag("role", "vip")))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ensure []func() error
+	for hostPool, vips := range loadbalancing {
+		for vipIdx := range vips {
+			vip := vips[vipIdx]
+			var (
+				vipPort *port
+				vipFIP  *floatingIP
+			)
+			for portIdx := range vipPorts {
+				if matches(vipPorts[portIdx].Tags, hostPool, vipIdx) {
+					vipPort = &vipPorts[portIdx]
+				}
+			}
+			for fipIdx := range floatingIPs {
+				if matches(floatingIPs[fipIdx].Tags, hostPool, vipIdx) {
+					vipFIP = &floatingIPs[fipIdx]
+				}
+			}
+
+			if vipPort != nil && vipFIP != nil && vipFIP.PortID != nil && *vipFIP.PortID == vipPort.ID {
+				ensureCurrentIngress(writeTo, vip, vipPort.ip(), vipFIP.FloatingIPAddress)
+				continue
+			}
+
+			ensure = append(ensure, func(hostPool string, vipIdx int, vip *dynamic.VIP, vipPort *port, vipFIP *floatingIP) func() error {
+				return func() error {
+					tags := context.tags(tag("role", "vip"), tag("pool", hostPool), tag("idx", strconv.Itoa(vipIdx)))
+					if vipPort == nil {
+						created, err := context.client.createPort(context.ctx, context.networkID, fmt.Sprintf("orbos-vip-%s-%d", hostPool, vipIdx), tags)
+						if err != nil {
+							return err
+						}
+						vipPort = created
+					}
+					if vipFIP == nil {
+						created, err := context.client.createFloatingIP(context.ctx, context.floatingNetworkID, vipPort.ID, tags)
+						if err != nil {
+							return err
+						}
+						vipFIP = created
+					} else if err := context.client.associateFloatingIP(context.ctx, vipFIP.ID, vipPort.ID); err != nil {
+						return err
+					}
+					ensureCurrentIngress(writeTo, vip, vipPort.ip(), vipFIP.FloatingIPAddress)
+					return nil
+				}
+			}(hostPool, vipIdx, vip, vipPort, vipFIP))
+		}
+	}
+
+	var remove []func() error
+	for fipIdx := range floatingIPs {
+		fip := floatingIPs[fipIdx]
+		if !desired(fip.Tags, loadbalancing) {
+			remove = append(remove, func(id string) func() error {
+				return func() error { return context.client.deleteFloatingIP(context.ctx, id) }
+			}(fip.ID))
+		}
+	}
+	for portIdx := range vipPorts {
+		vipPort := vipPorts[portIdx]
+		if !desired(vipPort.Tags, loadbalancing) {
+			remove = append(remove, func(id string) func() error {
+				return func() error { return context.client.deletePort(context.ctx, id) }
+			}(vipPort.ID))
+		}
+	}
+	return ensure, remove, nil
+}
+
+// ensureAllowedAddressPairs permits the keepalived VIPs on the load balancing machines ports
+func ensureAllowedAddressPairs(context *context, loadbalancing map[string][]*dynamic.VIP, current *Current) ([]func() error, error) {
+	var ensure []func() error
+	for hostPool, vips := range loadbalancing {
+		machines, err := context.machinesService.List(hostPool)
+		if err != nil {
+			return nil, err
+		}
+
+		var pairs []allowedAddrPair
+		for _, vip := range vips {
+			for _, transport := range vip.Transport {
+				if ip, ok := current.Current.vips[transport.Name]; ok && ip != "" {
+					pairs = append(pairs, allowedAddrPair{IPAddress: ip})
+					break
+				}
+			}
+		}
+
+		for idx := range machines {
+			ensure = append(ensure, func(m *machine) func() error {
+				return func() error {
+					ports, err := context.client.listPorts(context.ctx, url.Values{"device_id": {m.server.ID}})
+					if err != nil {
+						return err
+					}
+					for portIdx := range ports {
+						p := ports[portIdx]
+						if containsPairs(p.AllowedAddressPairs, pairs) {
+							continue
+						}
+						if err := context.client.updateAllowedAddressPairs(context.ctx, p.ID, pairs); err != nil {
+							return err
+						}
+						context.monitor.WithField("machine", m.ID()).Info("Allowed address pairs updated")
+					}
+					return nil
+				}
+			}(machines[idx].(*machine)))
+		}
+	}
+	return ensure, nil
+}
+
+func containsPairs(existing, desired []allowedAddrPair) bool {
+	if len(existing) != len(desired) {
+		return false
+	}
+desiredLoop:
+	for _, d := range desired {
+		for _, e := range existing {
+			if e.IPAddress == d.IPAddress {
+				continue desiredLoop
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matches(tags []string, hostPool string, vipIdx int) bool {
+	return tagValue(tags, "pool") == hostPool && tagValue(tags, "idx") == strconv.Itoa(vipIdx)
+}
+
+func desired(tags []string, loadbalancing map[string][]*dynamic.VIP) bool {
+	vips, ok := loadbalancing[tagValue(tags, "pool")]
+	if !ok {
+		return false
+	}
+	idx, err := strconv.Atoi(tagValue(tags, "idx"))
+	return err == nil && idx < len(vips)
+}
+
+func ensureCurrentIngress(writeTo *Current, vip *dynamic.VIP, internalIP, floatingIP string) {
+	if writeTo.Current.Ingresses == nil {
+		writeTo.Current.Ingresses = make(map[string]*infra.Address)
+	}
+	if writeTo.Current.vips == nil {
+		writeTo.Current.vips = make(map[string]string)
+	}
+	for _, transport := range vip.Transport {
+		writeTo.Current.vips[transport.Name] = internalIP
+		writeTo.Current.Ingresses[transport.Name] = &infra.Address{
+			Location:     floatingIP,
+			FrontendPort: uint16(transport.FrontendPort),
+			BackendPort:  uint16(transport.BackendPort),
+		}
+	}
+}
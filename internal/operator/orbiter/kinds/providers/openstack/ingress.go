@@ -0,0 +1,22 @@
+package openstack
+
+import (
+	"fmt"
+
+	dynamiclbmodel "github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers/dynamic"
+)
+
+// desiredToCurrentVIP maps a VIP to its reserved private address.
+// Neutron NATs the floating ip to this address, so keepalived and NGINX bind to it.
+func desiredToCurrentVIP(current *Current) func(vip *dynamiclbmodel.VIP) string {
+	return func(vip *dynamiclbmodel.VIP) string {
+		for idx := range vip.Transport {
+			transport := vip.Transport[idx]
+			ip, ok := current.Current.vips[transport.Name]
+			if ok {
+				return ip
+			}
+		}
+		panic(fmt.Errorf("internal address for %v is not ensured", vip))
+	}
+}
@@ -0,0 +1,375 @@
+package openstack
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/caos/orbos/internal/helpers"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/loadbalancers"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/cs"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/ssh"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/secret"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+func ListMachines(monitor mntr.Monitor, desiredTree *tree.Tree, orbID, providerID string) (map[string]infra.Machine, error) {
+	desired, err := parseDesired(desiredTree)
+	if err != nil {
+		return nil, fmt.Errorf("parsing desired state failed: %w", err)
+	}
+	desiredTree.Parsed = desired
+
+	_, _, _, _, _, err = loadbalancers.GetQueryAndDestroyFunc(monitor, nil, desired.Loadbalancing, &tree.Tree{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := buildContext(monitor, &desired.Spec, orbID, providerID, true)
+
+	if err := ctx.machinesService.use(desired.Spec.SSHKey); err != nil {
+		invalidKey := &secret.Secret{Value: "invalid"}
+		if err := ctx.machinesService.use(&SSHKey{
+			Private: invalidKey,
+			Public:  invalidKey,
+		}); err != nil {
+			panic(err)
+		}
+	}
+
+	return core.ListMachines(ctx.machinesService)
+}
+
+var _ core.MachinesService = (*machinesService)(nil)
+
+type machinesService struct {
+	context *context
+	oneoff  bool
+	key     *SSHKey
+	cache   struct {
+		instances map[string][]*machine
+		sync.Mutex
+	}
+	onCreate func(pool string, machine infra.Machine) error
+}
+
+func newMachinesService(context *context, oneoff bool) *machinesService {
+	return &machinesService{
+		context: context,
+		oneoff:  oneoff,
+	}
+}
+
+func (m *machinesService) DesiredMachines(poolName string, instances int) int {
+	_, ok := m.context.desired.Pools[poolName]
+	if !ok {
+		return 0
+	}
+
+	return instances
+}
+
+func (m *machinesService) use(key *SSHKey) error {
+	if key == nil || key.Private == nil || key.Public == nil || key.Private.Value == "" || key.Public.Value == "" {
+		return mntr.ToUserError(errors.New("machines are not connectable. have you configured the orb by running orbctl configure?"))
+	}
+	m.key = key
+	return nil
+}
+
+func (m *machinesService) Create(poolName string, _ int) (infra.Machines, error) {
+
+	desired, ok := m.context.desired.Pools[poolName]
+	if !ok {
+		return nil, fmt.Errorf("Pool %s is not configured", poolName)
+	}
+
+	if err := m.context.resolveNetworks(); err != nil {
+		return nil, err
+	}
+
+	flavorID, err := m.context.client.resolveFlavor(m.context.ctx, desired.Flavor)
+	if err != nil {
+		return nil, mntr.ToUserError(err)
+	}
+
+	imageID, err := m.context.client.resolveImage(m.context.ctx, desired.Image)
+	if err != nil {
+		return nil, mntr.ToUserError(err)
+	}
+
+	name := newName()
+	monitor := machineMonitor(m.context.monitor, name, poolName)
+
+	monitor.Debug("Creating instance")
+
+	userData, err := cs.NewCloudinit().AddGroupWithoutUsers(
+		"orbiter",
+	).AddUser(
+		"orbiter",
+		true,
+		"",
+		[]string{"orbiter", "wheel"},
+		"orbiter",
+		[]string{m.context.desired.SSHKey.Public.Value},
+		"ALL=(ALL) NOPASSWD:ALL",
+	).AddCmd(
+		"sudo echo \"\n\nPermitRootLogin no\n\" >> /etc/ssh/sshd_config",
+	).AddCmd(
+		"sudo service sshd restart",
+	).ToYamlString()
+	if err != nil {
+		return nil, err
+	}
+
+	var securityGroups []serverSecurityGrp
+	for _, sg := range m.context.desired.SecurityGroups {
+		securityGroups = append(securityGroups, serverSecurityGrp{Name: sg})
+	}
+
+	created, err := m.context.client.createServer(m.context.ctx, &serverCreate{
+		Name:             name,
+		FlavorRef:        flavorID,
+		ImageRef:         imageID,
+		AvailabilityZone: desired.Zone,
+		Networks:         []serverNetwork{{UUID: m.context.networkID}},
+		SecurityGroups:   securityGroups,
+		UserData:         base64.StdEncoding.EncodeToString([]byte(userData)),
+		Metadata:         map[string]string{"pool": poolName},
+		Tags:             m.context.tags(tag("pool", poolName)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newServer, err := m.awaitActive(created.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor.Info("Instance created")
+
+	var floatingIP string
+	if m.needsFloatingIP() {
+		fip, err := m.createFloatingIP(newServer.ID, name)
+		if err != nil {
+			return nil, err
+		}
+		floatingIP = fip.FloatingIPAddress
+	}
+
+	infraMachine, err := m.toMachine(newServer, monitor, poolName, floatingIP)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cache.Lock()
+	if m.cache.instances != nil {
+		m.cache.instances[poolName] = append(m.cache.instances[poolName], infraMachine)
+	}
+	m.cache.Unlock()
+
+	if err := m.onCreate(poolName, infraMachine); err != nil {
+		return nil, err
+	}
+
+	monitor.Info("Machine created")
+	return []infra.Machine{infraMachine}, nil
+}
+
+// needsFloatingIP is true if ORBITER runs outside of the private network or if machine floating ips are configured
+func (m *machinesService) needsFloatingIP() bool {
+	return m.oneoff || m.context.desired.MachineFloatingIPs
+}
+
+func (m *machinesService) createFloatingIP(serverID, name string) (*floatingIP, error) {
+	ports, err := m.context.client.listPorts(m.context.ctx, url.Values{"device_id": {serverID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no port found for server %s", serverID)
+	}
+	return m.context.client.createFloatingIP(m.context.ctx, m.context.floatingNetworkID, ports[0].ID, m.context.tags(tag("machine", name)))
+}
+
+func (m *machinesService) awaitActive(id string) (*server, error) {
+	var (
+		srv *server
+		err error
+	)
+	if timedOut := helpers.Retry(time.NewTimer(10*time.Minute), 5*time.Second, func() bool {
+		srv, err = m.context.client.getServer(m.context.ctx, id)
+		if err != nil {
+			return false
+		}
+		if srv.Status == "ERROR" {
+			err = fmt.Errorf("server %s is in status ERROR", id)
+			return false
+		}
+		return srv.Status != "ACTIVE"
+	}); timedOut != nil {
+		return nil, fmt.Errorf("server %s did not become active: %w", id, timedOut)
+	}
+	return srv, err
+}
+
+func (m *machinesService) toMachine(server *server, monitor mntr.Monitor, poolName, floatingIP string) (*machine, error) {
+	internalIP := privateIP(server)
+	sshIP := internalIP
+	if m.oneoff && floatingIP != "" {
+		sshIP = floatingIP
+	}
+
+	sshMachine := ssh.NewMachine(monitor, "orbiter", sshIP)
+	if err := sshMachine.UseKey([]byte(m.key.Private.Value)); err != nil {
+		return nil, err
+	}
+
+	infraMachine := newMachine(
+		server,
+		internalIP,
+		floatingIP,
+		sshMachine,
+		m.removeMachineFunc(poolName, server.ID, server.Name),
+		m.context,
+		poolName,
+	)
+	return infraMachine, nil
+}
+
+func privateIP(server *server) string {
+	for _, addresses := range server.Addresses {
+		for _, addr := range addresses {
+			if addr.Version == 4 && addr.Type != "floating" {
+				return addr.Addr
+			}
+		}
+	}
+	return ""
+}
+
+func (m *machinesService) ListPools() ([]string, error) {
+
+	m.cache.Lock()
+	defer m.cache.Unlock()
+
+	pools, err := m.machines()
+	if err != nil {
+		return nil, err
+	}
+
+	var poolNames []string
+	for poolName := range pools {
+		poolNames = append(poolNames, poolName)
+	}
+	return poolNames, nil
+}
+
+func (m *machinesService) List(poolName string) (infra.Machines, error) {
+
+	m.cache.Lock()
+	defer m.cache.Unlock()
+
+	pools, err := m.machines()
+	if err != nil {
+		return nil, err
+	}
+
+	pool := pools[poolName]
+	machines := make([]infra.Machine, len(pool))
+	for idx := range pool {
+		machine := pool[idx]
+		machines[idx] = machine
+	}
+
+	return machines, nil
+}
+
+// machines must be called with the cache locked
+func (m *machinesService) machines() (map[string][]*machine, error) {
+	if m.cache.instances != nil {
+		return m.cache.instances, nil
+	}
+
+	servers, err := m.context.client.listServers(m.context.ctx, m.context.tags())
+	if err != nil {
+		return nil, err
+	}
+
+	floatingIPs, err := m.context.client.listFloatingIPs(m.context.ctx, m.context.tags())
+	if err != nil {
+		return nil, err
+	}
+
+	machineFIPs := make(map[string]string)
+	for idx := range floatingIPs {
+		fip := floatingIPs[idx]
+		if machineName := fip.tag("machine"); machineName != "" {
+			machineFIPs[machineName] = fip.FloatingIPAddress
+		}
+	}
+
+	m.cache.instances = make(map[string][]*machine)
+	for idx := range servers {
+		server := servers[idx]
+		pool := server.Metadata["pool"]
+		machine, err := m.toMachine(&server, machineMonitor(m.context.monitor, server.Name, pool), pool, machineFIPs[server.Name])
+		if err != nil {
+			return nil, err
+		}
+		m.cache.instances[pool] = append(m.cache.instances[pool], machine)
+	}
+
+	return m.cache.instances, nil
+}
+
+func (m *machinesService) removeMachineFunc(pool, id, name string) func() error {
+
+	return func() error {
+		m.cache.Lock()
+		if m.cache.instances != nil {
+			cleanMachines := make([]*machine, 0)
+			for idx := range m.cache.instances[pool] {
+				cachedMachine := m.cache.instances[pool][idx]
+				if cachedMachine.server.ID != id {
+					cleanMachines = append(cleanMachines, cachedMachine)
+				}
+			}
+			m.cache.instances[pool] = cleanMachines
+		}
+		m.cache.Unlock()
+
+		if err := m.context.client.deleteServer(m.context.ctx, id); err != nil {
+			return err
+		}
+
+		fips, err := m.context.client.listFloatingIPs(m.context.ctx, m.context.tags(tag("machine", name)))
+		if err != nil {
+			return err
+		}
+		for idx := range fips {
+			if err := m.context.client.deleteFloatingIP(m.context.ctx, fips[idx].ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func machineMonitor(monitor mntr.Monitor, name string, poolName string) mntr.Monitor {
+	return monitor.WithFields(map[string]interface{}{
+		"machine": name,
+		"pool":    poolName,
+	})
+}
+
+func newName() string {
+	return "orbos-" + helpers.RandomStringRunes(6, []rune("abcdefghijklmnopqrstuvwxyz0123456789"))
+}
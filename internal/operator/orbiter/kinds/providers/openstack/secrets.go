@@ -0,0 +1,34 @@
+package openstack
+
+import (
+	"github.com/caos/orbos/pkg/secret"
+)
+
+func getSecretsMap(desiredKind *Desired) map[string]*secret.Secret {
+	if desiredKind.Spec.Password == nil {
+		desiredKind.Spec.Password = &secret.Secret{}
+	}
+
+	if desiredKind.Spec.ApplicationCredentialSecret == nil {
+		desiredKind.Spec.ApplicationCredentialSecret = &secret.Secret{}
+	}
+
+	if desiredKind.Spec.SSHKey == nil {
+		desiredKind.Spec.SSHKey = &SSHKey{}
+	}
+
+	if desiredKind.Spec.SSHKey.Public == nil {
+		desiredKind.Spec.SSHKey.Public = &secret.Secret{}
+	}
+
+	if desiredKind.Spec.SSHKey.Private == nil {
+		desiredKind.Spec.SSHKey.Private = &secret.Secret{}
+	}
+
+	return map[string]*secret.Secret{
+		"password":                    desiredKind.Spec.Password,
+		"applicationcredentialsecret": desiredKind.Spec.ApplicationCredentialSecret,
+		"sshkeyprivate":               desiredKind.Spec.SSHKey.Private,
+		"sshkeypublic":                desiredKind.Spec.SSHKey.Public,
+	}
+}
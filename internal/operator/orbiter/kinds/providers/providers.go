@@ -10,7 +10,9 @@ import (
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 
 	"github.com/caos/orbos/internal/operator/orbiter"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/ec2"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/gce"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/openstack"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/static"
 	"github.com/caos/orbos/mntr"
 	"github.com/caos/orbos/pkg/tree"
@@ -72,6 +74,34 @@ func GetQueryAndDestroyFuncs(
 			providerTree,
 			providerCurrent,
 		)
+	case "orbiter.caos.ch/OpenStackProvider":
+		return openstack.AdaptFunc(
+			provID,
+			orbID,
+			wlFunc,
+			orbiterCommit, repoURL, repoKey,
+			oneoff,
+			pprof,
+		)(
+			monitor,
+			finishedChan,
+			providerTree,
+			providerCurrent,
+		)
+	case "orbiter.caos.ch/EC2Provider":
+		return ec2.AdaptFunc(
+			provID,
+			orbID,
+			wlFunc,
+			orbiterCommit, repoURL, repoKey,
+			oneoff,
+			pprof,
+		)(
+			monitor,
+			finishedChan,
+			providerTree,
+			providerCurrent,
+		)
 	case "orbiter.caos.ch/StaticProvider":
 		return static.AdaptFunc(
 			provID,
@@ -115,6 +145,20 @@ func ListMachines(
 			orbID,
 			provID,
 		)
+	case "orbiter.caos.ch/OpenStackProvider":
+		return openstack.ListMachines(
+			monitor,
+			providerTree,
+			orbID,
+			provID,
+		)
+	case "orbiter.caos.ch/EC2Provider":
+		return ec2.ListMachines(
+			monitor,
+			providerTree,
+			orbID,
+			provID,
+		)
 	case "orbiter.caos.ch/StaticProvider":
 		return static.ListMachines(
 			monitor,
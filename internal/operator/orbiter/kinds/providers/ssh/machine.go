@@ -37,6 +37,10 @@ func (c *Machine) Zone() string {
 	return c.zone
 }
 
+func (c *Machine) RemoteUser() string {
+	return c.remoteUser
+}
+
 func (c *Machine) Execute(stdin io.Reader, cmd string) (stdout []byte, err error) {
 
 	monitor := c.monitor.WithFields(map[string]interface{}{
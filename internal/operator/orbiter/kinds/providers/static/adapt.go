@@ -92,7 +92,7 @@ func AdaptFunc(
 				if err := svc.updateKeys(); err != nil {
 					return nil, err
 				}
-				_, iterateNA := core.NodeAgentFuncs(monitor, repoURL, repoKey, pprof)
+				_, iterateNA := core.NodeAgentFuncs(monitor, id, repoURL, repoKey, pprof)
 				return query(desiredKind, current, nodeAgentsDesired, nodeAgentsCurrent, lbCurrent.Parsed, monitor, svc, iterateNA, orbiterCommit)
 			}, func(delegates map[string]interface{}) error {
 				if err := lbDestroy(delegates); err != nil {
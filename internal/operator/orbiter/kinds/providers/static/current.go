@@ -1,18 +1,23 @@
 package static
 
 import (
+	"github.com/caos/orbos/internal/operator/orbiter"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/core/infra"
 	"github.com/caos/orbos/internal/operator/orbiter/kinds/providers/core"
 	"github.com/caos/orbos/pkg/tree"
 )
 
-var _ infra.ProviderCurrent = (*Current)(nil)
+var (
+	_ infra.ProviderCurrent = (*Current)(nil)
+	_ orbiter.Planner       = (*Current)(nil)
+)
 
 type Current struct {
 	Common  *tree.Common `yaml:",inline"`
 	Current struct {
 		pools            map[string]infra.Pool `yaml:"-"`
 		Ingresses        map[string]*infra.Address
+		planNodeAgents   func() error `yaml:"-"`
 		cleanupped       <-chan error `yaml:"-"`
 		privateInterface string       `yaml:"-"`
 	}
@@ -52,3 +57,11 @@ func addPools(current *Current, desired *DesiredV0, machinesSvc core.MachinesSer
 	}
 	return nil
 }
+
+// Plan desires the load balancing node agent configs without touching any machine, so they are part of the plan
+func (c *Current) Plan(*orbiter.Plan) error {
+	if c.Current.planNodeAgents == nil {
+		return nil
+	}
+	return c.Current.planNodeAgents()
+}
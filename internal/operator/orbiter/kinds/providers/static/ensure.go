@@ -32,7 +32,7 @@ func query(
 	// TODO: Allow Changes
 	desireHostnameFunc := desireHostname(desired.Spec.Pools, nodeAgentsDesired, nodeAgentsCurrent, monitor)
 
-	queryNA, installNA := naFuncs(nodeAgentsCurrent)
+	queryNA, installNA := naFuncs(nodeAgentsDesired, nodeAgentsCurrent)
 
 	ensureNodeFunc := func(machine infra.Machine, pool string) error {
 
@@ -61,7 +61,7 @@ func query(
 	}
 
 	current.Current.Ingresses = make(map[string]*infra.Address)
-	ensureLBFunc := func() *orbiter.EnsureResult {
+	ensureLBFunc := func(_ func(mntr.Monitor) error) *orbiter.EnsureResult {
 		return &orbiter.EnsureResult{
 			Err:  nil,
 			Done: true,
@@ -86,8 +86,9 @@ func query(
 			AuthCheck:     nil,
 		}, mapVIP)
 		externalMachinesService = wrappedMachinesService
-		ensureLBFunc = func() *orbiter.EnsureResult {
-			return orbiter.ToEnsureResult(wrappedMachinesService.InitializeDesiredNodeAgents())
+		current.Current.planNodeAgents = wrappedMachinesService.PlanDesiredNodeAgents
+		ensureLBFunc = func(pdf func(mntr.Monitor) error) *orbiter.EnsureResult {
+			return orbiter.ToEnsureResult(wrappedMachinesService.InitializeDesiredNodeAgents(pdf))
 		}
 		deployPools, _, err := lbCurrent.Current.Spec(internalMachinesService)
 		if err != nil {
@@ -133,7 +134,7 @@ func query(
 		if err != nil {
 			return orbiter.ToEnsureResult(false, err)
 		}
-		result := ensureLBFunc()
+		result := ensureLBFunc(pdf)
 
 		if result.Err == nil {
 			fwDone, err := core.DesireInternalOSFirewall(monitor, nodeAgentsDesired, nodeAgentsCurrent, externalMachinesService, false, desired.Spec.ExternalInterfaces)
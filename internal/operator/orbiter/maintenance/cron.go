@@ -0,0 +1,131 @@
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a parsed cron expression with the fields minute, hour, day of month, month and day of week
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domRestricted and dowRestricted implement crons rule that a restricted day of month or day of week matches if either matches
+	domRestricted, dowRestricted bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fields = []bounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+func parseSchedule(expr string) (*schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %s must have the %d fields minute, hour, day of month, month and day of week", expr, len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for idx, part := range parts {
+		parsed, err := parseField(part, fields[idx])
+		if err != nil {
+			return nil, fmt.Errorf("parsing cron expression %s failed: %w", expr, err)
+		}
+		bits[idx] = parsed
+	}
+
+	return &schedule{
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: parts[2] != "*",
+		dowRestricted: parts[4] != "*",
+	}, nil
+}
+
+// parseField supports *, single values, ranges, lists and steps like */15 or 1-5/2
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[idx+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("step %s of the %s is invalid", item[idx+1:], b.name)
+			}
+			rng = item[:idx]
+		}
+
+		from, to := b.min, b.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("value %s of the %s is invalid", bounds[0], b.name)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("value %s of the %s is invalid", bounds[1], b.name)
+				}
+			} else if step > 1 {
+				to = b.max
+			}
+		}
+
+		if from < b.min || to > b.max || from > to {
+			return 0, fmt.Errorf("range %s of the %s must be within %d and %d", rng, b.name, b.min, b.max)
+		}
+
+		for value := from; value <= to; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (s *schedule) dayMatches(t time.Time) bool {
+	domMatches := s.dom&(1<<uint(t.Day())) > 0
+	dowMatches := s.dow&(1<<uint(t.Weekday())) > 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatches || dowMatches
+	}
+	return domMatches && dowMatches
+}
+
+// next returns the first matching minute not before t within the next five years
+func (s *schedule) next(t time.Time) (time.Time, bool) {
+	t = t.Add(time.Minute - time.Nanosecond).Truncate(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,109 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	// ORBITERs container image has no time zone database
+	_ "time/tzdata"
+)
+
+// Window allows disruptive changes like reboots, kubernetes upgrades and machine replacements while it is open
+type Window struct {
+	// Start is a cron expression with the fields minute, hour, day of month, month and day of week, for example 0 2 * * 6
+	Start string
+	// Duration is how long the window stays open, for example 4h
+	Duration string
+	// TimeZone is the IANA time zone, the start is evaluated in, for example Europe/Zurich
+	//@default: UTC
+	TimeZone string `yaml:",omitempty"`
+}
+
+// Windows are open if any window is open. If no windows are configured, disruptive changes are always allowed.
+type Windows []*Window
+
+func (w *Window) Validate() error {
+	if _, _, _, err := w.parse(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *Window) parse() (*schedule, time.Duration, *time.Location, error) {
+	sched, err := parseSchedule(w.Start)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("parsing maintenance window duration %s failed: %w", w.Duration, err)
+	}
+	if duration < time.Minute {
+		return nil, 0, nil, errors.New("maintenance windows must be open for at least a minute")
+	}
+
+	location, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("loading time zone %s failed: %w", w.TimeZone, err)
+	}
+	return sched, duration, location, nil
+}
+
+func (w Windows) Validate() error {
+	for _, window := range w {
+		if window == nil {
+			return errors.New("maintenance window must not be empty")
+		}
+		if err := window.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open returns true if no windows are configured or if any window is open at the given time
+func (w Windows) Open(now time.Time) bool {
+	if len(w) == 0 {
+		return true
+	}
+	for _, window := range w {
+		sched, duration, location, err := window.parse()
+		if err != nil {
+			continue
+		}
+		if start, ok := sched.next(now.In(location).Add(-duration + time.Nanosecond)); ok && !start.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns when the next window opens. If a window is open, it returns the given time.
+func (w Windows) Next(now time.Time) time.Time {
+	if w.Open(now) {
+		return now
+	}
+
+	var next time.Time
+	for _, window := range w {
+		sched, _, location, err := window.parse()
+		if err != nil {
+			continue
+		}
+		start, ok := sched.next(now.In(location))
+		if ok && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return next
+}
+
+// Or returns the windows if configured and the fallback otherwise. Configured empty windows are returned, so they allow disruptive changes at any time.
+func (w *Windows) Or(fallback Windows) Windows {
+	if w != nil {
+		return *w
+	}
+	return fallback
+}
@@ -0,0 +1,94 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindows_Open(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saturdayNights := Windows{{Start: "0 2 * * 6", Duration: "4h", TimeZone: "Europe/Zurich"}}
+
+	for _, tt := range []struct {
+		name     string
+		windows  Windows
+		now      time.Time
+		wantOpen bool
+		wantNext time.Time
+	}{{
+		name:     "no windows",
+		now:      time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC),
+		wantOpen: true,
+		wantNext: time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC),
+	}, {
+		name:     "before window",
+		windows:  saturdayNights,
+		now:      time.Date(2021, 3, 6, 1, 59, 0, 0, zurich),
+		wantNext: time.Date(2021, 3, 6, 2, 0, 0, 0, zurich),
+	}, {
+		name:     "window opens",
+		windows:  saturdayNights,
+		now:      time.Date(2021, 3, 6, 2, 0, 0, 0, zurich),
+		wantOpen: true,
+		wantNext: time.Date(2021, 3, 6, 2, 0, 0, 0, zurich),
+	}, {
+		name:     "within window in other time zone",
+		windows:  saturdayNights,
+		now:      time.Date(2021, 3, 6, 4, 30, 0, 0, time.UTC),
+		wantOpen: true,
+		wantNext: time.Date(2021, 3, 6, 4, 30, 0, 0, time.UTC),
+	}, {
+		name:     "window closed",
+		windows:  saturdayNights,
+		now:      time.Date(2021, 3, 6, 6, 0, 0, 0, zurich),
+		wantNext: time.Date(2021, 3, 13, 2, 0, 0, 0, zurich),
+	}, {
+		name: "any window",
+		windows: Windows{
+			{Start: "0 2 * * 6", Duration: "4h"},
+			{Start: "30 22 1-7 * *", Duration: "1h"},
+		},
+		now:      time.Date(2021, 3, 3, 23, 0, 0, 0, time.UTC),
+		wantOpen: true,
+		wantNext: time.Date(2021, 3, 3, 23, 0, 0, 0, time.UTC),
+	}, {
+		name:     "steps",
+		windows:  Windows{{Start: "*/20 8-17 * * 1-5", Duration: "10m"}},
+		now:      time.Date(2021, 3, 5, 17, 50, 0, 0, time.UTC),
+		wantNext: time.Date(2021, 3, 8, 8, 0, 0, 0, time.UTC),
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.windows.Open(tt.now); got != tt.wantOpen {
+				t.Errorf("Open() = %t, want %t", got, tt.wantOpen)
+			}
+			if got := tt.windows.Next(tt.now); !got.Equal(tt.wantNext) {
+				t.Errorf("Next() = %s, want %s", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestWindows_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		window  *Window
+		wantErr bool
+	}{
+		{"valid", &Window{Start: "0 2 * * 6", Duration: "4h", TimeZone: "Europe/Zurich"}, false},
+		{"missing field", &Window{Start: "0 2 * *", Duration: "4h"}, true},
+		{"out of range", &Window{Start: "0 24 * * *", Duration: "4h"}, true},
+		{"invalid step", &Window{Start: "*/0 2 * * *", Duration: "4h"}, true},
+		{"invalid duration", &Window{Start: "0 2 * * *", Duration: "4"}, true},
+		{"unknown time zone", &Window{Start: "0 2 * * *", Duration: "4h", TimeZone: "Mars/Olympus"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (Windows{tt.window}).Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
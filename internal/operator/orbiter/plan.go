@@ -0,0 +1,216 @@
+package orbiter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/mntr"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionDestroy = "destroy"
+	ActionChange  = "change"
+)
+
+// Change is a modification an ensure run would apply
+type Change struct {
+	Action string
+	Object string
+	From   string   `yaml:",omitempty"`
+	To     string   `yaml:",omitempty"`
+	Diff   []string `yaml:",omitempty"`
+}
+
+// Plan lists what an ensure run would do without doing it
+type Plan struct {
+	Migrated      bool      `yaml:",omitempty"`
+	Machines      []*Change `yaml:",omitempty"`
+	NodeAgents    []*Change `yaml:"nodeagents,omitempty"`
+	LoadBalancing []*Change `yaml:"loadbalancing,omitempty"`
+	Kubernetes    []*Change `yaml:",omitempty"`
+}
+
+func (p *Plan) Merge(other Plan) {
+	p.Machines = append(p.Machines, other.Machines...)
+	p.NodeAgents = append(p.NodeAgents, other.NodeAgents...)
+	p.LoadBalancing = append(p.LoadBalancing, other.LoadBalancing...)
+	p.Kubernetes = append(p.Kubernetes, other.Kubernetes...)
+}
+
+func (p *Plan) Empty() bool {
+	return !p.Migrated && len(p.Machines)+len(p.NodeAgents)+len(p.LoadBalancing)+len(p.Kubernetes) == 0
+}
+
+// Planner is implemented by the current states of kinds which know what their ensure funcs would do after querying.
+// Planners may also desire node agent configs which are otherwise only desired while ensuring, so they are part of the plan.
+// Planning must not change anything.
+type Planner interface {
+	Plan(plan *Plan) error
+}
+
+// Dryrun adapts and queries like Takeoff does, but instead of ensuring the desired state, it returns what ensuring would change.
+// Querying is free of side effects, so the conf.Adapt func just must not deploy ORBITER.
+func Dryrun(monitor mntr.Monitor, conf *Config) (*Plan, error) {
+
+	query, _, _, migrate, _, treeCurrent, _, err := Adapt(conf.GitClient, monitor, conf.FinishedChan, conf.Adapt)
+	if err != nil {
+		return nil, err
+	}
+
+	desiredNodeAgents := common.NodeAgentsDesiredKind{
+		Kind:    "nodeagent.caos.ch/NodeAgents",
+		Version: "v0",
+		Spec: common.NodeAgentsSpec{
+			Commit: conf.OrbiterCommit,
+		},
+	}
+
+	currentNodeAgents := common.NodeAgentsCurrentKind{}
+	if err := yaml.Unmarshal(conf.GitClient.Read("caos-internal/orbiter/node-agents-current.yml"), &currentNodeAgents); err != nil {
+		return nil, err
+	}
+
+	if _, err := query(&currentNodeAgents.Current, &desiredNodeAgents.Spec.NodeAgents, nil); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Migrated: migrate}
+	if planner, ok := treeCurrent.Parsed.(Planner); ok {
+		if err := planner.Plan(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	previousNodeAgents := common.NodeAgentsDesiredKind{}
+	if err := yaml.Unmarshal(conf.GitClient.Read("caos-internal/orbiter/node-agents-desired.yml"), &previousNodeAgents); err != nil {
+		return nil, err
+	}
+	plan.NodeAgents = diffNodeAgents(previousNodeAgents.Spec.NodeAgents.NA, desiredNodeAgents.Spec.NodeAgents.NA)
+
+	previousIngresses, err := parseIngresses(conf.GitClient.Read("caos-internal/orbiter/current.yml"))
+	if err != nil {
+		return nil, err
+	}
+	ingresses, err := parseIngresses(common.MarshalYAML(treeCurrent))
+	if err != nil {
+		return nil, err
+	}
+	plan.LoadBalancing = append(plan.LoadBalancing, diffStrings(previousIngresses, ingresses)...)
+
+	return plan, nil
+}
+
+func diffNodeAgents(from, to map[string]*common.NodeAgentSpec) []*Change {
+	marshal := func(nas map[string]*common.NodeAgentSpec) map[string]string {
+		marshalled := make(map[string]string, len(nas))
+		for id, na := range nas {
+			if na != nil {
+				marshalled[id] = string(common.MarshalYAML(na))
+			}
+		}
+		return marshalled
+	}
+	return diffStrings(marshal(from), marshal(to))
+}
+
+// parseIngresses reads all providers ingresses from an orbs current state
+func parseIngresses(currentYAML []byte) (map[string]string, error) {
+	current := struct {
+		Providers map[string]struct {
+			Current struct {
+				Ingresses map[string]struct {
+					Location     string
+					FrontendPort uint16
+					BackendPort  uint16
+				}
+			}
+		}
+	}{}
+	if err := yaml.Unmarshal(currentYAML, &current); err != nil {
+		return nil, err
+	}
+
+	ingresses := make(map[string]string)
+	for provID, prov := range current.Providers {
+		for name, ingress := range prov.Current.Ingresses {
+			ingresses[provID+"/"+name] = fmt.Sprintf("%s:%d -> %d", ingress.Location, ingress.FrontendPort, ingress.BackendPort)
+		}
+	}
+	return ingresses, nil
+}
+
+func diffStrings(from, to map[string]string) []*Change {
+	var changes []*Change
+	for id, toValue := range to {
+		fromValue, ok := from[id]
+		switch {
+		case !ok:
+			changes = append(changes, &Change{Action: ActionCreate, Object: id, Diff: diffLines("", toValue)})
+		case fromValue != toValue:
+			changes = append(changes, &Change{Action: ActionChange, Object: id, Diff: diffLines(fromValue, toValue)})
+		}
+	}
+	for id, fromValue := range from {
+		if _, ok := to[id]; !ok {
+			changes = append(changes, &Change{Action: ActionDestroy, Object: id, Diff: diffLines(fromValue, "")})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Object < changes[j].Object })
+	return changes
+}
+
+// diffLines returns the lines only in from prefixed with "- " and the lines only in to prefixed with "+ ",
+// based on their longest common subsequence
+func diffLines(from, to string) []string {
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	}
+	a, b := split(from), split(to)
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+ "+b[j])
+	}
+	return diff
+}
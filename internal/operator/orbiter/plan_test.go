@@ -0,0 +1,60 @@
+package orbiter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_diffLines(t *testing.T) {
+	from := "firewall:\n  external: {}\nsoftware:\n  kubelet: v1.18.8\n"
+	to := "firewall:\n  external: {}\nsoftware:\n  kubelet: v1.19.4\n  containerd: 1.4.3\n"
+
+	want := []string{"-   kubelet: v1.18.8", "+   kubelet: v1.19.4", "+   containerd: 1.4.3"}
+	if got := diffLines(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines() = %v, want %v", got, want)
+	}
+
+	if got := diffLines(from, from); len(got) != 0 {
+		t.Errorf("expected no diff for equal inputs, but got %v", got)
+	}
+}
+
+func Test_diffStrings(t *testing.T) {
+	got := diffStrings(map[string]string{
+		"kept":      "a",
+		"changed":   "b",
+		"destroyed": "c",
+	}, map[string]string{
+		"kept":    "a",
+		"changed": "d",
+		"created": "e",
+	})
+
+	want := []*Change{
+		{Action: ActionChange, Object: "changed", Diff: []string{"- b", "+ d"}},
+		{Action: ActionCreate, Object: "created", Diff: []string{"+ e"}},
+		{Action: ActionDestroy, Object: "destroyed", Diff: []string{"- c"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffStrings() = %v, want %v", got, want)
+	}
+}
+
+func Test_parseIngresses(t *testing.T) {
+	got, err := parseIngresses([]byte(`kind: orbiter.caos.ch/Orb
+providers:
+  gce:
+    current:
+      ingresses:
+        kubeapi:
+          location: 10.0.0.1
+          frontendport: 6443
+          backendport: 6666
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "10.0.0.1:6443 -> 6666"; got["gce/kubeapi"] != want {
+		t.Errorf("expected %s, but got %v", want, got)
+	}
+}
@@ -190,7 +190,13 @@ func Takeoff(monitor mntr.Monitor, conf *Config, healthyChan chan bool) func() {
 			return
 		}
 
-		result := ensure(conf.GitClient.PushDesiredFunc(git.OrbiterFile, treeDesired))
+		pushDesired := conf.GitClient.PushDesiredFunc(git.OrbiterFile, treeDesired)
+		result := ensure(func(monitor mntr.Monitor) error {
+			if err := secret.WriteChangedRefs(treeDesired); err != nil {
+				return err
+			}
+			return pushDesired(monitor)
+		})
 		if result.Err != nil {
 			handleAdapterError(result.Err)
 			return
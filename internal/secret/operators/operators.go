@@ -73,14 +73,7 @@ func getAllSecrets(
 		allSecrets,
 		allExisting,
 		func() (*tree.Tree, error) { return boomcrd.ReadCRD(k8sClient) },
-		func(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
-			toolset, migrate, _, _, err := boomapi.ParseToolset(t)
-			if err != nil {
-				return nil, nil, false, err
-			}
-			boomSecrets, boomExistingSecrets := latest.GetSecretsMap(toolset)
-			return boomSecrets, boomExistingSecrets, migrate, nil
-		},
+		boomSecrets,
 	); err != nil {
 		return nil, nil, nil, err
 	}
@@ -96,17 +89,7 @@ func getAllSecrets(
 			allSecrets,
 			allExisting,
 			func() (*tree.Tree, error) { return nil, errors.New("ORBITER doesn't support crd mode") },
-			func(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
-				_, _, _, migrate, orbiterSecrets, err := orbiterOrb.AdaptFunc(
-					labels.NoopOperator("ORBOS"),
-					orb,
-					"",
-					true,
-					false,
-					gitClient,
-				)(monitor, make(chan struct{}), t, &tree.Tree{})
-				return orbiterSecrets, nil, migrate, err
-			},
+			orbiterSecretsFunc(monitor, gitClient, orb),
 		); err != nil {
 			return nil, nil, nil, err
 		}
@@ -122,10 +105,7 @@ func getAllSecrets(
 		allSecrets,
 		allExisting,
 		func() (*tree.Tree, error) { return nwcrd.ReadCRD(k8sClient) },
-		func(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
-			_, _, nwSecrets, nwExisting, migrate, err := nwOrb.AdaptFunc(nil, nil, false)(monitor, t, nil)
-			return nwSecrets, nwExisting, migrate, err
-		},
+		networkingSecretsFunc(monitor),
 	); err != nil {
 		return nil, nil, nil, err
 	}
@@ -137,6 +117,40 @@ func getAllSecrets(
 	return allSecrets, allExisting, allTrees, nil
 }
 
+func boomSecrets(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
+	toolset, migrate, _, _, err := boomapi.ParseToolset(t)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	boomSecrets, boomExistingSecrets := latest.GetSecretsMap(toolset)
+	return boomSecrets, boomExistingSecrets, migrate, nil
+}
+
+func orbiterSecretsFunc(
+	monitor mntr.Monitor,
+	gitClient *git.Client,
+	orb *orbcfg.Orb,
+) func(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
+	return func(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
+		_, _, _, migrate, orbiterSecrets, err := orbiterOrb.AdaptFunc(
+			labels.NoopOperator("ORBOS"),
+			orb,
+			"",
+			true,
+			false,
+			gitClient,
+		)(monitor, make(chan struct{}), t, &tree.Tree{})
+		return orbiterSecrets, nil, migrate, err
+	}
+}
+
+func networkingSecretsFunc(monitor mntr.Monitor) func(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
+	return func(t *tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error) {
+		_, _, nwSecrets, nwExisting, migrate, err := nwOrb.AdaptFunc(nil, nil, false)(monitor, t, nil)
+		return nwSecrets, nwExisting, migrate, err
+	}
+}
+
 func PushFunc(
 	monitor mntr.Monitor,
 	gitops bool,
@@ -190,6 +204,10 @@ func push(
 		return mntr.ToUserError(fmt.Errorf("desired state not found for %s", desiredFile.WOExtension()))
 	}
 
+	if err := secret.WriteChangedRefs(desired); err != nil {
+		return err
+	}
+
 	if gitops {
 		return gitClient.PushDesiredFunc(desiredFile, desired)(monitor)
 	}
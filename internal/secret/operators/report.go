@@ -0,0 +1,140 @@
+package operators
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/boom/api/latest"
+	"github.com/caos/orbos/internal/operator/orbiter/kinds/clusters/kubernetes"
+	orbiterOrb "github.com/caos/orbos/internal/operator/orbiter/kinds/orb"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
+	orbcfg "github.com/caos/orbos/pkg/orb"
+	"github.com/caos/orbos/pkg/secret"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+// HistoryFunc parses the secrets of the operators desired states in the last depth commits without resolving their references
+func HistoryFunc(
+	monitor mntr.Monitor,
+	gitClient *git.Client,
+	orb *orbcfg.Orb,
+	depth int,
+) secret.HistoryFunc {
+	return func() (map[string][]*secret.Revision, error) {
+
+		parsers := map[git.DesiredFile]func(*tree.Tree) (map[string]*secret.Secret, map[string]*secret.Existing, bool, error){
+			git.BoomFile:       boomSecrets,
+			git.OrbiterFile:    orbiterSecretsFunc(monitor, gitClient, orb),
+			git.NetworkingFile: networkingSecretsFunc(monitor),
+		}
+
+		files := make([]git.DesiredFile, 0, len(parsers))
+		for file := range parsers {
+			if gitClient.Exists(file) {
+				files = append(files, file)
+			}
+		}
+
+		fileHistory, err := gitClient.History(depth, files...)
+		if err != nil {
+			return nil, err
+		}
+
+		history := make(map[string][]*secret.Revision, len(fileHistory))
+		for file, revisions := range fileHistory {
+			operator := file.WOExtension()
+			for _, revision := range revisions {
+				revMonitor := monitor.WithFields(map[string]interface{}{
+					"file":   file,
+					"commit": revision.Hash,
+				})
+
+				desired := &tree.Tree{}
+				if err := yaml.Unmarshal(revision.Content, desired); err != nil {
+					revMonitor.Debug("Stopped inspecting history, as the desired state can't be parsed")
+					break
+				}
+
+				// Older revisions might be encrypted with a rotated key or use an older api
+				var (
+					secrets  map[string]*secret.Secret
+					existing map[string]*secret.Existing
+					migrate  bool
+				)
+				err := secret.ParseUnresolved(func() (err error) {
+					secrets, existing, migrate, err = parsers[file](desired)
+					return err
+				})
+				if err != nil || migrate {
+					revMonitor.Debug("Stopped inspecting history, as the secrets can't be read")
+					break
+				}
+
+				parsed := &secret.Revision{
+					When:     revision.When,
+					Secrets:  make(map[string]*secret.Secret),
+					Existing: make(map[string]*secret.Existing),
+				}
+				secret.AppendOperatorSecrets(operator, parsed.Secrets, secrets, parsed.Existing, existing)
+				history[operator] = append(history[operator], parsed)
+			}
+		}
+		return history, nil
+	}
+}
+
+// Unused is true for secrets of BOOM tools which are not deployed and of ORBITER providers no cluster uses
+func Unused(trees map[string]*tree.Tree, path string) bool {
+	parts := strings.Split(path, ".")
+	if len(parts) < 3 {
+		return false
+	}
+
+	desired, ok := trees[parts[0]]
+	if !ok || desired == nil {
+		return false
+	}
+
+	switch parts[0] {
+	case git.BoomFile.WOExtension():
+		toolset, ok := desired.Parsed.(*latest.Toolset)
+		if !ok || toolset.Spec == nil {
+			return false
+		}
+		switch parts[1] {
+		case "apigateway":
+			return toolset.Spec.APIGateway == nil || !toolset.Spec.APIGateway.Deploy
+		case "monitoring":
+			return toolset.Spec.Monitoring == nil || !toolset.Spec.Monitoring.Deploy
+		case "reconciling":
+			return toolset.Spec.Reconciling == nil || !toolset.Spec.Reconciling.Deploy
+		}
+	case git.OrbiterFile.WOExtension():
+		orbDesired, ok := desired.Parsed.(*orbiterOrb.DesiredV0)
+		if !ok {
+			return false
+		}
+		if _, isProvider := orbDesired.Providers[parts[1]]; !isProvider {
+			return false
+		}
+		for _, cluster := range orbDesired.Clusters {
+			k8sDesired, ok := cluster.Parsed.(*kubernetes.DesiredV0)
+			if !ok {
+				// Unknown cluster kinds might use the provider
+				return false
+			}
+			if k8sDesired.Spec.ControlPlane.Provider == parts[1] {
+				return false
+			}
+			for _, worker := range k8sDesired.Spec.Workers {
+				if worker != nil && worker.Provider == parts[1] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"strings"
+	"sync"
 
 	sshlib "golang.org/x/crypto/ssh"
 )
@@ -17,7 +18,8 @@ type pair struct {
 }
 
 var (
-	cachedKeys []pair
+	cachedKeys    []pair
+	cachedKeysMux sync.Mutex
 )
 
 func Generate() (private string, public string) {
@@ -46,6 +48,9 @@ func Generate() (private string, public string) {
 
 func AuthMethodFromKeys(privKey ...[]byte) (method sshlib.AuthMethod, err error) {
 
+	cachedKeysMux.Lock()
+	defer cachedKeysMux.Unlock()
+
 	var signers []sshlib.Signer
 	for _, copyKey := range privKey {
 		key := copyKey
@@ -22,8 +22,11 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// ApplyOrbconfigSecret writes the orbconfig the operators use.
+// If the orbconfig has recipients, the operators get their own identity instead of the users identity.
 func ApplyOrbconfigSecret(
 	orbConfig *orb.Orb,
+	gitClient *git.Client,
 	k8sClient kubernetes.ClientInt,
 	monitor mntr.Monitor,
 ) error {
@@ -33,6 +36,21 @@ func ApplyOrbconfigSecret(
 		return nil
 	}
 
+	orbiterConfig, err := orbiterOrbconfig(monitor, orbConfig, gitClient, k8sClient)
+	if err != nil {
+		return err
+	}
+
+	if err := writeOrbconfigSecret(monitor, orbiterConfig, k8sClient); err != nil {
+		return err
+	}
+
+	monitor.Info("Orbconfig kubernetes secret written")
+	return nil
+}
+
+func writeOrbconfigSecret(monitor mntr.Monitor, orbConfig *orb.Orb, k8sClient kubernetes.ClientInt) error {
+
 	monitor.Info("Writing orbconfig kubernetes secret")
 
 	orbConfigBytes, err := yaml.Marshal(orbConfig)
@@ -43,8 +61,6 @@ func ApplyOrbconfigSecret(
 	if err := kubernetes.EnsureOrbconfigSecret(monitor, k8sClient, orbConfigBytes); err != nil {
 		return fmt.Errorf("writing orbconfig kubernetes secret failed: %w", err)
 	}
-
-	monitor.Info("Orbconfig kubernetes secret written")
 	return nil
 }
 
@@ -178,6 +194,10 @@ func configureOperator(
 
 	tree.Parsed = parsed
 
+	if err := secret.WriteChangedRefs(tree); err != nil {
+		return nil, err
+	}
+
 	return func() git.File {
 		return git.File{
 			Path:    string(desiredFile),
@@ -0,0 +1,152 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/caos/orbos/internal/operator/common"
+	"github.com/caos/orbos/internal/secret/operators"
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/git"
+	"github.com/caos/orbos/pkg/kubernetes"
+	"github.com/caos/orbos/pkg/orb"
+	"github.com/caos/orbos/pkg/secret"
+)
+
+// ReencryptSecrets decrypts all secrets with the current keys and returns a func which commits them encrypted with the keys configured at call time.
+func ReencryptSecrets(
+	monitor mntr.Monitor,
+	orbConfig *orb.Orb,
+	gitClient *git.Client,
+	k8sClient kubernetes.ClientInt,
+	msg string,
+) (func() error, error) {
+	_, _, trees, err := operators.GetAllSecretsFunc(monitor, false, true, gitClient, k8sClient, orbConfig)()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return gitClient.UpdateRemote(msg, func() []git.File {
+			files := make([]git.File, 0, len(trees))
+			for operator, desired := range trees {
+				files = append(files, git.File{
+					Path:    operator + ".yml",
+					Content: common.MarshalYAML(desired),
+				})
+			}
+			return files
+		})
+	}, nil
+}
+
+// RotateRecipients changes the recipients in the orbconfig and reencrypts all secrets for them in a single commit.
+// If the orbconfig has no recipients yet, the recipient of its identity is added.
+// Then, the orbconfig kubernetes secret is updated, so the operators encrypt for the same recipients.
+func RotateRecipients(
+	monitor mntr.Monitor,
+	orbConfig *orb.Orb,
+	gitClient *git.Client,
+	k8sClient kubernetes.ClientInt,
+	add,
+	remove []string,
+) error {
+
+	if len(orbConfig.Recipients) == 0 && orbConfig.Identity != "" {
+		own, err := secret.IdentityRecipient(orbConfig.Identity)
+		if err != nil {
+			return mntr.ToUserError(err)
+		}
+		add = append([]string{own}, add...)
+	}
+	recipients, err := secret.EditRecipients(orbConfig.Recipients, add, remove)
+	if err != nil {
+		return mntr.ToUserError(err)
+	}
+
+	if len(recipients) == 0 && (orbConfig.Vault == nil || orbConfig.Vault.Key == "") {
+		return mntr.ToUserError(errors.New("secrets must be encrypted for at least one recipient or a vault transit key"))
+	}
+
+	reencrypt, err := ReencryptSecrets(monitor, orbConfig, gitClient, k8sClient, "Reencrypted secrets for recipients")
+	if err != nil {
+		return err
+	}
+
+	if err := orbConfig.RotateRecipients(recipients, reencrypt); err != nil {
+		return err
+	}
+	monitor.WithField("recipients", len(recipients)).Info("Secrets reencrypted and orbconfig written")
+
+	return ApplyOrbconfigSecret(orbConfig, gitClient, k8sClient, monitor)
+}
+
+// orbiterOrbconfig returns the orbconfig the operators in the cluster use.
+// If the orbconfig has recipients, the operators decrypt the secrets with their own identity instead of the users identity.
+// Its recipient is added to the orbconfigs recipients if it is missing.
+func orbiterOrbconfig(
+	monitor mntr.Monitor,
+	orbConfig *orb.Orb,
+	gitClient *git.Client,
+	k8sClient kubernetes.ClientInt,
+) (*orb.Orb, error) {
+
+	if len(orbConfig.Recipients) == 0 {
+		return orbConfig, nil
+	}
+
+	identity, err := currentOrbiterIdentity(orbConfig, k8sClient)
+	if err != nil {
+		return nil, err
+	}
+	if identity == "" {
+		monitor.Info("Generating an identity for the operators")
+		if identity, err = secret.GenerateIdentity(); err != nil {
+			return nil, err
+		}
+	}
+
+	recipient, err := secret.IdentityRecipient(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	orbiterConfig := *orbConfig
+	orbiterConfig.Identity = identity
+
+	for idx := range orbConfig.Recipients {
+		if orbConfig.Recipients[idx] == recipient {
+			return &orbiterConfig, nil
+		}
+	}
+
+	// Writing the operators identity first lets the next call add its recipient again if reencrypting fails
+	if err := writeOrbconfigSecret(monitor, &orbiterConfig, k8sClient); err != nil {
+		return nil, err
+	}
+
+	monitor.Info("Adding the operators recipient to the orbconfig")
+	if err := RotateRecipients(monitor, orbConfig, gitClient, k8sClient, []string{recipient}, nil); err != nil {
+		return nil, fmt.Errorf("adding the operators recipient failed: %w", err)
+	}
+	orbiterConfig.Recipients = orbConfig.Recipients
+	return &orbiterConfig, nil
+}
+
+// currentOrbiterIdentity returns the identity from the orbconfig kubernetes secret, unless it is the users identity
+func currentOrbiterIdentity(orbConfig *orb.Orb, k8sClient kubernetes.ClientInt) (string, error) {
+	current, err := kubernetes.ReadOrbconfigSecret(k8sClient)
+	if err != nil {
+		return "", fmt.Errorf("reading orbconfig kubernetes secret failed: %w", err)
+	}
+	orbiterConfig := &orb.Orb{}
+	if err := yaml.Unmarshal(current, orbiterConfig); err != nil {
+		return "", fmt.Errorf("parsing orbconfig kubernetes secret failed: %w", err)
+	}
+	if orbiterConfig.Identity == orbConfig.Identity {
+		return "", nil
+	}
+	return orbiterConfig.Identity, nil
+}
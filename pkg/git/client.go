@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,11 +16,17 @@ import (
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
@@ -45,6 +52,13 @@ const (
 	ZitadelFile    DesiredFile = "zitadel.yml"
 )
 
+const (
+	syncAttempts = 3
+	maxBackoff   = 5 * time.Minute
+	// recloneInterval limits how many objects of fetched commits accumulate in memory
+	recloneInterval = 24 * time.Hour
+)
+
 type Client struct {
 	monitor   mntr.Monitor
 	ctx       context.Context
@@ -58,6 +72,12 @@ type Client struct {
 	progress  io.Writer
 	repoURL   string
 	cloned    bool
+	clonedAt  time.Time
+	// dir is empty for keeping the repository in memory
+	dir          string
+	failures     int
+	syncErr      error
+	backoffUntil time.Time
 }
 
 func New(ctx context.Context, monitor mntr.Monitor, committer, email string) *Client {
@@ -188,31 +208,122 @@ func (g *Client) writeCheck() (err error) {
 	return nil
 }
 
+// Clone clones the repository the first time and fetches it incrementally afterwards.
+// When the remote is unavailable, subsequent calls fail fast until a jittered exponential backoff elapsed.
 func (g *Client) Clone() (err error) {
-	for i := 0; i < 10; i++ {
-		if err = g.clone(); err == nil {
+
+	if wait := time.Until(g.backoffUntil); wait > 0 {
+		return fmt.Errorf("repository %s is unavailable, retrying in %s: %w", g.repoURL, wait.Round(time.Second), g.syncErr)
+	}
+
+	for i := 0; i < syncAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-g.ctx.Done():
+				return g.ctx.Err()
+			case <-time.After(jitter(time.Second << uint(i-1))):
+			}
+		}
+		if err = g.sync(); err == nil {
+			g.failures = 0
+			g.syncErr = nil
 			return nil
 		}
-		time.Sleep(time.Second)
 	}
+
+	g.failures++
+	g.syncErr = err
+	g.backoffUntil = time.Now().Add(jitter(backoff(g.failures)))
 	return err
 }
 
+func backoff(failures int) time.Duration {
+	if failures > 8 {
+		return maxBackoff
+	}
+	if wait := time.Second << uint(failures); wait < maxBackoff {
+		return wait
+	}
+	return maxBackoff
+}
+
+// jitter randomizes durations between half and the full duration, so many clients don't retry at once
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (g *Client) sync() error {
+	if g.cloned && (g.dir != "" || time.Since(g.clonedAt) < recloneInterval) {
+		err := g.fetch()
+		if err == nil {
+			return nil
+		}
+		g.monitor.WithField("reason", err.Error()).Info("Fetching failed, recloning")
+	}
+	return g.clone()
+}
+
+// fetch downloads only the objects of new commits, as go-git tells the remote which commits the local repository has.
+// Then it resets the work tree to the remote branch, discarding unpushed commits.
+func (g *Client) fetch() error {
+
+	g.monitor.Debug("Fetching")
+	if err := g.repo.FetchContext(g.ctx, &gogit.FetchOptions{
+		RemoteName: "origin",
+		Auth:       g.auth,
+		Force:      true,
+		Progress:   g.progress,
+	}); err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetching repository from %s failed: %w", g.repoURL, err)
+	}
+
+	remote, err := g.remoteBranch()
+	if err != nil {
+		return err
+	}
+
+	if err := g.workTree.Reset(&gogit.ResetOptions{
+		Commit: remote.Hash(),
+		Mode:   gogit.HardReset,
+	}); err != nil {
+		return fmt.Errorf("resetting work tree to %s failed: %w", remote.Hash(), err)
+	}
+	g.monitor.Debug("Fetched")
+	return nil
+}
+
+func (g *Client) remoteBranch() (*plumbing.Reference, error) {
+	head, err := g.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get head: %w", err)
+	}
+	remote, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", head.Name().Short()), true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote branch: %w", err)
+	}
+	return remote, nil
+}
+
+// clone fetches the whole history, as go-git can't negotiate fetching new commits into shallow repositories
 func (g *Client) clone() error {
-	g.fs = memfs.New()
 
 	g.monitor.Debug("Cloning")
-	var err error
-	g.repo, err = gogit.CloneContext(g.ctx, memory.NewStorage(), g.fs, &gogit.CloneOptions{
+
+	storage, fs, err := g.newStorage()
+	if err != nil {
+		return err
+	}
+
+	g.repo, err = gogit.CloneContext(g.ctx, storage, fs, &gogit.CloneOptions{
 		URL:          g.repoURL,
 		Auth:         g.auth,
 		SingleBranch: true,
-		Depth:        1,
 		Progress:     g.progress,
 	})
 	if err != nil {
 		return mntr.ToUserError(fmt.Errorf("cloning repository from %s failed: %w", g.repoURL, err))
 	}
+	g.fs = fs
 	g.monitor.Debug("Cloned")
 
 	g.workTree, err = g.repo.Worktree()
@@ -221,10 +332,93 @@ func (g *Client) clone() error {
 	}
 
 	g.cloned = true
+	g.clonedAt = time.Now()
 
 	return nil
 }
 
+// newStorage removes a previously cloned repository from the directory
+func (g *Client) newStorage() (storage.Storer, billy.Filesystem, error) {
+	if g.dir == "" {
+		return memory.NewStorage(), memfs.New(), nil
+	}
+
+	dir := g.repositoryDir()
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, nil, fmt.Errorf("removing repository directory %s failed: %w", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, fmt.Errorf("creating repository directory %s failed: %w", dir, err)
+	}
+	fs := osfs.New(dir)
+	dotGit, err := fs.Chroot(gogit.GitDirName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filesystem.NewStorage(dotGit, cache.NewObjectLRUDefault()), fs, nil
+}
+
+// repositoryDir is owned by the client, so recloning never removes other files in the directory passed to StoreIn
+func (g *Client) repositoryDir() string {
+	return filepath.Join(g.dir, "orbos-repository")
+}
+
+// StoreIn keeps the repository in a directory instead of in memory.
+// A repository cloned from the same URL by a previous process is fetched instead of cloned again.
+func (g *Client) StoreIn(dir string) {
+	g.dir = dir
+
+	fs := osfs.New(g.repositoryDir())
+	dotGit, err := fs.Chroot(gogit.GitDirName)
+	if err != nil {
+		return
+	}
+	repo, err := gogit.Open(filesystem.NewStorage(dotGit, cache.NewObjectLRUDefault()), fs)
+	if err != nil {
+		return
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return
+	}
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return
+	}
+
+	g.repo = repo
+	g.fs = fs
+	g.workTree = workTree
+	g.repoURL = remote.Config().URLs[0]
+	g.cloned = true
+	g.clonedAt = time.Now()
+}
+
+// Fingerprint returns the blob hashes of the files at the cloned commit, so callers can skip work if they didn't change
+func (g *Client) Fingerprint(paths ...string) string {
+	if !g.cloned {
+		return ""
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return ""
+	}
+	commit, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return ""
+	}
+	hashes := make([]string, len(paths))
+	for i, path := range paths {
+		file, err := commit.File(path)
+		if err != nil {
+			hashes[i] = "-"
+			continue
+		}
+		hashes[i] = file.Hash.String()
+	}
+	return strings.Join(hashes, ",")
+}
+
 func (g *Client) Read(path string) []byte {
 
 	readmonitor := g.monitor.WithFields(map[string]interface{}{
@@ -292,6 +486,24 @@ func (g *Client) EmptyFolder(path string) (bool, error) {
 	return false, nil
 }
 
+// ListFolder returns the names of the files in the folder without reading them
+func (g *Client) ListFolder(path string) ([]string, error) {
+	files, err := g.fs.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening %s from worktree failed: %w", path, err)
+	}
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if !file.IsDir() {
+			names = append(names, file.Name())
+		}
+	}
+	return names, nil
+}
+
 func (g *Client) ReadFolder(path string) (map[string][]byte, []string, error) {
 	monitor := g.monitor.WithFields(map[string]interface{}{
 		"path": path,
@@ -326,6 +538,8 @@ func (g *Client) ReadFolder(path string) (map[string][]byte, []string, error) {
 type File struct {
 	Path    string
 	Content []byte
+	// Delete removes the file instead of writing Content
+	Delete bool
 }
 
 func (g *Client) stageAndCommit(msg string, files ...File) (bool, error) {
@@ -355,6 +569,7 @@ func (g *Client) UpdateRemote(msg string, whenCloned func() []File) error {
 	if err != nil &&
 		(errors.Is(err, plumbing.ErrObjectNotFound) ||
 			strings.Contains(err.Error(), "cannot lock ref")) {
+		// Fetching resets the work tree to the remote branch, so the files are committed again on top of the remote changes
 		g.monitor.WithField("response", err.Error()).Info("Git collision detected, retrying")
 		return g.UpdateRemote(msg, whenCloned)
 	}
@@ -367,6 +582,14 @@ func (g *Client) stage(files ...File) bool {
 			"path": f.Path,
 		})
 
+		if f.Delete {
+			updatemonitor.Debug("Removing from local index")
+			if _, err := g.workTree.Remove(f.Path); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				panic(err)
+			}
+			continue
+		}
+
 		updatemonitor.Debug("Overwriting local index")
 
 		file, err := g.fs.Create(f.Path)
@@ -420,6 +643,15 @@ func (g *Client) Push() error {
 		return fmt.Errorf("pushing repository failed: %w", err)
 	}
 
+	// Pushing doesn't update the remote branch, which the next fetch resets the work tree to
+	head, err := g.repo.Head()
+	if err != nil {
+		return fmt.Errorf("failed to get head: %w", err)
+	}
+	if err := g.repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", head.Name().Short()), head.Hash())); err != nil {
+		return fmt.Errorf("updating remote branch failed: %w", err)
+	}
+
 	g.monitor.Info("Repository pushed")
 	return nil
 }
@@ -437,6 +669,81 @@ func (g *Client) ReadTree(path DesiredFile) (*tree.Tree, error) {
 	return tree, yaml.Unmarshal(g.Read(string(path)), tree)
 }
 
+// Revision is the content of a file since a commit
+type Revision struct {
+	Hash    string
+	When    time.Time
+	Content []byte
+}
+
+// History returns the distinct revisions of each file within the last depth commits, newest first.
+// The history is cloned separately, so reading it doesn't interfere with the clients work tree.
+func (g *Client) History(depth int, paths ...DesiredFile) (map[DesiredFile][]*Revision, error) {
+
+	repo, err := gogit.CloneContext(g.ctx, memory.NewStorage(), nil, &gogit.CloneOptions{
+		URL:          g.repoURL,
+		Auth:         g.auth,
+		SingleBranch: true,
+		Depth:        depth,
+		Progress:     g.progress,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cloning history from %s failed: %w", g.repoURL, err)
+	}
+
+	history := make(map[DesiredFile][]*Revision, len(paths))
+	for _, path := range paths {
+		revisions, err := fileHistory(repo, path)
+		if err != nil {
+			return nil, err
+		}
+		history[path] = revisions
+	}
+	return history, nil
+}
+
+func fileHistory(repo *gogit.Repository, path DesiredFile) ([]*Revision, error) {
+
+	commits, err := repo.Log(&gogit.LogOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("reading history failed: %w", err)
+	}
+
+	var (
+		revisions []*Revision
+		blob      plumbing.Hash
+	)
+	err = commits.ForEach(func(commit *object.Commit) error {
+		file, err := commit.File(string(path))
+		if err != nil {
+			// The file didn't exist before or the shallow history ends
+			return storer.ErrStop
+		}
+		if file.Hash == blob {
+			// Report when the content was introduced
+			last := revisions[len(revisions)-1]
+			last.Hash = commit.Hash.String()
+			last.When = commit.Committer.When
+			return nil
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return storer.ErrStop
+		}
+		blob = file.Hash
+		revisions = append(revisions, &Revision{
+			Hash:    commit.Hash.String(),
+			When:    commit.Committer.When,
+			Content: []byte(content),
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, fmt.Errorf("reading history of %s failed: %w", path, err)
+	}
+	return revisions, nil
+}
+
 type GitDesiredState struct {
 	Desired *tree.Tree
 	Path    DesiredFile
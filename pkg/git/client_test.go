@@ -0,0 +1,278 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage"
+
+	"github.com/caos/orbos/mntr"
+)
+
+// testRemote creates a bare repository with an initial commit, which clients access using the file transport
+func testRemote(t *testing.T) string {
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "remote.git")
+	work := filepath.Join(dir, "work")
+
+	for _, args := range [][]string{
+		{"init", "--bare", "--initial-branch=master", remote},
+		{"init", "--initial-branch=master", work},
+		{"-C", work, "-c", "user.name=test", "-c", "user.email=test@caos.ch", "commit", "--allow-empty", "-m", "initial"},
+		{"-C", work, "push", remote, "master"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %s: %v", strings.Join(args, " "), string(out), err)
+		}
+	}
+	return remote
+}
+
+func testClient(remote string) *Client {
+	client := New(context.Background(), mntr.Monitor{}, "orbos", "orbos@caos.ch")
+	client.repoURL = remote
+	return client
+}
+
+func write(t *testing.T, client *Client, files ...File) {
+	if err := client.UpdateRemote("test", func() []File { return files }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_CloneFetches(t *testing.T) {
+	remote := testRemote(t)
+	writer := testClient(remote)
+	write(t, writer, File{Path: "orbiter.yml", Content: []byte("v1")}, File{Path: "boom.yml", Content: []byte("v1")})
+
+	reader := testClient(remote)
+	if err := reader.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	clonedAt := reader.clonedAt
+	fingerprint := reader.Fingerprint("orbiter.yml", "boom.yml", "missing.yml")
+	if parts := strings.Split(fingerprint, ","); len(parts) != 3 || parts[2] != "-" {
+		t.Errorf("expected a hash for each existing file and - for missing files, but got %s", fingerprint)
+	}
+
+	if err := reader.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.Fingerprint("orbiter.yml", "boom.yml", "missing.yml") != fingerprint {
+		t.Error("expected the fingerprint not to change without new commits")
+	}
+
+	write(t, writer, File{Path: "orbiter.yml", Content: []byte("v2")})
+	counting := &countingStorer{Storer: reader.repo.Storer}
+	reader.repo.Storer = counting
+	if err := reader.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	// The new commit, its tree and the changed blob
+	if counting.stored != 3 {
+		t.Errorf("expected fetching to store only the 3 new objects, but it stored %d", counting.stored)
+	}
+	if !reader.clonedAt.Equal(clonedAt) {
+		t.Error("expected the repository to be fetched instead of cloned again")
+	}
+	if got := string(reader.Read("orbiter.yml")); got != "v2" {
+		t.Errorf("expected the fetched content v2, but got %s", got)
+	}
+	if reader.Fingerprint("orbiter.yml") == strings.Split(fingerprint, ",")[0] {
+		t.Error("expected the fingerprint of the changed file to change")
+	}
+	if reader.Fingerprint("boom.yml") != strings.Split(fingerprint, ",")[1] {
+		t.Error("expected the fingerprint of the unchanged file not to change")
+	}
+}
+
+type countingStorer struct {
+	storage.Storer
+	stored int
+}
+
+func (c *countingStorer) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	c.stored++
+	return c.Storer.SetEncodedObject(obj)
+}
+
+func TestClient_UpdateRemoteFetchesAfterCollisions(t *testing.T) {
+	remote := testRemote(t)
+	first := testClient(remote)
+	write(t, first, File{Path: "orbiter.yml", Content: []byte("v1")})
+
+	second := testClient(remote)
+	if err := second.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	clonedAt := second.clonedAt
+
+	write(t, first, File{Path: "orbiter.yml", Content: []byte("v2")})
+
+	// The second client commits on top of v1 first, so pushing collides
+	if second.stage(File{Path: "boom.yml", Content: []byte("v1")}) {
+		t.Fatal("expected staging to change the work tree")
+	}
+	if err := second.Commit("collides"); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Push(); err == nil {
+		t.Fatal("expected pushing a commit on top of an outdated commit to fail")
+	}
+
+	write(t, second, File{Path: "boom.yml", Content: []byte("v1")})
+	if !second.clonedAt.Equal(clonedAt) {
+		t.Error("expected the repository to be fetched instead of cloned again")
+	}
+
+	reader := testClient(remote)
+	if err := reader.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	if orbiter, boom := string(reader.Read("orbiter.yml")), string(reader.Read("boom.yml")); orbiter != "v2" || boom != "v1" {
+		t.Errorf("expected both changes to be pushed, but got orbiter.yml %s and boom.yml %s", orbiter, boom)
+	}
+}
+
+func TestClient_FetchDiscardsUnpushedCommits(t *testing.T) {
+	remote := testRemote(t)
+	client := testClient(remote)
+	write(t, client, File{Path: "orbiter.yml", Content: []byte("pushed")})
+
+	if client.stage(File{Path: "orbiter.yml", Content: []byte("unpushed")}) {
+		t.Fatal("expected staging to change the work tree")
+	}
+	if err := client.Commit("unpushed"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(client.Read("orbiter.yml")); got != "pushed" {
+		t.Errorf("expected the work tree to be reset to the remote branch, but got %s", got)
+	}
+}
+
+func TestClient_StoreIn(t *testing.T) {
+	remote := testRemote(t)
+	dir := filepath.Join(t.TempDir(), "repository")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other")
+	if err := ioutil.WriteFile(other, []byte("other"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	first := testClient(remote)
+	first.StoreIn(dir)
+	write(t, first, File{Path: "orbiter.yml", Content: []byte("v1")})
+
+	// A restarted process reuses the repository
+	second := New(context.Background(), mntr.Monitor{}, "orbos", "orbos@caos.ch")
+	second.StoreIn(dir)
+	if !second.cloned || second.GetURL() != remote {
+		t.Fatalf("expected the stored repository of %s to be reused, but cloned is %t and the url is %s", remote, second.cloned, second.GetURL())
+	}
+	if got := string(second.Read("orbiter.yml")); got != "v1" {
+		t.Errorf("expected to read the stored content v1, but got %s", got)
+	}
+
+	write(t, first, File{Path: "orbiter.yml", Content: []byte("v2")})
+	clonedAt := second.clonedAt
+	if err := second.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	if !second.clonedAt.Equal(clonedAt) {
+		t.Error("expected the stored repository to be fetched instead of cloned again")
+	}
+	if got := string(second.Read("orbiter.yml")); got != "v2" {
+		t.Errorf("expected the fetched content v2, but got %s", got)
+	}
+
+	if err := second.clone(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("expected cloning again not to remove other files in the directory, but got %v", err)
+	}
+
+	// Directories without a repository are ignored
+	empty := New(context.Background(), mntr.Monitor{}, "orbos", "orbos@caos.ch")
+	empty.StoreIn(t.TempDir())
+	if empty.cloned {
+		t.Error("expected a directory without a repository not to be used")
+	}
+}
+
+func TestClient_CloneBacksOff(t *testing.T) {
+	client := testClient(filepath.Join(t.TempDir(), "missing.git"))
+
+	if err := client.Clone(); err == nil {
+		t.Fatal("expected cloning a missing repository to fail")
+	}
+	if client.failures != 1 || time.Until(client.backoffUntil) <= 0 {
+		t.Fatalf("expected a backoff after the first failure, but got %d failures and backoff until %s", client.failures, client.backoffUntil)
+	}
+
+	started := time.Now()
+	err := client.Clone()
+	if err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Errorf("expected cloning to fail fast while backing off, but got %v", err)
+	}
+	if took := time.Since(started); took > 100*time.Millisecond {
+		t.Errorf("expected cloning to fail fast while backing off, but it took %s", took)
+	}
+	if client.failures != 1 {
+		t.Errorf("expected failing fast not to count as failure, but got %d failures", client.failures)
+	}
+}
+
+func TestClient_CloneStopsRetryingWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := New(ctx, mntr.Monitor{}, "orbos", "orbos@caos.ch")
+	client.repoURL = filepath.Join(t.TempDir(), "missing.git")
+
+	started := time.Now()
+	if err := client.Clone(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cloning to stop retrying with the contexts error, but got %v", err)
+	}
+	if took := time.Since(started); took > 100*time.Millisecond {
+		t.Errorf("expected cloning not to wait for retries, but it took %s", took)
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	for _, tt := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 2 * time.Second},
+		{failures: 4, want: 16 * time.Second},
+		{failures: 8, want: 256 * time.Second},
+		{failures: 9, want: maxBackoff},
+		{failures: 100, want: maxBackoff},
+	} {
+		if got := backoff(tt.failures); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func Test_jitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := jitter(time.Minute); got < 30*time.Second || got > time.Minute {
+			t.Fatalf("jitter(1m) = %s, want between 30s and 1m", got)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// OrphanBranch is a branch without history in the clients repository.
+// Each update replaces the branch by a single commit, so deleted files don't remain in the repository
+// and the desired state branch is never touched.
+type OrphanBranch struct {
+	client *Client
+	name   string
+}
+
+// OrphanBranch returns a branch which shares the clients repository and credentials but nothing else
+func (g *Client) OrphanBranch(name string) *OrphanBranch {
+	return &OrphanBranch{client: g, name: name}
+}
+
+func (o *OrphanBranch) ref() plumbing.ReferenceName {
+	return plumbing.NewBranchReferenceName(o.name)
+}
+
+// files fetches the latest commit of the branch and returns all its files. If the branch doesn't exist yet, no files are returned.
+func (o *OrphanBranch) files() (map[string][]byte, error) {
+
+	repo, err := gogit.Init(memory.NewStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteRef := plumbing.NewRemoteReferenceName("origin", o.name)
+	remote, err := repo.CreateRemote(&config.RemoteConfig{
+		Name:  "origin",
+		URLs:  []string{o.client.repoURL},
+		Fetch: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", o.ref(), remoteRef))},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	err = remote.FetchContext(o.client.ctx, &gogit.FetchOptions{
+		Auth:     o.client.auth,
+		Depth:    1,
+		Progress: o.client.progress,
+	})
+	if errors.Is(err, gogit.NoMatchingRefSpecError{}) || errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return files, nil
+	}
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fetching branch %s from %s failed: %w", o.name, o.client.repoURL, err)
+	}
+
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+	iter, err := commit.Files()
+	if err != nil {
+		return nil, err
+	}
+	return files, iter.ForEach(func(file *object.File) error {
+		reader, err := file.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		files[file.Name] = content
+		return nil
+	})
+}
+
+// ListFolder returns the names of the files in a folder of the branch
+func (o *OrphanBranch) ListFolder(path string) ([]string, error) {
+	files, err := o.files()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for file := range files {
+		if dir, name := filepath.Split(file); strings.TrimSuffix(dir, "/") == path {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Read returns the content of a file of the branch or nil if it doesn't exist
+func (o *OrphanBranch) Read(path string) ([]byte, error) {
+	files, err := o.files()
+	if err != nil {
+		return nil, err
+	}
+	return files[path], nil
+}
+
+// Update force pushes a single commit, which contains the branches files with the changes applied
+func (o *OrphanBranch) Update(msg string, changes ...File) error {
+
+	files, err := o.files()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		if change.Delete {
+			delete(files, change.Path)
+			continue
+		}
+		files[change.Path] = change.Content
+	}
+
+	fs := memfs.New()
+	repo, err := gogit.Init(memory.NewStorage(), fs)
+	if err != nil {
+		return err
+	}
+	workTree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	for path, content := range files {
+		if err := util.WriteFile(fs, path, content, 0600); err != nil {
+			return err
+		}
+		if _, err := workTree.Add(path); err != nil {
+			return err
+		}
+	}
+
+	hash, err := workTree.Commit(msg, &gogit.CommitOptions{
+		Author: &object.Signature{
+			Name:  o.client.committer,
+			Email: o.client.email,
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("committing to branch %s failed: %w", o.name, err)
+	}
+
+	if _, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{o.client.repoURL},
+	}); err != nil {
+		return err
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(o.ref(), hash)); err != nil {
+		return err
+	}
+
+	if err := repo.PushContext(o.client.ctx, &gogit.PushOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", o.ref(), o.ref()))},
+		Auth:       o.client.auth,
+		Progress:   o.client.progress,
+	}); err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("pushing branch %s failed: %w", o.name, err)
+	}
+
+	o.client.monitor.WithField("branch", o.name).Info("Branch replaced")
+	return nil
+}
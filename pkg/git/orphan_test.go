@@ -0,0 +1,55 @@
+package git
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOrphanBranch(t *testing.T) {
+	remote := testRemote(t)
+	client := testClient(remote)
+	write(t, client, File{Path: "orbiter.yml", Content: []byte("desired")})
+
+	branch := client.OrphanBranch("snapshots")
+	if names, err := branch.ListFolder("cluster"); err != nil || len(names) != 0 {
+		t.Fatalf("expected a missing branch to be empty, but got %v, %v", names, err)
+	}
+
+	if err := branch.Update("first", File{Path: "cluster/1", Content: []byte("one")}, File{Path: "cluster/2", Content: []byte("two")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := branch.Update("prune", File{Path: "cluster/1", Delete: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := branch.ListFolder("cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"2"}) {
+		t.Errorf("expected only the remaining file, but got %v", names)
+	}
+	if content, err := branch.Read("cluster/2"); err != nil || string(content) != "two" {
+		t.Errorf("expected the content two, but got %s, %v", string(content), err)
+	}
+
+	out, err := exec.Command("git", "--git-dir", remote, "rev-list", "--count", "snapshots").CombinedOutput()
+	if err != nil {
+		t.Fatalf("counting commits failed: %s: %v", string(out), err)
+	}
+	if count := strings.TrimSpace(string(out)); count != "1" {
+		t.Errorf("expected the branch to consist of a single commit, but got %s", count)
+	}
+
+	if err := client.Clone(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(client.Read("orbiter.yml")); got != "desired" {
+		t.Errorf("expected the desired state branch to be untouched, but got %s", got)
+	}
+	if files, err := client.ListFolder("cluster"); err != nil || len(files) != 0 {
+		t.Errorf("expected the branch files not to be in the desired state branch, but got %v, %v", files, err)
+	}
+}
@@ -2,6 +2,7 @@ package kubernetes
 
 import (
 	core "k8s.io/api/core/v1"
+	macherrs "k8s.io/apimachinery/pkg/api/errors"
 	mach "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/caos/orbos/mntr"
@@ -48,6 +49,18 @@ func EnsureOrbconfigSecret(monitor mntr.Monitor, client ClientInt, orbconfig []b
 	return nil
 }
 
+// ReadOrbconfigSecret returns the orbconfig the operators use or nil, if it is not written yet
+func ReadOrbconfigSecret(client ClientInt) ([]byte, error) {
+	secret, err := client.GetSecret("caos-system", "caos")
+	if macherrs.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data["orbconfig"], nil
+}
+
 func toNameLabels(apiLabels *labels.API, operatorName string) *labels.Name {
 	return labels.MustForName(labels.MustForComponent(apiLabels, "operator"), operatorName)
 }
@@ -0,0 +1,183 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	macherrs "k8s.io/apimachinery/pkg/api/errors"
+	mach "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AutoscalerServerSecret holds ORBITERs certificate for serving the cluster-autoscaler cloud provider
+	AutoscalerServerSecret = "orbiter-autoscaler-server"
+	// AutoscalerClientSecret holds the cluster-autoscalers client certificate in the kube-system namespace
+	AutoscalerClientSecret = "orbiter-autoscaler-client"
+	// AutoscalerTLSPath is where ORBITER mounts the AutoscalerServerSecret
+	AutoscalerTLSPath = "/etc/orbiter-autoscaler"
+
+	autoscalerCertValidity = 10 * 365 * 24 * time.Hour
+)
+
+// AutoscalerTLS holds PEM encoded certificates and keys for mutual TLS between the cluster-autoscaler and ORBITER
+type AutoscalerTLS struct {
+	CA         []byte
+	ServerCert []byte
+	ServerKey  []byte
+	ClientCert []byte
+	ClientKey  []byte
+}
+
+// NewAutoscalerTLS creates a certificate authority, which signs a server certificate for the passed DNS names and a client certificate
+func NewAutoscalerTLS(serverNames ...string) (*AutoscalerTLS, error) {
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := certTemplate("orbiter-autoscaler-ca")
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	caCert, _, err := signCert(caTemplate, caTemplate, caKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(caCert.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	serverTemplate := certTemplate("orbiter")
+	serverTemplate.DNSNames = serverNames
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	serverCert, serverKey, err := signCert(serverTemplate, ca, nil, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientTemplate := certTemplate("cluster-autoscaler")
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	clientCert, clientKey, err := signCert(clientTemplate, ca, nil, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AutoscalerTLS{
+		CA:         pem.EncodeToMemory(caCert),
+		ServerCert: pem.EncodeToMemory(serverCert),
+		ServerKey:  pem.EncodeToMemory(serverKey),
+		ClientCert: pem.EncodeToMemory(clientCert),
+		ClientKey:  pem.EncodeToMemory(clientKey),
+	}, nil
+}
+
+func certTemplate(commonName string) *x509.Certificate {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(autoscalerCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+// signCert generates a new key if key is nil
+func signCert(template, parent *x509.Certificate, key, parentKey *ecdsa.PrivateKey) (*pem.Block, *pem.Block, error) {
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, nil, err
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &pem.Block{Type: "CERTIFICATE", Bytes: der}, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}, nil
+}
+
+// ensureAutoscalerTLS creates the server and client secrets for the cluster-autoscaler cloud provider.
+// If one of them is missing or they are signed by different certificate authorities, both are recreated with a new certificate authority.
+func ensureAutoscalerTLS(client ClientInt, service string, labels map[string]string) error {
+
+	ca := func(namespace, name string) ([]byte, error) {
+		secret, err := client.GetSecret(namespace, name)
+		if macherrs.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return secret.Data["ca.crt"], nil
+	}
+
+	serverCA, err := ca("caos-system", AutoscalerServerSecret)
+	if err != nil {
+		return err
+	}
+	clientCA, err := ca("kube-system", AutoscalerClientSecret)
+	if err != nil {
+		return err
+	}
+	if len(serverCA) > 0 && bytes.Equal(serverCA, clientCA) {
+		return nil
+	}
+
+	certs, err := NewAutoscalerTLS(
+		service,
+		fmt.Sprintf("%s.caos-system", service),
+		fmt.Sprintf("%s.caos-system.svc", service),
+		fmt.Sprintf("%s.caos-system.svc.cluster.local", service),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := client.ApplySecret(&core.Secret{
+		ObjectMeta: mach.ObjectMeta{
+			Name:      AutoscalerClientSecret,
+			Namespace: "kube-system",
+			Labels:    labels,
+		},
+		Type: core.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":  certs.CA,
+			"tls.crt": certs.ClientCert,
+			"tls.key": certs.ClientKey,
+		},
+	}); err != nil {
+		return err
+	}
+
+	return client.ApplySecret(&core.Secret{
+		ObjectMeta: mach.ObjectMeta{
+			Name:      AutoscalerServerSecret,
+			Namespace: "caos-system",
+			Labels:    labels,
+		},
+		Type: core.SecretTypeTLS,
+		Data: map[string][]byte{
+			"ca.crt":  certs.CA,
+			"tls.crt": certs.ServerCert,
+			"tls.key": certs.ServerKey,
+		},
+	})
+}
@@ -132,6 +132,10 @@ type ClientInt interface {
 	ApplyPodDisruptionBudget(rsc *policy.PodDisruptionBudget) error
 	DeletePodDisruptionBudget(namespace string, name string) error
 
+	AcquireLease(namespace, name, holder string, duration time.Duration) (bool, error)
+	ReleaseLease(namespace, name, holder string) error
+	HeldLeases(namespace, prefix, holder string) ([]string, error)
+
 	ApplyNamespace(rsc *core.Namespace) error
 	DeleteNamespace(name string) error
 
@@ -147,7 +151,7 @@ var _ ClientInt = (*Client)(nil)
 
 type Client struct {
 	monitor           mntr.Monitor
-	set               *kubernetes.Clientset
+	set               kubernetes.Interface
 	dynamic           dynamic.Interface
 	apixv1beta1client *apixv1beta1client.ApiextensionsV1beta1Client
 	mapper            *restmapper.DeferredDiscoveryRESTMapper
@@ -1167,19 +1171,34 @@ func (c *Client) evictPods(node *core.Node, self bool) (err error) {
 			}
 			defer watcher.Stop()
 
-			if goErr := c.set.PolicyV1beta1().Evictions(pod.Namespace).Evict(context.Background(), &policy.Eviction{
-				TypeMeta: mach.TypeMeta{
-					Kind:       "EvictionKind",
-					APIVersion: c.set.PolicyV1beta1().RESTClient().APIVersion().String(),
-				},
-				ObjectMeta: mach.ObjectMeta{
-					Name:      pod.Name,
-					Namespace: pod.Namespace,
-				},
-				DeleteOptions: &mach.DeleteOptions{
-					GracePeriodSeconds: &gracePeriodSeconds,
-				},
-			}); goErr != nil {
+			evict := func() error {
+				return c.set.PolicyV1beta1().Evictions(pod.Namespace).Evict(context.Background(), &policy.Eviction{
+					TypeMeta: mach.TypeMeta{
+						Kind:       "EvictionKind",
+						APIVersion: c.set.PolicyV1beta1().RESTClient().APIVersion().String(),
+					},
+					ObjectMeta: mach.ObjectMeta{
+						Name:      pod.Name,
+						Namespace: pod.Namespace,
+					},
+					DeleteOptions: &mach.DeleteOptions{
+						GracePeriodSeconds: &gracePeriodSeconds,
+					},
+				})
+			}
+
+			// The eviction API refuses evictions which would violate a pod disruption budget
+			goErr = evict()
+			for retries := 0; macherrs.IsTooManyRequests(goErr) && retries < 12; retries++ {
+				monitor.Info("Pod disruption budget does not allow evicting the pod yet")
+				time.Sleep(5 * time.Second)
+				goErr = evict()
+			}
+			if macherrs.IsTooManyRequests(goErr) {
+				synchronizer.Done(fmt.Errorf("pod disruption budget does not allow evicting pod %s: %w", pod.Name, goErr))
+				return
+			}
+			if goErr != nil {
 				synchronizer.Done(fmt.Errorf("evicting pod %s failed: %w", pod.Name, goErr))
 				return
 			}
@@ -0,0 +1,117 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	coordination "k8s.io/api/coordination/v1"
+	macherrs "k8s.io/apimachinery/pkg/api/errors"
+	mach "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AcquireLease returns true if the holder holds the lease afterwards.
+// A lease is acquired if it doesn't exist, if it is expired or if the holder already holds it, in which case it is renewed.
+func (c *Client) AcquireLease(namespace, name, holder string, duration time.Duration) (bool, error) {
+	leases := c.set.CoordinationV1().Leases(namespace)
+	seconds := int32(duration.Seconds())
+	now := mach.NewMicroTime(time.Now())
+
+	lease, err := leases.Get(context.Background(), name, mach.GetOptions{})
+	if macherrs.IsNotFound(err) {
+		_, err = leases.Create(context.Background(), &coordination.Lease{
+			ObjectMeta: mach.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: coordination.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, mach.CreateOptions{})
+		if macherrs.IsAlreadyExists(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("creating lease %s in namespace %s failed: %w", name, namespace, err)
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting lease %s in namespace %s failed: %w", name, namespace, err)
+	}
+
+	held := lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == holder
+	if !held && !leaseExpired(lease, now.Time) {
+		return false, nil
+	}
+
+	if !held {
+		lease.Spec.HolderIdentity = &holder
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+
+	// The resource version of the read lease guards against concurrent acquisitions
+	_, err = leases.Update(context.Background(), lease, mach.UpdateOptions{})
+	if macherrs.IsConflict(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("updating lease %s in namespace %s failed: %w", name, namespace, err)
+	}
+	return true, nil
+}
+
+// ReleaseLease deletes the lease if the holder holds it
+func (c *Client) ReleaseLease(namespace, name, holder string) error {
+	leases := c.set.CoordinationV1().Leases(namespace)
+	lease, err := leases.Get(context.Background(), name, mach.GetOptions{})
+	if macherrs.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting lease %s in namespace %s failed: %w", name, namespace, err)
+	}
+
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != holder {
+		return nil
+	}
+
+	if err := notFoundIsSuccess(leases.Delete(context.Background(), name, mach.DeleteOptions{
+		Preconditions: &mach.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})); err != nil && !macherrs.IsConflict(err) {
+		return fmt.Errorf("deleting lease %s in namespace %s failed: %w", name, namespace, err)
+	}
+	return nil
+}
+
+// HeldLeases returns the names of the leases starting with the prefix which the holder holds, even if they are expired
+func (c *Client) HeldLeases(namespace, prefix, holder string) ([]string, error) {
+	list, err := c.set.CoordinationV1().Leases(namespace).List(context.Background(), mach.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing leases in namespace %s failed: %w", namespace, err)
+	}
+
+	var held []string
+	for _, lease := range list.Items {
+		if strings.HasPrefix(lease.Name, prefix) && lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == holder {
+			held = append(held, lease.Name)
+		}
+	}
+	return held, nil
+}
+
+func leaseExpired(lease *coordination.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return true
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
@@ -0,0 +1,174 @@
+package kubernetes
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	coordination "k8s.io/api/coordination/v1"
+	macherrs "k8s.io/apimachinery/pkg/api/errors"
+	mach "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "kube-system"
+
+func testLease(name, holder string, renewed time.Time) *coordination.Lease {
+	seconds := int32(time.Minute.Seconds())
+	renewTime := mach.NewMicroTime(renewed)
+	return &coordination.Lease{
+		ObjectMeta: mach.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+		},
+		Spec: coordination.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &seconds,
+			AcquireTime:          &renewTime,
+			RenewTime:            &renewTime,
+		},
+	}
+}
+
+func testLeaseClient(leases ...runtime.Object) *Client {
+	return &Client{set: fake.NewSimpleClientset(leases...)}
+}
+
+func holderOf(t *testing.T, client *Client, name string) string {
+	lease, err := client.set.CoordinationV1().Leases(testNamespace).Get(context.Background(), name, mach.GetOptions{})
+	if macherrs.IsNotFound(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func TestClient_AcquireLease(t *testing.T) {
+
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		existing   []runtime.Object
+		holder     string
+		want       bool
+		wantHolder string
+	}{{
+		name:       "It should create missing leases",
+		holder:     "machine-a",
+		want:       true,
+		wantHolder: "machine-a",
+	}, {
+		name:       "It should renew leases the holder holds",
+		existing:   []runtime.Object{testLease("lock", "machine-a", now.Add(-30*time.Second))},
+		holder:     "machine-a",
+		want:       true,
+		wantHolder: "machine-a",
+	}, {
+		name:       "It should not acquire leases others hold",
+		existing:   []runtime.Object{testLease("lock", "machine-b", now.Add(-30*time.Second))},
+		holder:     "machine-a",
+		want:       false,
+		wantHolder: "machine-b",
+	}, {
+		name:       "It should take over expired leases",
+		existing:   []runtime.Object{testLease("lock", "machine-b", now.Add(-2*time.Minute))},
+		holder:     "machine-a",
+		want:       true,
+		wantHolder: "machine-a",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testLeaseClient(tt.existing...)
+			got, err := client.AcquireLease(testNamespace, "lock", tt.holder, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("AcquireLease() = %t, want %t", got, tt.want)
+			}
+			if holder := holderOf(t, client, "lock"); holder != tt.wantHolder {
+				t.Errorf("lease is held by %s, want %s", holder, tt.wantHolder)
+			}
+		})
+	}
+}
+
+func TestClient_AcquireLease_renews(t *testing.T) {
+	renewed := time.Now().Add(-30 * time.Second)
+	client := testLeaseClient(testLease("lock", "machine-a", renewed))
+
+	if _, err := client.AcquireLease(testNamespace, "lock", "machine-a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	lease, err := client.set.CoordinationV1().Leases(testNamespace).Get(context.Background(), "lock", mach.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lease.Spec.RenewTime.After(renewed) {
+		t.Errorf("expected the renew time %s to be after %s", lease.Spec.RenewTime, renewed)
+	}
+	if !lease.Spec.AcquireTime.Time.Equal(mach.NewMicroTime(renewed).Time) {
+		t.Errorf("expected the acquire time not to change when renewing, but got %s", lease.Spec.AcquireTime)
+	}
+}
+
+func TestClient_ReleaseLease(t *testing.T) {
+
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		existing   []runtime.Object
+		holder     string
+		wantHolder string
+	}{{
+		name:     "It should delete leases the holder holds",
+		existing: []runtime.Object{testLease("lock", "machine-a", now)},
+		holder:   "machine-a",
+	}, {
+		name:       "It should keep leases others hold",
+		existing:   []runtime.Object{testLease("lock", "machine-b", now)},
+		holder:     "machine-a",
+		wantHolder: "machine-b",
+	}, {
+		name:   "It should succeed when the lease doesn't exist",
+		holder: "machine-a",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testLeaseClient(tt.existing...)
+			if err := client.ReleaseLease(testNamespace, "lock", tt.holder); err != nil {
+				t.Fatal(err)
+			}
+			if holder := holderOf(t, client, "lock"); holder != tt.wantHolder {
+				t.Errorf("lease is held by %s, want %s", holder, tt.wantHolder)
+			}
+		})
+	}
+}
+
+func TestClient_HeldLeases(t *testing.T) {
+	now := time.Now()
+	client := testLeaseClient(
+		testLease("orbos-reboot-gce-workers-0", "machine-a", now),
+		testLease("orbos-reboot-gce-workers-1", "machine-b", now),
+		testLease("orbos-reboot-gce-masters-0", "machine-a", now.Add(-time.Hour)),
+		testLease("kube-scheduler", "machine-a", now),
+	)
+
+	got, err := client.HeldLeases(testNamespace, "orbos-reboot-", "machine-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if want := []string{"orbos-reboot-gce-masters-0", "orbos-reboot-gce-workers-0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HeldLeases() = %v, want %v", got, want)
+	}
+}
@@ -820,6 +820,50 @@ func (mr *MockClientIntMockRecorder) DeletePodDisruptionBudget(namespace, name i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePodDisruptionBudget", reflect.TypeOf((*MockClientInt)(nil).DeletePodDisruptionBudget), namespace, name)
 }
 
+// AcquireLease mocks base method
+func (m *MockClientInt) AcquireLease(namespace, name, holder string, duration time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcquireLease", namespace, name, holder, duration)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcquireLease indicates an expected call of AcquireLease
+func (mr *MockClientIntMockRecorder) AcquireLease(namespace, name, holder, duration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcquireLease", reflect.TypeOf((*MockClientInt)(nil).AcquireLease), namespace, name, holder, duration)
+}
+
+// ReleaseLease mocks base method
+func (m *MockClientInt) ReleaseLease(namespace, name, holder string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseLease", namespace, name, holder)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseLease indicates an expected call of ReleaseLease
+func (mr *MockClientIntMockRecorder) ReleaseLease(namespace, name, holder interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseLease", reflect.TypeOf((*MockClientInt)(nil).ReleaseLease), namespace, name, holder)
+}
+
+// HeldLeases mocks base method
+func (m *MockClientInt) HeldLeases(namespace, prefix, holder string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HeldLeases", namespace, prefix, holder)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HeldLeases indicates an expected call of HeldLeases
+func (mr *MockClientIntMockRecorder) HeldLeases(namespace, prefix, holder interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HeldLeases", reflect.TypeOf((*MockClientInt)(nil).HeldLeases), namespace, prefix, holder)
+}
+
 // ApplyNamespace mocks base method
 func (m *MockClientInt) ApplyNamespace(rsc *v11.Namespace) error {
 	m.ctrl.T.Helper()
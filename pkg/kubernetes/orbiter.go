@@ -42,6 +42,10 @@ func EnsureOrbiterArtifacts(
 		cmd = append(cmd, "--disable-analytics")
 	}
 
+	if err := ensureAutoscalerTLS(client, nameLabels.Name(), k8sNameLabels); err != nil {
+		return err
+	}
+
 	deployment := &apps.Deployment{
 		ObjectMeta: mach.ObjectMeta{
 			Name:      nameLabels.Name(),
@@ -67,10 +71,17 @@ func EnsureOrbiterArtifacts(
 							Name:      "keys",
 							ReadOnly:  true,
 							MountPath: "/etc/orbiter",
+						}, {
+							Name:      "autoscaler",
+							ReadOnly:  true,
+							MountPath: AutoscalerTLSPath,
 						}},
 						Ports: []core.ContainerPort{{
 							Name:          "metrics",
 							ContainerPort: 9000,
+						}, {
+							Name:          "autoscaler",
+							ContainerPort: 9001,
 						}},
 						Resources: core.ResourceRequirements{
 							Limits: core.ResourceList{
@@ -106,6 +117,14 @@ func EnsureOrbiterArtifacts(
 								Optional:   boolPtr(false),
 							},
 						},
+					}, {
+						Name: "autoscaler",
+						VolumeSource: core.VolumeSource{
+							Secret: &core.SecretVolumeSource{
+								SecretName: AutoscalerServerSecret,
+								Optional:   boolPtr(true),
+							},
+						},
 					}},
 					NodeSelector: map[string]string{
 						"node-role.kubernetes.io/master": "",
@@ -143,6 +162,11 @@ func EnsureOrbiterArtifacts(
 				Protocol:   "TCP",
 				Port:       9000,
 				TargetPort: intstr.FromInt(9000),
+			}, {
+				Name:       "autoscaler",
+				Protocol:   "TCP",
+				Port:       9001,
+				TargetPort: intstr.FromInt(9001),
 			}},
 			Selector: k8sPodSelector,
 			Type:     core.ServiceTypeClusterIP,
@@ -63,6 +63,10 @@ func (l *Operator) Equal(r comparable) bool {
 	return false
 }
 
+func (l *Operator) Version() string {
+	return l.model.Version
+}
+
 func (l *Operator) MarshalYAML() (interface{}, error) {
 	return nil, errors.New("type *labels.Operator is not serializable")
 }
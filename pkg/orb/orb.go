@@ -28,6 +28,8 @@ type Orb struct {
 	URL       string
 	Repokey   string
 	Masterkey string
+	// Keys replace the masterkey for encrypting secrets if recipients or a vault transit key are configured
+	secret.Keys `yaml:",inline"`
 }
 
 func (o *Orb) IsConnectable() (err error) {
@@ -58,8 +60,8 @@ func IsComplete(o *Orb) (err error) {
 		return errors.New("path not provided")
 	}
 
-	if o.Masterkey == "" {
-		err = helpers.Concat(err, errors.New("master key is missing"))
+	if o.Masterkey == "" && o.Identity == "" && o.Vault == nil {
+		err = helpers.Concat(err, errors.New("master key, identity or vault is missing"))
 	}
 
 	if o.Path == "" {
@@ -88,8 +90,13 @@ func ParseOrbConfig(orbConfigPath string) (orb *Orb, err error) {
 		return nil, fmt.Errorf("unable to unmarshal yaml: %w", err)
 	}
 
+	if err := orb.Keys.Validate(); err != nil {
+		return nil, err
+	}
+
 	orb.Path = orbConfigPath
 	secret.Masterkey = orb.Masterkey
+	secret.KeyProviders = &orb.Keys
 	return orb, nil
 }
 
@@ -97,7 +104,7 @@ func (o *Orb) writeBackOrbConfig() error {
 
 	data, err := yaml.Marshal(o)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("marshalling orbconfig failed: %w", err)
 	}
 
 	if err := ioutil.WriteFile(o.Path, data, os.ModePerm); err != nil {
@@ -106,6 +113,51 @@ func (o *Orb) writeBackOrbConfig() error {
 	return nil
 }
 
+// RotateMasterkey writes the orbconfig with the new masterkey next to the current orbconfig before rewrite is called.
+// Only if rewrite succeeds, the current orbconfig is replaced, so the new masterkey is never lost.
+func (o *Orb) RotateMasterkey(newMasterkey string, rewrite func() error) error {
+
+	rotated := *o
+	rotated.Masterkey = newMasterkey
+
+	data, err := yaml.Marshal(&rotated)
+	if err != nil {
+		return fmt.Errorf("marshalling orbconfig with new masterkey failed: %w", err)
+	}
+
+	tmpPath := o.Path + ".new"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return mntr.ToUserError(fmt.Errorf("writing orbconfig with new masterkey to %s failed: %w", tmpPath, err))
+	}
+
+	if err := rewrite(); err != nil {
+		return fmt.Errorf("rewriting secrets failed, keep %s until you made sure that the secrets are not encrypted with its masterkey: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, o.Path); err != nil {
+		return mntr.ToUserError(fmt.Errorf("replacing orbconfig failed, the new masterkey is written to %s: %w", tmpPath, err))
+	}
+
+	o.Masterkey = newMasterkey
+	secret.Masterkey = newMasterkey
+	return nil
+}
+
+// RotateRecipients configures the new recipients before rewrite is called, so rewrite encrypts the secrets for them.
+// Only if rewrite succeeds, the orbconfig is written. Otherwise, the current recipients are configured again.
+func (o *Orb) RotateRecipients(recipients []string, rewrite func() error) error {
+
+	current := o.Recipients
+	o.Recipients = recipients
+
+	if err := rewrite(); err != nil {
+		o.Recipients = current
+		return fmt.Errorf("rewriting secrets failed: %w", err)
+	}
+
+	return o.writeBackOrbConfig()
+}
+
 func Reconfigure(
 	ctx context.Context,
 	monitor mntr.Monitor,
@@ -132,7 +184,7 @@ func Reconfigure(
 		return mntr.ToUserError(fmt.Errorf("repository url %s is not reconfigurable", orbConfig.URL))
 	}
 
-	if orbConfig.Masterkey == "" && newMasterKey == "" {
+	if orbConfig.Masterkey == "" && newMasterKey == "" && orbConfig.Identity == "" && orbConfig.Vault == nil {
 		return mntr.ToUserError(errors.New("master key is neighter passed by flag masterkey nor written in orbconfig"))
 	}
 
@@ -0,0 +1,157 @@
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Values of the versioned envelope look like v1:<base64url(salt)>:<key id>:<base64url(nonce|ciphertext)>.
+// As the colon is not part of the base64url alphabet, they never collide with legacy AES-CFB values.
+const (
+	envelopeVersion    = "v1"
+	envelopeEncryption = "XChaCha20-Poly1305"
+	envelopeEncoding   = "Base64"
+	saltSize           = 16
+)
+
+var (
+	kdfTime    = uint32(3)
+	kdfMemory  = uint32(32 * 1024)
+	kdfThreads = uint8(4)
+)
+
+type derivedKey struct {
+	key []byte
+	id  string
+}
+
+var (
+	derivedKeysMux sync.Mutex
+	derivedKeys    = make(map[string]*derivedKey)
+	// sealingSalts holds a random salt per masterkey for the lifetime of the process,
+	// so the key derivation runs only once per masterkey when writing many secrets
+	sealingSalts = make(map[string][]byte)
+)
+
+// deriveKey derives the AEAD key from the masterkey and the salt using Argon2id.
+// The key id is a short hash of the derived key, so a wrong masterkey is detected before decrypting.
+func deriveKey(masterkey string, salt []byte) (*derivedKey, error) {
+	masterkey = strings.Trim(masterkey, "\n")
+	if masterkey == "" {
+		return nil, errors.New("master key must not be empty")
+	}
+
+	derivedKeysMux.Lock()
+	defer derivedKeysMux.Unlock()
+
+	cacheKey := masterkey + ":" + base64.URLEncoding.EncodeToString(salt)
+	if key, ok := derivedKeys[cacheKey]; ok {
+		return key, nil
+	}
+
+	key := argon2.IDKey([]byte(masterkey), salt, kdfTime, kdfMemory, kdfThreads, chacha20poly1305.KeySize)
+	hash := sha256.Sum256(key)
+	derived := &derivedKey{
+		key: key,
+		id:  hex.EncodeToString(hash[:4]),
+	}
+	derivedKeys[cacheKey] = derived
+	return derived, nil
+}
+
+func sealingSalt(masterkey string) ([]byte, error) {
+	derivedKeysMux.Lock()
+	defer derivedKeysMux.Unlock()
+
+	if salt, ok := sealingSalts[masterkey]; ok {
+		return salt, nil
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	sealingSalts[masterkey] = salt
+	return salt, nil
+}
+
+func isEnvelope(value string) bool {
+	return strings.HasPrefix(value, envelopeVersion+":")
+}
+
+func seal(masterkey, plainText string) (string, error) {
+	salt, err := sealingSalt(masterkey)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := deriveKey(masterkey, salt)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := chacha20poly1305.NewX(key.key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plainText)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	header := envelopeVersion + ":" + base64.URLEncoding.EncodeToString(salt) + ":" + key.id
+	sealed := aead.Seal(nonce, nonce, []byte(plainText), []byte(header))
+	return header + ":" + base64.URLEncoding.EncodeToString(sealed), nil
+}
+
+func open(masterkey, value string) (string, error) {
+	parts := strings.SplitN(value, ":", 4)
+	if !isEnvelope(value) || len(parts) != 4 {
+		return "", errors.New("encrypted value has an unknown format")
+	}
+
+	salt, err := base64.URLEncoding.DecodeString(parts[1])
+	if err != nil || len(salt) != saltSize {
+		return "", errors.New("encrypted value has an invalid salt")
+	}
+	keyID, encoded := parts[2], parts[3]
+
+	key, err := deriveKey(masterkey, salt)
+	if err != nil {
+		return "", err
+	}
+
+	if keyID != key.id {
+		return "", errors.New("value is encrypted with another master key than the configured one")
+	}
+
+	sealed, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := chacha20poly1305.NewX(key.key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+
+	// The header up to the ciphertext is authenticated
+	header := strings.Join(parts[:3], ":")
+	plainText, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(header))
+	if err != nil {
+		return "", errors.New("decryption failed, the value is corrupted or encrypted with another master key")
+	}
+	return string(plainText), nil
+}
@@ -0,0 +1,125 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSecret_RoundTrip(t *testing.T) {
+	Masterkey = "a masterkey which is longer than thirty-two characters"
+	defer func() { Masterkey = "empty" }()
+
+	out, err := yaml.Marshal(&Secret{Value: "my secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := &Secret{}
+	if err := yaml.Unmarshal(out, in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Value != "my secret" || in.Encryption != envelopeEncryption {
+		t.Errorf("expected decrypted value and encryption %s, but got %+v", envelopeEncryption, in)
+	}
+
+	Masterkey = "another masterkey"
+	if err := yaml.Unmarshal(out, &Secret{}); err == nil {
+		t.Error("expected decrypting with another master key to fail")
+	}
+}
+
+func TestSecret_UnmarshalLegacy(t *testing.T) {
+	Masterkey = "legacy"
+	defer func() { Masterkey = "empty" }()
+
+	key := make([]byte, 32)
+	copy(key, Masterkey)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText := make([]byte, aes.BlockSize+len("my secret"))
+	cipher.NewCFBEncrypter(block, cipherText[:aes.BlockSize]).XORKeyStream(cipherText[aes.BlockSize:], []byte("my secret"))
+
+	in := &Secret{}
+	if err := yaml.Unmarshal([]byte("encryption: AES256\nencoding: Base64\nvalue: "+base64.URLEncoding.EncodeToString(cipherText)), in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Value != "my secret" {
+		t.Errorf("expected legacy value to be decrypted, but got %s", in.Value)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	Masterkey = "a masterkey"
+	defer func() { Masterkey = "empty" }()
+
+	data := []byte{0x1f, 0x8b, 0x00, 0xff}
+	sealed, err := Seal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, data) {
+		t.Error("expected sealed data not to contain the plain data")
+	}
+
+	opened, err := Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, data) {
+		t.Errorf("expected %v, but got %v", data, opened)
+	}
+
+	if _, err := Open(data); err == nil {
+		t.Error("expected opening unencrypted data to fail")
+	}
+}
+
+func TestSeal_salts(t *testing.T) {
+	const masterkey = "a masterkey"
+
+	first, err := seal(masterkey, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := seal(masterkey, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := func(value string) string { return strings.SplitN(value, ":", 4)[1] }
+	if salt(first) != salt(second) {
+		t.Error("expected values sealed by the same process to share the salt, so the key is derived only once")
+	}
+
+	// Another process seals with another salt
+	delete(sealingSalts, masterkey)
+	third, err := seal(masterkey, "third")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if salt(third) == salt(first) {
+		t.Error("expected another process to seal with a random salt")
+	}
+
+	for value, want := range map[string]string{first: "first", second: "second", third: "third"} {
+		got, err := open(masterkey, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected %s, but got %s", want, got)
+		}
+	}
+
+	tampered := strings.Replace(first, salt(first), salt(third), 1)
+	if _, err := open(masterkey, tampered); err == nil {
+		t.Error("expected opening a value with a replaced salt to fail")
+	}
+}
@@ -0,0 +1,336 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+)
+
+// Values encrypted for key providers look like v2:<base64url(age file)>.
+// The age header wraps the values file key for each recipient and for a Vault Transit key.
+const (
+	recipientsVersion    = "v2"
+	recipientsEncryption = "age"
+
+	// stanzaVault is the type of the age stanzas which wrap file keys with a Vault Transit key
+	stanzaVault = "orbos-vault"
+)
+
+// Keys replace the masterkey for encrypting secrets if recipients or a Vault Transit key are configured
+type Keys struct {
+	// Identity is the age identity like AGE-SECRET-KEY-1... used for decrypting secrets
+	Identity string `yaml:",omitempty"`
+	// Recipients are the age recipients like age1... secrets are encrypted for
+	Recipients []string `yaml:",omitempty"`
+	// Vault encrypts the secrets file keys using a Vault Transit compatible API
+	Vault *Vault `yaml:",omitempty"`
+}
+
+// Vault configures a Vault Transit compatible HTTP API
+type Vault struct {
+	Address string
+	// Mount is the path the transit secrets engine is mounted at
+	//@default: transit
+	Mount string `yaml:",omitempty"`
+	// Key is the transit key which wraps the secrets file keys. Omit it for only resolving vault:// references
+	Key string `yaml:",omitempty"`
+	// Token authenticates the requests. If it is empty, the environment variable VAULT_TOKEN is used
+	Token string `yaml:",omitempty"`
+}
+
+var KeyProviders = &Keys{}
+
+var (
+	fileKeysMux sync.Mutex
+	// fileKeys caches file keys unwrapped by Vault by their wrapped form, so Vault is not requested whenever a desired state is parsed
+	fileKeys = make(map[string][]byte)
+)
+
+// Configured is true if secrets are encrypted for recipients or a vault transit key instead of the masterkey
+func (k *Keys) Configured() bool {
+	return k != nil && (len(k.Recipients) > 0 || k.Vault != nil && k.Vault.Key != "")
+}
+
+func (k *Keys) Validate() error {
+	if k == nil {
+		return nil
+	}
+	if k.Identity != "" {
+		if _, err := parseIdentity(k.Identity); err != nil {
+			return err
+		}
+	}
+	for _, recipient := range k.Recipients {
+		if _, err := parseRecipient(recipient); err != nil {
+			return err
+		}
+	}
+	if k.Vault != nil && k.Vault.Address == "" {
+		return errors.New("vault address is required")
+	}
+	return nil
+}
+
+func (k *Keys) recipients() ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(k.Recipients)+1)
+	for _, recipient := range k.Recipients {
+		parsed, err := parseRecipient(recipient)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, parsed)
+	}
+	if k.Vault != nil && k.Vault.Key != "" {
+		recipients = append(recipients, &vaultRecipient{k.Vault})
+	}
+	return recipients, nil
+}
+
+// identities returns the local identity first, so Vault is only requested if it can't decrypt the value
+func (k *Keys) identities() ([]age.Identity, error) {
+	var identities []age.Identity
+	if k == nil {
+		return identities, nil
+	}
+	if k.Identity != "" {
+		identity, err := parseIdentity(k.Identity)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	if k.Vault != nil && k.Vault.Key != "" {
+		identities = append(identities, &vaultIdentity{k.Vault})
+	}
+	return identities, nil
+}
+
+func isRecipientsEnvelope(value string) bool {
+	return strings.HasPrefix(value, recipientsVersion+":")
+}
+
+func sealForRecipients(keys *Keys, plainText string) (string, error) {
+	recipients, err := keys.recipients()
+	if err != nil {
+		return "", err
+	}
+
+	sealed := new(bytes.Buffer)
+	w, err := age.Encrypt(sealed, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, plainText); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return recipientsVersion + ":" + base64.RawURLEncoding.EncodeToString(sealed.Bytes()), nil
+}
+
+func openForRecipients(keys *Keys, value string) (string, error) {
+	if !isRecipientsEnvelope(value) {
+		return "", errors.New("encrypted value has an unknown format")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, recipientsVersion+":"))
+	if err != nil {
+		return "", err
+	}
+
+	identities, err := keys.identities()
+	if err != nil {
+		return "", err
+	}
+	if len(identities) == 0 {
+		return "", errors.New("no identity or vault transit key is configured for decrypting the value")
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(sealed), identities...)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return "", errors.New("no configured key can decrypt the value, ask someone who can to add your recipient")
+	}
+	if err != nil {
+		return "", fmt.Errorf("decrypting value failed: %w", err)
+	}
+	plainText, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("decryption failed, the value is corrupted: %w", err)
+	}
+	return string(plainText), nil
+}
+
+func parseRecipient(recipient string) (*age.X25519Recipient, error) {
+	parsed, err := age.ParseX25519Recipient(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("parsing recipient %s failed: %w", recipient, err)
+	}
+	return parsed, nil
+}
+
+func parseIdentity(identity string) (*age.X25519Identity, error) {
+	parsed, err := age.ParseX25519Identity(strings.TrimSpace(identity))
+	if err != nil {
+		return nil, fmt.Errorf("parsing identity failed: %w", err)
+	}
+	return parsed, nil
+}
+
+// GenerateIdentity returns a new age identity
+func GenerateIdentity() (string, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", err
+	}
+	return identity.String(), nil
+}
+
+// IdentityRecipient returns the age recipient of an age identity
+func IdentityRecipient(identity string) (string, error) {
+	parsed, err := parseIdentity(identity)
+	if err != nil {
+		return "", err
+	}
+	return parsed.Recipient().String(), nil
+}
+
+// vaultRecipient wraps file keys with the Vault Transit key
+type vaultRecipient struct {
+	vault *Vault
+}
+
+func (v *vaultRecipient) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	wrapped, err := v.vault.wrap(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return []*age.Stanza{{
+		Type: stanzaVault,
+		Args: []string{v.vault.Key},
+		Body: []byte(wrapped),
+	}}, nil
+}
+
+// vaultIdentity unwraps file keys which were wrapped with the Vault Transit key
+type vaultIdentity struct {
+	vault *Vault
+}
+
+func (v *vaultIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != stanzaVault || len(s.Args) != 1 || s.Args[0] != v.vault.Key {
+			continue
+		}
+
+		fileKeysMux.Lock()
+		defer fileKeysMux.Unlock()
+
+		if fileKey, ok := fileKeys[string(s.Body)]; ok {
+			return fileKey, nil
+		}
+		fileKey, err := v.vault.unwrap(string(s.Body))
+		if err != nil {
+			return nil, err
+		}
+		fileKeys[string(s.Body)] = fileKey
+		return fileKey, nil
+	}
+	return nil, age.ErrIncorrectIdentity
+}
+
+var (
+	vaultClient      = &http.Client{Timeout: 30 * time.Second}
+	errVaultNotFound = errors.New("not found in vault")
+)
+
+func (v *Vault) wrap(fileKey []byte) (string, error) {
+	resp := struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}{}
+	if err := v.request("encrypt", map[string]string{"plaintext": base64.StdEncoding.EncodeToString(fileKey)}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Data.Ciphertext, nil
+}
+
+func (v *Vault) unwrap(wrapped string) ([]byte, error) {
+	resp := struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}{}
+	if err := v.request("decrypt", map[string]string{"ciphertext": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+func (v *Vault) request(operation string, body map[string]string, into interface{}) error {
+	mount := v.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+	if err := v.do(http.MethodPost, fmt.Sprintf("%s/%s/%s", strings.Trim(mount, "/"), operation, v.Key), body, into); err != nil {
+		return fmt.Errorf("vault transit %s with key %s failed: %w", operation, v.Key, err)
+	}
+	return nil
+}
+
+// do requests the Vault API at path relative to /v1 and decodes the response into into, if it has a body
+func (v *Vault) do(method, path string, body interface{}, into interface{}) error {
+	token := v.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(v.Address, "/"), strings.TrimPrefix(path, "/")), payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := vaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errVaultNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if into == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
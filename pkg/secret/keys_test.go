@@ -0,0 +1,141 @@
+package secret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+func TestIdentityRecipient(t *testing.T) {
+	recipient, err := IdentityRecipient("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "age1zvkyg2lqzraa2lnjvqej32nkuu0ues2s82hzrye869xeexvn73equnujwj"; recipient != expected {
+		t.Errorf("expected recipient %s, but got %s", expected, recipient)
+	}
+}
+
+func TestSecret_Recipients(t *testing.T) {
+	alice, aliceRecipient := testIdentity(t)
+	bob, bobRecipient := testIdentity(t)
+	eve, _ := testIdentity(t)
+	defer func() { KeyProviders = &Keys{} }()
+
+	KeyProviders = &Keys{Identity: alice, Recipients: []string{aliceRecipient, bobRecipient}}
+	out, err := yaml.Marshal(&Secret{Value: "my secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	KeyProviders = &Keys{Identity: bob}
+	in := &Secret{}
+	if err := yaml.Unmarshal(out, in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Value != "my secret" || in.Encryption != recipientsEncryption {
+		t.Errorf("expected bob to decrypt the value encrypted with %s, but got %+v", recipientsEncryption, in)
+	}
+
+	KeyProviders = &Keys{Identity: eve}
+	if err := yaml.Unmarshal(out, &Secret{}); err == nil {
+		t.Error("expected eve not to decrypt the value")
+	}
+}
+
+func TestSecret_RecipientsAreAgeFiles(t *testing.T) {
+	identity, recipient := testIdentity(t)
+	defer func() { KeyProviders = &Keys{} }()
+	KeyProviders = &Keys{Recipients: []string{recipient}}
+
+	value, err := sealForRecipients(KeyProviders, "my secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The age CLI decrypts the decoded value
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, recipientsVersion+":"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := age.ParseX25519Identity(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := age.Decrypt(strings.NewReader(string(sealed)), parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := new(strings.Builder)
+	if _, err := io.Copy(plain, r); err != nil {
+		t.Fatal(err)
+	}
+	if plain.String() != "my secret" {
+		t.Errorf("expected my secret, but got %s", plain.String())
+	}
+}
+
+func TestSecret_VaultTransit(t *testing.T) {
+	var decryptions int
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/orbos":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + body["plaintext"]}})
+		case "/v1/transit/decrypt/orbos":
+			decryptions++
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": strings.TrimPrefix(body["ciphertext"], "vault:v1:")}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	_, recipient := testIdentity(t)
+	defer func() { KeyProviders = &Keys{} }()
+	KeyProviders = &Keys{
+		Recipients: []string{recipient},
+		Vault:      &Vault{Address: vault.URL, Key: "orbos", Token: "token"},
+	}
+
+	out, err := yaml.Marshal(&Secret{Value: "my secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		in := &Secret{}
+		if err := yaml.Unmarshal(out, in); err != nil {
+			t.Fatal(err)
+		}
+		if in.Value != "my secret" {
+			t.Errorf("expected vault to decrypt the value, but got %s", in.Value)
+		}
+	}
+	if decryptions != 1 {
+		t.Errorf("expected the unwrapped file key to be cached, but vault decrypted %d times", decryptions)
+	}
+
+	KeyProviders = &Keys{Vault: &Vault{Address: vault.URL, Key: "other", Token: "token"}}
+	if err := yaml.Unmarshal(out, &Secret{}); err == nil {
+		t.Error("expected another vault transit key not to decrypt the value")
+	}
+}
+
+func testIdentity(t *testing.T) (string, string) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity.String(), identity.Recipient().String()
+}
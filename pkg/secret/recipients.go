@@ -0,0 +1,41 @@
+package secret
+
+import (
+	"fmt"
+)
+
+// EditRecipients adds and removes recipients from the recipients of an orbconfig, keeping their order
+func EditRecipients(recipients, add, remove []string) ([]string, error) {
+
+	removed := make(map[string]bool)
+	for _, recipient := range remove {
+		removed[recipient] = true
+	}
+
+	edited := make([]string, 0, len(recipients)+len(add))
+	existing := make(map[string]bool)
+	for _, recipient := range recipients {
+		if removed[recipient] {
+			delete(removed, recipient)
+			continue
+		}
+		existing[recipient] = true
+		edited = append(edited, recipient)
+	}
+
+	for recipient := range removed {
+		return nil, fmt.Errorf("recipient %s is not configured", recipient)
+	}
+
+	for _, recipient := range add {
+		if existing[recipient] {
+			continue
+		}
+		if _, err := parseRecipient(recipient); err != nil {
+			return nil, err
+		}
+		existing[recipient] = true
+		edited = append(edited, recipient)
+	}
+	return edited, nil
+}
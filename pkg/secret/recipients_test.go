@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditRecipients(t *testing.T) {
+	_, alice := testIdentity(t)
+	_, bob := testIdentity(t)
+	_, eve := testIdentity(t)
+
+	tests := []struct {
+		name        string
+		recipients  []string
+		add, remove []string
+		want        []string
+		wantErr     bool
+	}{{
+		name: "It should add recipients",
+		add:  []string{alice},
+		want: []string{alice},
+	}, {
+		name:       "It should keep the order of the other recipients when removing a recipient",
+		recipients: []string{alice, bob, eve},
+		remove:     []string{bob},
+		want:       []string{alice, eve},
+	}, {
+		name:       "It should not add existing recipients twice",
+		recipients: []string{alice},
+		add:        []string{alice, bob},
+		want:       []string{alice, bob},
+	}, {
+		name:       "It should fail removing a recipient which is not configured",
+		recipients: []string{alice},
+		remove:     []string{eve},
+		wantErr:    true,
+	}, {
+		name:    "It should fail adding an invalid recipient",
+		add:     []string{"AGE-SECRET-KEY-1..."},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EditRecipients(tt.recipients, tt.add, tt.remove)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EditRecipients() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("EditRecipients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// References point to values in external stores instead of containing their ciphertext.
+// They are resolved whenever a desired state is parsed.
+const (
+	refVault = "vault"
+	refFile  = "file"
+	refEnv   = "env"
+)
+
+type ref struct {
+	scheme string
+	// path is the vault API path, the absolute file path or the environment variable
+	path string
+	// key is the field of the vault secret
+	key string
+}
+
+func parseRef(raw string) (*ref, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing secret reference %s failed: %w", raw, err)
+	}
+
+	r := &ref{scheme: u.Scheme}
+	switch u.Scheme {
+	case refVault:
+		r.path = strings.Trim(u.Host+u.Path, "/")
+		r.key = u.Fragment
+		if r.path == "" || r.key == "" {
+			return nil, fmt.Errorf("vault secret reference %s must look like vault://<path>#<key>", raw)
+		}
+	case refFile:
+		r.path = u.Path
+		if u.Host != "" || !filepath.IsAbs(r.path) {
+			return nil, fmt.Errorf("file secret reference %s must look like file:///<absolute path>", raw)
+		}
+	case refEnv:
+		r.path = u.Host
+		if r.path == "" || u.Path != "" {
+			return nil, fmt.Errorf("environment secret reference %s must look like env://<variable>", raw)
+		}
+	default:
+		return nil, fmt.Errorf("secret reference %s has an unknown scheme, supported are vault://, file:// and env://", raw)
+	}
+	return r, nil
+}
+
+// LocalRefs allows resolving file:// and env:// references.
+// orbctl enables it, while the operators only resolve vault:// references,
+// so a desired state pushed to the repository can't make them read their local files and environment variables.
+var LocalRefs bool
+
+// unresolving is greater than zero while desired states are parsed without resolving references
+var unresolving int32
+
+// ParseUnresolved calls parse while references are kept as they are and secrets containing them stay empty.
+// Older desired states are parsed like this, as resolving their references would return the current values.
+func ParseUnresolved(parse func() error) error {
+	atomic.AddInt32(&unresolving, 1)
+	defer atomic.AddInt32(&unresolving, -1)
+	return parse()
+}
+
+func ValidateRef(raw string) error {
+	_, err := parseRef(raw)
+	return err
+}
+
+func resolveRef(raw string) (value string, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("resolving secret reference %s failed: %w", raw, err)
+		}
+	}()
+
+	r, err := parseRef(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if r.scheme != refVault && !LocalRefs {
+		return "", fmt.Errorf("%s references are only resolved by orbctl, the operators only resolve %s references", r.scheme, refVault)
+	}
+
+	switch r.scheme {
+	case refVault:
+		data, err := readVaultRef(r)
+		if err != nil {
+			return "", err
+		}
+		val, ok := data[r.key]
+		if !ok {
+			return "", fmt.Errorf("key %s not found", r.key)
+		}
+		str, ok := val.(string)
+		if !ok {
+			return "", fmt.Errorf("key %s is not a string", r.key)
+		}
+		value = str
+	case refFile:
+		content, err := ioutil.ReadFile(r.path)
+		if err != nil {
+			return "", err
+		}
+		value = string(content)
+	case refEnv:
+		value = os.Getenv(r.path)
+	}
+
+	if value == "" {
+		return "", errors.New("value is empty")
+	}
+	return value, nil
+}
+
+func writeRef(raw, value string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("writing secret reference %s failed: %w", raw, err)
+		}
+	}()
+
+	r, err := parseRef(raw)
+	if err != nil {
+		return err
+	}
+
+	if r.scheme != refVault {
+		return fmt.Errorf("%s references are read only", r.scheme)
+	}
+	return writeVaultRef(r, value)
+}
+
+// WriteChangedRefs persists the values the operators changed, like renewed certificates, in the referenced stores.
+// It must be called before the desired state containing the secrets is pushed, as only the references are marshalled.
+func WriteChangedRefs(desired ...interface{}) error {
+	for _, secret := range findRefSecrets(desired) {
+		if secret.Value == "" || secret.Value == secret.resolved {
+			continue
+		}
+		if err := writeRef(secret.Ref, secret.Value); err != nil {
+			return err
+		}
+		secret.resolved = secret.Value
+	}
+	return nil
+}
+
+func findRefSecrets(desired []interface{}) []*Secret {
+	var (
+		secrets []*Secret
+		visited = make(map[uintptr]bool)
+		walk    func(v reflect.Value)
+	)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() || visited[v.Pointer()] {
+				return
+			}
+			visited[v.Pointer()] = true
+			if secret, ok := v.Interface().(*Secret); ok {
+				if secret.Ref != "" {
+					secrets = append(secrets, secret)
+				}
+				return
+			}
+			walk(v.Elem())
+		case reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Struct:
+			if v.Type() == reflect.TypeOf(Secret{}) {
+				if v.CanAddr() {
+					walk(v.Addr())
+				}
+				return
+			}
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).PkgPath == "" {
+					walk(v.Field(i))
+				}
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				walk(iter.Value())
+			}
+		}
+	}
+	for _, d := range desired {
+		walk(reflect.ValueOf(d))
+	}
+	return secrets
+}
+
+type vaultSecret struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+func refVaultAPI() (*Vault, error) {
+	if KeyProviders == nil || KeyProviders.Vault == nil {
+		return nil, errors.New("vault is not configured in the orbconfig")
+	}
+	return KeyProviders.Vault, nil
+}
+
+// readVaultRef supports both versions of the key value secrets engine.
+// Version 2 nests the secrets data in a data field and requires paths like secret/data/orbos.
+func readVaultRef(r *ref) (map[string]interface{}, error) {
+	vault, err := refVaultAPI()
+	if err != nil {
+		return nil, err
+	}
+	secret := &vaultSecret{}
+	if err := vault.do(http.MethodGet, r.path, nil, secret); err != nil {
+		return nil, err
+	}
+	if isKVv2(r.path, secret) {
+		data, _ := secret.Data["data"].(map[string]interface{})
+		return data, nil
+	}
+	return secret.Data, nil
+}
+
+// writeVaultRef keeps the other keys of the vault secret
+func writeVaultRef(r *ref, value string) error {
+	vault, err := refVaultAPI()
+	if err != nil {
+		return err
+	}
+
+	secret := &vaultSecret{}
+	if err := vault.do(http.MethodGet, r.path, nil, secret); err != nil && !errors.Is(err, errVaultNotFound) {
+		return err
+	}
+
+	if isKVv2(r.path, secret) {
+		data, _ := secret.Data["data"].(map[string]interface{})
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+		data[r.key] = value
+		return vault.do(http.MethodPost, r.path, map[string]interface{}{"data": data}, nil)
+	}
+
+	data := secret.Data
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data[r.key] = value
+	return vault.do(http.MethodPost, r.path, data, nil)
+}
+
+// isKVv2 falls back to the path convention for secrets which don't exist yet
+func isKVv2(path string, secret *vaultSecret) bool {
+	if secret.Data == nil {
+		return strings.Contains("/"+path+"/", "/data/")
+	}
+	nested, ok := secret.Data["data"].(map[string]interface{})
+	_, hasMetadata := secret.Data["metadata"]
+	return ok && nested != nil && hasMetadata
+}
@@ -0,0 +1,204 @@
+package secret
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caos/orbos/mntr"
+	"github.com/caos/orbos/pkg/tree"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSecret_UnmarshalRef(t *testing.T) {
+	os.Setenv("ORBOS_TEST_SECRET", "my secret")
+	defer os.Unsetenv("ORBOS_TEST_SECRET")
+	LocalRefs = true
+	defer func() { LocalRefs = false }()
+
+	in := &Secret{}
+	if err := yaml.Unmarshal([]byte("ref: env://ORBOS_TEST_SECRET"), in); err != nil {
+		t.Fatal(err)
+	}
+	if in.Value != "my secret" {
+		t.Errorf("expected resolved value, but got %s", in.Value)
+	}
+
+	out, err := yaml.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "ref: env://ORBOS_TEST_SECRET\n" {
+		t.Errorf("expected only the reference to be marshalled, but got %s", string(out))
+	}
+}
+
+func TestParseUnresolved(t *testing.T) {
+	os.Setenv("ORBOS_TEST_SECRET", "my secret")
+	defer os.Unsetenv("ORBOS_TEST_SECRET")
+
+	in := &Secret{}
+	if err := ParseUnresolved(func() error {
+		return yaml.Unmarshal([]byte("ref: env://ORBOS_TEST_SECRET"), in)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if in.Value != "" || in.Ref != "env://ORBOS_TEST_SECRET" {
+		t.Errorf("expected only the reference, but got %+v", in)
+	}
+}
+
+func TestSecret_UnmarshalLocalRefInOperators(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := ioutil.WriteFile(path, []byte("host secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("ORBOS_TEST_SECRET", "my secret")
+	defer os.Unsetenv("ORBOS_TEST_SECRET")
+
+	for _, ref := range []string{"file://" + path, "env://ORBOS_TEST_SECRET"} {
+		in := &Secret{}
+		if err := yaml.Unmarshal([]byte("ref: "+ref), in); err == nil || in.Value != "" {
+			t.Errorf("expected the operators not to resolve %s, but got %+v", ref, in)
+		}
+	}
+}
+
+// testVaultKV serves a key value secrets engine of version 1
+func testVaultKV(t *testing.T) map[string]map[string]interface{} {
+	stored := make(map[string]map[string]interface{})
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1/")
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := stored[path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+		case http.MethodPost:
+			data := make(map[string]interface{})
+			if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+				t.Fatal(err)
+			}
+			stored[path] = data
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(vault.Close)
+
+	KeyProviders = &Keys{Vault: &Vault{Address: vault.URL, Token: "token"}}
+	t.Cleanup(func() { KeyProviders = &Keys{} })
+	return stored
+}
+
+func TestWriteChangedRefs(t *testing.T) {
+	stored := testVaultKV(t)
+	stored["secret/orbos"] = map[string]interface{}{"certificate": "expiring", "other": "kept"}
+
+	desired := &struct {
+		Spec struct {
+			Secrets map[string]*Secret
+		}
+	}{}
+	in := &Secret{}
+	if err := yaml.Unmarshal([]byte("ref: vault://secret/orbos#certificate"), in); err != nil {
+		t.Fatal(err)
+	}
+	desired.Spec.Secrets = map[string]*Secret{"certificate": in}
+
+	delete(stored, "secret/orbos")
+	if err := WriteChangedRefs(desired); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["secret/orbos"]; ok {
+		t.Error("expected an unchanged value not to be written to the referenced store")
+	}
+
+	stored["secret/orbos"] = map[string]interface{}{"certificate": "expiring", "other": "kept"}
+	in.Value = "renewed"
+	out, err := yaml.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "ref: vault://secret/orbos#certificate\n" {
+		t.Errorf("expected only the reference to be marshalled, but got %s", string(out))
+	}
+	if stored["secret/orbos"]["certificate"] != "expiring" {
+		t.Error("expected marshalling not to write to the referenced store")
+	}
+
+	if err := WriteChangedRefs(desired); err != nil {
+		t.Fatal(err)
+	}
+	if stored["secret/orbos"]["certificate"] != "renewed" || stored["secret/orbos"]["other"] != "kept" {
+		t.Errorf("expected the changed value to be written to the referenced store, but got %v", stored["secret/orbos"])
+	}
+
+	path := filepath.Join(t.TempDir(), "certificate")
+	for _, ref := range []string{"file://" + path, "env://ORBOS_TEST_SECRET"} {
+		readOnly := &Secret{Ref: ref, Value: "renewed"}
+		if err := WriteChangedRefs(readOnly); err == nil {
+			t.Errorf("expected writing a changed value of the read only reference %s to fail", ref)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected no file to be written")
+	}
+}
+
+func TestWriteRef(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certificate")
+	if err := ioutil.WriteFile(path, []byte("referenced"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	inline := &Secret{Value: "inline"}
+	var pushed string
+	if err := WriteRef(
+		mntr.Monitor{},
+		"orbiter.certificate.encrypted",
+		"file://"+path,
+		func() (map[string]*Secret, map[string]*Existing, map[string]*tree.Tree, error) {
+			return map[string]*Secret{"orbiter.certificate.encrypted": inline}, nil, nil, nil
+		},
+		func(_ map[string]*tree.Tree, _ string) error {
+			if err := WriteChangedRefs(inline); err != nil {
+				return err
+			}
+			out, err := yaml.Marshal(inline)
+			pushed = string(out)
+			return err
+		},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if pushed != "ref: file://"+path+"\n" {
+		t.Errorf("expected only the reference to be pushed, but got %s", pushed)
+	}
+	if content, err := ioutil.ReadFile(path); err != nil || string(content) != "referenced" {
+		t.Errorf("expected the inline value not to overwrite the referenced store, but got %s, %v", string(content), err)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	for ref, valid := range map[string]bool{
+		"vault://secret/data/orbos#jsonkey": true,
+		"vault://secret/data/orbos":         false,
+		"file:///etc/orbos/key":             true,
+		"file://relative/key":               false,
+		"env://GCE_KEY":                     true,
+		"https://example.com":               false,
+	} {
+		if _, err := parseRef(ref); (err == nil) != valid {
+			t.Errorf("expected ref %s to be valid %t, but got error %v", ref, valid, err)
+		}
+	}
+}
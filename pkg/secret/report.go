@@ -0,0 +1,156 @@
+package secret
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	macherrs "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/caos/orbos/pkg/helper"
+	"github.com/caos/orbos/pkg/kubernetes"
+	"github.com/caos/orbos/pkg/tree"
+)
+
+const (
+	KindInline    = "inline"
+	KindReference = "reference"
+	KindExisting  = "existing"
+)
+
+// ReportItem describes the state of a secret for audits
+type ReportItem struct {
+	Path      string `json:"path"`
+	Kind      string `json:"kind"`
+	Set       bool   `json:"set"`
+	Reference string `json:"reference,omitempty"`
+	// Kubernetes tells if the kubernetes secret an existing secret references is found and if it matches the inline value
+	Kubernetes string `json:"kubernetes,omitempty"`
+	// LastChanged is when the current value was committed. For references, it's when the reference was committed.
+	// If the inspected history is too short, it's the oldest inspected commit.
+	LastChanged *time.Time `json:"lastChanged,omitempty"`
+	Unused      bool       `json:"unused"`
+}
+
+// Revision holds the secrets of an operator at a commit.
+// Secrets containing references are not resolved, so only their Ref is set.
+type Revision struct {
+	When     time.Time
+	Secrets  map[string]*Secret
+	Existing map[string]*Existing
+}
+
+// HistoryFunc returns the revisions of all operators secrets by operator, newest first
+type HistoryFunc func() (map[string][]*Revision, error)
+
+func Report(
+	k8sClient kubernetes.ClientInt,
+	getFunc GetFuncs,
+	historyFunc HistoryFunc,
+	unused func(trees map[string]*tree.Tree, path string) bool,
+) ([]*ReportItem, error) {
+
+	allSecrets, allExisting, allTrees, err := getFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	var history map[string][]*Revision
+	if historyFunc != nil {
+		if history, err = historyFunc(); err != nil {
+			return nil, err
+		}
+	}
+
+	items := make([]*ReportItem, 0, len(allSecrets)+len(allExisting))
+	for path, sec := range allSecrets {
+		sec = InitIfNil(sec)
+		item := &ReportItem{
+			Path:      path,
+			Kind:      KindInline,
+			Set:       sec.Value != "",
+			Reference: sec.Ref,
+		}
+		if sec.Ref != "" {
+			item.Kind = KindReference
+		}
+		item.LastChanged = lastChanged(history, path, func(r *Revision) bool {
+			old, ok := r.Secrets[path]
+			if !ok || old == nil || old.Ref != sec.Ref {
+				return false
+			}
+			// Historical references are not resolved, as they would resolve to the current value
+			return sec.Ref != "" || old.Value == sec.Value
+		})
+		items = append(items, item)
+	}
+
+	for path, existing := range allExisting {
+		if existing == nil {
+			existing = &Existing{}
+		}
+		item := &ReportItem{
+			Path: path,
+			Kind: KindExisting,
+			Set:  existing.Name != "" && existing.Key != "",
+		}
+		if item.Set {
+			item.Kubernetes = checkKubernetesSecret(k8sClient, existing, allSecrets[strings.TrimSuffix(path, ".existing")+".encrypted"])
+		}
+		item.LastChanged = lastChanged(history, path, func(r *Revision) bool {
+			old, ok := r.Existing[path]
+			return ok && old != nil && old.Name == existing.Name && old.Key == existing.Key
+		})
+		items = append(items, item)
+	}
+
+	for _, item := range items {
+		item.Unused = item.Set && unused != nil && unused(allTrees, item.Path)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Path < items[j].Path
+	})
+	return items, nil
+}
+
+// lastChanged walks the revisions back as long as the value is unchanged
+func lastChanged(history map[string][]*Revision, path string, unchanged func(*Revision) bool) *time.Time {
+	revisions := history[strings.Split(path, ".")[0]]
+	var changed *time.Time
+	for i := range revisions {
+		if !unchanged(revisions[i]) {
+			break
+		}
+		changed = &revisions[i].When
+	}
+	return changed
+}
+
+func checkKubernetesSecret(k8sClient kubernetes.ClientInt, existing *Existing, inline *Secret) string {
+	if helper.IsNil(k8sClient) {
+		return "not checked"
+	}
+
+	k8sSecret, err := k8sClient.GetSecret(existingSecretsNamespace, existing.Name)
+	if macherrs.IsNotFound(err) {
+		return "secret not found"
+	}
+	if err != nil {
+		return fmt.Sprintf("reading secret failed: %s", err.Error())
+	}
+
+	value, ok := k8sSecret.Data[existing.Key]
+	if !ok || len(value) == 0 {
+		return "key not found"
+	}
+
+	if inline == nil || inline.Value == "" {
+		return "found"
+	}
+	if inline.Value == string(value) {
+		return "matches inline value"
+	}
+	return "differs from inline value"
+}
@@ -0,0 +1,73 @@
+package secret
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caos/orbos/pkg/tree"
+)
+
+func TestReport(t *testing.T) {
+	now := time.Now()
+	introduced := now.Add(-48 * time.Hour)
+
+	items, err := Report(
+		nil,
+		func() (map[string]*Secret, map[string]*Existing, map[string]*tree.Tree, error) {
+			return map[string]*Secret{
+				"boom.monitoring.admin.password.encrypted": {Value: "current"},
+				"boom.apigateway.licencekey.encrypted":     {},
+				"boom.reconciling.github.token.encrypted":  {Value: "resolved", Ref: "vault://orbos/github#token"},
+			}, map[string]*Existing{
+				"boom.monitoring.admin.password.existing": {Name: "grafana", Key: "password"},
+			}, nil, nil
+		},
+		func() (map[string][]*Revision, error) {
+			return map[string][]*Revision{
+				"boom": {{
+					When: now,
+					Secrets: map[string]*Secret{
+						"boom.monitoring.admin.password.encrypted": {Value: "current"},
+						"boom.reconciling.github.token.encrypted":  {Ref: "vault://orbos/github#token"},
+					},
+				}, {
+					When: introduced,
+					Secrets: map[string]*Secret{
+						"boom.monitoring.admin.password.encrypted": {Value: "current"},
+						"boom.reconciling.github.token.encrypted":  {Ref: "vault://orbos/github#token"},
+					},
+				}, {
+					When: now.Add(-72 * time.Hour),
+					Secrets: map[string]*Secret{
+						"boom.monitoring.admin.password.encrypted": {Value: "previous"},
+						"boom.reconciling.github.token.encrypted":  {Ref: "vault://orbos/old#token"},
+					},
+				}},
+			}, nil
+		},
+		func(_ map[string]*tree.Tree, path string) bool {
+			return path == "boom.monitoring.admin.password.existing"
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 4 {
+		t.Fatalf("expected 4 items, but got %d", len(items))
+	}
+
+	licence, inline, existing, reference := items[0], items[1], items[2], items[3]
+	if licence.Set || licence.LastChanged != nil || licence.Unused {
+		t.Errorf("expected unset licence key without history, but got %+v", licence)
+	}
+	if !inline.Set || inline.Kind != KindInline || inline.LastChanged == nil || !inline.LastChanged.Equal(introduced) {
+		t.Errorf("expected inline password introduced at %s, but got %+v", introduced, inline)
+	}
+	if !existing.Set || existing.Kind != KindExisting || existing.Kubernetes != "not checked" || !existing.Unused {
+		t.Errorf("expected unused existing password which is not checked, but got %+v", existing)
+	}
+	if !reference.Set || reference.Kind != KindReference || reference.LastChanged == nil || !reference.LastChanged.Equal(introduced) {
+		t.Errorf("expected reference introduced at %s, but got %+v", introduced, reference)
+	}
+}
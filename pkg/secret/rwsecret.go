@@ -125,6 +125,13 @@ func Write(
 
 	switch secretType := secret.(type) {
 	case *Secret:
+		if secretType.Ref != "" {
+			if err := writeRef(secretType.Ref, value); err != nil {
+				return err
+			}
+			monitor.WithField("ref", secretType.Ref).Info("Value written to referenced store")
+			return nil
+		}
 		if secretType.Value == value {
 			monitor.Info("Value is unchanged")
 			return nil
@@ -177,6 +184,44 @@ func Write(
 	return pushFunc(allTrees, path)
 }
 
+// WriteRef lets a secret reference its value in an external store instead of containing its ciphertext
+func WriteRef(
+	monitor mntr.Monitor,
+	path,
+	ref string,
+	getFunc GetFuncs,
+	pushFunc PushFuncs,
+) error {
+	if err := ValidateRef(ref); err != nil {
+		return mntr.ToUserError(err)
+	}
+
+	allSecrets, _, allTrees, err := getFunc()
+	if err != nil {
+		return err
+	}
+
+	secret, err := findSecret(allSecrets, make(map[string]*Existing), &path, true)
+	if err != nil {
+		return err
+	}
+
+	secretType, ok := secret.(*Secret)
+	if !ok {
+		return mntr.ToUserError(fmt.Errorf("secret %s can't reference an external store", path))
+	}
+
+	if secretType.Ref == ref {
+		monitor.Info("Reference is unchanged")
+		return nil
+	}
+	// The inline value is not written to the referenced store
+	secretType.Ref = ref
+	secretType.Value = ""
+	secretType.resolved = ""
+	return pushFunc(allTrees, path)
+}
+
 func GetOperatorSecrets(
 	monitor mntr.Monitor,
 	printLogs,
@@ -226,18 +271,23 @@ func GetOperatorSecrets(
 		secrets = nil
 	}
 
-	suffixedSecrets := make(map[string]*Secret, len(secrets))
-	suffixedExisting := make(map[string]*Existing, len(existing))
-	for k, v := range secrets {
+	AppendOperatorSecrets(operator, allSecrets, secrets, allExistingSecrets, existing)
+
+	return nil
+}
+
+// AppendOperatorSecrets adds the secrets of an operator with the paths orbctl uses
+func AppendOperatorSecrets(operator string, intoSecrets, addSecrets map[string]*Secret, intoExisting, addExisting map[string]*Existing) {
+	suffixedSecrets := make(map[string]*Secret, len(addSecrets))
+	suffixedExisting := make(map[string]*Existing, len(addExisting))
+	for k, v := range addSecrets {
 		suffixedSecrets[k+".encrypted"] = v
 	}
-	for k, v := range existing {
+	for k, v := range addExisting {
 		suffixedExisting[k+".existing"] = v
 	}
 
-	AppendSecrets(operator, allSecrets, suffixedSecrets, allExistingSecrets, suffixedExisting)
-
-	return nil
+	AppendSecrets(operator, intoSecrets, suffixedSecrets, intoExisting, suffixedExisting)
 }
 
 func secretsListToSlice(
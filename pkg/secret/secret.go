@@ -3,11 +3,10 @@ package secret
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"encoding/base64"
 	"errors"
-	"io"
 	"strings"
+	"sync/atomic"
 	"unicode/utf8"
 
 	"github.com/caos/orbos/internal/utils/clientgo"
@@ -24,6 +23,10 @@ type Secret struct {
 	Encoding string `json:"encoding,omitempty" yaml:"encoding,omitempty"`
 	//Encrypted and encoded Value
 	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	//Reference to the value in an external store like vault://<path>#<key>, file:///<path> or env://<variable>
+	Ref string `json:"ref,omitempty" yaml:"ref,omitempty"`
+	// resolved is the value Ref resolved to, so values changed afterwards are written back to the referenced store
+	resolved string
 }
 type secretAlias Secret
 
@@ -93,6 +96,15 @@ func unmarshal(s *Secret) (string, error) {
 		return "", nil
 	}
 
+	if isRecipientsEnvelope(s.Value) {
+		return openForRecipients(KeyProviders, s.Value)
+	}
+
+	if isEnvelope(s.Value) {
+		return open(Masterkey, s.Value)
+	}
+
+	// Legacy values are encrypted with AES-CFB using the zero-padded master key
 	cipherText, err := base64.URLEncoding.DecodeString(s.Value)
 	if err != nil {
 		return "", err
@@ -147,17 +159,34 @@ func (s *Secret) Unmarshal(masterkey string) error {
 
 func (s *Secret) UnmarshalYAML(node *yaml.Node) error {
 	alias := new(secretAlias)
-	err := node.Decode(alias)
+	if err := node.Decode(alias); err != nil {
+		return err
+	}
 
 	s.Encoding = alias.Encoding
 	s.Encryption = alias.Encryption
 	s.Value = alias.Value
+	s.Ref = alias.Ref
+
+	if alias.Ref != "" {
+		if atomic.LoadInt32(&unresolving) > 0 {
+			s.Value = ""
+			return nil
+		}
+		value, err := resolveRef(alias.Ref)
+		if err != nil {
+			return err
+		}
+		s.Value = value
+		s.resolved = value
+		return nil
+	}
 
 	if alias.Value == "" {
 		return nil
 	}
 
-	if len(Masterkey) < 1 || len(Masterkey) > 32 {
+	if !isRecipientsEnvelope(alias.Value) && (len(Masterkey) < 1 || !isEnvelope(alias.Value) && len(Masterkey) > 32) {
 		return nil
 		//return errors.New("Master key size must be between 1 and 32 characters")
 	}
@@ -176,34 +205,64 @@ func (s *Secret) UnmarshalYAML(node *yaml.Node) error {
 
 func (s *Secret) MarshalYAML() (interface{}, error) {
 
+	if s.Ref != "" {
+		// The desired state only contains the reference, WriteChangedRefs persists changed values in the referenced store
+		return &secretAlias{Ref: s.Ref}, nil
+	}
+
 	if s.Value == "" {
 		return nil, nil
 	}
 
-	if len(Masterkey) < 1 || len(Masterkey) > 32 {
-		return nil, errors.New("Master key size must be between 1 and 32 characters")
+	if KeyProviders.Configured() {
+		value, err := sealForRecipients(KeyProviders, s.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &secretAlias{Encryption: recipientsEncryption, Encoding: envelopeEncoding, Value: value}, nil
 	}
 
-	masterKey := make([]byte, 32)
-	for idx, char := range []byte(strings.Trim(Masterkey, "\n")) {
-		masterKey[idx] = char
+	if len(Masterkey) < 1 {
+		return nil, errors.New("Master key must not be empty")
 	}
 
-	c, err := aes.NewCipher(masterKey)
+	value, err := seal(Masterkey, s.Value)
 	if err != nil {
 		return nil, err
 	}
 
-	cipherText := make([]byte, aes.BlockSize+len(s.Value))
-	iv := cipherText[:aes.BlockSize]
-	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
-		return nil, err
+	return &secretAlias{Encryption: envelopeEncryption, Encoding: envelopeEncoding, Value: value}, nil
+}
+
+// Seal encrypts arbitrary data like secrets are encrypted when they are written
+func Seal(data []byte) ([]byte, error) {
+	plainText := base64.StdEncoding.EncodeToString(data)
+
+	if KeyProviders.Configured() {
+		value, err := sealForRecipients(KeyProviders, plainText)
+		return []byte(value), err
 	}
 
-	stream := cipher.NewCFBEncrypter(c, iv)
-	stream.XORKeyStream(cipherText[aes.BlockSize:], []byte(s.Value))
+	if len(Masterkey) < 1 {
+		return nil, errors.New("Master key must not be empty")
+	}
 
-	return &secretAlias{Encryption: "AES256", Encoding: "Base64", Value: base64.URLEncoding.EncodeToString(cipherText)}, nil
+	value, err := seal(Masterkey, plainText)
+	return []byte(value), err
+}
+
+// Open decrypts data encrypted by Seal
+func Open(sealed []byte) ([]byte, error) {
+	value := string(sealed)
+	if !isRecipientsEnvelope(value) && !isEnvelope(value) {
+		return nil, errors.New("data is not encrypted")
+	}
+
+	plainText, err := unmarshal(&Secret{Value: value})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(plainText)
 }
 
 func InitIfNil(sec *Secret) *Secret {